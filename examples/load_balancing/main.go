@@ -135,6 +135,7 @@ func demonstrateWeighted() {
 		MasterDataSource:    "master",
 		SlaveDataSources:    []string{"slave1", "slave2", "slave3"},
 		LoadBalanceAlgorithm: string(readwrite.Weight),
+		SlaveWeights:        map[string]int{"slave1": 5, "slave2": 3, "slave3": 2},
 	}
 
 	fmt.Printf("\n配置示例: %+v\n", rwConfig)
@@ -180,6 +181,9 @@ func demonstrateReadWriteSplit() {
 	fmt.Println("1. 轮询算法: 适用于从库性能相近的场景")
 	fmt.Println("2. 随机算法: 实现简单，适用于大多数场景")
 	fmt.Println("3. 加权算法: 适用于从库性能差异较大的场景")
+	fmt.Println("   最少连接(least_connections): 适用于查询耗时差异较大、从库负载不均的场景")
+	fmt.Println("   延迟感知(ewma_latency): 根据实际查询延迟自动避开较慢的从库")
+	fmt.Println("   自定义算法: 实现 readwrite.LoadBalancer 接口并通过 readwrite.RegisterLoadBalancer 注册")
 	fmt.Println("4. 配置健康检查以确保高可用性")
 	fmt.Println("5. 监控各从库的负载分布情况")
 }
\ No newline at end of file
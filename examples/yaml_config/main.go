@@ -37,15 +37,12 @@ func main() {
 		fmt.Printf("- %s\n", name)
 	}
 
-	// 创建分片数据源
-	dataSource, err := sharding.NewShardingDataSource(shardingConfig)
+	// 分片数据节点引用读写分离组，使用支持读写分离的增强分片数据库
+	db, err := sharding.NewEnhancedShardingDB(shardingConfig)
 	if err != nil {
-		log.Fatalf("Failed to create sharding data source: %v", err)
+		log.Fatalf("Failed to create sharding database: %v", err)
 	}
-	defer dataSource.Close()
-
-	// 获取数据库连接
-	db := dataSource.DB()
+	defer db.Close()
 
 	fmt.Println("\n=== 测试数据库连接 ===")
 
@@ -1,7 +1,10 @@
 package algorithm
 
 import (
+	"database/sql"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -327,6 +330,16 @@ func TestConvertToInt(t *testing.T) {
 		{"string with invalid char", "12a3", 0, true},
 		{"float", 123.45, 0, true},
 		{"nil", nil, 0, true},
+		{"integral float", 123.0, 123, false},
+		{"float out of range", 1e20, 0, true},
+		{"negative string", "-42", -42, false},
+		{"overflowing string", "9223372036854775808", 0, true},
+		{"uint64 overflow", uint64(math.MaxUint64), 0, true},
+		{"bytes", []byte("17"), 17, false},
+		{"null int64", sql.NullInt64{Int64: 9, Valid: true}, 9, false},
+		{"invalid null int64", sql.NullInt64{}, 0, true},
+		{"integral decimal", mustParseDecimal(t, "12.00"), 12, false},
+		{"fractional decimal", mustParseDecimal(t, "1.5"), 0, true},
 	}
 
 	for _, tt := range tests {
@@ -358,6 +371,11 @@ func TestConvertToString(t *testing.T) {
 		{"float", 123.45, "123.45"},
 		{"bool", true, "true"},
 		{"nil", nil, "<nil>"},
+		{"bytes", []byte("tenant_a"), "tenant_a"},
+		{"uuid", UUID{0xf4, 0x7a, 0xc1, 0x0b, 0x58, 0xcc, 0x43, 0x72, 0xa5, 0x67, 0x0e, 0x02, 0xb2, 0xc3, 0xd4, 0x79}, "f47ac10b-58cc-4372-a567-0e02b2c3d479"},
+		{"decimal", mustParseDecimal(t, "012.50"), "12.5"},
+		{"time", time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), "2024-03-01T00:00:00Z"},
+		{"null string", sql.NullString{String: "x", Valid: true}, "x"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,230 @@
+package algorithm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BoundaryRangeShardingAlgorithm 按有序边界划分的范围分片算法，VOLUME_RANGE 和 BOUNDARY_RANGE 共用该实现
+// 边界 b0 < b1 < ... < bk 将值域划分为 k+2 个分片：分片 0 为 (-∞, b0) 的下溢分片，
+// 分片 i 为 [b(i-1), b(i))，分片 k+1 为 [bk, +∞) 的上溢分片；分片序号即目标名称的数字后缀
+type BoundaryRangeShardingAlgorithm struct {
+	algorithmType string
+	boundaries    []int64
+	properties    map[string]interface{}
+}
+
+// NewVolumeRangeShardingAlgorithm 创建按容量划分的范围分片算法
+// 属性：range-lower 和 range-upper 为覆盖范围 [range-lower, range-upper)，sharding-volume 为每个分片的容量
+func NewVolumeRangeShardingAlgorithm(properties map[string]interface{}) (ShardingAlgorithm, error) {
+	lower, err := int64Property(properties, "range-lower")
+	if err != nil {
+		return nil, err
+	}
+	upper, err := int64Property(properties, "range-upper")
+	if err != nil {
+		return nil, err
+	}
+	volume, err := int64Property(properties, "sharding-volume")
+	if err != nil {
+		return nil, err
+	}
+	if lower >= upper {
+		return nil, fmt.Errorf("range-lower %d must be less than range-upper %d", lower, upper)
+	}
+	if volume <= 0 {
+		return nil, fmt.Errorf("sharding-volume must be positive")
+	}
+
+	var boundaries []int64
+	for boundary := lower; boundary < upper; boundary += volume {
+		boundaries = append(boundaries, boundary)
+		if boundary > upper-volume {
+			break
+		}
+	}
+	boundaries = append(boundaries, upper)
+
+	return &BoundaryRangeShardingAlgorithm{
+		algorithmType: "VOLUME_RANGE",
+		boundaries:    boundaries,
+		properties:    properties,
+	}, nil
+}
+
+// NewBoundaryRangeShardingAlgorithm 创建按边界列表划分的范围分片算法
+// 属性：sharding-ranges 为严格递增的边界列表，如 "1000,5000,20000"
+func NewBoundaryRangeShardingAlgorithm(properties map[string]interface{}) (ShardingAlgorithm, error) {
+	ranges, ok := properties["sharding-ranges"].(string)
+	if !ok || strings.TrimSpace(ranges) == "" {
+		return nil, fmt.Errorf("sharding-ranges is required for boundary range sharding algorithm")
+	}
+
+	var boundaries []int64
+	for _, part := range strings.Split(ranges, ",") {
+		boundary, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sharding-ranges boundary %q: %w", part, err)
+		}
+		// 边界必须严格递增，否则相邻分片重叠或为空
+		if n := len(boundaries); n > 0 && boundary <= boundaries[n-1] {
+			return nil, fmt.Errorf("sharding-ranges must be strictly increasing, got %d after %d", boundary, boundaries[n-1])
+		}
+		boundaries = append(boundaries, boundary)
+	}
+
+	return &BoundaryRangeShardingAlgorithm{
+		algorithmType: "BOUNDARY_RANGE",
+		boundaries:    boundaries,
+		properties:    properties,
+	}, nil
+}
+
+// DoSharding 执行分片计算，支持单值、IN 和范围查询
+func (a *BoundaryRangeShardingAlgorithm) DoSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if shardingValue.Range != nil {
+		return a.DoRangeSharding(availableTargetNames, shardingValue)
+	}
+
+	values := shardingValue.Values
+	if len(values) == 0 {
+		values = []interface{}{shardingValue.Value}
+	}
+
+	var results []string
+	for _, value := range values {
+		intValue, err := ConvertToInt(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert value to int: %w", err)
+		}
+		target, err := a.target(availableTargetNames, a.ShardIndex(intValue))
+		if err != nil {
+			return nil, err
+		}
+		if !contains(results, target) {
+			results = append(results, target)
+		}
+	}
+	return results, nil
+}
+
+// DoPreciseSharding 精确分片
+func (a *BoundaryRangeShardingAlgorithm) DoPreciseSharding(availableTargetNames []string, shardingValue *ShardingValue) (string, error) {
+	intValue, err := ConvertToInt(shardingValue.Value)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert value to int: %w", err)
+	}
+	return a.target(availableTargetNames, a.ShardIndex(intValue))
+}
+
+// DoRangeSharding 范围分片，未指定的起止值视为不限
+func (a *BoundaryRangeShardingAlgorithm) DoRangeSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if shardingValue.Range == nil {
+		return nil, fmt.Errorf("range value is required for range sharding")
+	}
+
+	first, last := 0, a.ShardCount()-1
+	if shardingValue.Range.Start != nil {
+		start, err := ConvertToInt(shardingValue.Range.Start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert range start to int: %w", err)
+		}
+		first = a.ShardIndex(start)
+	}
+	if shardingValue.Range.End != nil {
+		end, err := ConvertToInt(shardingValue.Range.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert range end to int: %w", err)
+		}
+		last = a.ShardIndex(end)
+	}
+
+	var results []string
+	for index := first; index <= last; index++ {
+		target, err := a.target(availableTargetNames, index)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, target)
+	}
+	return results, nil
+}
+
+// GetType 获取算法类型
+func (a *BoundaryRangeShardingAlgorithm) GetType() string {
+	return a.algorithmType
+}
+
+// GetProperties 获取算法属性
+func (a *BoundaryRangeShardingAlgorithm) GetProperties() map[string]interface{} {
+	return a.properties
+}
+
+// ShardCount 获取分片数量，包括下溢和上溢分片
+func (a *BoundaryRangeShardingAlgorithm) ShardCount() int {
+	return len(a.boundaries) + 1
+}
+
+// ShardIndex 获取值所在分片的序号，小于第一个边界时为 0，不小于最后一个边界时为 ShardCount()-1
+func (a *BoundaryRangeShardingAlgorithm) ShardIndex(value int64) int {
+	return sort.Search(len(a.boundaries), func(i int) bool { return a.boundaries[i] > value })
+}
+
+// ShardRange 获取分片的取值范围 [lower, upper)，下溢分片没有下界，上溢分片没有上界
+func (a *BoundaryRangeShardingAlgorithm) ShardRange(index int) (lower *int64, upper *int64) {
+	if index > 0 && index <= len(a.boundaries) {
+		lower = &a.boundaries[index-1]
+	}
+	if index >= 0 && index < len(a.boundaries) {
+		upper = &a.boundaries[index]
+	}
+	return lower, upper
+}
+
+// TargetNames 按分片序号生成目标名称，如前缀 t_order_ 生成 t_order_0 到 t_order_{ShardCount()-1}
+func (a *BoundaryRangeShardingAlgorithm) TargetNames(prefix string) []string {
+	names := make([]string, a.ShardCount())
+	for i := range names {
+		names[i] = prefix + strconv.Itoa(i)
+	}
+	return names
+}
+
+// ValidateTargets 检查每个分片都有对应的目标，启动时调用以便尽早发现配置遗漏
+func (a *BoundaryRangeShardingAlgorithm) ValidateTargets(availableTargetNames []string) error {
+	for index := 0; index < a.ShardCount(); index++ {
+		if _, err := a.target(availableTargetNames, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target 获取数字后缀等于分片序号的目标
+func (a *BoundaryRangeShardingAlgorithm) target(availableTargetNames []string, index int) (string, error) {
+	for _, name := range availableTargetNames {
+		digits := len(name)
+		for digits > 0 && name[digits-1] >= '0' && name[digits-1] <= '9' {
+			digits--
+		}
+		if suffix, err := strconv.Atoi(name[digits:]); err == nil && suffix == index {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no target found for shard %d of %s sharding algorithm", index, a.algorithmType)
+}
+
+// int64Property 读取必填的整数算法属性
+func int64Property(properties map[string]interface{}, key string) (int64, error) {
+	value, exists := properties[key]
+	if !exists {
+		return 0, fmt.Errorf("%s is required", key)
+	}
+	intValue, err := ConvertToInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return intValue, nil
+}
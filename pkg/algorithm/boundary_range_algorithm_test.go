@@ -0,0 +1,105 @@
+package algorithm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeRangeShardingAlgorithm(t *testing.T) {
+	shardingAlgorithm, err := NewAlgorithmFactory().CreateAlgorithm("VOLUME_RANGE", map[string]interface{}{
+		"range-lower":     0,
+		"range-upper":     "100",
+		"sharding-volume": 30,
+	})
+	require.NoError(t, err)
+	a := shardingAlgorithm.(*BoundaryRangeShardingAlgorithm)
+	assert.Equal(t, "VOLUME_RANGE", a.GetType())
+
+	// 下溢分片、[0,30)、[30,60)、[60,90)、[90,100)、上溢分片
+	assert.Equal(t, 6, a.ShardCount())
+	targets := a.TargetNames("t_order_")
+	assert.Equal(t, []string{"t_order_0", "t_order_1", "t_order_2", "t_order_3", "t_order_4", "t_order_5"}, targets)
+	require.NoError(t, a.ValidateTargets(targets))
+	assert.Error(t, a.ValidateTargets(targets[:5]))
+
+	lower, upper := a.ShardRange(4)
+	assert.Equal(t, int64(90), *lower)
+	assert.Equal(t, int64(100), *upper)
+	lower, upper = a.ShardRange(0)
+	assert.Nil(t, lower)
+	assert.Equal(t, int64(0), *upper)
+
+	tests := []struct {
+		name     string
+		value    *ShardingValue
+		expected []string
+	}{
+		{"below lower", &ShardingValue{Value: -5}, []string{"t_order_0"}},
+		{"lower bound", &ShardingValue{Value: 0}, []string{"t_order_1"}},
+		{"volume boundary", &ShardingValue{Value: "30"}, []string{"t_order_2"}},
+		{"last partial shard", &ShardingValue{Value: int64(99)}, []string{"t_order_4"}},
+		{"upper bound", &ShardingValue{Value: 100}, []string{"t_order_5"}},
+		{"in values", &ShardingValue{Values: []interface{}{1, 29, 1000}}, []string{"t_order_1", "t_order_5"}},
+		{"between", &ShardingValue{Range: &Range{Start: 25, End: 65}}, []string{"t_order_1", "t_order_2", "t_order_3"}},
+		{"open range", &ShardingValue{Range: &Range{Start: 95}}, []string{"t_order_4", "t_order_5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := a.DoSharding(targets, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, results)
+		})
+	}
+}
+
+func TestBoundaryRangeShardingAlgorithm(t *testing.T) {
+	shardingAlgorithm, err := NewBoundaryRangeShardingAlgorithm(map[string]interface{}{
+		"sharding-ranges": "1000, 5000, 20000",
+	})
+	require.NoError(t, err)
+	a := shardingAlgorithm.(*BoundaryRangeShardingAlgorithm)
+	assert.Equal(t, "BOUNDARY_RANGE", a.GetType())
+	assert.Equal(t, 4, a.ShardCount())
+
+	targets := []string{"ds_3", "ds_0", "ds_2", "ds_1"}
+	for value, expected := range map[int]string{999: "ds_0", 1000: "ds_1", 4999: "ds_1", 5000: "ds_2", 20000: "ds_3", 1 << 40: "ds_3"} {
+		target, err := a.DoPreciseSharding(targets, &ShardingValue{Value: value})
+		require.NoError(t, err)
+		assert.Equal(t, expected, target, value)
+	}
+
+	results, err := a.DoSharding(targets, &ShardingValue{Range: &Range{End: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0", "ds_1"}, results)
+
+	_, err = a.DoSharding([]string{"ds_0", "ds_1"}, &ShardingValue{Value: 6000})
+	assert.Error(t, err)
+}
+
+func TestRangeAlgorithms_InvalidProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  string
+		properties map[string]interface{}
+		errorMsg   string
+	}{
+		{"missing volume", "VOLUME_RANGE", map[string]interface{}{"range-lower": 0, "range-upper": 10}, "sharding-volume is required"},
+		{"empty range", "VOLUME_RANGE", map[string]interface{}{"range-lower": 10, "range-upper": 10, "sharding-volume": 1}, "must be less than range-upper"},
+		{"zero volume", "VOLUME_RANGE", map[string]interface{}{"range-lower": 0, "range-upper": 10, "sharding-volume": 0}, "sharding-volume must be positive"},
+		{"missing ranges", "BOUNDARY_RANGE", map[string]interface{}{}, "sharding-ranges is required"},
+		{"invalid boundary", "BOUNDARY_RANGE", map[string]interface{}{"sharding-ranges": "10,abc"}, "invalid sharding-ranges boundary"},
+		{"overlapping boundaries", "BOUNDARY_RANGE", map[string]interface{}{"sharding-ranges": "10,30,20"}, "strictly increasing"},
+		{"duplicate boundaries", "BOUNDARY_RANGE", map[string]interface{}{"sharding-ranges": "10,10"}, "strictly increasing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAlgorithmFactory().CreateAlgorithm(tt.algorithm, tt.properties)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package algorithm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultVirtualNodes 每个目标默认的虚拟节点数
+const defaultVirtualNodes = 160
+
+// ConsistentHashShardingAlgorithm 一致性哈希分片算法
+// 每个目标按 "目标名#序号" 在哈希环上放置 virtual-nodes 个虚拟节点，分片值路由到顺时针方向的第一个虚拟节点，
+// 哈希环只由目标名称决定，与目标的顺序无关；增减目标时只有相邻区间的分片值需要迁移
+type ConsistentHashShardingAlgorithm struct {
+	virtualNodes int
+	hash         HashFunction
+	rings        map[string]*HashRing
+	mu           sync.Mutex
+	properties   map[string]interface{}
+}
+
+// NewConsistentHashShardingAlgorithm 创建一致性哈希分片算法
+// 属性：virtual-nodes 每个目标的虚拟节点数（默认 160），hash-function 哈希函数（MURMUR3、XXHASH、CRC32，默认 MURMUR3）
+func NewConsistentHashShardingAlgorithm(properties map[string]interface{}) (ShardingAlgorithm, error) {
+	virtualNodes, err := intProperty(properties, "virtual-nodes", defaultVirtualNodes)
+	if err != nil {
+		return nil, err
+	}
+	if virtualNodes <= 0 {
+		return nil, fmt.Errorf("virtual-nodes must be positive")
+	}
+
+	hashName, _ := properties["hash-function"].(string)
+	if hashName == "" {
+		hashName = HashMurmur3
+	}
+	hash, err := GetHashFunction(hashName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsistentHashShardingAlgorithm{
+		virtualNodes: virtualNodes,
+		hash:         hash,
+		rings:        make(map[string]*HashRing),
+		properties:   properties,
+	}, nil
+}
+
+// DoSharding 执行分片计算，支持单值和 IN 查询，范围查询路由到所有目标
+func (a *ConsistentHashShardingAlgorithm) DoSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if len(availableTargetNames) == 0 {
+		return nil, fmt.Errorf("no available targets for consistent hash sharding")
+	}
+	if shardingValue.Range != nil {
+		return availableTargetNames, nil
+	}
+
+	ring := a.Ring(availableTargetNames)
+	if len(shardingValue.Values) > 0 {
+		var results []string
+		for _, value := range shardingValue.Values {
+			if target := ring.Locate(a.HashKey(value)); !contains(results, target) {
+				results = append(results, target)
+			}
+		}
+		return results, nil
+	}
+
+	return []string{ring.Locate(a.HashKey(shardingValue.Value))}, nil
+}
+
+// DoPreciseSharding 精确分片
+func (a *ConsistentHashShardingAlgorithm) DoPreciseSharding(availableTargetNames []string, shardingValue *ShardingValue) (string, error) {
+	if len(availableTargetNames) == 0 {
+		return "", fmt.Errorf("no available targets for consistent hash sharding")
+	}
+	return a.Ring(availableTargetNames).Locate(a.HashKey(shardingValue.Value)), nil
+}
+
+// GetType 获取算法类型
+func (a *ConsistentHashShardingAlgorithm) GetType() string {
+	return "CONSISTENT_HASH"
+}
+
+// GetProperties 获取算法属性
+func (a *ConsistentHashShardingAlgorithm) GetProperties() map[string]interface{} {
+	return a.properties
+}
+
+// HashKey 计算分片值在哈希环上的位置，哈希 HashBytes 得到的规范字节，整数与其十进制字符串的位置相同
+func (a *ConsistentHashShardingAlgorithm) HashKey(value interface{}) uint32 {
+	return a.hash(HashBytes(value))
+}
+
+// Ring 获取由目标名称构建的哈希环，相同的目标集合复用同一个哈希环
+func (a *ConsistentHashShardingAlgorithm) Ring(targets []string) *HashRing {
+	sorted := append([]string(nil), targets...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, "\x00")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ring, exists := a.rings[key]
+	if !exists {
+		ring = NewHashRing(sorted, a.virtualNodes, a.hash)
+		a.rings[key] = ring
+	}
+	return ring
+}
+
+// PlanRebalance 计算目标从 oldTargets 变为 newTargets 时归属发生变化的哈希区间，
+// 迁移这些区间内的分片值即可完成扩缩容
+func (a *ConsistentHashShardingAlgorithm) PlanRebalance(oldTargets, newTargets []string) []KeyRangeMove {
+	return a.Ring(oldTargets).Diff(a.Ring(newTargets))
+}
+
+// ringPoint 哈希环上的虚拟节点
+type ringPoint struct {
+	hash   uint32
+	target string
+}
+
+// HashRing 一致性哈希环
+type HashRing struct {
+	points []ringPoint
+}
+
+// NewHashRing 创建哈希环，每个目标放置 virtualNodes 个虚拟节点，哈希冲突时按目标名称排序
+func NewHashRing(targets []string, virtualNodes int, hash HashFunction) *HashRing {
+	ring := &HashRing{points: make([]ringPoint, 0, len(targets)*virtualNodes)}
+	for _, target := range targets {
+		for i := 0; i < virtualNodes; i++ {
+			ring.points = append(ring.points, ringPoint{
+				hash:   hash([]byte(target + "#" + strconv.Itoa(i))),
+				target: target,
+			})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash != ring.points[j].hash {
+			return ring.points[i].hash < ring.points[j].hash
+		}
+		return ring.points[i].target < ring.points[j].target
+	})
+	return ring
+}
+
+// Locate 获取哈希位置顺时针方向第一个虚拟节点所属的目标，环为空时返回空字符串
+func (r *HashRing) Locate(hash uint32) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].target
+}
+
+// Diff 计算从当前哈希环切换到 other 时归属发生变化的哈希区间，相邻且迁移方向相同的区间会合并
+func (r *HashRing) Diff(other *HashRing) []KeyRangeMove {
+	boundaries := make([]uint32, 0, len(r.points)+len(other.points))
+	for _, point := range r.points {
+		boundaries = append(boundaries, point.hash)
+	}
+	for _, point := range other.points {
+		boundaries = append(boundaries, point.hash)
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i] < boundaries[j] })
+	if len(boundaries) == 0 {
+		return nil
+	}
+	unique := boundaries[:1]
+	for _, boundary := range boundaries[1:] {
+		if boundary != unique[len(unique)-1] {
+			unique = append(unique, boundary)
+		}
+	}
+	boundaries = unique
+
+	// 每个区间 (上一个边界, 当前边界] 内的哈希位置都归属当前边界所在的虚拟节点
+	var moves []KeyRangeMove
+	previous := boundaries[len(boundaries)-1]
+	for _, boundary := range boundaries {
+		from, to := r.Locate(boundary), other.Locate(boundary)
+		if from != to {
+			if last := len(moves) - 1; last >= 0 && moves[last].End == previous && moves[last].From == from && moves[last].To == to {
+				moves[last].End = boundary
+			} else {
+				moves = append(moves, KeyRangeMove{Start: previous, End: boundary, From: from, To: to})
+			}
+		}
+		previous = boundary
+	}
+
+	// 跨越环起点的区间与第一个区间合并
+	if n := len(moves); n > 1 && moves[n-1].End == moves[0].Start && moves[n-1].From == moves[0].From && moves[n-1].To == moves[0].To {
+		moves[0].Start = moves[n-1].Start
+		moves = moves[:n-1]
+	}
+	return moves
+}
+
+// KeyRangeMove 扩缩容时归属发生变化的哈希区间 (Start, End]，Start 大于等于 End 时区间跨越环的起点
+type KeyRangeMove struct {
+	Start uint32
+	End   uint32
+	From  string
+	To    string
+}
+
+// Contains 判断哈希位置是否在区间内
+func (m KeyRangeMove) Contains(hash uint32) bool {
+	if m.Start < m.End {
+		return hash > m.Start && hash <= m.End
+	}
+	return hash > m.Start || hash <= m.End
+}
+
+// Size 区间包含的哈希位置数量
+func (m KeyRangeMove) Size() uint64 {
+	return uint64(m.End-m.Start-1) + 1
+}
@@ -0,0 +1,114 @@
+package algorithm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashFunctions(t *testing.T) {
+	assert.Equal(t, uint32(0x248bfa47), Murmur3Hash32([]byte("hello"), 0))
+	assert.Equal(t, uint32(0xfaf6cdb3), Murmur3Hash32([]byte("Hello, world!"), 1234))
+	assert.Equal(t, uint64(0xef46db3751d8e999), XXHash64(nil, 0))
+	assert.Equal(t, uint64(0x44bc2cf5ad770999), XXHash64([]byte("abc"), 0))
+	assert.Equal(t, uint64(0xfbcea83c8a378bf1), XXHash64([]byte("Nobody inspects the spammish repetition"), 0))
+
+	assert.Equal(t, []string{"CRC32", "MURMUR3", "XXHASH"}, GetAvailableHashFunctions())
+	fn, err := GetHashFunction("crc32")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x3610a686), fn([]byte("hello")))
+	_, err = GetHashFunction("md5")
+	assert.Error(t, err)
+}
+
+func TestConsistentHashShardingAlgorithm_DoSharding(t *testing.T) {
+	targets := []string{"ds_0", "ds_1", "ds_2"}
+	for _, hashFunction := range []string{"murmur3", "xxhash", "crc32"} {
+		t.Run(hashFunction, func(t *testing.T) {
+			shardingAlgorithm, err := NewAlgorithmFactory().CreateAlgorithm("CONSISTENT_HASH", map[string]interface{}{
+				"virtual-nodes": "64",
+				"hash-function": hashFunction,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "CONSISTENT_HASH", shardingAlgorithm.GetType())
+
+			counts := make(map[string]int)
+			for i := 0; i < 3000; i++ {
+				result, err := shardingAlgorithm.DoSharding(targets, &ShardingValue{Value: i})
+				require.NoError(t, err)
+				require.Len(t, result, 1)
+				counts[result[0]]++
+
+				// 哈希环只由目标名称决定
+				reordered, err := shardingAlgorithm.DoSharding([]string{"ds_2", "ds_0", "ds_1"}, &ShardingValue{Value: fmt.Sprint(i)})
+				require.NoError(t, err)
+				assert.Equal(t, result, reordered)
+			}
+			for _, target := range targets {
+				assert.InDelta(t, 1000, counts[target], 350, target)
+			}
+		})
+	}
+
+	shardingAlgorithm, err := NewConsistentHashShardingAlgorithm(map[string]interface{}{})
+	require.NoError(t, err)
+	results, err := shardingAlgorithm.DoSharding(targets, &ShardingValue{Values: []interface{}{1, 1, "1"}})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	results, err = shardingAlgorithm.DoSharding(targets, &ShardingValue{Range: &Range{Start: 1, End: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, targets, results)
+	_, err = shardingAlgorithm.DoSharding(nil, &ShardingValue{Value: 1})
+	assert.Error(t, err)
+
+	_, err = NewConsistentHashShardingAlgorithm(map[string]interface{}{"virtual-nodes": 0})
+	assert.Error(t, err)
+	_, err = NewConsistentHashShardingAlgorithm(map[string]interface{}{"hash-function": "md5"})
+	assert.Error(t, err)
+}
+
+func TestConsistentHashShardingAlgorithm_PlanRebalance(t *testing.T) {
+	shardingAlgorithm, err := NewConsistentHashShardingAlgorithm(map[string]interface{}{"virtual-nodes": 100})
+	require.NoError(t, err)
+	a := shardingAlgorithm.(*ConsistentHashShardingAlgorithm)
+
+	oldTargets := []string{"ds_0", "ds_1", "ds_2"}
+	newTargets := []string{"ds_0", "ds_1", "ds_2", "ds_3"}
+	moves := a.PlanRebalance(oldTargets, newTargets)
+	require.NotEmpty(t, moves)
+
+	var moved uint64
+	for _, move := range moves {
+		assert.Equal(t, "ds_3", move.To)
+		assert.NotEqual(t, "ds_3", move.From)
+		moved += move.Size()
+	}
+	// 新增一个目标时大约四分之一的哈希空间需要迁移
+	assert.InDelta(t, 0.25, float64(moved)/float64(uint64(1)<<32), 0.1)
+
+	// 区间准确描述了每个分片值的迁移
+	for i := 0; i < 2000; i++ {
+		hash := a.HashKey(i)
+		from, to := a.Ring(oldTargets).Locate(hash), a.Ring(newTargets).Locate(hash)
+
+		var matched []KeyRangeMove
+		for _, move := range moves {
+			if move.Contains(hash) {
+				matched = append(matched, move)
+			}
+		}
+		if from == to {
+			assert.Empty(t, matched, i)
+		} else if assert.Len(t, matched, 1, i) {
+			assert.Equal(t, KeyRangeMove{Start: matched[0].Start, End: matched[0].End, From: from, To: to}, matched[0])
+		}
+	}
+
+	// 缩容时迁移方向相反
+	for _, move := range a.PlanRebalance(newTargets, oldTargets) {
+		assert.Equal(t, "ds_3", move.From)
+	}
+	assert.Empty(t, a.PlanRebalance(oldTargets, []string{"ds_2", "ds_1", "ds_0"}))
+}
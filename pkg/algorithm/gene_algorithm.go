@@ -0,0 +1,122 @@
+package algorithm
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// maxGeneBits 基因位的最大宽度，与 id.MaxGeneBits 一致
+const maxGeneBits = 10
+
+// GeneShardingAlgorithm 基因取模分片算法
+// 取分片值低 gene-bits 位作为基因，再按 sharding-count 取模选择目标；
+// 基因雪花算法生成的 ID 低位嵌入了分片列的基因，因此按 ID 或分片列路由得到同一个目标
+type GeneShardingAlgorithm struct {
+	shardingCount int
+	geneBits      int
+	properties    map[string]interface{}
+}
+
+// NewGeneShardingAlgorithm 创建基因取模分片算法
+// 属性：sharding-count 分片数量，gene-bits 基因位宽度（默认为容纳分片数量所需的最少位数）
+func NewGeneShardingAlgorithm(properties map[string]interface{}) (ShardingAlgorithm, error) {
+	shardingCount, err := intProperty(properties, "sharding-count", 0)
+	if err != nil {
+		return nil, err
+	}
+	if shardingCount <= 0 {
+		return nil, fmt.Errorf("sharding-count must be positive")
+	}
+
+	geneBits, err := intProperty(properties, "gene-bits", max(bits.Len(uint(shardingCount-1)), 1))
+	if err != nil {
+		return nil, err
+	}
+	if geneBits < 1 || geneBits > maxGeneBits {
+		return nil, fmt.Errorf("gene-bits must be between 1 and %d", maxGeneBits)
+	}
+	if shardingCount > 1<<geneBits {
+		return nil, fmt.Errorf("%d gene bits cannot address %d shards", geneBits, shardingCount)
+	}
+
+	return &GeneShardingAlgorithm{
+		shardingCount: shardingCount,
+		geneBits:      geneBits,
+		properties:    properties,
+	}, nil
+}
+
+// DoSharding 执行分片计算，支持单值和 IN 查询，范围查询路由到所有目标
+func (a *GeneShardingAlgorithm) DoSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if shardingValue.Range != nil {
+		return availableTargetNames, nil
+	}
+
+	if len(shardingValue.Values) > 0 {
+		var results []string
+		for _, value := range shardingValue.Values {
+			target, err := a.calculateTarget(availableTargetNames, value)
+			if err != nil {
+				return nil, err
+			}
+			if !contains(results, target) {
+				results = append(results, target)
+			}
+		}
+		return results, nil
+	}
+
+	target, err := a.calculateTarget(availableTargetNames, shardingValue.Value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{target}, nil
+}
+
+// DoPreciseSharding 精确分片
+func (a *GeneShardingAlgorithm) DoPreciseSharding(availableTargetNames []string, shardingValue *ShardingValue) (string, error) {
+	return a.calculateTarget(availableTargetNames, shardingValue.Value)
+}
+
+// GetType 获取算法类型
+func (a *GeneShardingAlgorithm) GetType() string {
+	return "GENE_MOD"
+}
+
+// GetProperties 获取算法属性
+func (a *GeneShardingAlgorithm) GetProperties() map[string]interface{} {
+	return a.properties
+}
+
+// GeneBits 获取基因位宽度
+func (a *GeneShardingAlgorithm) GeneBits() int {
+	return a.geneBits
+}
+
+// ShardIndex 获取值所在分片的序号
+func (a *GeneShardingAlgorithm) ShardIndex(value int64) int {
+	gene := value & (int64(1)<<a.geneBits - 1)
+	return int(gene % int64(a.shardingCount))
+}
+
+// ValidateTargets 检查可用目标数量与分片数量一致
+func (a *GeneShardingAlgorithm) ValidateTargets(availableTargetNames []string) error {
+	if len(availableTargetNames) != a.shardingCount {
+		return fmt.Errorf("gene sharding algorithm expects %d targets, got %d", a.shardingCount, len(availableTargetNames))
+	}
+	return nil
+}
+
+// calculateTarget 计算单个分片值的目标
+func (a *GeneShardingAlgorithm) calculateTarget(availableTargetNames []string, value interface{}) (string, error) {
+	intValue, err := ConvertToInt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert value to int: %w", err)
+	}
+
+	index := a.ShardIndex(intValue)
+	if index >= len(availableTargetNames) {
+		return "", fmt.Errorf("no target found for shard %d of gene sharding algorithm", index)
+	}
+	return availableTargetNames[index], nil
+}
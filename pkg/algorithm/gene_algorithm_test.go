@@ -0,0 +1,65 @@
+package algorithm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGeneShardingAlgorithm(t *testing.T) {
+	tests := []struct {
+		name     string
+		props    map[string]interface{}
+		geneBits int
+		hasError bool
+	}{
+		{"default gene bits", map[string]interface{}{"sharding-count": 16}, 4, false},
+		{"default gene bits for non power of two", map[string]interface{}{"sharding-count": "10"}, 4, false},
+		{"single shard", map[string]interface{}{"sharding-count": 1}, 1, false},
+		{"explicit gene bits", map[string]interface{}{"sharding-count": 4, "gene-bits": 6}, 6, false},
+		{"gene bits too narrow", map[string]interface{}{"sharding-count": 16, "gene-bits": 3}, 0, true},
+		{"gene bits too wide", map[string]interface{}{"sharding-count": 16, "gene-bits": 11}, 0, true},
+		{"missing sharding count", map[string]interface{}{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alg, err := NewGeneShardingAlgorithm(tt.props)
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.geneBits, alg.(*GeneShardingAlgorithm).GeneBits())
+		})
+	}
+}
+
+func TestGeneShardingAlgorithm_DoSharding(t *testing.T) {
+	alg, err := NewGeneShardingAlgorithm(map[string]interface{}{"sharding-count": 4, "gene-bits": 4})
+	require.NoError(t, err)
+	targets := []string{"ds_0", "ds_1", "ds_2", "ds_3"}
+
+	// 低 4 位与 user_id 相同的订单 ID 路由到同一个目标
+	for _, userID := range []int64{0, 7, 13, 1022, -3} {
+		orderID := int64(123456789)<<4 | userID&15
+		byUser, err := alg.DoSharding(targets, &ShardingValue{Value: userID})
+		require.NoError(t, err)
+		byOrder, err := alg.DoSharding(targets, &ShardingValue{Value: orderID})
+		require.NoError(t, err)
+		assert.Equal(t, byUser, byOrder, "user_id %d", userID)
+	}
+
+	result, err := alg.DoSharding(targets, &ShardingValue{Values: []interface{}{1, 5, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_1", "ds_2"}, result)
+
+	result, err = alg.DoSharding(targets, &ShardingValue{Range: &Range{Start: 1, End: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, targets, result)
+
+	validator := alg.(TargetValidator)
+	assert.NoError(t, validator.ValidateTargets(targets))
+	assert.Error(t, validator.ValidateTargets(targets[:3]))
+}
@@ -0,0 +1,190 @@
+package algorithm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HashFunction 分片算法使用的 32 位哈希函数，相同输入必须始终得到相同结果
+type HashFunction func(data []byte) uint32
+
+// 内置哈希函数名称
+const (
+	HashMurmur3 = "MURMUR3"
+	HashXXHash  = "XXHASH"
+	HashCRC32   = "CRC32"
+)
+
+var (
+	hashFunctions = map[string]HashFunction{
+		HashMurmur3: func(data []byte) uint32 { return Murmur3Hash32(data, 0) },
+		HashXXHash:  func(data []byte) uint32 { return foldHash64(XXHash64(data, 0)) },
+		HashCRC32:   crc32.ChecksumIEEE,
+	}
+	hashFunctionsMu sync.RWMutex
+)
+
+// RegisterHashFunction 注册哈希函数，名称不区分大小写
+func RegisterHashFunction(name string, fn HashFunction) {
+	hashFunctionsMu.Lock()
+	defer hashFunctionsMu.Unlock()
+
+	hashFunctions[strings.ToUpper(name)] = fn
+}
+
+// GetHashFunction 根据名称获取哈希函数，名称为空时使用 murmur3
+func GetHashFunction(name string) (HashFunction, error) {
+	if name == "" {
+		name = HashMurmur3
+	}
+
+	hashFunctionsMu.RLock()
+	defer hashFunctionsMu.RUnlock()
+
+	fn, exists := hashFunctions[strings.ToUpper(name)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported hash function: %s", name)
+	}
+	return fn, nil
+}
+
+// GetAvailableHashFunctions 获取已注册的哈希函数名称
+func GetAvailableHashFunctions() []string {
+	hashFunctionsMu.RLock()
+	defer hashFunctionsMu.RUnlock()
+
+	names := make([]string, 0, len(hashFunctions))
+	for name := range hashFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Murmur3Hash32 计算 MurmurHash3 x86 32 位哈希
+func Murmur3Hash32(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	length := len(data)
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+		data = data[4:]
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(length)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// xxHash64 常量
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXHash64 计算 xxHash64 哈希
+func XXHash64(data []byte, seed uint64) uint64 {
+	length := len(data)
+	var h uint64
+
+	if len(data) >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for len(data) >= 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(data[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(data[24:]))
+			data = data[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint64(length)
+	for len(data) >= 8 {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+		data = data[8:]
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// xxRound xxHash64 的单轮累加
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+// xxMergeRound xxHash64 合并累加器
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+// foldHash64 将 64 位哈希折叠为 32 位
+func foldHash64(h uint64) uint32 {
+	return uint32(h ^ h>>32)
+}
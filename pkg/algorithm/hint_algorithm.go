@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // HintInlineShardingAlgorithm Hint内联分片算法
@@ -160,9 +161,11 @@ func (a *HintInlineShardingAlgorithm) extractRangeSize() int64 {
 	return 1000 // 默认值
 }
 
-// HintManager Hint管理器
+// HintManager Hint管理器，可以并发读写
+// 路由器不读取 HintManager，按请求强制路由请使用 routing.WithHint 在上下文中绑定提示
 type HintManager struct {
 	hints map[string]interface{}
+	mu    sync.RWMutex
 }
 
 // NewHintManager 创建Hint管理器
@@ -174,31 +177,49 @@ func NewHintManager() *HintManager {
 
 // SetDatabaseShardingValue 设置数据库分片Hint值
 func (hm *HintManager) SetDatabaseShardingValue(value interface{}) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
 	hm.hints["database_sharding_value"] = value
 }
 
 // SetTableShardingValue 设置表分片Hint值
 func (hm *HintManager) SetTableShardingValue(value interface{}) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
 	hm.hints["table_sharding_value"] = value
 }
 
 // SetMasterRouteOnly 设置仅主库路由
 func (hm *HintManager) SetMasterRouteOnly() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
 	hm.hints["master_route_only"] = true
 }
 
 // GetDatabaseShardingValue 获取数据库分片Hint值
 func (hm *HintManager) GetDatabaseShardingValue() interface{} {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
 	return hm.hints["database_sharding_value"]
 }
 
 // GetTableShardingValue 获取表分片Hint值
 func (hm *HintManager) GetTableShardingValue() interface{} {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
 	return hm.hints["table_sharding_value"]
 }
 
 // IsMasterRouteOnly 是否仅主库路由
 func (hm *HintManager) IsMasterRouteOnly() bool {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
 	if value, ok := hm.hints["master_route_only"].(bool); ok {
 		return value
 	}
@@ -207,11 +228,17 @@ func (hm *HintManager) IsMasterRouteOnly() bool {
 
 // Clear 清除所有Hint
 func (hm *HintManager) Clear() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
 	hm.hints = make(map[string]interface{})
 }
 
 // GetAllHints 获取所有Hint
 func (hm *HintManager) GetAllHints() map[string]interface{} {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
 	result := make(map[string]interface{})
 	for k, v := range hm.hints {
 		result[k] = v
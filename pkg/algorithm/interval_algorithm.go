@@ -0,0 +1,314 @@
+package algorithm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalUnit 时间分片间隔单位
+type IntervalUnit string
+
+const (
+	IntervalYears   IntervalUnit = "YEARS"
+	IntervalMonths  IntervalUnit = "MONTHS"
+	IntervalWeeks   IntervalUnit = "WEEKS"
+	IntervalDays    IntervalUnit = "DAYS"
+	IntervalHours   IntervalUnit = "HOURS"
+	IntervalMinutes IntervalUnit = "MINUTES"
+	IntervalSeconds IntervalUnit = "SECONDS"
+)
+
+// defaultDatetimePattern 未配置 datetime-pattern 时使用的时间格式
+const defaultDatetimePattern = "2006-01-02 15:04:05"
+
+// IntervalShardingAlgorithm 时间间隔分片算法
+// 从 datetime-lower 开始每隔 datetime-interval-amount 个 datetime-interval-unit 划分一个分片，
+// 分片的起始时间按 sharding-suffix-pattern 格式化后作为真实表或数据源的后缀
+type IntervalShardingAlgorithm struct {
+	datetimePattern string
+	suffixPattern   string
+	lower           time.Time
+	upper           time.Time
+	unit            IntervalUnit
+	amount          int
+	properties      map[string]interface{}
+}
+
+// NewIntervalShardingAlgorithm 创建时间间隔分片算法
+// 属性：datetime-pattern 时间格式（Go 时间布局），datetime-lower 和 datetime-upper 分片时间上下界，
+// datetime-interval-unit 和 datetime-interval-amount 分片间隔，sharding-suffix-pattern 分片后缀格式
+func NewIntervalShardingAlgorithm(properties map[string]interface{}) (ShardingAlgorithm, error) {
+	a := &IntervalShardingAlgorithm{
+		datetimePattern: defaultDatetimePattern,
+		unit:            IntervalDays,
+		amount:          1,
+		properties:      properties,
+	}
+
+	if pattern, ok := properties["datetime-pattern"].(string); ok && pattern != "" {
+		a.datetimePattern = pattern
+	}
+
+	suffixPattern, ok := properties["sharding-suffix-pattern"].(string)
+	if !ok || suffixPattern == "" {
+		return nil, fmt.Errorf("sharding-suffix-pattern is required for interval sharding algorithm")
+	}
+	a.suffixPattern = suffixPattern
+
+	lower, ok := properties["datetime-lower"].(string)
+	if !ok {
+		return nil, fmt.Errorf("datetime-lower is required for interval sharding algorithm")
+	}
+	var err error
+	if a.lower, err = time.ParseInLocation(a.datetimePattern, lower, time.Local); err != nil {
+		return nil, fmt.Errorf("invalid datetime-lower %s: %w", lower, err)
+	}
+
+	if upper, ok := properties["datetime-upper"].(string); ok && upper != "" {
+		if a.upper, err = time.ParseInLocation(a.datetimePattern, upper, time.Local); err != nil {
+			return nil, fmt.Errorf("invalid datetime-upper %s: %w", upper, err)
+		}
+		if a.upper.Before(a.lower) {
+			return nil, fmt.Errorf("datetime-upper %s is before datetime-lower %s", upper, lower)
+		}
+	}
+
+	if unit, ok := properties["datetime-interval-unit"].(string); ok && unit != "" {
+		a.unit = IntervalUnit(strings.ToUpper(unit))
+		if _, err := a.addIntervals(a.lower, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.amount, err = intProperty(properties, "datetime-interval-amount", 1); err != nil {
+		return nil, err
+	}
+	if a.amount <= 0 {
+		return nil, fmt.Errorf("datetime-interval-amount must be positive")
+	}
+
+	// 相邻分片的后缀必须不同，否则后缀格式比分片间隔粗
+	next := a.NextIntervalStart(a.lower)
+	if a.Suffix(a.lower) == a.Suffix(next) {
+		return nil, fmt.Errorf("sharding-suffix-pattern %s cannot distinguish intervals of %d %s", suffixPattern, a.amount, a.unit)
+	}
+
+	return a, nil
+}
+
+// DoSharding 执行分片计算，支持单值、IN 和范围查询
+func (a *IntervalShardingAlgorithm) DoSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if shardingValue.Range != nil {
+		return a.DoRangeSharding(availableTargetNames, shardingValue)
+	}
+
+	if len(shardingValue.Values) > 0 {
+		var results []string
+		for _, value := range shardingValue.Values {
+			target, err := a.doPreciseSharding(availableTargetNames, value)
+			if err != nil {
+				return nil, err
+			}
+			if !contains(results, target) {
+				results = append(results, target)
+			}
+		}
+		return results, nil
+	}
+
+	target, err := a.doPreciseSharding(availableTargetNames, shardingValue.Value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{target}, nil
+}
+
+// DoPreciseSharding 精确分片
+func (a *IntervalShardingAlgorithm) DoPreciseSharding(availableTargetNames []string, shardingValue *ShardingValue) (string, error) {
+	return a.doPreciseSharding(availableTargetNames, shardingValue.Value)
+}
+
+// DoRangeSharding 范围分片，返回与时间范围有交集的所有分片，未指定的起止时间视为不限
+func (a *IntervalShardingAlgorithm) DoRangeSharding(availableTargetNames []string, shardingValue *ShardingValue) ([]string, error) {
+	if shardingValue.Range == nil {
+		return nil, fmt.Errorf("range value is required for range sharding")
+	}
+
+	start, end := a.lower, a.upper
+	if shardingValue.Range.Start != nil {
+		value, err := a.ParseDatetime(shardingValue.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		if value.After(start) {
+			start = value
+		}
+	}
+	if shardingValue.Range.End != nil {
+		value, err := a.ParseDatetime(shardingValue.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if end.IsZero() || value.Before(end) {
+			end = value
+		}
+	}
+
+	var results []string
+	for _, target := range availableTargetNames {
+		intervalStart, ok := a.targetIntervalStart(target)
+		if !ok || intervalStart.Before(a.lower) || !a.upper.IsZero() && intervalStart.After(a.upper) {
+			continue
+		}
+		// 分片时间段 [intervalStart, 下一分片起始时间) 与查询范围 [start, end] 有交集
+		if (end.IsZero() || !intervalStart.After(end)) && a.NextIntervalStart(intervalStart).After(start) {
+			results = append(results, target)
+		}
+	}
+	return results, nil
+}
+
+// GetType 获取算法类型
+func (a *IntervalShardingAlgorithm) GetType() string {
+	return "INTERVAL"
+}
+
+// GetProperties 获取算法属性
+func (a *IntervalShardingAlgorithm) GetProperties() map[string]interface{} {
+	return a.properties
+}
+
+// Lower 获取分片时间下界
+func (a *IntervalShardingAlgorithm) Lower() time.Time {
+	return a.lower
+}
+
+// Upper 获取分片时间上界，未配置时返回零值
+func (a *IntervalShardingAlgorithm) Upper() time.Time {
+	return a.upper
+}
+
+// IntervalStart 获取时间所在分片的起始时间
+func (a *IntervalShardingAlgorithm) IntervalStart(t time.Time) time.Time {
+	t = t.In(a.lower.Location())
+
+	// 先按间隔的近似长度估算分片序号，再逐个调整到准确位置
+	index := int(t.Sub(a.lower) / a.approximateInterval())
+	start, _ := a.addIntervals(a.lower, index)
+	for start.After(t) {
+		index--
+		start, _ = a.addIntervals(a.lower, index)
+	}
+	for {
+		next, _ := a.addIntervals(a.lower, index+1)
+		if next.After(t) {
+			return start
+		}
+		index++
+		start = next
+	}
+}
+
+// NextIntervalStart 获取时间所在分片的下一个分片的起始时间
+func (a *IntervalShardingAlgorithm) NextIntervalStart(t time.Time) time.Time {
+	next, _ := a.addIntervals(a.IntervalStart(t), 1)
+	return next
+}
+
+// Suffix 获取时间所在分片的后缀
+func (a *IntervalShardingAlgorithm) Suffix(t time.Time) string {
+	return a.IntervalStart(t).Format(a.suffixPattern)
+}
+
+// ParseDatetime 将分片值转换为时间，字符串和 []byte 按 datetime-pattern 解析，sql.NullTime 等 driver.Valuer 先展开
+func (a *IntervalShardingAlgorithm) ParseDatetime(value interface{}) (time.Time, error) {
+	return ConvertToTime(value, a.lower.Location(), a.datetimePattern)
+}
+
+// doPreciseSharding 计算单个时间值所在的分片
+func (a *IntervalShardingAlgorithm) doPreciseSharding(availableTargetNames []string, value interface{}) (string, error) {
+	t, err := a.ParseDatetime(value)
+	if err != nil {
+		return "", err
+	}
+	if t.Before(a.lower) || !a.upper.IsZero() && t.After(a.upper) {
+		return "", fmt.Errorf("datetime %s is out of sharding range", t.Format(a.datetimePattern))
+	}
+
+	suffix := a.Suffix(t)
+	for _, target := range availableTargetNames {
+		if strings.HasSuffix(target, suffix) {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("no target found with suffix %s", suffix)
+}
+
+// targetIntervalStart 从目标名称的后缀解析分片的起始时间
+func (a *IntervalShardingAlgorithm) targetIntervalStart(target string) (time.Time, bool) {
+	suffixLength := len(a.lower.Format(a.suffixPattern))
+	if len(target) < suffixLength {
+		return time.Time{}, false
+	}
+
+	start, err := time.ParseInLocation(a.suffixPattern, target[len(target)-suffixLength:], a.lower.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return a.IntervalStart(start), true
+}
+
+// addIntervals 计算从 t 开始经过 n 个分片间隔后的时间
+func (a *IntervalShardingAlgorithm) addIntervals(t time.Time, n int) (time.Time, error) {
+	amount := n * a.amount
+	switch a.unit {
+	case IntervalYears:
+		return t.AddDate(amount, 0, 0), nil
+	case IntervalMonths:
+		return t.AddDate(0, amount, 0), nil
+	case IntervalWeeks:
+		return t.AddDate(0, 0, 7*amount), nil
+	case IntervalDays:
+		return t.AddDate(0, 0, amount), nil
+	case IntervalHours:
+		return t.Add(time.Duration(amount) * time.Hour), nil
+	case IntervalMinutes:
+		return t.Add(time.Duration(amount) * time.Minute), nil
+	case IntervalSeconds:
+		return t.Add(time.Duration(amount) * time.Second), nil
+	}
+	return t, fmt.Errorf("unsupported datetime-interval-unit: %s", a.unit)
+}
+
+// approximateInterval 分片间隔的近似长度，用于估算分片序号
+func (a *IntervalShardingAlgorithm) approximateInterval() time.Duration {
+	next, _ := a.addIntervals(a.lower, 1)
+	return next.Sub(a.lower)
+}
+
+// intProperty 读取 int、整数值的 float64（JSON 解码得到的数字）或数字字符串类型的算法属性，未配置时返回默认值
+func intProperty(properties map[string]interface{}, key string, defaultValue int) (int, error) {
+	switch v := properties[key].(type) {
+	case nil:
+		return defaultValue, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("invalid %s: %v is not an integer", key, v)
+		}
+		return int(v), nil
+	case string:
+		value, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", key, v)
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("%s must be an integer or string", key)
+}
@@ -0,0 +1,143 @@
+package algorithm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMonthlyAlgorithm 创建 2024 年按月分片的时间间隔分片算法
+func newMonthlyAlgorithm(t *testing.T) *IntervalShardingAlgorithm {
+	shardingAlgorithm, err := NewAlgorithmFactory().CreateAlgorithm("INTERVAL", map[string]interface{}{
+		"datetime-pattern":         "2006-01-02 15:04:05",
+		"datetime-lower":           "2024-01-01 00:00:00",
+		"datetime-upper":           "2024-12-31 23:59:59",
+		"datetime-interval-unit":   "months",
+		"datetime-interval-amount": 1,
+		"sharding-suffix-pattern":  "200601",
+	})
+	require.NoError(t, err)
+	return shardingAlgorithm.(*IntervalShardingAlgorithm)
+}
+
+// monthlyTables 生成 t_order_202401 到 t_order_202412
+func monthlyTables() []string {
+	var tables []string
+	for month := 1; month <= 12; month++ {
+		tables = append(tables, time.Date(2024, time.Month(month), 1, 0, 0, 0, 0, time.Local).Format("t_order_200601"))
+	}
+	return tables
+}
+
+func TestIntervalShardingAlgorithm_DoSharding(t *testing.T) {
+	a := newMonthlyAlgorithm(t)
+	tables := monthlyTables()
+	assert.Equal(t, "INTERVAL", a.GetType())
+
+	tests := []struct {
+		name     string
+		value    *ShardingValue
+		expected []string
+	}{
+		{"string value", &ShardingValue{Value: "2024-03-15 10:00:00"}, []string{"t_order_202403"}},
+		{"time value", &ShardingValue{Value: time.Date(2024, 12, 31, 23, 0, 0, 0, time.Local)}, []string{"t_order_202412"}},
+		{"interval start", &ShardingValue{Value: []byte("2024-02-01 00:00:00")}, []string{"t_order_202402"}},
+		{"in values", &ShardingValue{Values: []interface{}{"2024-05-01 00:00:00", "2024-05-31 00:00:00", "2024-07-04 00:00:00"}}, []string{"t_order_202405", "t_order_202407"}},
+		{"between", &ShardingValue{Range: &Range{Start: "2024-02-15 00:00:00", End: "2024-04-01 00:00:00"}}, []string{"t_order_202402", "t_order_202403", "t_order_202404"}},
+		{"range ends before interval", &ShardingValue{Range: &Range{Start: "2024-02-15 00:00:00", End: "2024-03-31 23:59:59"}}, []string{"t_order_202402", "t_order_202403"}},
+		{"open start", &ShardingValue{Range: &Range{End: time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)}}, []string{"t_order_202401", "t_order_202402"}},
+		{"open end", &ShardingValue{Range: &Range{Start: "2024-11-30 00:00:00"}}, []string{"t_order_202411", "t_order_202412"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := a.DoSharding(tables, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, targets)
+		})
+	}
+
+	_, err := a.DoSharding(tables, &ShardingValue{Value: "2025-01-01 00:00:00"})
+	assert.Error(t, err)
+	_, err = a.DoSharding(tables, &ShardingValue{Value: "2024/01/01"})
+	assert.Error(t, err)
+	_, err = a.DoSharding(tables[:6], &ShardingValue{Value: "2024-08-01 00:00:00"})
+	assert.Error(t, err)
+}
+
+func TestIntervalShardingAlgorithm_Intervals(t *testing.T) {
+	shardingAlgorithm, err := NewIntervalShardingAlgorithm(map[string]interface{}{
+		"datetime-pattern":         "2006-01-02",
+		"datetime-lower":           "2024-01-01",
+		"datetime-interval-unit":   "DAYS",
+		"datetime-interval-amount": "7",
+		"sharding-suffix-pattern":  "20060102",
+	})
+	require.NoError(t, err)
+	a := shardingAlgorithm.(*IntervalShardingAlgorithm)
+
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 12, 0, 0, 0, time.Local) }
+	assert.Equal(t, "20240101", a.Suffix(day(time.January, 7)))
+	assert.Equal(t, "20240108", a.Suffix(day(time.January, 8)))
+	assert.Equal(t, "20241230", a.Suffix(day(time.December, 31)))
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local), a.NextIntervalStart(day(time.March, 4)))
+	assert.True(t, a.Upper().IsZero())
+
+	targets, err := a.DoSharding([]string{"t_log_20240101", "t_log_20240108", "t_log_20240115", "t_log_other"},
+		&ShardingValue{Range: &Range{Start: "2024-01-09"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t_log_20240108", "t_log_20240115"}, targets)
+}
+
+func TestNewIntervalShardingAlgorithm_InvalidProperties(t *testing.T) {
+	valid := map[string]interface{}{
+		"datetime-lower":          "2024-01-01 00:00:00",
+		"sharding-suffix-pattern": "20060102",
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		properties := make(map[string]interface{})
+		for k, v := range valid {
+			properties[k] = v
+		}
+		if value == nil {
+			delete(properties, key)
+		} else {
+			properties[key] = value
+		}
+		return properties
+	}
+
+	_, err := NewIntervalShardingAlgorithm(valid)
+	require.NoError(t, err)
+
+	// JSON 解码得到的 float64 整数值可以作为整数属性
+	shardingAlgorithm, err := NewIntervalShardingAlgorithm(with("datetime-interval-amount", float64(7)))
+	require.NoError(t, err)
+	assert.Equal(t, 7, shardingAlgorithm.(*IntervalShardingAlgorithm).amount)
+
+	tests := []struct {
+		name       string
+		properties map[string]interface{}
+		errorMsg   string
+	}{
+		{"missing suffix", with("sharding-suffix-pattern", nil), "sharding-suffix-pattern is required"},
+		{"missing lower", with("datetime-lower", nil), "datetime-lower is required"},
+		{"invalid lower", with("datetime-lower", "2024-01-01"), "invalid datetime-lower"},
+		{"upper before lower", with("datetime-upper", "2023-01-01 00:00:00"), "is before datetime-lower"},
+		{"unknown unit", with("datetime-interval-unit", "DECADES"), "unsupported datetime-interval-unit"},
+		{"invalid amount", with("datetime-interval-amount", 0), "must be positive"},
+		{"fractional amount", with("datetime-interval-amount", 1.5), "is not an integer"},
+		{"non-numeric amount", with("datetime-interval-amount", true), "must be an integer or string"},
+		{"coarse suffix", with("sharding-suffix-pattern", "200601"), "cannot distinguish intervals"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIntervalShardingAlgorithm(tt.properties)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package algorithm
 
 import (
 	"fmt"
-	"hash/crc32"
 	"strconv"
 )
 
@@ -103,9 +102,18 @@ func (a *ModShardingAlgorithm) calculateTarget(value interface{}, availableTarge
 	return availableTargetNames[index], nil
 }
 
+// 哈希取模分片算法的 hash-input 属性取值
+const (
+	// HashInputLegacy 哈希字符串原值或 fmt.Sprintf("%v") 的结果，与早期版本的分片位置一致
+	HashInputLegacy = "legacy"
+	// HashInputCanonical 哈希 HashBytes 得到的规范字节，与 CONSISTENT_HASH 一致
+	HashInputCanonical = "canonical"
+)
+
 // HashModShardingAlgorithm 哈希取模分片算法
 type HashModShardingAlgorithm struct {
 	shardingCount int
+	hashInput     string
 	properties    map[string]interface{}
 }
 
@@ -134,8 +142,17 @@ func NewHashModShardingAlgorithm(properties map[string]interface{}) (ShardingAlg
 		return nil, fmt.Errorf("sharding-count must be positive")
 	}
 	
+	hashInput := HashInputLegacy
+	if value, ok := properties["hash-input"]; ok {
+		hashInput = fmt.Sprintf("%v", value)
+		if hashInput != HashInputLegacy && hashInput != HashInputCanonical {
+			return nil, fmt.Errorf("invalid hash-input: %s, must be %s or %s", hashInput, HashInputLegacy, HashInputCanonical)
+		}
+	}
+	
 	return &HashModShardingAlgorithm{
 		shardingCount: shardingCount,
+		hashInput:     hashInput,
 		properties:    properties,
 	}, nil
 }
@@ -183,8 +200,10 @@ func (a *HashModShardingAlgorithm) GetProperties() map[string]interface{} {
 
 // calculateTarget 计算目标
 func (a *HashModShardingAlgorithm) calculateTarget(value interface{}, availableTargetNames []string) (string, error) {
-	valueStr := ConvertToString(value)
-	hash := crc32.ChecksumIEEE([]byte(valueStr))
+	hash := StableHash(value)
+	if a.hashInput == HashInputLegacy {
+		hash = legacyHash(value)
+	}
 	index := int(hash) % a.shardingCount
 	
 	if index >= len(availableTargetNames) {
@@ -1,8 +1,12 @@
 package algorithm
 
 import (
+	"database/sql/driver"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"time"
 )
 
 // ShardingValue 分片值
@@ -57,6 +61,12 @@ type HintShardingAlgorithm interface {
 	DoHintSharding(availableTargetNames []string, hintValue *ShardingValue) ([]string, error)
 }
 
+// TargetValidator 需要在启动时检查目标是否覆盖所有分片的算法实现该接口
+type TargetValidator interface {
+	// ValidateTargets 检查可用目标是否覆盖算法的所有分片
+	ValidateTargets(availableTargetNames []string) error
+}
+
 // AlgorithmFactory 算法工厂
 type AlgorithmFactory struct {
 	algorithms map[string]func(properties map[string]interface{}) (ShardingAlgorithm, error)
@@ -75,6 +85,11 @@ func NewAlgorithmFactory() *AlgorithmFactory {
 	factory.RegisterAlgorithm("RANGE", NewRangeShardingAlgorithm)
 	factory.RegisterAlgorithm("COMPLEX_INLINE", NewComplexInlineShardingAlgorithm)
 	factory.RegisterAlgorithm("HINT_INLINE", NewHintInlineShardingAlgorithm)
+	factory.RegisterAlgorithm("INTERVAL", NewIntervalShardingAlgorithm)
+	factory.RegisterAlgorithm("CONSISTENT_HASH", NewConsistentHashShardingAlgorithm)
+	factory.RegisterAlgorithm("VOLUME_RANGE", NewVolumeRangeShardingAlgorithm)
+	factory.RegisterAlgorithm("BOUNDARY_RANGE", NewBoundaryRangeShardingAlgorithm)
+	factory.RegisterAlgorithm("GENE_MOD", NewGeneShardingAlgorithm)
 	
 	return factory
 }
@@ -104,48 +119,106 @@ func (f *AlgorithmFactory) GetAvailableAlgorithms() []string {
 }
 
 // ConvertToInt 转换为整数
+// 浮点数和 Decimal 必须是 int64 范围内的整数，不会截断小数部分；字符串和 []byte 按十进制整数解析；
+// sql.NullInt64 等 driver.Valuer 先展开为驱动值
 func ConvertToInt(value interface{}) (int64, error) {
 	switch v := value.(type) {
 	case int:
 		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
 	case int32:
 		return int64(v), nil
 	case int64:
 		return v, nil
 	case uint:
+		return uintToInt(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
 		return int64(v), nil
 	case uint32:
 		return int64(v), nil
 	case uint64:
-		return int64(v), nil
+		return uintToInt(v)
+	case float32:
+		return floatToInt(float64(v))
+	case float64:
+		return floatToInt(v)
 	case string:
-		// 尝试解析字符串为数字
-		if len(v) == 0 {
-			return 0, fmt.Errorf("empty string cannot be converted to int")
+		return parseInt(v)
+	case []byte:
+		return parseInt(string(v))
+	case Decimal:
+		intValue, ok := v.Int64()
+		if !ok {
+			return 0, fmt.Errorf("decimal %s is not an int64 integer", v)
 		}
-		// 简单的字符串转数字逻辑
-		var result int64
-		for _, char := range v {
-			if char >= '0' && char <= '9' {
-				result = result*10 + int64(char-'0')
-			} else {
-				return 0, fmt.Errorf("invalid character in string: %c", char)
-			}
+		return intValue, nil
+	case driver.Valuer:
+		driverValue, err := driverValue(v)
+		if err != nil {
+			return 0, err
 		}
-		return result, nil
+		return ConvertToInt(driverValue)
 	default:
 		return 0, fmt.Errorf("unsupported type for conversion to int: %v", reflect.TypeOf(value))
 	}
 }
 
+// uintToInt 转换无符号整数，超出 int64 范围时返回错误
+func uintToInt(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("value %d overflows int64", v)
+	}
+	return int64(v), nil
+}
+
+// floatToInt 转换浮点数，有小数部分或超出 int64 范围时返回错误
+func floatToInt(v float64) (int64, error) {
+	if v != math.Trunc(v) {
+		return 0, fmt.Errorf("value %v has a fractional part", v)
+	}
+	if v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, fmt.Errorf("value %v overflows int64", v)
+	}
+	return int64(v), nil
+}
+
+// parseInt 解析十进制整数字符串
+func parseInt(s string) (int64, error) {
+	if len(s) == 0 {
+		return 0, fmt.Errorf("empty string cannot be converted to int")
+	}
+	result, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer string %q: %w", s, err)
+	}
+	return result, nil
+}
+
 // ConvertToString 转换为字符串
+// []byte 按文本转换，UUID 为小写标准格式，Decimal 为规范十进制表示，时间为 UTC 的 RFC3339 格式
 func ConvertToString(value interface{}) string {
 	switch v := value.(type) {
 	case string:
 		return v
-	case int, int32, int64, uint, uint32, uint64:
+	case []byte:
+		return string(v)
+	case UUID:
+		return v.String()
+	case Decimal:
+		return v.String()
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case driver.Valuer:
+		if driverValue, err := driverValue(v); err == nil {
+			return ConvertToString(driverValue)
+		}
 		return fmt.Sprintf("%v", v)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,310 @@
+package algorithm
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ColumnType 分片列的类型，路由前按列类型将驱动返回或调用方传入的值转换为统一的 Go 类型
+type ColumnType string
+
+const (
+	// ColumnTypeAny 未声明列类型，只展开 driver.Valuer
+	ColumnTypeAny ColumnType = ""
+	// ColumnTypeInt 整数列，值转换为 int64
+	ColumnTypeInt ColumnType = "INT"
+	// ColumnTypeString 字符串列，值转换为 string
+	ColumnTypeString ColumnType = "STRING"
+	// ColumnTypeBytes 二进制列，值转换为 []byte
+	ColumnTypeBytes ColumnType = "BYTES"
+	// ColumnTypeUUID UUID 列，值转换为 UUID
+	ColumnTypeUUID ColumnType = "UUID"
+	// ColumnTypeDatetime 日期时间列，值转换为 time.Time
+	ColumnTypeDatetime ColumnType = "DATETIME"
+	// ColumnTypeDecimal 定点数列，值转换为 Decimal
+	ColumnTypeDecimal ColumnType = "DECIMAL"
+)
+
+// columnTypeAliases SQL 类型名到列类型的映射
+var columnTypeAliases = map[string]ColumnType{
+	"INT": ColumnTypeInt, "INTEGER": ColumnTypeInt, "BIGINT": ColumnTypeInt, "SMALLINT": ColumnTypeInt, "TINYINT": ColumnTypeInt,
+	"STRING": ColumnTypeString, "VARCHAR": ColumnTypeString, "CHAR": ColumnTypeString, "TEXT": ColumnTypeString,
+	"BYTES": ColumnTypeBytes, "BINARY": ColumnTypeBytes, "VARBINARY": ColumnTypeBytes, "BLOB": ColumnTypeBytes, "BYTEA": ColumnTypeBytes,
+	"UUID": ColumnTypeUUID, "UNIQUEIDENTIFIER": ColumnTypeUUID,
+	"DATETIME": ColumnTypeDatetime, "TIMESTAMP": ColumnTypeDatetime, "DATE": ColumnTypeDatetime,
+	"DECIMAL": ColumnTypeDecimal, "NUMERIC": ColumnTypeDecimal,
+}
+
+// defaultDatetimeLayouts 未指定格式时解析日期时间字符串依次尝试的格式
+var defaultDatetimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// ParseColumnType 解析列类型，支持常见的 SQL 类型名，如 BIGINT、VARCHAR、TIMESTAMP，为空时返回 ColumnTypeAny
+func ParseColumnType(name string) (ColumnType, error) {
+	if name == "" {
+		return ColumnTypeAny, nil
+	}
+	columnType, exists := columnTypeAliases[strings.ToUpper(name)]
+	if !exists {
+		return "", fmt.Errorf("unsupported sharding column type: %s", name)
+	}
+	return columnType, nil
+}
+
+// CoerceValue 按列类型转换分片值，sql.NullInt64 等 driver.Valuer 先展开为驱动值，NULL 值返回错误
+func CoerceValue(value interface{}, columnType ColumnType) (interface{}, error) {
+	value, err := driverValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch columnType {
+	case ColumnTypeAny:
+		return value, nil
+	case ColumnTypeInt:
+		return ConvertToInt(value)
+	case ColumnTypeString:
+		return ConvertToString(value), nil
+	case ColumnTypeBytes:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+	case ColumnTypeUUID:
+		return ConvertToUUID(value)
+	case ColumnTypeDatetime:
+		return ConvertToTime(value, time.Local, defaultDatetimeLayouts...)
+	case ColumnTypeDecimal:
+		return ConvertToDecimal(value)
+	default:
+		return nil, fmt.Errorf("unsupported sharding column type: %s", columnType)
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s sharding value", value, columnType)
+}
+
+// HashBytes 获取分片值用于哈希的规范字节，[]byte 为原始字节，其他值为 ConvertToString 的结果，
+// 因此 42、"42"、[]byte("42") 和整数值的 Decimal 得到相同的字节
+func HashBytes(value interface{}) []byte {
+	if data, ok := value.([]byte); ok {
+		return data
+	}
+	return []byte(ConvertToString(value))
+}
+
+// StableHash 计算分片值的稳定哈希（CRC32），结果只由 HashBytes 决定，与进程和平台无关
+func StableHash(value interface{}) uint32 {
+	return crc32.ChecksumIEEE(HashBytes(value))
+}
+
+// legacyHash 按早期版本的方式计算哈希：字符串取原值，其他值取 fmt.Sprintf("%v") 的结果，
+// 因此 []byte、time.Time 和 driver.Valuer 的哈希与 StableHash 不同
+func legacyHash(value interface{}) uint32 {
+	if s, ok := value.(string); ok {
+		return crc32.ChecksumIEEE([]byte(s))
+	}
+	return crc32.ChecksumIEEE([]byte(fmt.Sprintf("%v", value)))
+}
+
+// ConvertToTime 转换为时间，字符串和 []byte 按 layouts 依次解析，未带时区的时间按 loc 解释
+func ConvertToTime(value interface{}, loc *time.Location, layouts ...string) (time.Time, error) {
+	value, err := driverValue(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, fmt.Errorf("sharding value is NULL")
+		}
+		return *v, nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported datetime sharding value type: %T", value)
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse datetime %s with pattern %s", text, strings.Join(layouts, " or "))
+}
+
+// driverValue 展开 driver.Valuer，NULL 值返回错误
+func driverValue(value interface{}) (interface{}, error) {
+	valuer, ok := value.(driver.Valuer)
+	if !ok {
+		return value, nil
+	}
+	v, err := valuer.Value()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver value of %T: %w", value, err)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("sharding value is NULL")
+	}
+	return v, nil
+}
+
+// UUID 128 位通用唯一标识符
+type UUID [16]byte
+
+// ParseUUID 解析 UUID 字符串，支持带连字符的标准格式和 32 位十六进制格式，不区分大小写
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	text := s
+	if len(text) == 36 {
+		if text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+			return u, fmt.Errorf("invalid UUID: %s", s)
+		}
+		text = text[:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+	}
+	if len(text) != 32 {
+		return u, fmt.Errorf("invalid UUID: %s", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(text)); err != nil {
+		return u, fmt.Errorf("invalid UUID: %s", s)
+	}
+	return u, nil
+}
+
+// String 获取小写带连字符的标准格式
+func (u UUID) String() string {
+	text := hex.EncodeToString(u[:])
+	return text[:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:]
+}
+
+// ConvertToUUID 转换为 UUID，16 字节的 []byte 视为原始字节，其他字符串和 []byte 按文本解析
+func ConvertToUUID(value interface{}) (UUID, error) {
+	value, err := driverValue(value)
+	if err != nil {
+		return UUID{}, err
+	}
+
+	switch v := value.(type) {
+	case UUID:
+		return v, nil
+	case [16]byte:
+		return UUID(v), nil
+	case []byte:
+		if len(v) == 16 {
+			return UUID(v), nil
+		}
+		return ParseUUID(string(v))
+	case string:
+		return ParseUUID(v)
+	}
+	return UUID{}, fmt.Errorf("cannot convert %T to UUID", value)
+}
+
+// Decimal 任意精度的十进制定点数
+type Decimal struct {
+	rat *big.Rat
+}
+
+// ParseDecimal 解析十进制数字符串，如 -12.50、1e3
+func ParseDecimal(s string) (Decimal, error) {
+	if strings.Contains(s, "/") {
+		return Decimal{}, fmt.Errorf("invalid decimal: %s", s)
+	}
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal: %s", s)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+// NewDecimalFromInt 创建整数值的 Decimal
+func NewDecimalFromInt(value int64) Decimal {
+	return Decimal{rat: new(big.Rat).SetInt64(value)}
+}
+
+// String 获取规范的十进制表示，去掉多余的前导零和小数部分末尾的零，如 12.50 为 12.5，3.0 为 3
+func (d Decimal) String() string {
+	rat := d.value()
+	if rat.IsInt() {
+		return rat.Num().String()
+	}
+
+	// 有限小数的分母只含因子 2 和 5，小数位数为两者指数的较大值
+	denom := new(big.Int).Set(rat.Denom())
+	twos, fives := 0, 0
+	two, five, zero := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for new(big.Int).Mod(denom, two).Cmp(zero) == 0 {
+		denom.Quo(denom, two)
+		twos++
+	}
+	for new(big.Int).Mod(denom, five).Cmp(zero) == 0 {
+		denom.Quo(denom, five)
+		fives++
+	}
+	return rat.FloatString(max(twos, fives))
+}
+
+// IsInteger 判断是否为整数
+func (d Decimal) IsInteger() bool {
+	return d.value().IsInt()
+}
+
+// Int64 获取整数值，不是整数或超出 int64 范围时 ok 为 false
+func (d Decimal) Int64() (value int64, ok bool) {
+	rat := d.value()
+	if !rat.IsInt() || !rat.Num().IsInt64() {
+		return 0, false
+	}
+	return rat.Num().Int64(), true
+}
+
+// Cmp 比较两个 Decimal，小于、等于、大于 other 时分别返回 -1、0、1
+func (d Decimal) Cmp(other Decimal) int {
+	return d.value().Cmp(other.value())
+}
+
+// value 获取数值，零值 Decimal 表示 0
+func (d Decimal) value() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+// ConvertToDecimal 转换为 Decimal，浮点数按最短的十进制表示转换
+func ConvertToDecimal(value interface{}) (Decimal, error) {
+	value, err := driverValue(value)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	switch v := value.(type) {
+	case Decimal:
+		return v, nil
+	case string:
+		return ParseDecimal(v)
+	case []byte:
+		return ParseDecimal(string(v))
+	case float32, float64:
+		return ParseDecimal(ConvertToString(v))
+	}
+	intValue, err := ConvertToInt(value)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("cannot convert %T to decimal: %w", value, err)
+	}
+	return NewDecimalFromInt(intValue), nil
+}
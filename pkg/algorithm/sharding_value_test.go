@@ -0,0 +1,216 @@
+package algorithm
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseDecimal(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(s)
+	require.NoError(t, err)
+	return d
+}
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected ColumnType
+		hasError bool
+	}{
+		{"", ColumnTypeAny, false},
+		{"bigint", ColumnTypeInt, false},
+		{"VARCHAR", ColumnTypeString, false},
+		{"varbinary", ColumnTypeBytes, false},
+		{"uuid", ColumnTypeUUID, false},
+		{"timestamp", ColumnTypeDatetime, false},
+		{"numeric", ColumnTypeDecimal, false},
+		{"json", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columnType, err := ParseColumnType(tt.name)
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, columnType)
+		})
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	uuid, err := ParseUUID("f47ac10b-58cc-4372-a567-0e02b2c3d479")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		value      interface{}
+		columnType ColumnType
+		expected   interface{}
+		hasError   bool
+	}{
+		{"any keeps value", []byte("abc"), ColumnTypeAny, []byte("abc"), false},
+		{"any unwraps valuer", sql.NullString{String: "abc", Valid: true}, ColumnTypeAny, "abc", false},
+		{"any rejects null", sql.NullInt64{}, ColumnTypeAny, nil, true},
+		{"int from bytes", []byte("42"), ColumnTypeInt, int64(42), false},
+		{"int from null int64", sql.NullInt64{Int64: 42, Valid: true}, ColumnTypeInt, int64(42), false},
+		{"int rejects fraction", 4.2, ColumnTypeInt, nil, true},
+		{"string from bytes", []byte("tenant_a"), ColumnTypeString, "tenant_a", false},
+		{"bytes from string", "abc", ColumnTypeBytes, []byte("abc"), false},
+		{"bytes rejects int", 1, ColumnTypeBytes, nil, true},
+		{"uuid from upper case text", "F47AC10B-58CC-4372-A567-0E02B2C3D479", ColumnTypeUUID, uuid, false},
+		{"uuid from raw bytes", uuid[:], ColumnTypeUUID, uuid, false},
+		{"uuid from compact text", []byte("f47ac10b58cc4372a5670e02b2c3d479"), ColumnTypeUUID, uuid, false},
+		{"uuid rejects invalid text", "not-a-uuid", ColumnTypeUUID, nil, true},
+		{"datetime from bytes", []byte("2024-03-01 08:30:00"), ColumnTypeDatetime, time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local), false},
+		{"datetime from date", "2024-03-01", ColumnTypeDatetime, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), false},
+		{"datetime from null time", sql.NullTime{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}, ColumnTypeDatetime, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"decimal from bytes", []byte("10.50"), ColumnTypeDecimal, mustParseDecimal(t, "10.5"), false},
+		{"decimal from float", 0.1, ColumnTypeDecimal, mustParseDecimal(t, "0.1"), false},
+		{"decimal from int", 7, ColumnTypeDecimal, mustParseDecimal(t, "7"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := CoerceValue(tt.value, tt.columnType)
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if expected, ok := tt.expected.(Decimal); ok {
+				require.IsType(t, Decimal{}, value)
+				assert.Zero(t, expected.Cmp(value.(Decimal)))
+				return
+			}
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		integer  bool
+	}{
+		{"12.50", "12.5", false},
+		{"-0012.000", "-12", true},
+		{"1e3", "1000", true},
+		{"0.125", "0.125", false},
+		{"-0.0", "0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d := mustParseDecimal(t, tt.input)
+			assert.Equal(t, tt.expected, d.String())
+			assert.Equal(t, tt.integer, d.IsInteger())
+		})
+	}
+
+	_, err := ParseDecimal("1/2")
+	assert.Error(t, err)
+	assert.Equal(t, -1, mustParseDecimal(t, "9.99").Cmp(mustParseDecimal(t, "10")))
+	assert.Equal(t, "0", Decimal{}.String())
+}
+
+func TestStableHash(t *testing.T) {
+	// 哈希值与原有的 CRC32(十进制字符串) 一致，已有数据的分片位置不变
+	assert.Equal(t, crc32.ChecksumIEEE([]byte("42")), StableHash(42))
+
+	for _, value := range []interface{}{int64(42), "42", []byte("42"), 42.0, mustParseDecimal(t, "42.00"), sql.NullInt64{Int64: 42, Valid: true}} {
+		assert.Equal(t, StableHash(42), StableHash(value), "%T", value)
+	}
+
+	uuid, err := ConvertToUUID("F47AC10B-58CC-4372-A567-0E02B2C3D479")
+	require.NoError(t, err)
+	assert.Equal(t, StableHash("f47ac10b-58cc-4372-a567-0e02b2c3d479"), StableHash(uuid))
+}
+
+func TestHashModShardingAlgorithm_TypedValues(t *testing.T) {
+	alg, err := NewHashModShardingAlgorithm(map[string]interface{}{"sharding-count": 4, "hash-input": HashInputCanonical})
+	require.NoError(t, err)
+	targets := []string{"t_0", "t_1", "t_2", "t_3"}
+
+	uuid, err := ParseUUID("f47ac10b-58cc-4372-a567-0e02b2c3d479")
+	require.NoError(t, err)
+	byString, err := alg.DoSharding(targets, &ShardingValue{Value: "f47ac10b-58cc-4372-a567-0e02b2c3d479"})
+	require.NoError(t, err)
+	byUUID, err := alg.DoSharding(targets, &ShardingValue{Value: uuid})
+	require.NoError(t, err)
+	assert.Equal(t, byString, byUUID)
+
+	byTenant, err := alg.DoSharding(targets, &ShardingValue{Value: []byte("tenant_a")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{targets[crc32.ChecksumIEEE([]byte("tenant_a"))%4]}, byTenant)
+}
+
+func TestHashModShardingAlgorithm_HashInput(t *testing.T) {
+	targets := []string{"t_0", "t_1", "t_2", "t_3"}
+	at := time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		name      string
+		hashInput interface{}
+		value     interface{}
+		hashed    string
+	}{
+		{"legacy string", nil, "tenant_a", "tenant_a"},
+		{"legacy int", nil, 42, "42"},
+		{"legacy bytes", nil, []byte("17"), "[49 55]"},
+		{"legacy time", nil, at, fmt.Sprintf("%v", at)},
+		{"legacy valuer", HashInputLegacy, sql.NullInt64{Int64: 41, Valid: true}, "{41 true}"},
+		{"canonical bytes", HashInputCanonical, []byte("17"), "17"},
+		{"canonical time", HashInputCanonical, at, "2024-03-01T00:00:00Z"},
+		{"canonical valuer", HashInputCanonical, sql.NullInt64{Int64: 41, Valid: true}, "41"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := map[string]interface{}{"sharding-count": 4}
+			if tt.hashInput != nil {
+				properties["hash-input"] = tt.hashInput
+			}
+			alg, err := NewHashModShardingAlgorithm(properties)
+			require.NoError(t, err)
+
+			result, err := alg.DoSharding(targets, &ShardingValue{Value: tt.value})
+			require.NoError(t, err)
+			assert.Equal(t, []string{targets[crc32.ChecksumIEEE([]byte(tt.hashed))%4]}, result)
+		})
+	}
+
+	_, err := NewHashModShardingAlgorithm(map[string]interface{}{"sharding-count": 4, "hash-input": "crc32"})
+	assert.Error(t, err)
+}
+
+func TestIntervalShardingAlgorithm_DriverValues(t *testing.T) {
+	alg, err := NewIntervalShardingAlgorithm(map[string]interface{}{
+		"datetime-lower":          "2024-01-01 00:00:00",
+		"datetime-interval-unit":  "MONTHS",
+		"sharding-suffix-pattern": "200601",
+	})
+	require.NoError(t, err)
+	targets := []string{"t_order_202401", "t_order_202402"}
+
+	for _, value := range []interface{}{
+		sql.NullTime{Time: time.Date(2024, 2, 10, 0, 0, 0, 0, time.Local), Valid: true},
+		[]byte("2024-02-10 00:00:00"),
+	} {
+		result, err := alg.DoSharding(targets, &ShardingValue{Value: value})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"t_order_202402"}, result)
+	}
+
+	_, err = alg.DoSharding(targets, &ShardingValue{Value: sql.NullTime{}})
+	assert.Error(t, err)
+}
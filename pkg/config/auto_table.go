@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultAutoTableAlgorithm 未配置算法时自动分片表使用的分片算法
+const defaultAutoTableAlgorithm = "MOD"
+
+// AutoTableRuleConfig 自动分片表规则配置
+// 只需声明数据源和分片数量，真实表 逻辑表名_序号 按序号轮流分布到各个数据源上，
+// 即第 i 张表位于第 i % len(ActualDataSources) 个数据源
+type AutoTableRuleConfig struct {
+	LogicTable        string   `yaml:"logicTable" json:"logicTable"`
+	ActualDataSources []string `yaml:"actualDataSources" json:"actualDataSources"`
+	ShardingColumn    string   `yaml:"shardingColumn" json:"shardingColumn"`
+	// Algorithm 分片算法类型，默认为 MOD，分片算法在所有真实表中选择目标表
+	Algorithm string `yaml:"algorithm" json:"algorithm"`
+	// ShardingCount 真实表数量，未在 Props 中配置 sharding-count 时作为算法的 sharding-count
+	ShardingCount int                    `yaml:"shardingCount" json:"shardingCount"`
+	Props         map[string]interface{} `yaml:"props" json:"props"`
+	KeyGenerator  *KeyGeneratorConfig    `yaml:"keyGenerator" json:"keyGenerator"`
+}
+
+// AutoTableNode 自动分片表的真实表
+type AutoTableNode struct {
+	// Index 真实表序号，即表名的数字后缀
+	Index      int
+	DataSource string
+	Table      string
+}
+
+// Layout 计算真实表在数据源上的分布，按表序号排列，可用于生成建表语句
+func (c *AutoTableRuleConfig) Layout() ([]AutoTableNode, error) {
+	if c.LogicTable == "" {
+		return nil, fmt.Errorf("logic table is required")
+	}
+	if len(c.ActualDataSources) == 0 {
+		return nil, fmt.Errorf("actual data sources are required for auto table %s", c.LogicTable)
+	}
+	if c.ShardingCount <= 0 {
+		return nil, fmt.Errorf("sharding count of auto table %s must be positive", c.LogicTable)
+	}
+
+	seen := make(map[string]bool, len(c.ActualDataSources))
+	for _, dataSource := range c.ActualDataSources {
+		if dataSource == "" || seen[dataSource] {
+			return nil, fmt.Errorf("invalid or duplicate data source %q in auto table %s", dataSource, c.LogicTable)
+		}
+		seen[dataSource] = true
+	}
+
+	nodes := make([]AutoTableNode, c.ShardingCount)
+	for i := range nodes {
+		nodes[i] = AutoTableNode{
+			Index:      i,
+			DataSource: c.ActualDataSources[i%len(c.ActualDataSources)],
+			Table:      c.LogicTable + "_" + strconv.Itoa(i),
+		}
+	}
+	return nodes, nil
+}
+
+// TableRule 生成与自动分片表等价的表规则
+// 实际数据节点按表序号逐个列出，表分片策略为 standard 策略，不配置数据库分片策略，数据源由真实表唯一确定
+func (c *AutoTableRuleConfig) TableRule() (*TableRuleConfig, error) {
+	nodes, err := c.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if c.ShardingColumn == "" {
+		return nil, fmt.Errorf("sharding column is required for auto table %s", c.LogicTable)
+	}
+
+	dataNodes := make([]string, len(nodes))
+	for i, node := range nodes {
+		dataNodes[i] = node.DataSource + "." + node.Table
+	}
+
+	algorithmType := c.Algorithm
+	if algorithmType == "" {
+		algorithmType = defaultAutoTableAlgorithm
+	}
+	props := make(map[string]interface{}, len(c.Props)+1)
+	for key, value := range c.Props {
+		props[key] = value
+	}
+	if _, exists := props["sharding-count"]; !exists {
+		props["sharding-count"] = c.ShardingCount
+	}
+
+	return &TableRuleConfig{
+		LogicTable:      c.LogicTable,
+		ActualDataNodes: strings.Join(dataNodes, ","),
+		TableStrategy: &ShardingStrategyConfig{
+			ShardingColumn: c.ShardingColumn,
+			Algorithm:      algorithmType,
+			Type:           "standard",
+			Props:          props,
+		},
+		KeyGenerator: c.KeyGenerator,
+		autoTable:    true,
+	}, nil
+}
+
+// ResolveAutoTables 将自动分片表展开为 Tables 中的表规则，可重复调用
+// 自动分片表不能与 Tables 中手工配置的其他表同名
+func (c *ShardingRuleConfig) ResolveAutoTables() error {
+	rules, err := c.autoTableRules()
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	if c.Tables == nil {
+		c.Tables = make(map[string]*TableRuleConfig)
+	}
+	for name, tableRule := range rules {
+		c.Tables[name] = tableRule
+	}
+	return nil
+}
+
+// autoTableRules 生成自动分片表展开后的表规则，不修改配置
+func (c *ShardingRuleConfig) autoTableRules() (map[string]*TableRuleConfig, error) {
+	rules := make(map[string]*TableRuleConfig, len(c.AutoTables))
+	for name, autoTable := range c.AutoTables {
+		tableRule, err := autoTable.named(name).TableRule()
+		if err != nil {
+			return nil, fmt.Errorf("invalid auto table %s: %w", name, err)
+		}
+		// 保存后重新加载的配置中已包含展开的表规则，数据节点相同时视为同一张表
+		if existing, exists := c.Tables[name]; exists && !existing.autoTable && existing.ActualDataNodes != tableRule.ActualDataNodes {
+			return nil, fmt.Errorf("auto table %s conflicts with table rule of the same name", name)
+		}
+		rules[name] = tableRule
+	}
+	return rules, nil
+}
+
+// AutoTableLayout 获取自动分片表的真实表分布
+func (c *ShardingRuleConfig) AutoTableLayout(logicTable string) ([]AutoTableNode, error) {
+	autoTable, exists := c.AutoTables[logicTable]
+	if !exists {
+		return nil, fmt.Errorf("auto table not found: %s", logicTable)
+	}
+	return autoTable.named(logicTable).Layout()
+}
+
+// named 获取以 name 作为默认逻辑表名的自动分片表规则，已设置 LogicTable 时返回自身
+func (c *AutoTableRuleConfig) named(name string) *AutoTableRuleConfig {
+	if c.LogicTable != "" {
+		return c
+	}
+	named := *c
+	named.LogicTable = name
+	return &named
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoTableRuleConfig_Layout(t *testing.T) {
+	autoTable := &AutoTableRuleConfig{
+		LogicTable:        "t_order",
+		ActualDataSources: []string{"ds_0", "ds_1", "ds_2"},
+		ShardingColumn:    "order_id",
+		ShardingCount:     5,
+	}
+
+	nodes, err := autoTable.Layout()
+	require.NoError(t, err)
+	assert.Equal(t, []AutoTableNode{
+		{Index: 0, DataSource: "ds_0", Table: "t_order_0"},
+		{Index: 1, DataSource: "ds_1", Table: "t_order_1"},
+		{Index: 2, DataSource: "ds_2", Table: "t_order_2"},
+		{Index: 3, DataSource: "ds_0", Table: "t_order_3"},
+		{Index: 4, DataSource: "ds_1", Table: "t_order_4"},
+	}, nodes)
+
+	tableRule, err := autoTable.TableRule()
+	require.NoError(t, err)
+	assert.Equal(t, "ds_0.t_order_0,ds_1.t_order_1,ds_2.t_order_2,ds_0.t_order_3,ds_1.t_order_4", tableRule.ActualDataNodes)
+	assert.Nil(t, tableRule.DatabaseStrategy)
+	require.NotNil(t, tableRule.TableStrategy)
+	assert.Equal(t, "MOD", tableRule.TableStrategy.Algorithm)
+	assert.Equal(t, "standard", tableRule.TableStrategy.Type)
+	assert.Equal(t, 5, tableRule.TableStrategy.Props["sharding-count"])
+}
+
+func TestAutoTableRuleConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		autoTable AutoTableRuleConfig
+	}{
+		{"no data sources", AutoTableRuleConfig{LogicTable: "t", ShardingColumn: "id", ShardingCount: 4}},
+		{"non-positive count", AutoTableRuleConfig{LogicTable: "t", ActualDataSources: []string{"ds_0"}, ShardingColumn: "id"}},
+		{"duplicate data source", AutoTableRuleConfig{LogicTable: "t", ActualDataSources: []string{"ds_0", "ds_0"}, ShardingColumn: "id", ShardingCount: 4}},
+		{"no sharding column", AutoTableRuleConfig{LogicTable: "t", ActualDataSources: []string{"ds_0"}, ShardingCount: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.autoTable.TableRule()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestShardingRuleConfig_ResolveAutoTables(t *testing.T) {
+	rule := &ShardingRuleConfig{
+		Tables: map[string]*TableRuleConfig{
+			"t_user": {ActualDataNodes: "ds_0.t_user"},
+		},
+		AutoTables: map[string]*AutoTableRuleConfig{
+			"t_order": {
+				ActualDataSources: []string{"ds_0", "ds_1"},
+				ShardingColumn:    "order_id",
+				Algorithm:         "HASH_MOD",
+				ShardingCount:     4,
+				Props:             map[string]interface{}{"sharding-count": "4"},
+			},
+		},
+	}
+
+	require.NoError(t, rule.ResolveAutoTables())
+	require.NoError(t, rule.ResolveAutoTables())
+	require.Contains(t, rule.Tables, "t_order")
+	assert.Contains(t, rule.Tables, "t_user")
+	assert.Equal(t, "t_order", rule.Tables["t_order"].LogicTable)
+	assert.Equal(t, "HASH_MOD", rule.Tables["t_order"].TableStrategy.Algorithm)
+	assert.Equal(t, "4", rule.Tables["t_order"].TableStrategy.Props["sharding-count"])
+
+	nodes, err := rule.AutoTableLayout("t_order")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 4)
+	assert.Equal(t, "t_order_3", nodes[3].Table)
+	assert.Empty(t, rule.AutoTables["t_order"].LogicTable)
+	_, err = rule.AutoTableLayout("t_user")
+	assert.Error(t, err)
+
+	rule.Tables["t_order"] = &TableRuleConfig{ActualDataNodes: "ds_0.t_order_${0..3}"}
+	assert.Error(t, rule.ResolveAutoTables())
+}
+
+func TestShardingConfig_AutoTablesRoundTrip(t *testing.T) {
+	cfg := &ShardingConfig{
+		DataSources: map[string]*DataSourceConfig{
+			"ds_0": {DriverName: "mysql", URL: "root:@tcp(localhost:3306)/ds_0"},
+			"ds_1": {DriverName: "mysql", URL: "root:@tcp(localhost:3306)/ds_1"},
+		},
+		ShardingRule: &ShardingRuleConfig{
+			AutoTables: map[string]*AutoTableRuleConfig{
+				"t_order": {ActualDataSources: []string{"ds_0", "ds_1"}, ShardingColumn: "order_id", ShardingCount: 4},
+			},
+		},
+	}
+	// 校验不展开自动分片表
+	require.NoError(t, cfg.Validate())
+	assert.Empty(t, cfg.ShardingRule.Tables)
+	require.NoError(t, cfg.Normalize())
+
+	tmpFile, err := os.CreateTemp("", "test_auto_table_*.yaml")
+	require.NoError(t, err)
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	// 保存的配置同时包含自动分片表和展开的表规则，重新加载后仍然有效
+	require.NoError(t, cfg.SaveToYAML(tmpFile.Name()))
+	loaded, err := LoadFromYAML(tmpFile.Name())
+	require.NoError(t, err)
+	require.NoError(t, loaded.Validate())
+	assert.Equal(t, cfg.ShardingRule.Tables["t_order"].ActualDataNodes, loaded.ShardingRule.Tables["t_order"].ActualDataNodes)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
+	"time"
 )
 
 // DataSourceConfig 数据源配置
@@ -21,6 +22,13 @@ type ShardingStrategyConfig struct {
 	ShardingColumn string `yaml:"shardingColumn" json:"shardingColumn"`
 	Algorithm      string `yaml:"algorithm" json:"algorithm"`
 	Type           string `yaml:"type" json:"type"` // inline, standard, complex, hint
+	// Props standard 策略的算法属性，此时 Algorithm 为算法类型，如 INTERVAL
+	Props map[string]interface{} `yaml:"props" json:"props"`
+	// ColumnType 分片列类型，如 BIGINT、VARCHAR、UUID、TIMESTAMP、DECIMAL，路由前按该类型转换分片值
+	ColumnType string `yaml:"columnType,omitempty" json:"columnType,omitempty"`
+	// AlternateShardingColumns standard 策略的备选分片列，ShardingColumn 没有分片值时依次使用，
+	// 算法必须对这些列给出相同的目标，如 GENE_MOD 算法下由基因生成器生成的主键列
+	AlternateShardingColumns []string `yaml:"alternateShardingColumns,omitempty" json:"alternateShardingColumns,omitempty"`
 }
 
 // TableRuleConfig 表规则配置
@@ -30,17 +38,27 @@ type TableRuleConfig struct {
 	DatabaseStrategy *ShardingStrategyConfig `yaml:"databaseStrategy" json:"databaseStrategy"`
 	TableStrategy    *ShardingStrategyConfig `yaml:"tableStrategy" json:"tableStrategy"`
 	KeyGenerator     *KeyGeneratorConfig     `yaml:"keyGenerator" json:"keyGenerator"`
+	// PrimaryKeyColumns 主键列，支持复合主键，AT 模式按主键生成前后镜像；未配置时使用主键生成器的列
+	PrimaryKeyColumns []string `yaml:"primaryKeyColumns,omitempty" json:"primaryKeyColumns,omitempty"`
+	// autoTable 是否由自动分片表展开生成
+	autoTable bool
 }
 
 // KeyGeneratorConfig 主键生成器配置
 type KeyGeneratorConfig struct {
 	Column string `yaml:"column" json:"column"`
-	Type   string `yaml:"type" json:"type"` // snowflake, uuid, increment
+	Type   string `yaml:"type" json:"type"` // snowflake, uuid, uuidv7, ulid, ksuid, increment, gene, segment
+	// Props 生成器属性，gene 生成器使用 gene-column（提供基因的分片列）、gene-bits、worker-id 和 datacenter-id；
+	// segment 生成器使用 data-source（号段表所在数据源）、key、table、step、max-step 和 segment-duration；
+	// uuid、uuidv7、ulid 和 ksuid 生成器使用 format（string 或 binary）
+	Props map[string]interface{} `yaml:"props,omitempty" json:"props,omitempty"`
 }
 
 // ShardingRuleConfig 分片规则配置
 type ShardingRuleConfig struct {
 	Tables                map[string]*TableRuleConfig `yaml:"tables" json:"tables"`
+	// AutoTables 自动分片表，只需声明数据源和分片数量，由 Normalize 展开为 Tables 中的表规则
+	AutoTables map[string]*AutoTableRuleConfig `yaml:"autoTables" json:"autoTables"`
 	DefaultDatabaseStrategy *ShardingStrategyConfig   `yaml:"defaultDatabaseStrategy" json:"defaultDatabaseStrategy"`
 	DefaultTableStrategy    *ShardingStrategyConfig   `yaml:"defaultTableStrategy" json:"defaultTableStrategy"`
 	DefaultKeyGenerator     *KeyGeneratorConfig       `yaml:"defaultKeyGenerator" json:"defaultKeyGenerator"`
@@ -52,6 +70,96 @@ type ReadWriteSplitConfig struct {
 	MasterDataSource string   `yaml:"masterDataSource" json:"masterDataSource"`
 	SlaveDataSources []string `yaml:"slaveDataSources" json:"slaveDataSources"`
 	LoadBalanceAlgorithm string `yaml:"loadBalanceAlgorithm" json:"loadBalanceAlgorithm"`
+	// SlaveWeights 从库权重，用于 weight 负载均衡算法，未配置的从库权重为 1，权重为 0 的从库不接收读请求
+	SlaveWeights map[string]int `yaml:"slaveWeights,omitempty" json:"slaveWeights,omitempty"`
+	// HealthCheck 从库健康检查配置，为 nil 时不做后台健康检查
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
+	// ReplicationLag 从库复制延迟检测配置，为 nil 时不检测延迟
+	ReplicationLag *ReplicationLagConfig `yaml:"replicationLag,omitempty" json:"replicationLag,omitempty"`
+	// SessionConsistency 读己之写会话一致性配置，为 nil 时使用默认窗口且不跟踪写入位点
+	SessionConsistency *SessionConsistencyConfig `yaml:"sessionConsistency,omitempty" json:"sessionConsistency,omitempty"`
+}
+
+// SessionConsistencyConfig 读己之写会话一致性配置
+type SessionConsistencyConfig struct {
+	// Window 会话写入后读请求路由到主库的时间窗口，默认 5s
+	Window time.Duration `yaml:"window" json:"window"`
+	// TrackPosition 是否记录写入后的 GTID/LSN，从库回放到该位点后窗口内的读请求也可以使用从库
+	TrackPosition bool `yaml:"trackPosition" json:"trackPosition"`
+}
+
+// ReplicationLagConfig 从库复制延迟检测配置
+type ReplicationLagConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Interval 检测间隔
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	// Timeout 单次检测超时时间
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// MaxLagSeconds 允许的最大复制延迟（秒），超过的从库不接收读请求，0 表示不限制
+	MaxLagSeconds float64 `yaml:"maxLagSeconds" json:"maxLagSeconds"`
+}
+
+// HealthCheckConfig 从库健康检查配置
+type HealthCheckConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Interval 检查间隔，如 "5s"
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	// Timeout 单次检查超时时间
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// FailureThreshold 连续失败多少次后摘除从库
+	FailureThreshold int `yaml:"failureThreshold" json:"failureThreshold"`
+	// RecoveryThreshold 连续成功多少次后恢复从库
+	RecoveryThreshold int `yaml:"recoveryThreshold" json:"recoveryThreshold"`
+	// FallbackToMaster 没有健康的从库时是否将读请求路由到主库
+	FallbackToMaster bool `yaml:"fallbackToMaster" json:"fallbackToMaster"`
+}
+
+// GetSlaveWeight 获取从库权重，未配置时返回 1
+func (c *ReadWriteSplitConfig) GetSlaveWeight(slave string) int {
+	if weight, exists := c.SlaveWeights[slave]; exists {
+		return weight
+	}
+	return 1
+}
+
+// Validate 验证读写分离配置
+func (c *ReadWriteSplitConfig) Validate() error {
+	slaves := make(map[string]bool, len(c.SlaveDataSources))
+	for _, slave := range c.SlaveDataSources {
+		slaves[slave] = true
+	}
+
+	for slave, weight := range c.SlaveWeights {
+		if !slaves[slave] {
+			return fmt.Errorf("weight configured for unknown slave data source %s", slave)
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of slave data source %s must not be negative", slave)
+		}
+	}
+
+	if hc := c.HealthCheck; hc != nil {
+		if hc.Interval < 0 || hc.Timeout < 0 {
+			return fmt.Errorf("health check interval and timeout must not be negative")
+		}
+		if hc.FailureThreshold < 0 || hc.RecoveryThreshold < 0 {
+			return fmt.Errorf("health check thresholds must not be negative")
+		}
+	}
+
+	if lag := c.ReplicationLag; lag != nil {
+		if lag.Interval < 0 || lag.Timeout < 0 {
+			return fmt.Errorf("replication lag interval and timeout must not be negative")
+		}
+		if lag.MaxLagSeconds < 0 {
+			return fmt.Errorf("max lag seconds must not be negative")
+		}
+	}
+
+	if sc := c.SessionConsistency; sc != nil && sc.Window < 0 {
+		return fmt.Errorf("session consistency window must not be negative")
+	}
+	return nil
 }
 
 // ParserConfig 解析器配置
@@ -67,6 +175,7 @@ type ParserConfig struct {
 type ShardingConfig struct {
 	DataSources      map[string]*DataSourceConfig    `yaml:"dataSources" json:"dataSources"`
 	ShardingRule     *ShardingRuleConfig            `yaml:"shardingRule" json:"shardingRule"`
+	// ReadWriteSplits 读写分离组，键为组名，组名作为逻辑数据源在 actualDataNodes 中引用
 	ReadWriteSplits  map[string]*ReadWriteSplitConfig `yaml:"readWriteSplits" json:"readWriteSplits"`
 	Parser           *ParserConfig                   `yaml:"parser" json:"parser"`
 }
@@ -82,10 +191,27 @@ func LoadFromYAML(filename string) (*ShardingConfig, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if err := config.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
 
 	return &config, nil
 }
 
+// Normalize 补全可以由配置推导的默认值并展开自动分片表，加载配置和创建数据源时调用，可重复调用
+// 未设置 Name 的读写分离组使用组名
+func (c *ShardingConfig) Normalize() error {
+	for name, rwConfig := range c.ReadWriteSplits {
+		if rwConfig != nil && rwConfig.Name == "" {
+			rwConfig.Name = name
+		}
+	}
+	if c.ShardingRule != nil {
+		return c.ShardingRule.ResolveAutoTables()
+	}
+	return nil
+}
+
 // SaveToYAML 保存配置到 YAML 文件
 func (c *ShardingConfig) SaveToYAML(filename string) error {
 	data, err := yaml.Marshal(c)
@@ -142,7 +268,19 @@ func (c *ShardingConfig) Validate() error {
 		}
 	}
 
+	for name, rwConfig := range c.ReadWriteSplits {
+		if err := rwConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid read-write split %s: %w", name, err)
+		}
+	}
+	if err := c.ValidateReadWriteGroups(); err != nil {
+		return err
+	}
+
 	if c.ShardingRule != nil {
+		if _, err := c.ShardingRule.autoTableRules(); err != nil {
+			return err
+		}
 		for tableName, tableRule := range c.ShardingRule.Tables {
 			if tableRule.LogicTable == "" {
 				tableRule.LogicTable = tableName
@@ -154,4 +292,50 @@ func (c *ShardingConfig) Validate() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// ValidateReadWriteGroups 校验读写分离组，不修改配置
+// 组名是可以在 actualDataNodes 中引用的逻辑数据源，不能与物理数据源重名；
+// 主库和从库必须是已配置的物理数据源；设置了 Name 时必须与组名一致
+func (c *ShardingConfig) ValidateReadWriteGroups() error {
+	members := make(map[string]string)
+	for name, rwConfig := range c.ReadWriteSplits {
+		if rwConfig.Name != "" && rwConfig.Name != name {
+			return fmt.Errorf("read-write split %s has mismatched name %s", name, rwConfig.Name)
+		}
+		if _, exists := c.DataSources[name]; exists {
+			return fmt.Errorf("read-write split %s conflicts with data source of the same name", name)
+		}
+		if _, exists := c.DataSources[rwConfig.MasterDataSource]; !exists {
+			return fmt.Errorf("master data source %s of read-write split %s not found", rwConfig.MasterDataSource, name)
+		}
+		for _, slave := range rwConfig.SlaveDataSources {
+			if _, exists := c.DataSources[slave]; !exists {
+				return fmt.Errorf("slave data source %s of read-write split %s not found", slave, name)
+			}
+		}
+
+		for _, member := range append([]string{rwConfig.MasterDataSource}, rwConfig.SlaveDataSources...) {
+			if group, exists := members[member]; exists && group != name {
+				return fmt.Errorf("data source %s belongs to both read-write splits %s and %s", member, group, name)
+			}
+			members[member] = name
+		}
+	}
+	return nil
+}
+
+// ReadWriteGroupOf 获取物理数据源所属的读写分离组，不属于任何组时返回空字符串
+func (c *ShardingConfig) ReadWriteGroupOf(dataSource string) string {
+	for name, rwConfig := range c.ReadWriteSplits {
+		if rwConfig.MasterDataSource == dataSource {
+			return name
+		}
+		for _, slave := range rwConfig.SlaveDataSources {
+			if slave == dataSource {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -217,6 +217,131 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "actual data nodes is required for table t_order",
 		},
+		{
+			name: "negative slave weight",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0": {
+						DriverName: "mysql",
+						URL:        "root:@tcp(localhost:3306)/ds_0",
+					},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"rw_0": {
+						MasterDataSource: "ds_0",
+						SlaveDataSources: []string{"ds_0_slave"},
+						SlaveWeights:     map[string]int{"ds_0_slave": -1},
+					},
+				},
+			},
+			expectError: true,
+			errorMsg:    "invalid read-write split rw_0: weight of slave data source ds_0_slave must not be negative",
+		},
+		{
+			name: "weight for unknown slave",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0": {
+						DriverName: "mysql",
+						URL:        "root:@tcp(localhost:3306)/ds_0",
+					},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"rw_0": {
+						MasterDataSource: "ds_0",
+						SlaveDataSources: []string{"ds_0_slave"},
+						SlaveWeights:     map[string]int{"ds_1_slave": 2},
+					},
+				},
+			},
+			expectError: true,
+			errorMsg:    "weight configured for unknown slave data source ds_1_slave",
+		},
+		{
+			name: "valid read-write group",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "read-write group clashes with data source",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0_primary": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "read-write split ds_0_primary conflicts with data source of the same name",
+		},
+		{
+			name: "mismatched read-write group name",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0": {Name: "rw_0", MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "read-write split ds_0 has mismatched name rw_0",
+		},
+		{
+			name: "unknown master data source",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0": {MasterDataSource: "ds_1_primary", SlaveDataSources: []string{"ds_0_replica"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "master data source ds_1_primary of read-write split ds_0 not found",
+		},
+		{
+			name: "unknown slave data source",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_1_replica"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "slave data source ds_1_replica of read-write split ds_0 not found",
+		},
+		{
+			name: "data source in two read-write groups",
+			config: &ShardingConfig{
+				DataSources: map[string]*DataSourceConfig{
+					"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+					"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+					"ds_1_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_1"},
+				},
+				ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+					"ds_0": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+					"ds_1": {MasterDataSource: "ds_1_primary", SlaveDataSources: []string{"ds_0_replica"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "data source ds_0_replica belongs to both read-write splits",
+		},
 	}
 
 	for _, tt := range tests {
@@ -230,4 +355,24 @@ func TestValidate(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestShardingConfig_Normalize(t *testing.T) {
+	cfg := &ShardingConfig{
+		DataSources: map[string]*DataSourceConfig{
+			"ds_0_primary": {DriverName: "mysql", URL: "root:@tcp(primary:3306)/ds_0"},
+			"ds_0_replica": {DriverName: "mysql", URL: "root:@tcp(replica:3306)/ds_0"},
+		},
+		ReadWriteSplits: map[string]*ReadWriteSplitConfig{
+			"ds_0": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+		},
+	}
+
+	// 校验不修改配置
+	require.NoError(t, cfg.Validate())
+	assert.Empty(t, cfg.ReadWriteSplits["ds_0"].Name)
+
+	require.NoError(t, cfg.Normalize())
+	assert.Equal(t, "ds_0", cfg.ReadWriteSplits["ds_0"].Name)
+	require.NoError(t, cfg.Validate())
+}
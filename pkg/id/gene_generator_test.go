@@ -0,0 +1,92 @@
+package id
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGeneSnowflakeGenerator(t *testing.T) {
+	tests := []struct {
+		name         string
+		workerID     int64
+		datacenterID int64
+		geneBits     int
+		expectError  bool
+	}{
+		{"valid parameters", 1, 1, 4, false},
+		{"maximum gene bits", 31, 31, MaxGeneBits, false},
+		{"zero gene bits", 1, 1, 0, true},
+		{"too many gene bits", 1, 1, MaxGeneBits + 1, true},
+		{"invalid worker ID", 32, 1, 4, true},
+		{"invalid datacenter ID", 1, -1, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewGeneSnowflakeGenerator(tt.workerID, tt.datacenterID, tt.geneBits)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.geneBits, generator.GeneBits())
+		})
+	}
+}
+
+func TestGeneSnowflakeGenerator_NextIDWithGene(t *testing.T) {
+	generator, err := NewGeneSnowflakeGenerator(3, 2, 4)
+	require.NoError(t, err)
+
+	_, err = generator.NextID()
+	assert.Error(t, err)
+
+	// 每毫秒只有 8 个序列号，生成足够多的 ID 以覆盖序列号溢出
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		userID := int64(1000 + i)
+		id, err := generator.NextIDWithGene(userID)
+		require.NoError(t, err)
+		assert.Greater(t, id, int64(0))
+		assert.Equal(t, GeneOf(userID, 4), GeneOf(id, 4))
+		assert.Equal(t, int64(3), (id>>12)&31, "worker ID")
+		assert.Equal(t, int64(2), (id>>17)&31, "datacenter ID")
+		assert.False(t, seen[id], "duplicate ID %d", id)
+		seen[id] = true
+	}
+
+	// 负数按补码取低位
+	id, err := generator.NextIDWithGene(-1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), GeneOf(id, 4))
+}
+
+func TestValidateGeneBits(t *testing.T) {
+	assert.NoError(t, ValidateGeneBits(4, 16))
+	assert.NoError(t, ValidateGeneBits(4, 10))
+	assert.Error(t, ValidateGeneBits(3, 16))
+	assert.Error(t, ValidateGeneBits(4, 0))
+	assert.Error(t, ValidateGeneBits(MaxGeneBits+1, 16))
+}
+
+func TestGeneratorFactory_CreateGeneGenerator(t *testing.T) {
+	factory := NewGeneratorFactory()
+
+	generator, err := factory.CreateGenerator("gene", map[string]interface{}{
+		"workerID":      1,
+		"datacenterID":  int64(2),
+		"geneBits":      4,
+		"shardingCount": 16,
+	})
+	require.NoError(t, err)
+	geneGenerator, ok := generator.(GeneAwareGenerator)
+	require.True(t, ok)
+	assert.Equal(t, 4, geneGenerator.GeneBits())
+
+	_, err = factory.CreateGenerator("gene", map[string]interface{}{"geneBits": 3, "shardingCount": 16})
+	assert.Error(t, err)
+	_, err = factory.CreateGenerator("gene", nil)
+	assert.Error(t, err)
+}
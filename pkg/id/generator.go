@@ -14,32 +14,66 @@ type Generator interface {
 
 // SnowflakeGenerator 雪花算法 ID 生成器
 type SnowflakeGenerator struct {
-	mutex       sync.Mutex
-	epoch       int64 // 起始时间戳 (毫秒)
-	workerID    int64 // 工作节点 ID
-	datacenterID int64 // 数据中心 ID
-	sequence    int64 // 序列号
-	lastTime    int64 // 上次生成 ID 的时间戳
+	mutex            sync.Mutex
+	epoch            int64 // 起始时间戳 (毫秒)
+	workerID         int64 // 工作节点 ID
+	datacenterID     int64 // 数据中心 ID
+	sequence         int64 // 序列号
+	lastTime         int64 // 上次生成 ID 的时间戳
+	layout           SnowflakeLayout
+	maxClockBackward time.Duration
+	clock            func() int64 // 当前毫秒时间戳
+	sleep            func(time.Duration)
 }
 
 // NewSnowflakeGenerator 创建雪花算法生成器
 func NewSnowflakeGenerator(workerID, datacenterID int64) (*SnowflakeGenerator, error) {
-	if workerID < 0 || workerID > 31 {
-		return nil, fmt.Errorf("worker ID must be between 0 and 31")
+	return NewSnowflakeGeneratorWithOptions(workerID, datacenterID, SnowflakeOptions{})
+}
+
+// NewSnowflakeGeneratorWithOptions 按位布局、起始时间和时钟回拨容忍时长创建雪花算法生成器
+func NewSnowflakeGeneratorWithOptions(workerID, datacenterID int64, options SnowflakeOptions) (*SnowflakeGenerator, error) {
+	layout := options.Layout
+	if layout == (SnowflakeLayout{}) {
+		layout = DefaultSnowflakeLayout
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	if workerID < 0 || workerID > layout.MaxWorkerID() {
+		return nil, fmt.Errorf("worker ID must be between 0 and %d", layout.MaxWorkerID())
+	}
+	if datacenterID < 0 || datacenterID > layout.MaxDatacenterID() {
+		return nil, fmt.Errorf("datacenter ID must be between 0 and %d", layout.MaxDatacenterID())
 	}
-	if datacenterID < 0 || datacenterID > 31 {
-		return nil, fmt.Errorf("datacenter ID must be between 0 and 31")
+
+	// 使用 2020-01-01 00:00:00 UTC 作为默认起始时间
+	epoch := options.Epoch
+	if epoch.IsZero() {
+		epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
 	}
 
-	// 使用 2020-01-01 00:00:00 UTC 作为起始时间
-	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / 1e6
+	maxClockBackward := options.MaxClockBackward
+	if maxClockBackward == 0 {
+		maxClockBackward = DefaultMaxClockBackward
+	}
+
+	// 上次运行可能已用完 LastTimestamp 这一毫秒的序列号，视为序列号已用尽，该毫秒内的请求等待下一毫秒
+	var sequence int64
+	if options.LastTimestamp > 0 {
+		sequence = layout.MaxSequence()
+	}
 
 	return &SnowflakeGenerator{
-		epoch:        epoch,
-		workerID:     workerID,
-		datacenterID: datacenterID,
-		sequence:     0,
-		lastTime:     0,
+		epoch:            epoch.UnixMilli(),
+		workerID:         workerID,
+		datacenterID:     datacenterID,
+		sequence:         sequence,
+		lastTime:         options.LastTimestamp,
+		layout:           layout,
+		maxClockBackward: maxClockBackward,
+		clock:            func() int64 { return time.Now().UnixMilli() },
+		sleep:            time.Sleep,
 	}, nil
 }
 
@@ -48,33 +82,55 @@ func (g *SnowflakeGenerator) NextID() (int64, error) {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	now := time.Now().UnixNano() / 1e6 // 毫秒时间戳
+	now := g.clock() // 毫秒时间戳
 
 	if now < g.lastTime {
-		return 0, fmt.Errorf("clock moved backwards, refusing to generate ID")
+		// 小幅时钟回拨时等待时钟追上上次的时间戳
+		backward := time.Duration(g.lastTime-now) * time.Millisecond
+		if backward > g.maxClockBackward {
+			return 0, fmt.Errorf("clock moved backwards by %s, refusing to generate ID", backward)
+		}
+		g.sleep(backward)
+		if now = g.clock(); now < g.lastTime {
+			return 0, fmt.Errorf("clock moved backwards by %s, refusing to generate ID", time.Duration(g.lastTime-now)*time.Millisecond)
+		}
 	}
 
 	if now == g.lastTime {
-		g.sequence = (g.sequence + 1) & 4095 // 12 位序列号
+		g.sequence = (g.sequence + 1) & g.layout.MaxSequence()
 		if g.sequence == 0 {
 			// 序列号溢出，等待下一毫秒
 			for now <= g.lastTime {
-				now = time.Now().UnixNano() / 1e6
+				now = g.clock()
 			}
 		}
 	} else {
 		g.sequence = 0
 	}
 
+	timestamp := now - g.epoch
+	if timestamp < 0 || timestamp > g.layout.maxTimestamp() {
+		return 0, fmt.Errorf("timestamp %d is out of range of %d timestamp bits", timestamp, g.layout.TimestampBits)
+	}
+
 	g.lastTime = now
 
 	// 组装 64 位 ID
-	// 1 位符号位(0) + 41 位时间戳 + 5 位数据中心 ID + 5 位工作节点 ID + 12 位序列号
-	id := ((now - g.epoch) << 22) | (g.datacenterID << 17) | (g.workerID << 12) | g.sequence
+	// 1 位符号位(0) + 时间戳 + 数据中心 ID + 工作节点 ID + 序列号，默认各为 41、5、5、12 位
+	sequenceShift := g.layout.SequenceBits
+	workerShift := sequenceShift + g.layout.WorkerBits
+	id := (timestamp << (workerShift + g.layout.DatacenterBits)) | (g.datacenterID << workerShift) | (g.workerID << sequenceShift) | g.sequence
 
 	return id, nil
 }
 
+// LastTimestamp 获取最后一次生成 ID 使用的时间戳（Unix 毫秒），重启时通过 SnowflakeOptions.LastTimestamp 传入可避免时间戳倒退
+func (g *SnowflakeGenerator) LastTimestamp() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.lastTime
+}
+
 // UUIDGenerator UUID 生成器
 type UUIDGenerator struct {
 }
@@ -85,6 +141,7 @@ func NewUUIDGenerator() *UUIDGenerator {
 }
 
 // NextID 生成下一个 ID (返回 UUID 的哈希值)
+// 只保留了 UUID 的前 8 个字符，需要完整 UUID 作为主键时使用 UUIDv4Generator 或 UUIDv7Generator
 func (g *UUIDGenerator) NextID() (int64, error) {
 	uuid, err := g.generateUUID()
 	if err != nil {
@@ -174,22 +231,19 @@ func (f *GeneratorFactory) GetGenerator(name string) (Generator, bool) {
 func (f *GeneratorFactory) CreateGenerator(generatorType string, config map[string]interface{}) (Generator, error) {
 	switch generatorType {
 	case "snowflake":
-		workerID := int64(0)
-		datacenterID := int64(0)
-		
-		if val, ok := config["workerID"]; ok {
-			if id, ok := val.(int64); ok {
-				workerID = id
-			}
-		}
-		if val, ok := config["datacenterID"]; ok {
-			if id, ok := val.(int64); ok {
-				datacenterID = id
-			}
-		}
-		
-		return NewSnowflakeGenerator(workerID, datacenterID)
-		
+		layout := DefaultSnowflakeLayout
+		layout.TimestampBits = int(configInt64(config, "timestampBits", int64(layout.TimestampBits)))
+		layout.DatacenterBits = int(configInt64(config, "datacenterBits", int64(layout.DatacenterBits)))
+		layout.WorkerBits = int(configInt64(config, "workerBits", int64(layout.WorkerBits)))
+		layout.SequenceBits = int(configInt64(config, "sequenceBits", int64(layout.SequenceBits)))
+		maxClockBackward, _ := config["maxClockBackward"].(time.Duration)
+
+		return NewSnowflakeGeneratorWithOptions(configInt64(config, "workerID", 0), configInt64(config, "datacenterID", 0), SnowflakeOptions{
+			Layout:           layout,
+			MaxClockBackward: maxClockBackward,
+			LastTimestamp:    configInt64(config, "lastTimestamp", 0),
+		})
+
 	case "uuid":
 		return NewUUIDGenerator(), nil
 		
@@ -209,12 +263,48 @@ func (f *GeneratorFactory) CreateGenerator(generatorType string, config map[stri
 		}
 		
 		return NewIncrementGenerator(start, step), nil
-		
+
+	case "gene":
+		workerID := configInt64(config, "workerID", 0)
+		datacenterID := configInt64(config, "datacenterID", 0)
+		geneBits := int(configInt64(config, "geneBits", 0))
+
+		// 配置了分片数量时检查基因位能否区分所有分片
+		if shardingCount := int(configInt64(config, "shardingCount", 0)); shardingCount > 0 {
+			if err := ValidateGeneBits(geneBits, shardingCount); err != nil {
+				return nil, err
+			}
+		}
+
+		return NewGeneSnowflakeGenerator(workerID, datacenterID, geneBits)
+
+	case "segment":
+		store, _ := config["store"].(SegmentStore)
+		key, _ := config["key"].(string)
+		duration, _ := config["duration"].(time.Duration)
+
+		return NewSegmentGenerator(store, key, SegmentOptions{
+			Step:     configInt64(config, "step", 0),
+			MaxStep:  configInt64(config, "maxStep", 0),
+			Duration: duration,
+		})
+
 	default:
 		return nil, fmt.Errorf("unsupported generator type: %s", generatorType)
 	}
 }
 
+// configInt64 读取 int 或 int64 类型的生成器配置，未配置时返回默认值
+func configInt64(config map[string]interface{}, key string, defaultValue int64) int64 {
+	switch v := config[key].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return defaultValue
+}
+
 // DefaultGeneratorFactory 默认生成器工厂实例
 var DefaultGeneratorFactory = NewGeneratorFactory()
 
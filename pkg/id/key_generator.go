@@ -0,0 +1,274 @@
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyGenerator 通用主键生成器接口，生成的主键为 int64、string 或 []byte
+type KeyGenerator interface {
+	NextKey() (interface{}, error)
+}
+
+// KeyFormat 字符串主键生成器的输出格式
+type KeyFormat string
+
+const (
+	// KeyFormatString 输出文本形式，如 UUID 的标准格式、ULID 的 Crockford Base32 编码
+	KeyFormatString KeyFormat = "string"
+	// KeyFormatBinary 输出原始字节，UUID 和 ULID 为 16 字节，KSUID 为 20 字节
+	KeyFormatBinary KeyFormat = "binary"
+)
+
+// ParseKeyFormat 解析主键输出格式，为空时返回 KeyFormatString
+func ParseKeyFormat(name string) (KeyFormat, error) {
+	switch format := KeyFormat(strings.ToLower(name)); format {
+	case "", KeyFormatString:
+		return KeyFormatString, nil
+	case KeyFormatBinary:
+		return KeyFormatBinary, nil
+	default:
+		return "", fmt.Errorf("unsupported key format: %s", name)
+	}
+}
+
+// stringKeyGeneratorTypes 生成字符串或二进制主键的生成器类型
+var stringKeyGeneratorTypes = map[string]bool{"uuid": true, "uuidv4": true, "uuidv7": true, "ulid": true, "ksuid": true}
+
+// IsStringKeyGeneratorType 判断生成器类型是否生成字符串或二进制主键
+func IsStringKeyGeneratorType(generatorType string) bool {
+	return stringKeyGeneratorTypes[generatorType]
+}
+
+// int64KeyGenerator 将 int64 生成器适配为通用主键生成器
+type int64KeyGenerator struct {
+	generator Generator
+}
+
+// AsKeyGenerator 将 int64 生成器适配为通用主键生成器
+func AsKeyGenerator(generator Generator) KeyGenerator {
+	return &int64KeyGenerator{generator: generator}
+}
+
+// NextKey 生成下一个 int64 主键
+func (g *int64KeyGenerator) NextKey() (interface{}, error) {
+	return g.generator.NextID()
+}
+
+// UUIDv4Generator 随机 UUID (版本 4) 主键生成器
+type UUIDv4Generator struct {
+	format KeyFormat
+}
+
+// NewUUIDv4Generator 创建随机 UUID 主键生成器
+func NewUUIDv4Generator(format KeyFormat) *UUIDv4Generator {
+	return &UUIDv4Generator{format: format}
+}
+
+// NextKey 生成下一个 UUID
+func (g *UUIDv4Generator) NextKey() (interface{}, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return nil, err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // Version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // Variant 10
+	return formatUUID(u, g.format), nil
+}
+
+// UUIDv7Generator 按时间排序的 UUID (版本 7) 主键生成器
+// 高 48 位为 Unix 毫秒时间戳，同一毫秒内 12 位 rand_a 作为递增计数器，保证同一个生成器生成的 UUID 严格递增
+type UUIDv7Generator struct {
+	mutex    sync.Mutex
+	format   KeyFormat
+	lastTime int64 // 上次生成使用的毫秒时间戳
+	counter  int64 // 当前毫秒内的计数器
+	clock    func() int64
+}
+
+// NewUUIDv7Generator 创建按时间排序的 UUID 主键生成器
+func NewUUIDv7Generator(format KeyFormat) *UUIDv7Generator {
+	return &UUIDv7Generator{
+		format: format,
+		clock:  func() int64 { return time.Now().UnixMilli() },
+	}
+}
+
+// NextKey 生成下一个 UUID
+func (g *UUIDv7Generator) NextKey() (interface{}, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	now := g.clock()
+	if now > g.lastTime {
+		// 新的毫秒从随机值开始计数，保留一半空间用于同一毫秒内递增
+		g.lastTime = now
+		g.counter = int64(binary.BigEndian.Uint16(u[6:8]) & 0x7ff)
+	} else {
+		// 同一毫秒或时钟回拨时沿用上次的时间戳，计数器溢出时借用下一毫秒
+		g.counter++
+		if g.counter > 0xfff {
+			g.lastTime++
+			g.counter = 0
+		}
+	}
+	timestamp, counter := g.lastTime, g.counter
+	g.mutex.Unlock()
+
+	binary.BigEndian.PutUint16(u[0:2], uint16(timestamp>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(timestamp))
+	binary.BigEndian.PutUint16(u[6:8], 0x7000|uint16(counter)) // Version 7
+	u[8] = (u[8] & 0x3f) | 0x80                                // Variant 10
+	return formatUUID(u, g.format), nil
+}
+
+// formatUUID 按输出格式返回 UUID
+func formatUUID(u [16]byte, format KeyFormat) interface{} {
+	if format == KeyFormatBinary {
+		return u[:]
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// crockfordAlphabet ULID 使用的 Crockford Base32 字符表
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator ULID 主键生成器
+// 高 48 位为 Unix 毫秒时间戳，低 80 位随机；同一毫秒内随机部分递增，保证同一个生成器生成的 ULID 严格递增，
+// 文本形式为 26 个字符的 Crockford Base32 编码，字典序与生成顺序一致
+type ULIDGenerator struct {
+	mutex    sync.Mutex
+	format   KeyFormat
+	lastTime int64
+	entropy  [10]byte // 上次生成使用的随机部分
+	clock    func() int64
+}
+
+// NewULIDGenerator 创建 ULID 主键生成器
+func NewULIDGenerator(format KeyFormat) *ULIDGenerator {
+	return &ULIDGenerator{
+		format: format,
+		clock:  func() int64 { return time.Now().UnixMilli() },
+	}
+}
+
+// NextKey 生成下一个 ULID
+func (g *ULIDGenerator) NextKey() (interface{}, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := g.clock()
+	if now > g.lastTime {
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			return nil, err
+		}
+		g.lastTime = now
+	} else if !incrementBytes(g.entropy[:]) {
+		// 同一毫秒内随机部分溢出，借用下一毫秒
+		g.lastTime++
+	}
+
+	var u [16]byte
+	binary.BigEndian.PutUint16(u[0:2], uint16(g.lastTime>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(g.lastTime))
+	copy(u[6:], g.entropy[:])
+	if g.format == KeyFormatBinary {
+		return u[:], nil
+	}
+	return encodeBase(u[:], 32, crockfordAlphabet, 26), nil
+}
+
+// incrementBytes 将大端字节序的无符号整数加一，溢出时返回 false
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ksuidEpoch KSUID 时间戳的起始时间 (Unix 秒)
+const ksuidEpoch = 1400000000
+
+// base62Alphabet KSUID 使用的 Base62 字符表
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUIDGenerator KSUID 主键生成器
+// 20 字节：4 字节自 2014-05-13 起的秒级时间戳和 16 字节随机数，文本形式为 27 个字符的 Base62 编码，按秒粒度排序
+type KSUIDGenerator struct {
+	format KeyFormat
+	clock  func() int64 // 当前 Unix 秒
+}
+
+// NewKSUIDGenerator 创建 KSUID 主键生成器
+func NewKSUIDGenerator(format KeyFormat) *KSUIDGenerator {
+	return &KSUIDGenerator{
+		format: format,
+		clock:  func() int64 { return time.Now().Unix() },
+	}
+}
+
+// NextKey 生成下一个 KSUID
+func (g *KSUIDGenerator) NextKey() (interface{}, error) {
+	var k [20]byte
+	binary.BigEndian.PutUint32(k[0:4], uint32(g.clock()-ksuidEpoch))
+	if _, err := rand.Read(k[4:]); err != nil {
+		return nil, err
+	}
+	if g.format == KeyFormatBinary {
+		return k[:], nil
+	}
+	return encodeBase(k[:], 62, base62Alphabet, 27), nil
+}
+
+// encodeBase 将大端字节序的无符号整数按字符表编码，左侧补零到 width 个字符
+func encodeBase(data []byte, base int64, alphabet string, width int) string {
+	n := new(big.Int).SetBytes(data)
+	radix, mod := big.NewInt(base), new(big.Int)
+	encoded := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		n.QuoRem(n, radix, mod)
+		encoded[i] = alphabet[mod.Int64()]
+	}
+	return string(encoded)
+}
+
+// CreateKeyGenerator 根据类型创建通用主键生成器
+// uuid (uuidv4)、uuidv7、ulid 和 ksuid 生成字符串主键，config["format"] 为 binary 时生成二进制主键；
+// 其他类型按 CreateGenerator 创建 int64 生成器
+func (f *GeneratorFactory) CreateKeyGenerator(generatorType string, config map[string]interface{}) (KeyGenerator, error) {
+	if !IsStringKeyGeneratorType(generatorType) {
+		generator, err := f.CreateGenerator(generatorType, config)
+		if err != nil {
+			return nil, err
+		}
+		return AsKeyGenerator(generator), nil
+	}
+
+	formatName, _ := config["format"].(string)
+	format, err := ParseKeyFormat(formatName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch generatorType {
+	case "uuidv7":
+		return NewUUIDv7Generator(format), nil
+	case "ulid":
+		return NewULIDGenerator(format), nil
+	case "ksuid":
+		return NewKSUIDGenerator(format), nil
+	default:
+		return NewUUIDv4Generator(format), nil
+	}
+}
@@ -0,0 +1,159 @@
+package id
+
+import (
+	"go-sharding/pkg/algorithm"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextKeys 生成 count 个主键
+func nextKeys(t *testing.T, generator KeyGenerator, count int) []interface{} {
+	keys := make([]interface{}, count)
+	for i := range keys {
+		key, err := generator.NextKey()
+		require.NoError(t, err)
+		keys[i] = key
+	}
+	return keys
+}
+
+// assertStrictlyAscending 检查字符串主键严格递增且不重复
+func assertStrictlyAscending(t *testing.T, keys []interface{}) {
+	for i := 1; i < len(keys); i++ {
+		assert.Less(t, keys[i-1].(string), keys[i].(string))
+	}
+}
+
+func TestParseKeyFormat(t *testing.T) {
+	format, err := ParseKeyFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, KeyFormatString, format)
+	format, err = ParseKeyFormat("BINARY")
+	require.NoError(t, err)
+	assert.Equal(t, KeyFormatBinary, format)
+	_, err = ParseKeyFormat("base64")
+	assert.Error(t, err)
+}
+
+func TestUUIDv4Generator(t *testing.T) {
+	key, err := NewUUIDv4Generator(KeyFormatString).NextKey()
+	require.NoError(t, err)
+	u, err := algorithm.ParseUUID(key.(string))
+	require.NoError(t, err)
+	assert.Equal(t, key, u.String())
+	assert.Equal(t, byte(0x40), u[6]&0xf0)
+	assert.Equal(t, byte(0x80), u[8]&0xc0)
+
+	key, err = NewUUIDv4Generator(KeyFormatBinary).NextKey()
+	require.NoError(t, err)
+	assert.Len(t, key, 16)
+}
+
+func TestUUIDv7Generator(t *testing.T) {
+	generator := NewUUIDv7Generator(KeyFormatString)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	generator.clock = func() int64 { return now }
+
+	// 同一毫秒内超过计数器容量时仍然严格递增
+	keys := nextKeys(t, generator, 5000)
+	assertStrictlyAscending(t, keys)
+
+	u, err := algorithm.ParseUUID(keys[0].(string))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x70), u[6]&0xf0)
+	assert.Equal(t, byte(0x80), u[8]&0xc0)
+	timestamp := int64(u[0])<<40 | int64(u[1])<<32 | int64(u[2])<<24 | int64(u[3])<<16 | int64(u[4])<<8 | int64(u[5])
+	assert.Equal(t, now, timestamp)
+
+	// 时钟回拨时不会生成更小的 UUID
+	now -= 1000
+	assertStrictlyAscending(t, append(keys[len(keys)-1:], nextKeys(t, generator, 10)...))
+}
+
+func TestULIDGenerator(t *testing.T) {
+	generator := NewULIDGenerator(KeyFormatString)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	generator.clock = func() int64 { return now }
+
+	keys := nextKeys(t, generator, 100)
+	assertStrictlyAscending(t, keys)
+	for _, key := range keys {
+		assert.Len(t, key, 26)
+		assert.Empty(t, strings.Trim(key.(string), crockfordAlphabet))
+	}
+	// 前 10 个字符编码 48 位时间戳
+	assert.Equal(t, "01HK153X00", keys[0].(string)[:10])
+
+	now++
+	next, err := generator.NextKey()
+	require.NoError(t, err)
+	assert.Equal(t, "01HK153X01", next.(string)[:10])
+
+	binary, err := NewULIDGenerator(KeyFormatBinary).NextKey()
+	require.NoError(t, err)
+	assert.Len(t, binary, 16)
+}
+
+func TestKSUIDGenerator(t *testing.T) {
+	generator := NewKSUIDGenerator(KeyFormatString)
+	second := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	generator.clock = func() int64 { return second }
+
+	keys := nextKeys(t, generator, 50)
+	second++
+	later := nextKeys(t, generator, 50)
+	for _, key := range append(keys, later...) {
+		assert.Len(t, key, 27)
+	}
+
+	// 不同秒生成的 KSUID 按时间排序
+	sorted := append(append([]interface{}{}, later...), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].(string) < sorted[j].(string) })
+	assert.ElementsMatch(t, keys, sorted[:50])
+
+	binary, err := NewKSUIDGenerator(KeyFormatBinary).NextKey()
+	require.NoError(t, err)
+	assert.Len(t, binary, 20)
+}
+
+func TestEncodeBase(t *testing.T) {
+	assert.Equal(t, "00000000000000000000000000", encodeBase(make([]byte, 16), 32, crockfordAlphabet, 26))
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeBase([]byte(strings.Repeat("\xff", 16)), 32, crockfordAlphabet, 26))
+	assert.Equal(t, "aWgEPTl1tmebfsQzFP4bxwgy80V", encodeBase([]byte(strings.Repeat("\xff", 20)), 62, base62Alphabet, 27))
+}
+
+func TestGeneratorFactory_CreateKeyGenerator(t *testing.T) {
+	factory := NewGeneratorFactory()
+
+	tests := []struct {
+		generatorType string
+		config        map[string]interface{}
+		check         func(t *testing.T, key interface{})
+	}{
+		{"uuid", nil, func(t *testing.T, key interface{}) { assert.Len(t, key, 36) }},
+		{"uuidv7", map[string]interface{}{"format": "binary"}, func(t *testing.T, key interface{}) { assert.Len(t, key, 16) }},
+		{"ulid", nil, func(t *testing.T, key interface{}) { assert.Len(t, key, 26) }},
+		{"ksuid", nil, func(t *testing.T, key interface{}) { assert.Len(t, key, 27) }},
+		{"increment", nil, func(t *testing.T, key interface{}) { assert.Equal(t, int64(2), key) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.generatorType, func(t *testing.T) {
+			generator, err := factory.CreateKeyGenerator(tt.generatorType, tt.config)
+			require.NoError(t, err)
+			key, err := generator.NextKey()
+			require.NoError(t, err)
+			tt.check(t, key)
+		})
+	}
+
+	_, err := factory.CreateKeyGenerator("ulid", map[string]interface{}{"format": "hex"})
+	assert.Error(t, err)
+	_, err = factory.CreateKeyGenerator("unsupported", nil)
+	assert.Error(t, err)
+}
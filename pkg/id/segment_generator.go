@@ -0,0 +1,233 @@
+package id
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-sharding/pkg/database"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSegmentTable 号段表的默认表名
+	DefaultSegmentTable = "sequence"
+	// DefaultSegmentStep 默认号段步长
+	DefaultSegmentStep = 1000
+	// DefaultSegmentDuration 默认号段目标消耗时长，号段消耗快于该时长时步长加倍，慢于两倍时步长减半
+	DefaultSegmentDuration = 15 * time.Minute
+	// segmentPreloadRatio 当前号段剩余不足该比例时异步加载下一个号段
+	segmentPreloadRatio = 0.9
+)
+
+// SegmentStore 号段存储，按 key 原子地预留一段连续 ID
+type SegmentStore interface {
+	// Allocate 将 key 的最大 ID 增加 step 并返回增加后的最大 ID，预留的号段为 (maxID-step, maxID]
+	Allocate(ctx context.Context, key string, step int64) (maxID int64, err error)
+}
+
+// DBSegmentStore 基于数据库号段表的存储，号段表结构：
+//
+//	CREATE TABLE sequence (biz_tag VARCHAR(128) PRIMARY KEY, max_id BIGINT NOT NULL)
+//
+// 每个 key 需要预先插入一行，max_id 为已经分配出去的最大 ID
+type DBSegmentStore struct {
+	db           *sql.DB
+	table        string
+	databaseType database.DatabaseType
+}
+
+// NewDBSegmentStore 创建数据库号段存储，table 为空时使用 DefaultSegmentTable
+func NewDBSegmentStore(db *sql.DB, databaseType database.DatabaseType, table string) *DBSegmentStore {
+	if table == "" {
+		table = DefaultSegmentTable
+	}
+	return &DBSegmentStore{
+		db:           db,
+		table:        table,
+		databaseType: databaseType,
+	}
+}
+
+// Allocate 在事务中执行 UPDATE ... SET max_id = max_id + step 并读取更新后的 max_id
+func (s *DBSegmentStore) Allocate(ctx context.Context, key string, step int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin segment transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	update := fmt.Sprintf("UPDATE %s SET max_id = max_id + %s WHERE biz_tag = %s", s.table, s.placeholder(1), s.placeholder(2))
+	result, err := tx.ExecContext(ctx, update, step, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update segment of %s: %w", key, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return 0, fmt.Errorf("segment key %s not found in table %s", key, s.table)
+	}
+
+	var maxID int64
+	query := fmt.Sprintf("SELECT max_id FROM %s WHERE biz_tag = %s", s.table, s.placeholder(1))
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&maxID); err != nil {
+		return 0, fmt.Errorf("failed to read segment of %s: %w", key, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit segment of %s: %w", key, err)
+	}
+	return maxID, nil
+}
+
+// placeholder 获取第 index 个参数的占位符
+func (s *DBSegmentStore) placeholder(index int) string {
+	return sqlPlaceholder(s.databaseType, index)
+}
+
+// SegmentOptions 号段生成器选项
+type SegmentOptions struct {
+	Step     int64         // 初始步长，也是自适应调整的最小步长
+	MaxStep  int64         // 自适应调整的最大步长，默认为 Step 的 100 倍
+	Duration time.Duration // 号段目标消耗时长
+}
+
+// segment 已预留的号段 [next, max]
+type segment struct {
+	next int64 // 下一个可分配的 ID
+	max  int64 // 号段的最大 ID
+	step int64 // 号段大小
+}
+
+// remaining 获取号段剩余的 ID 数量
+func (s *segment) remaining() int64 {
+	return s.max - s.next + 1
+}
+
+// SegmentGenerator 号段模式 ID 生成器
+// 从号段存储批量预留连续 ID，在内存中递增分配，生成的 ID 在所有节点间全局唯一且趋势递增；
+// 当前号段消耗到一定比例时异步预留下一个号段（双缓冲），并按号段消耗速度自适应调整步长
+type SegmentGenerator struct {
+	mutex    sync.Mutex
+	loaded   *sync.Cond
+	store    SegmentStore
+	key      string
+	options  SegmentOptions
+	current  *segment
+	next     *segment
+	loading  bool
+	loadErr  error
+	step     int64     // 下一次预留使用的步长
+	lastLoad time.Time // 上一次预留号段的时间
+	now      func() time.Time
+}
+
+// NewSegmentGenerator 创建号段生成器，首次生成 ID 时才预留号段
+func NewSegmentGenerator(store SegmentStore, key string, options SegmentOptions) (*SegmentGenerator, error) {
+	if store == nil {
+		return nil, fmt.Errorf("segment store is required")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("segment key is required")
+	}
+	if options.Step == 0 {
+		options.Step = DefaultSegmentStep
+	}
+	if options.Step < 0 {
+		return nil, fmt.Errorf("segment step must be positive")
+	}
+	if options.MaxStep == 0 {
+		options.MaxStep = options.Step * 100
+	}
+	if options.MaxStep < options.Step {
+		return nil, fmt.Errorf("segment max step %d is less than step %d", options.MaxStep, options.Step)
+	}
+	if options.Duration <= 0 {
+		options.Duration = DefaultSegmentDuration
+	}
+
+	g := &SegmentGenerator{
+		store:   store,
+		key:     key,
+		options: options,
+		step:    options.Step,
+		now:     time.Now,
+	}
+	g.loaded = sync.NewCond(&g.mutex)
+	return g, nil
+}
+
+// NextID 生成下一个 ID
+func (g *SegmentGenerator) NextID() (int64, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for {
+		if g.current != nil && g.current.remaining() > 0 {
+			id := g.current.next
+			g.current.next++
+			if g.next == nil && !g.loading && float64(g.current.remaining()) < float64(g.current.step)*segmentPreloadRatio {
+				g.startLoad()
+			}
+			return id, nil
+		}
+
+		// 当前号段用尽，切换到已预留的下一个号段
+		if g.next != nil {
+			g.current, g.next = g.next, nil
+			continue
+		}
+
+		if !g.loading {
+			g.startLoad()
+		}
+		g.loaded.Wait()
+		if g.next == nil && g.loadErr != nil {
+			err := g.loadErr
+			g.loadErr = nil
+			return 0, err
+		}
+	}
+}
+
+// Step 获取下一次预留号段使用的步长
+func (g *SegmentGenerator) Step() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.step
+}
+
+// startLoad 异步预留下一个号段，调用方必须持有锁
+func (g *SegmentGenerator) startLoad() {
+	g.loading = true
+	step := g.adjustStep()
+
+	go func() {
+		maxID, err := g.store.Allocate(context.Background(), g.key, step)
+
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.loading = false
+		if err != nil {
+			g.loadErr = fmt.Errorf("failed to allocate segment of %s: %w", g.key, err)
+		} else {
+			g.loadErr = nil
+			g.next = &segment{next: maxID - step + 1, max: maxID, step: step}
+		}
+		g.loaded.Broadcast()
+	}()
+}
+
+// adjustStep 按上一个号段的消耗时长调整步长并返回本次使用的步长，调用方必须持有锁
+func (g *SegmentGenerator) adjustStep() int64 {
+	now := g.now()
+	if !g.lastLoad.IsZero() {
+		elapsed := now.Sub(g.lastLoad)
+		switch {
+		case elapsed < g.options.Duration && g.step*2 <= g.options.MaxStep:
+			g.step *= 2
+		case elapsed >= 2*g.options.Duration && g.step/2 >= g.options.Step:
+			g.step /= 2
+		}
+	}
+	g.lastLoad = now
+	return g.step
+}
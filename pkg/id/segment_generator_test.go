@@ -0,0 +1,319 @@
+package id
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySegmentStore 内存号段存储，记录每次预留的步长
+type memorySegmentStore struct {
+	mu     sync.Mutex
+	maxIDs map[string]int64
+	steps  []int64
+	err    error
+	block  chan struct{} // 非空时预留号段前等待
+}
+
+func newMemorySegmentStore() *memorySegmentStore {
+	return &memorySegmentStore{maxIDs: make(map[string]int64)}
+}
+
+func (s *memorySegmentStore) Allocate(ctx context.Context, key string, step int64) (int64, error) {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.steps = append(s.steps, step)
+	s.maxIDs[key] += step
+	return s.maxIDs[key], nil
+}
+
+func (s *memorySegmentStore) allocatedSteps() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.steps...)
+}
+
+// waitForSegments 等待号段存储完成 count 次预留
+func waitForSegments(t *testing.T, store *memorySegmentStore, count int) {
+	require.Eventually(t, func() bool { return len(store.allocatedSteps()) >= count }, time.Second, time.Millisecond)
+}
+
+func TestNewSegmentGenerator(t *testing.T) {
+	store := newMemorySegmentStore()
+	tests := []struct {
+		name        string
+		store       SegmentStore
+		key         string
+		options     SegmentOptions
+		expectError bool
+	}{
+		{"default options", store, "t_order", SegmentOptions{}, false},
+		{"custom step", store, "t_order", SegmentOptions{Step: 10, MaxStep: 10}, false},
+		{"missing store", nil, "t_order", SegmentOptions{}, true},
+		{"missing key", store, "", SegmentOptions{}, true},
+		{"negative step", store, "t_order", SegmentOptions{Step: -1}, true},
+		{"max step less than step", store, "t_order", SegmentOptions{Step: 10, MaxStep: 5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSegmentGenerator(tt.store, tt.key, tt.options)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSegmentGenerator_NextID(t *testing.T) {
+	store := newMemorySegmentStore()
+	generator, err := NewSegmentGenerator(store, "t_order", SegmentOptions{Step: 10, MaxStep: 10})
+	require.NoError(t, err)
+
+	// 生成的 ID 连续递增，跨越多个号段
+	for i := int64(1); i <= 35; i++ {
+		id, err := generator.NextID()
+		require.NoError(t, err)
+		assert.Equal(t, i, id)
+	}
+	assert.Len(t, store.allocatedSteps(), 4)
+
+	// 不同的 key 使用独立的号段
+	other, err := NewSegmentGenerator(store, "t_user", SegmentOptions{Step: 10})
+	require.NoError(t, err)
+	id, err := other.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
+
+func TestSegmentGenerator_PreloadsNextSegment(t *testing.T) {
+	store := newMemorySegmentStore()
+	generator, err := NewSegmentGenerator(store, "t_order", SegmentOptions{Step: 10, MaxStep: 10})
+	require.NoError(t, err)
+
+	_, err = generator.NextID()
+	require.NoError(t, err)
+	assert.Len(t, store.allocatedSteps(), 1)
+
+	// 消耗超过 10% 后在后台预留下一个号段，当前号段用尽时无需等待存储
+	_, err = generator.NextID()
+	require.NoError(t, err)
+	waitForSegments(t, store, 2)
+
+	store.block = make(chan struct{})
+	defer close(store.block)
+	for i := int64(3); i <= 20; i++ {
+		id, err := generator.NextID()
+		require.NoError(t, err)
+		assert.Equal(t, i, id)
+	}
+}
+
+func TestSegmentGenerator_ConcurrentNextID(t *testing.T) {
+	store := newMemorySegmentStore()
+	generator, err := NewSegmentGenerator(store, "t_order", SegmentOptions{Step: 16})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				id, err := generator.NextID()
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				assert.False(t, seen[id], "duplicate ID %d", id)
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 1600)
+}
+
+func TestSegmentGenerator_AdaptiveStep(t *testing.T) {
+	store := newMemorySegmentStore()
+	generator, err := NewSegmentGenerator(store, "t_order", SegmentOptions{Step: 10, MaxStep: 40, Duration: time.Minute})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	generator.now = func() time.Time { return now }
+
+	consume := func(count int) {
+		for i := 0; i < count; i++ {
+			_, err := generator.NextID()
+			require.NoError(t, err)
+		}
+	}
+
+	// 号段在目标时长内消耗完时步长加倍，直到最大步长
+	consume(2)
+	waitForSegments(t, store, 2)
+	consume(20)
+	waitForSegments(t, store, 3)
+	assert.Equal(t, int64(40), generator.Step())
+
+	// 号段消耗时长超过目标时长两倍时步长减半
+	now = now.Add(3 * time.Minute)
+	consume(40)
+	waitForSegments(t, store, 4)
+	assert.Equal(t, int64(20), generator.Step())
+	assert.Equal(t, []int64{10, 20, 40, 20}, store.allocatedSteps())
+}
+
+func TestSegmentGenerator_AllocateError(t *testing.T) {
+	store := newMemorySegmentStore()
+	store.err = errors.New("connection refused")
+	generator, err := NewSegmentGenerator(store, "t_order", SegmentOptions{Step: 10})
+	require.NoError(t, err)
+
+	_, err = generator.NextID()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+
+	// 存储恢复后继续生成
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+	id, err := generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
+
+var sequenceTables = struct {
+	sync.Mutex
+	maxIDs     map[string]map[string]int64
+	statements map[string][]string
+}{maxIDs: make(map[string]map[string]int64), statements: make(map[string][]string)}
+
+// handleSequence 模拟号段表，按 DSN 保存每个 biz_tag 的 max_id 并记录执行的语句
+func handleSequence(dsn, query string, args []driver.Value) sqltest.Result {
+	switch query {
+	case "BEGIN", "COMMIT", "ROLLBACK":
+		return sqltest.Result{}
+	}
+
+	sequenceTables.Lock()
+	defer sequenceTables.Unlock()
+	sequenceTables.statements[dsn] = append(sequenceTables.statements[dsn], query)
+
+	maxIDs := sequenceTables.maxIDs[dsn]
+	switch {
+	case strings.HasPrefix(query, "UPDATE"):
+		key := args[1].(string)
+		if _, exists := maxIDs[key]; !exists {
+			return sqltest.Result{}
+		}
+		maxIDs[key] += args[0].(int64)
+		return sqltest.Result{Affected: 1}
+	case strings.HasPrefix(query, "SELECT max_id"):
+		return sqltest.Result{Columns: []string{"max_id"}, Rows: [][]driver.Value{{maxIDs[args[0].(string)]}}}
+	}
+	return sqltest.Result{Err: errors.New("unexpected query: " + query)}
+}
+
+func init() {
+	sql.Register("id-sequence", &sqltest.Driver{Handler: handleSequence})
+}
+
+// openSequenceDB 打开包含给定号段行的模拟数据库
+func openSequenceDB(t *testing.T, maxIDs map[string]int64) *sql.DB {
+	dsn := t.Name()
+	sequenceTables.Lock()
+	sequenceTables.maxIDs[dsn] = maxIDs
+	sequenceTables.statements[dsn] = nil
+	sequenceTables.Unlock()
+
+	db, err := sql.Open("id-sequence", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDBSegmentStore_Allocate(t *testing.T) {
+	tests := []struct {
+		name         string
+		databaseType database.DatabaseType
+		table        string
+		expected     []string
+	}{
+		{
+			name:         "mysql",
+			databaseType: database.MySQL,
+			expected: []string{
+				"UPDATE sequence SET max_id = max_id + ? WHERE biz_tag = ?",
+				"SELECT max_id FROM sequence WHERE biz_tag = ?",
+			},
+		},
+		{
+			name:         "postgresql custom table",
+			databaseType: database.PostgreSQL,
+			table:        "id_segment",
+			expected: []string{
+				"UPDATE id_segment SET max_id = max_id + $1 WHERE biz_tag = $2",
+				"SELECT max_id FROM id_segment WHERE biz_tag = $1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewDBSegmentStore(openSequenceDB(t, map[string]int64{"t_order": 100}), tt.databaseType, tt.table)
+
+			maxID, err := store.Allocate(context.Background(), "t_order", 50)
+			require.NoError(t, err)
+			assert.Equal(t, int64(150), maxID)
+
+			sequenceTables.Lock()
+			assert.Equal(t, tt.expected, sequenceTables.statements[t.Name()])
+			sequenceTables.Unlock()
+
+			_, err = store.Allocate(context.Background(), "t_user", 50)
+			assert.ErrorContains(t, err, "segment key t_user not found")
+		})
+	}
+}
+
+func TestGeneratorFactory_CreateSegmentGenerator(t *testing.T) {
+	factory := NewGeneratorFactory()
+	store := NewDBSegmentStore(openSequenceDB(t, map[string]int64{"t_order": 0}), database.MySQL, "")
+
+	generator, err := factory.CreateGenerator("segment", map[string]interface{}{
+		"store": store,
+		"key":   "t_order",
+		"step":  int64(100),
+	})
+	require.NoError(t, err)
+	for i := int64(1); i <= 3; i++ {
+		id, err := generator.NextID()
+		require.NoError(t, err)
+		assert.Equal(t, i, id)
+	}
+
+	_, err = factory.CreateGenerator("segment", map[string]interface{}{"key": "t_order"})
+	assert.Error(t, err)
+}
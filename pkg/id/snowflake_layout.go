@@ -0,0 +1,68 @@
+package id
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultMaxClockBackward 默认容忍的时钟回拨时长，回拨不超过该时长时等待时钟追上
+const DefaultMaxClockBackward = 10 * time.Millisecond
+
+// SnowflakeLayout 雪花算法 ID 的位布局，从高到低依次为时间戳、数据中心 ID、工作节点 ID 和序列号，最高位为符号位
+type SnowflakeLayout struct {
+	TimestampBits  int // 毫秒时间戳位数
+	DatacenterBits int // 数据中心 ID 位数，可以为 0
+	WorkerBits     int // 工作节点 ID 位数，可以为 0
+	SequenceBits   int // 每毫秒序列号位数
+}
+
+// DefaultSnowflakeLayout 默认位布局：41 位时间戳 + 5 位数据中心 ID + 5 位工作节点 ID + 12 位序列号
+var DefaultSnowflakeLayout = SnowflakeLayout{TimestampBits: 41, DatacenterBits: 5, WorkerBits: 5, SequenceBits: 12}
+
+// Validate 检查位布局，各部分位数之和不能超过 63
+func (l SnowflakeLayout) Validate() error {
+	if l.TimestampBits < 1 || l.SequenceBits < 1 {
+		return fmt.Errorf("snowflake timestamp and sequence bits must be positive")
+	}
+	if l.DatacenterBits < 0 || l.WorkerBits < 0 {
+		return fmt.Errorf("snowflake datacenter and worker bits must not be negative")
+	}
+	if total := l.TimestampBits + l.DatacenterBits + l.WorkerBits + l.SequenceBits; total > 63 {
+		return fmt.Errorf("snowflake layout uses %d bits, at most 63 are available", total)
+	}
+	return nil
+}
+
+// MaxWorkerID 获取工作节点 ID 的最大值
+func (l SnowflakeLayout) MaxWorkerID() int64 {
+	return int64(1)<<l.WorkerBits - 1
+}
+
+// MaxDatacenterID 获取数据中心 ID 的最大值
+func (l SnowflakeLayout) MaxDatacenterID() int64 {
+	return int64(1)<<l.DatacenterBits - 1
+}
+
+// MaxSequence 获取序列号的最大值
+func (l SnowflakeLayout) MaxSequence() int64 {
+	return int64(1)<<l.SequenceBits - 1
+}
+
+// maxTimestamp 获取相对起始时间的时间戳最大值
+func (l SnowflakeLayout) maxTimestamp() int64 {
+	return int64(1)<<l.TimestampBits - 1
+}
+
+// SnowflakeOptions 雪花算法生成器选项
+type SnowflakeOptions struct {
+	// Layout 位布局，零值使用 DefaultSnowflakeLayout
+	Layout SnowflakeLayout
+	// Epoch 起始时间，零值为 2020-01-01 00:00:00 UTC
+	Epoch time.Time
+	// MaxClockBackward 容忍的时钟回拨时长，零值使用 DefaultMaxClockBackward，负数表示时钟回拨时立即返回错误
+	MaxClockBackward time.Duration
+	// LastTimestamp 上次运行最后使用的时间戳（Unix 毫秒），重启后生成的 ID 时间戳不早于它
+	LastTimestamp int64
+	// LeaseSafetyMargin 租约到期前停止生成 ID 的提前量，只用于 NewLeasedSnowflakeGenerator，零值使用 DefaultWorkerLeaseSafetyMargin
+	LeaseSafetyMargin time.Duration
+}
@@ -0,0 +1,156 @@
+package id
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock 可控的毫秒时钟，sleep 时按睡眠时长推进
+type fakeClock struct {
+	now       int64
+	slept     []time.Duration
+	frozen    bool // 为 true 时 sleep 不推进时钟
+	tickEvery int  // 大于 0 时每读取 tickEvery 次推进 1 毫秒，模拟等待下一毫秒时时钟走动
+	reads     int
+}
+
+func (c *fakeClock) install(g *SnowflakeGenerator) {
+	g.clock = func() int64 {
+		c.reads++
+		if c.tickEvery > 0 && c.reads%c.tickEvery == 0 {
+			c.now++
+		}
+		return c.now
+	}
+	g.sleep = func(d time.Duration) {
+		c.slept = append(c.slept, d)
+		if !c.frozen {
+			c.now += d.Milliseconds()
+		}
+	}
+}
+
+func TestSnowflakeLayout_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		layout      SnowflakeLayout
+		expectError bool
+	}{
+		{"default layout", DefaultSnowflakeLayout, false},
+		{"no datacenter bits", SnowflakeLayout{TimestampBits: 41, WorkerBits: 10, SequenceBits: 12}, false},
+		{"too many bits", SnowflakeLayout{TimestampBits: 42, DatacenterBits: 5, WorkerBits: 5, SequenceBits: 12}, true},
+		{"no sequence bits", SnowflakeLayout{TimestampBits: 41, DatacenterBits: 5, WorkerBits: 5}, true},
+		{"negative worker bits", SnowflakeLayout{TimestampBits: 41, WorkerBits: -1, SequenceBits: 12}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.layout.Validate()
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewSnowflakeGeneratorWithOptions_Layout(t *testing.T) {
+	layout := SnowflakeLayout{TimestampBits: 41, WorkerBits: 10, SequenceBits: 12}
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := NewSnowflakeGeneratorWithOptions(1024, 0, SnowflakeOptions{Layout: layout})
+	assert.ErrorContains(t, err, "worker ID must be between 0 and 1023")
+	_, err = NewSnowflakeGeneratorWithOptions(1, 1, SnowflakeOptions{Layout: layout})
+	assert.ErrorContains(t, err, "datacenter ID must be between 0 and 0")
+
+	generator, err := NewSnowflakeGeneratorWithOptions(1000, 0, SnowflakeOptions{Layout: layout, Epoch: epoch})
+	require.NoError(t, err)
+	clock := &fakeClock{now: epoch.UnixMilli() + 1234}
+	clock.install(generator)
+
+	id, err := generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), id>>22)
+	assert.Equal(t, int64(1000), (id>>12)&1023)
+	assert.Equal(t, int64(0), id&4095)
+
+	id, err = generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id&4095)
+	assert.Equal(t, clock.now, generator.LastTimestamp())
+}
+
+func TestSnowflakeGenerator_ClockBackward(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxClockBackward time.Duration
+		backward         int64
+		frozen           bool
+		expectError      bool
+	}{
+		{"small jump waits", 0, 5, false, false},
+		{"jump beyond tolerance", 0, 11, false, true},
+		{"custom tolerance", time.Second, 500, false, false},
+		{"tolerance disabled", -1, 1, false, true},
+		{"clock still behind after wait", 0, 5, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSnowflakeGeneratorWithOptions(1, 1, SnowflakeOptions{MaxClockBackward: tt.maxClockBackward})
+			require.NoError(t, err)
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), frozen: tt.frozen}
+			clock.install(generator)
+
+			first, err := generator.NextID()
+			require.NoError(t, err)
+			clock.now -= tt.backward
+
+			second, err := generator.NextID()
+			if tt.expectError {
+				assert.ErrorContains(t, err, "clock moved backwards")
+				return
+			}
+			require.NoError(t, err)
+			assert.Greater(t, second, first)
+			assert.Equal(t, []time.Duration{time.Duration(tt.backward) * time.Millisecond}, clock.slept)
+		})
+	}
+}
+
+func TestSnowflakeGenerator_LastTimestampOnRestart(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	// 上次运行的最后时间戳比当前时钟晚时，重启后等待时钟追上，不会生成时间戳更早的 ID；
+	// 追上后仍处于上次运行的最后一毫秒，继续等待下一毫秒
+	generator, err := NewSnowflakeGeneratorWithOptions(1, 1, SnowflakeOptions{LastTimestamp: now + 3})
+	require.NoError(t, err)
+	clock := &fakeClock{now: now, tickEvery: 3}
+	clock.install(generator)
+
+	_, err = generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, now+4, generator.LastTimestamp())
+	assert.Equal(t, []time.Duration{3 * time.Millisecond}, clock.slept)
+}
+
+func TestSnowflakeGenerator_LastTimestampSameMillisecond(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	layout := SnowflakeLayout{TimestampBits: 41, WorkerBits: 10, SequenceBits: 12}
+
+	// 时钟停在上次运行的最后一毫秒时，不使用该毫秒内上次运行可能已用过的序列号
+	generator, err := NewSnowflakeGeneratorWithOptions(1, 0, SnowflakeOptions{Layout: layout, LastTimestamp: now})
+	require.NoError(t, err)
+	clock := &fakeClock{now: now, tickEvery: 2}
+	clock.install(generator)
+
+	id, err := generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, now+1, generator.LastTimestamp())
+	assert.Equal(t, int64(0), id&layout.MaxSequence())
+	assert.Empty(t, clock.slept)
+}
@@ -0,0 +1,353 @@
+package id
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"go-sharding/pkg/database"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWorkerTable 工作节点租约表的默认表名
+	DefaultWorkerTable = "snowflake_worker"
+	// DefaultWorkerLeaseTTL 默认工作节点租约有效期
+	DefaultWorkerLeaseTTL = time.Minute
+	// DefaultWorkerLeaseSafetyMargin 默认租约安全余量，租约到期前这段时间内不再生成 ID，用于容忍节点之间的时钟偏差
+	DefaultWorkerLeaseSafetyMargin = 5 * time.Second
+)
+
+// WorkerLease 工作节点 ID 租约，持有期间其他节点不会分配到同一个工作节点 ID
+type WorkerLease interface {
+	// WorkerID 获取租用的工作节点 ID
+	WorkerID() int64
+	// LastTimestamp 获取该工作节点 ID 上一次记录的最后使用时间戳（Unix 毫秒），没有记录时为 0
+	LastTimestamp() int64
+	// ExpiresAt 获取租约在本地时钟下的过期时间，零值表示租约在释放前一直有效
+	ExpiresAt() time.Time
+	// Renew 续租并记录最后使用的时间戳
+	Renew(ctx context.Context, lastTimestamp int64) error
+	// Release 记录最后使用的时间戳并释放租约
+	Release(ctx context.Context, lastTimestamp int64) error
+}
+
+// WorkerIDAssigner 工作节点 ID 分配器
+type WorkerIDAssigner interface {
+	// Acquire 租用 [0, maxWorkerID] 中一个未被占用的工作节点 ID
+	Acquire(ctx context.Context, maxWorkerID int64) (WorkerLease, error)
+}
+
+// LeasedSnowflakeGenerator 通过租约分配工作节点 ID 的雪花算法生成器
+// 创建时租用工作节点 ID，并从上一个持有者记录的时间戳继续生成，避免重启后时间戳倒退产生重复 ID；
+// 后台定期续租并记录最后使用的时间戳，续租失败或租约即将到期时拒绝生成 ID，
+// 避免续租阻塞超过有效期后其他节点接管同一个工作节点 ID 产生重复 ID
+type LeasedSnowflakeGenerator struct {
+	*SnowflakeGenerator
+	lease        WorkerLease
+	safetyMargin time.Duration
+	now          func() time.Time
+	mutex        sync.Mutex
+	leaseErr     error // 租约失效的原因
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewLeasedSnowflakeGenerator 租用工作节点 ID 并创建雪花算法生成器，renewInterval 为续租间隔，不大于 0 时不续租
+func NewLeasedSnowflakeGenerator(ctx context.Context, assigner WorkerIDAssigner, datacenterID int64, options SnowflakeOptions, renewInterval time.Duration) (*LeasedSnowflakeGenerator, error) {
+	layout := options.Layout
+	if layout == (SnowflakeLayout{}) {
+		layout = DefaultSnowflakeLayout
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	lease, err := assigner.Acquire(ctx, layout.MaxWorkerID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire worker ID: %w", err)
+	}
+
+	safetyMargin := options.LeaseSafetyMargin
+	if safetyMargin == 0 {
+		safetyMargin = DefaultWorkerLeaseSafetyMargin
+	}
+	options.Layout = layout
+	options.LastTimestamp = max(options.LastTimestamp, lease.LastTimestamp())
+	generator, err := NewSnowflakeGeneratorWithOptions(lease.WorkerID(), datacenterID, options)
+	if err != nil {
+		lease.Release(ctx, options.LastTimestamp)
+		return nil, err
+	}
+
+	g := &LeasedSnowflakeGenerator{
+		SnowflakeGenerator: generator,
+		lease:              lease,
+		safetyMargin:       safetyMargin,
+		now:                time.Now,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+	if err := g.checkExpiry(); err != nil {
+		lease.Release(ctx, options.LastTimestamp)
+		return nil, fmt.Errorf("%w, the lease TTL must be longer than the safety margin %s", err, safetyMargin)
+	}
+	if renewInterval > 0 {
+		go g.renewLoop(renewInterval)
+	} else {
+		close(g.done)
+	}
+	return g, nil
+}
+
+// NextID 生成下一个 ID，租约失效或距离到期不足安全余量时返回错误
+func (g *LeasedSnowflakeGenerator) NextID() (int64, error) {
+	g.mutex.Lock()
+	leaseErr := g.leaseErr
+	g.mutex.Unlock()
+	if leaseErr != nil {
+		return 0, leaseErr
+	}
+	if err := g.checkExpiry(); err != nil {
+		return 0, err
+	}
+	return g.SnowflakeGenerator.NextID()
+}
+
+// checkExpiry 检查租约距离到期是否还有安全余量
+func (g *LeasedSnowflakeGenerator) checkExpiry() error {
+	expiresAt := g.lease.ExpiresAt()
+	if expiresAt.IsZero() || g.now().Before(expiresAt.Add(-g.safetyMargin)) {
+		return nil
+	}
+	return fmt.Errorf("worker lease %d expires at %s", g.lease.WorkerID(), expiresAt.Format(time.RFC3339Nano))
+}
+
+// WorkerID 获取租用的工作节点 ID
+func (g *LeasedSnowflakeGenerator) WorkerID() int64 {
+	return g.lease.WorkerID()
+}
+
+// Close 停止续租，记录最后使用的时间戳并释放租约
+func (g *LeasedSnowflakeGenerator) Close() error {
+	g.mutex.Lock()
+	if g.leaseErr == nil {
+		g.leaseErr = fmt.Errorf("worker lease %d released", g.lease.WorkerID())
+	}
+	select {
+	case <-g.stop:
+		g.mutex.Unlock()
+		return nil
+	default:
+		close(g.stop)
+	}
+	g.mutex.Unlock()
+
+	<-g.done
+	return g.lease.Release(context.Background(), g.LastTimestamp())
+}
+
+// renewLoop 定期续租
+func (g *LeasedSnowflakeGenerator) renewLoop(interval time.Duration) {
+	defer close(g.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			if err := g.renew(); err != nil {
+				g.mutex.Lock()
+				g.leaseErr = fmt.Errorf("worker lease %d lost: %w", g.lease.WorkerID(), err)
+				g.mutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// renew 续租一次，有过期时间的租约必须在进入安全余量之前完成续租，之后的续租结果已经没有意义
+func (g *LeasedSnowflakeGenerator) renew() error {
+	ctx := context.Background()
+	if expiresAt := g.lease.ExpiresAt(); !expiresAt.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiresAt.Add(-g.safetyMargin))
+		defer cancel()
+	}
+	return g.lease.Renew(ctx, g.LastTimestamp())
+}
+
+// SQLWorkerIDAssigner 基于数据库租约表的工作节点 ID 分配器，租约表结构：
+//
+//	CREATE TABLE snowflake_worker (worker_id INT PRIMARY KEY, owner VARCHAR(128) NOT NULL,
+//	    expires_at BIGINT NOT NULL, last_timestamp BIGINT NOT NULL)
+//
+// 没有记录的工作节点 ID 通过插入获取，已过期的租约通过带过期时间条件的更新接管
+type SQLWorkerIDAssigner struct {
+	db           *sql.DB
+	databaseType database.DatabaseType
+	table        string
+	ttl          time.Duration
+	owner        string
+	now          func() time.Time
+}
+
+// NewSQLWorkerIDAssigner 创建数据库工作节点 ID 分配器，table 为空时使用 DefaultWorkerTable，ttl 不大于 0 时使用 DefaultWorkerLeaseTTL
+func NewSQLWorkerIDAssigner(db *sql.DB, databaseType database.DatabaseType, table string, ttl time.Duration) *SQLWorkerIDAssigner {
+	if table == "" {
+		table = DefaultWorkerTable
+	}
+	if ttl <= 0 {
+		ttl = DefaultWorkerLeaseTTL
+	}
+	return &SQLWorkerIDAssigner{
+		db:           db,
+		databaseType: databaseType,
+		table:        table,
+		ttl:          ttl,
+		owner:        newLeaseOwner(),
+		now:          time.Now,
+	}
+}
+
+// Acquire 租用第一个没有记录或租约已过期的工作节点 ID
+func (a *SQLWorkerIDAssigner) Acquire(ctx context.Context, maxWorkerID int64) (WorkerLease, error) {
+	type workerRow struct {
+		expiresAt     int64
+		lastTimestamp int64
+	}
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf("SELECT worker_id, expires_at, last_timestamp FROM %s", a.table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query worker leases: %w", err)
+	}
+	workers := make(map[int64]workerRow)
+	for rows.Next() {
+		var workerID int64
+		var row workerRow
+		if err := rows.Scan(&workerID, &row.expiresAt, &row.lastTimestamp); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan worker lease: %w", err)
+		}
+		workers[workerID] = row
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query worker leases: %w", err)
+	}
+
+	now := a.now().UnixMilli()
+	expiresAt := now + a.ttl.Milliseconds()
+	for workerID := int64(0); workerID <= maxWorkerID; workerID++ {
+		row, exists := workers[workerID]
+		if !exists {
+			// 并发插入同一个工作节点 ID 时主键冲突，继续尝试下一个
+			insert := fmt.Sprintf("INSERT INTO %s (worker_id, owner, expires_at, last_timestamp) VALUES (%s, %s, %s, 0)",
+				a.table, a.placeholder(1), a.placeholder(2), a.placeholder(3))
+			if _, err := a.db.ExecContext(ctx, insert, workerID, a.owner, expiresAt); err == nil {
+				return &sqlWorkerLease{assigner: a, workerID: workerID, expiresAt: time.UnixMilli(expiresAt)}, nil
+			}
+			continue
+		}
+		if row.expiresAt >= now {
+			continue
+		}
+
+		// 过期时间作为乐观锁条件，并发接管同一个过期租约时只有一个节点成功
+		update := fmt.Sprintf("UPDATE %s SET owner = %s, expires_at = %s WHERE worker_id = %s AND expires_at = %s",
+			a.table, a.placeholder(1), a.placeholder(2), a.placeholder(3), a.placeholder(4))
+		result, err := a.db.ExecContext(ctx, update, a.owner, expiresAt, workerID, row.expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to take over worker lease %d: %w", workerID, err)
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 1 {
+			return &sqlWorkerLease{assigner: a, workerID: workerID, lastTimestamp: row.lastTimestamp, expiresAt: time.UnixMilli(expiresAt)}, nil
+		}
+	}
+	return nil, fmt.Errorf("no free worker ID between 0 and %d in table %s", maxWorkerID, a.table)
+}
+
+// placeholder 获取第 index 个参数的占位符
+func (a *SQLWorkerIDAssigner) placeholder(index int) string {
+	return sqlPlaceholder(a.databaseType, index)
+}
+
+// sqlWorkerLease 数据库租约表中的工作节点租约
+type sqlWorkerLease struct {
+	assigner      *SQLWorkerIDAssigner
+	workerID      int64
+	lastTimestamp int64
+	mutex         sync.Mutex
+	expiresAt     time.Time // 最近一次成功写入租约表的过期时间
+}
+
+// WorkerID 获取租用的工作节点 ID
+func (l *sqlWorkerLease) WorkerID() int64 {
+	return l.workerID
+}
+
+// LastTimestamp 获取接管租约时记录的最后使用时间戳
+func (l *sqlWorkerLease) LastTimestamp() int64 {
+	return l.lastTimestamp
+}
+
+// ExpiresAt 获取最近一次成功写入租约表的过期时间
+func (l *sqlWorkerLease) ExpiresAt() time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.expiresAt
+}
+
+// Renew 延长租约有效期并记录最后使用的时间戳，租约已被其他节点接管时返回错误
+// 过期时间在执行更新之前计算，更新耗时越长，本地记录的过期时间越保守
+func (l *sqlWorkerLease) Renew(ctx context.Context, lastTimestamp int64) error {
+	a := l.assigner
+	expiresAt := a.now().Add(a.ttl).UnixMilli()
+	if err := l.update(ctx, "renew", expiresAt, lastTimestamp); err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	l.expiresAt = time.UnixMilli(expiresAt)
+	l.mutex.Unlock()
+	return nil
+}
+
+// Release 记录最后使用的时间戳并使租约立即过期
+func (l *sqlWorkerLease) Release(ctx context.Context, lastTimestamp int64) error {
+	return l.update(ctx, "release", 0, lastTimestamp)
+}
+
+// update 更新本节点持有的租约
+func (l *sqlWorkerLease) update(ctx context.Context, action string, expiresAt, lastTimestamp int64) error {
+	a := l.assigner
+	update := fmt.Sprintf("UPDATE %s SET expires_at = %s, last_timestamp = %s WHERE worker_id = %s AND owner = %s",
+		a.table, a.placeholder(1), a.placeholder(2), a.placeholder(3), a.placeholder(4))
+	result, err := a.db.ExecContext(ctx, update, expiresAt, lastTimestamp, l.workerID, a.owner)
+	if err != nil {
+		return fmt.Errorf("failed to %s worker lease %d: %w", action, l.workerID, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("worker lease %d is held by another owner", l.workerID)
+	}
+	return nil
+}
+
+// newLeaseOwner 生成租约持有者标识：主机名、进程号和随机后缀
+func newLeaseOwner() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// sqlPlaceholder 获取第 index 个参数的占位符，PostgreSQL 使用 $n，其他数据库使用 ?
+func sqlPlaceholder(databaseType database.DatabaseType, index int) string {
+	if databaseType == database.PostgreSQL {
+		return fmt.Sprintf("$%d", index)
+	}
+	return "?"
+}
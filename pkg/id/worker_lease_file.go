@@ -0,0 +1,139 @@
+package id
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileLockWorkerIDAssigner 基于文件锁的工作节点 ID 分配器，适用于同一台主机上的多个进程
+// 每个工作节点 ID 对应目录下的一个 worker-<id>.lock 文件，进程退出时操作系统自动释放文件锁；
+// 锁文件中保存最后使用的时间戳，文件在释放后保留，供下一个持有者继续使用
+type FileLockWorkerIDAssigner struct {
+	dir string
+}
+
+// NewFileLockWorkerIDAssigner 创建文件锁工作节点 ID 分配器
+func NewFileLockWorkerIDAssigner(dir string) *FileLockWorkerIDAssigner {
+	return &FileLockWorkerIDAssigner{dir: dir}
+}
+
+// Acquire 锁定第一个未被其他进程锁定的工作节点 ID 文件
+func (a *FileLockWorkerIDAssigner) Acquire(ctx context.Context, maxWorkerID int64) (WorkerLease, error) {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create worker lock directory: %w", err)
+	}
+
+	for workerID := int64(0); workerID <= maxWorkerID; workerID++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(a.dir, fmt.Sprintf("worker-%d.lock", workerID))
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open worker lock file %s: %w", path, err)
+		}
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock worker lock file %s: %w", path, err)
+		}
+		if !locked {
+			file.Close()
+			continue
+		}
+
+		lastTimestamp, err := readLockTimestamp(file)
+		if err != nil {
+			unlockFile(file)
+			file.Close()
+			return nil, fmt.Errorf("invalid worker lock file %s: %w", path, err)
+		}
+		return &fileWorkerLease{file: file, workerID: workerID, lastTimestamp: lastTimestamp}, nil
+	}
+	return nil, fmt.Errorf("no free worker ID between 0 and %d in %s", maxWorkerID, a.dir)
+}
+
+// readLockTimestamp 读取锁文件中保存的时间戳，空文件为 0
+func readLockTimestamp(file *os.File) (int64, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+	text := strings.TrimSpace(string(content))
+	if text == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+// fileWorkerLease 文件锁工作节点租约
+type fileWorkerLease struct {
+	mutex         sync.Mutex
+	file          *os.File
+	workerID      int64
+	lastTimestamp int64
+}
+
+// WorkerID 获取租用的工作节点 ID
+func (l *fileWorkerLease) WorkerID() int64 {
+	return l.workerID
+}
+
+// LastTimestamp 获取锁文件中保存的最后使用时间戳
+func (l *fileWorkerLease) LastTimestamp() int64 {
+	return l.lastTimestamp
+}
+
+// ExpiresAt 文件锁一直持有到释放，租约没有过期时间
+func (l *fileWorkerLease) ExpiresAt() time.Time {
+	return time.Time{}
+}
+
+// Renew 将最后使用的时间戳写入锁文件，文件锁一直持有到释放，无需延长有效期
+func (l *fileWorkerLease) Renew(ctx context.Context, lastTimestamp int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("worker lease %d is released", l.workerID)
+	}
+	return l.writeTimestamp(lastTimestamp)
+}
+
+// Release 将最后使用的时间戳写入锁文件并释放文件锁
+func (l *fileWorkerLease) Release(ctx context.Context, lastTimestamp int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+
+	writeErr := l.writeTimestamp(lastTimestamp)
+	unlockErr := unlockFile(l.file)
+	closeErr := l.file.Close()
+	l.file = nil
+	for _, err := range []error{writeErr, unlockErr, closeErr} {
+		if err != nil {
+			return fmt.Errorf("failed to release worker lease %d: %w", l.workerID, err)
+		}
+	}
+	return nil
+}
+
+// writeTimestamp 覆盖写入锁文件中的时间戳
+func (l *fileWorkerLease) writeTimestamp(lastTimestamp int64) error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt([]byte(strconv.FormatInt(lastTimestamp, 10)), 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package id
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile 当前平台不支持文件锁
+func tryLockFile(file *os.File) (bool, error) {
+	return false, errors.New("file lock worker ID assignment is not supported on this platform")
+}
+
+// unlockFile 当前平台不支持文件锁
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package id
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile 以非阻塞方式对文件加排他锁，文件已被锁定时返回 false
+func tryLockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile 释放文件锁
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,317 @@
+package id
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLockWorkerIDAssigner(t *testing.T) {
+	ctx := context.Background()
+	assigner := NewFileLockWorkerIDAssigner(t.TempDir())
+
+	first, err := assigner.Acquire(ctx, 1)
+	require.NoError(t, err)
+	second, err := assigner.Acquire(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), first.WorkerID())
+	assert.Equal(t, int64(1), second.WorkerID())
+	assert.Equal(t, int64(0), first.LastTimestamp())
+
+	// 所有工作节点 ID 都被锁定时分配失败
+	_, err = assigner.Acquire(ctx, 1)
+	assert.ErrorContains(t, err, "no free worker ID")
+
+	// 释放后的工作节点 ID 可以重新分配，并带上上一个持有者记录的时间戳
+	require.NoError(t, first.Renew(ctx, 100))
+	require.NoError(t, first.Release(ctx, 200))
+	assert.Error(t, first.Renew(ctx, 300))
+	reacquired, err := assigner.Acquire(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), reacquired.WorkerID())
+	assert.Equal(t, int64(200), reacquired.LastTimestamp())
+
+	require.NoError(t, reacquired.Release(ctx, 0))
+	require.NoError(t, second.Release(ctx, 0))
+}
+
+// workerRow 模拟租约表中的一行
+type workerRow struct {
+	owner         string
+	expiresAt     int64
+	lastTimestamp int64
+}
+
+var workerTables = struct {
+	sync.Mutex
+	rows map[string]map[int64]*workerRow
+}{rows: make(map[string]map[int64]*workerRow)}
+
+// handleWorkerTable 模拟工作节点租约表，按 DSN 保存租约行
+func handleWorkerTable(dsn, query string, args []driver.Value) sqltest.Result {
+	workerTables.Lock()
+	defer workerTables.Unlock()
+	rows := workerTables.rows[dsn]
+
+	switch {
+	case strings.HasPrefix(query, "SELECT worker_id, expires_at, last_timestamp FROM snowflake_worker"):
+		result := sqltest.Result{Columns: []string{"worker_id", "expires_at", "last_timestamp"}}
+		for workerID, row := range rows {
+			result.Rows = append(result.Rows, []driver.Value{workerID, row.expiresAt, row.lastTimestamp})
+		}
+		sort.Slice(result.Rows, func(i, j int) bool { return result.Rows[i][0].(int64) < result.Rows[j][0].(int64) })
+		return result
+	case strings.HasPrefix(query, "INSERT INTO"):
+		workerID := args[0].(int64)
+		if _, exists := rows[workerID]; exists {
+			return sqltest.Result{Err: errors.New("duplicate key")}
+		}
+		rows[workerID] = &workerRow{owner: args[1].(string), expiresAt: args[2].(int64)}
+		return sqltest.Result{Affected: 1}
+	case strings.Contains(query, "SET owner ="):
+		row, exists := rows[args[2].(int64)]
+		if !exists || row.expiresAt != args[3].(int64) {
+			return sqltest.Result{}
+		}
+		row.owner, row.expiresAt = args[0].(string), args[1].(int64)
+		return sqltest.Result{Affected: 1}
+	case strings.Contains(query, "SET expires_at ="):
+		row, exists := rows[args[2].(int64)]
+		if !exists || row.owner != args[3].(string) {
+			return sqltest.Result{}
+		}
+		row.expiresAt, row.lastTimestamp = args[0].(int64), args[1].(int64)
+		return sqltest.Result{Affected: 1}
+	}
+	return sqltest.Result{Err: errors.New("unexpected statement: " + query)}
+}
+
+func init() {
+	sql.Register("id-worker-table", &sqltest.Driver{Handler: handleWorkerTable})
+}
+
+// openWorkerTableDB 打开包含给定租约行的模拟数据库
+func openWorkerTableDB(t *testing.T, rows map[int64]*workerRow) *sql.DB {
+	dsn := t.Name()
+	workerTables.Lock()
+	workerTables.rows[dsn] = rows
+	workerTables.Unlock()
+
+	db, err := sql.Open("id-worker-table", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLWorkerIDAssigner(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := map[int64]*workerRow{
+		0: {owner: "pod-a", expiresAt: now.Add(time.Minute).UnixMilli(), lastTimestamp: 10},
+		1: {owner: "pod-b", expiresAt: now.Add(-time.Second).UnixMilli(), lastTimestamp: 20},
+	}
+	db := openWorkerTableDB(t, rows)
+
+	newAssigner := func() *SQLWorkerIDAssigner {
+		assigner := NewSQLWorkerIDAssigner(db, database.MySQL, "", 30*time.Second)
+		assigner.now = func() time.Time { return now }
+		return assigner
+	}
+
+	// 跳过有效租约，接管过期租约并继承其时间戳
+	first := newAssigner()
+	lease, err := first.Acquire(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lease.WorkerID())
+	assert.Equal(t, int64(20), lease.LastTimestamp())
+	assert.Equal(t, now.Add(30*time.Second).UnixMilli(), rows[1].expiresAt)
+	assert.Equal(t, now.Add(30*time.Second).UnixMilli(), lease.ExpiresAt().UnixMilli())
+
+	// 没有记录的工作节点 ID 通过插入获取
+	second := newAssigner()
+	other, err := second.Acquire(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), other.WorkerID())
+	assert.Equal(t, int64(0), other.LastTimestamp())
+
+	// 续租延长有效期并记录时间戳
+	now = now.Add(10 * time.Second)
+	require.NoError(t, lease.Renew(ctx, 1234))
+	assert.Equal(t, now.Add(30*time.Second).UnixMilli(), rows[1].expiresAt)
+	assert.Equal(t, int64(1234), rows[1].lastTimestamp)
+	assert.Equal(t, now.Add(30*time.Second).UnixMilli(), lease.ExpiresAt().UnixMilli())
+
+	// 租约被其他节点接管后续租失败
+	rows[2].owner = "pod-c"
+	otherExpiresAt := other.ExpiresAt()
+	assert.ErrorContains(t, other.Renew(ctx, 1), "held by another owner")
+	assert.Equal(t, otherExpiresAt, other.ExpiresAt())
+
+	// 释放后租约立即过期
+	require.NoError(t, lease.Release(ctx, 5678))
+	assert.Equal(t, int64(0), rows[1].expiresAt)
+	assert.Equal(t, int64(5678), rows[1].lastTimestamp)
+
+	_, err = newAssigner().Acquire(ctx, 0)
+	assert.ErrorContains(t, err, "no free worker ID")
+}
+
+// memoryWorkerLease 内存工作节点租约，记录续租和释放时的时间戳
+type memoryWorkerLease struct {
+	mu            sync.Mutex
+	workerID      int64
+	lastTimestamp int64
+	renewed       []int64
+	released      []int64
+	renewErr      error
+}
+
+func (l *memoryWorkerLease) WorkerID() int64      { return l.workerID }
+func (l *memoryWorkerLease) LastTimestamp() int64 { return l.lastTimestamp }
+func (l *memoryWorkerLease) ExpiresAt() time.Time { return time.Time{} }
+
+func (l *memoryWorkerLease) Renew(ctx context.Context, lastTimestamp int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.renewErr != nil {
+		return l.renewErr
+	}
+	l.renewed = append(l.renewed, lastTimestamp)
+	return nil
+}
+
+func (l *memoryWorkerLease) Release(ctx context.Context, lastTimestamp int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = append(l.released, lastTimestamp)
+	return nil
+}
+
+// memoryWorkerIDAssigner 总是返回同一个租约的分配器
+type memoryWorkerIDAssigner struct {
+	lease       WorkerLease
+	maxWorkerID int64
+}
+
+func (a *memoryWorkerIDAssigner) Acquire(ctx context.Context, maxWorkerID int64) (WorkerLease, error) {
+	a.maxWorkerID = maxWorkerID
+	return a.lease, nil
+}
+
+func TestLeasedSnowflakeGenerator(t *testing.T) {
+	lease := &memoryWorkerLease{workerID: 700, lastTimestamp: time.Now().UnixMilli() - 1000}
+	assigner := &memoryWorkerIDAssigner{lease: lease}
+	layout := SnowflakeLayout{TimestampBits: 41, WorkerBits: 10, SequenceBits: 12}
+
+	generator, err := NewLeasedSnowflakeGenerator(context.Background(), assigner, 0, SnowflakeOptions{Layout: layout}, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1023), assigner.maxWorkerID)
+	assert.Equal(t, int64(700), generator.WorkerID())
+
+	id, err := generator.NextID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), (id>>12)&1023)
+
+	// 后台续租时记录最后使用的时间戳
+	require.Eventually(t, func() bool {
+		lease.mu.Lock()
+		defer lease.mu.Unlock()
+		return len(lease.renewed) > 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, generator.Close())
+	require.NoError(t, generator.Close())
+	assert.Equal(t, []int64{generator.LastTimestamp()}, lease.released)
+	_, err = generator.NextID()
+	assert.ErrorContains(t, err, "released")
+}
+
+func TestLeasedSnowflakeGenerator_LeaseLost(t *testing.T) {
+	lease := &memoryWorkerLease{workerID: 3, renewErr: errors.New("held by another owner")}
+	generator, err := NewLeasedSnowflakeGenerator(context.Background(), &memoryWorkerIDAssigner{lease: lease}, 1, SnowflakeOptions{}, time.Millisecond)
+	require.NoError(t, err)
+	defer generator.Close()
+
+	require.Eventually(t, func() bool {
+		_, err := generator.NextID()
+		return err != nil
+	}, time.Second, time.Millisecond)
+	_, err = generator.NextID()
+	assert.ErrorContains(t, err, "worker lease 3 lost")
+}
+
+// blockingWorkerLease 有过期时间且续租一直阻塞到 unblock 关闭的租约，续租时不理会 ctx
+type blockingWorkerLease struct {
+	memoryWorkerLease
+	expiresAt time.Time
+	deadline  chan time.Time
+	unblock   chan struct{}
+}
+
+func (l *blockingWorkerLease) ExpiresAt() time.Time { return l.expiresAt }
+
+func (l *blockingWorkerLease) Renew(ctx context.Context, lastTimestamp int64) error {
+	deadline, _ := ctx.Deadline()
+	select {
+	case l.deadline <- deadline:
+	default:
+	}
+	<-l.unblock
+	return ctx.Err()
+}
+
+func TestLeasedSnowflakeGenerator_RenewBlocked(t *testing.T) {
+	lease := &blockingWorkerLease{
+		memoryWorkerLease: memoryWorkerLease{workerID: 5},
+		expiresAt:         time.Now().Add(300 * time.Millisecond),
+		deadline:          make(chan time.Time, 1),
+		unblock:           make(chan struct{}),
+	}
+	options := SnowflakeOptions{LeaseSafetyMargin: 100 * time.Millisecond}
+	generator, err := NewLeasedSnowflakeGenerator(context.Background(), &memoryWorkerIDAssigner{lease: lease}, 0, options, time.Millisecond)
+	require.NoError(t, err)
+
+	_, err = generator.NextID()
+	require.NoError(t, err)
+
+	// 续租的截止时间是租约到期前的安全余量
+	select {
+	case deadline := <-lease.deadline:
+		assert.Equal(t, lease.expiresAt.Add(-100*time.Millisecond), deadline)
+	case <-time.After(time.Second):
+		t.Fatal("renew was not called")
+	}
+
+	// 续租一直阻塞超过有效期，进入安全余量后停止生成 ID
+	require.Eventually(t, func() bool {
+		_, err := generator.NextID()
+		return err != nil
+	}, time.Second, time.Millisecond)
+	assert.False(t, time.Now().Before(lease.expiresAt.Add(-100*time.Millisecond)))
+	_, err = generator.NextID()
+	assert.ErrorContains(t, err, "worker lease 5 expires at")
+
+	close(lease.unblock)
+	require.NoError(t, generator.Close())
+}
+
+func TestNewLeasedSnowflakeGenerator_LeaseShorterThanSafetyMargin(t *testing.T) {
+	lease := &blockingWorkerLease{
+		memoryWorkerLease: memoryWorkerLease{workerID: 5},
+		expiresAt:         time.Now().Add(time.Second),
+	}
+	_, err := NewLeasedSnowflakeGenerator(context.Background(), &memoryWorkerIDAssigner{lease: lease}, 0, SnowflakeOptions{}, time.Millisecond)
+	assert.ErrorContains(t, err, "safety margin")
+	assert.Len(t, lease.released, 1)
+}
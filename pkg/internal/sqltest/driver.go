@@ -0,0 +1,140 @@
+// Package sqltest 提供测试使用的 database/sql 模拟驱动
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Result 模拟驱动对单条语句的响应
+type Result struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	Affected int64
+	Err      error
+}
+
+// Handler 处理驱动收到的语句，dsn 为打开连接时的数据源名称
+// 事务的开始、提交和回滚分别以 BEGIN、COMMIT、ROLLBACK 语句传入
+type Handler func(dsn, query string, args []driver.Value) Result
+
+// Driver 将每条语句交给 Handler 处理的模拟驱动
+type Driver struct {
+	// Handler 处理语句，为 nil 时所有语句都成功且影响 0 行
+	Handler Handler
+	// Ping 处理连接探活，为 nil 时总是成功
+	Ping func(dsn string) error
+}
+
+// Open 实现 driver.Driver 接口
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	return &conn{driver: d, dsn: name}, nil
+}
+
+func (d *Driver) handle(dsn, query string, args []driver.Value) Result {
+	if d.Handler == nil {
+		return Result{}
+	}
+	return d.Handler(dsn, query, args)
+}
+
+var driverSeq atomic.Int64
+
+// Open 以唯一名称注册使用 handler 的驱动并打开 dsn，测试结束时关闭连接
+func Open(t testing.TB, dsn string, handler Handler) *sql.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("sqltest-%s-%d", strings.ReplaceAll(t.Name(), "/", "_"), driverSeq.Add(1))
+	sql.Register(name, &Driver{Handler: handler})
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type conn struct {
+	driver *Driver
+	dsn    string
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	if result := c.driver.handle(c.dsn, "BEGIN", nil); result.Err != nil {
+		return nil, result.Err
+	}
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if c.driver.Ping == nil {
+		return nil
+	}
+	return c.driver.Ping(c.dsn)
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	return t.conn.driver.handle(t.conn.dsn, "COMMIT", nil).Err
+}
+
+func (t *tx) Rollback() error {
+	return t.conn.driver.handle(t.conn.dsn, "ROLLBACK", nil).Err
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	result := s.conn.driver.handle(s.conn.dsn, s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return driver.RowsAffected(result.Affected), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	result := s.conn.driver.handle(s.conn.dsn, s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{columns: result.Columns, rows: result.Rows}, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+	index   int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.index >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.index])
+	r.index++
+	return nil
+}
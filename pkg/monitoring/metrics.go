@@ -1,7 +1,10 @@
 package monitoring
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/http"
 	"sync"
 	"time"
 )
@@ -180,9 +183,10 @@ func (h *HistogramMetric) GetValue() interface{} {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	
+	// 返回桶计数的副本，调用方读取时不受并发 Observe 影响
 	return map[string]interface{}{
 		"buckets": h.buckets,
-		"counts":  h.counts,
+		"counts":  append([]int64(nil), h.counts...),
 		"sum":     h.sum,
 		"count":   h.count,
 	}
@@ -248,6 +252,27 @@ func (mc *MetricsCollector) GetMetric(name string, labels map[string]string) Met
 	return mc.metrics[key]
 }
 
+// getOrRegister 获取名称和标签对应的指标，不存在时用 create 创建并注册
+func (mc *MetricsCollector) getOrRegister(name string, labels map[string]string, create func(labels map[string]string) Metric) Metric {
+	key := mc.getMetricKey(name, labels)
+
+	mc.mu.RLock()
+	metric, exists := mc.metrics[key]
+	mc.mu.RUnlock()
+	if exists {
+		return metric
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if metric, exists := mc.metrics[key]; exists {
+		return metric
+	}
+	metric = create(labels)
+	mc.metrics[key] = metric
+	return metric
+}
+
 // GetAllMetrics 获取所有指标
 func (mc *MetricsCollector) GetAllMetrics() map[string]Metric {
 	mc.mu.RLock()
@@ -260,13 +285,9 @@ func (mc *MetricsCollector) GetAllMetrics() map[string]Metric {
 	return result
 }
 
-// getMetricKey 生成指标键
+// getMetricKey 生成指标键，标签按名称排序，相同的名称和标签总是得到相同的键
 func (mc *MetricsCollector) getMetricKey(name string, labels map[string]string) string {
-	key := name
-	for k, v := range labels {
-		key += "_" + k + "_" + v
-	}
-	return key
+	return name + formatLabels(labels)
 }
 
 // ShardingMetrics 分片相关指标
@@ -290,6 +311,43 @@ type ShardingMetrics struct {
 	TransactionTotal    *CounterMetric
 	TransactionDuration *HistogramMetric
 	TransactionErrors   *CounterMetric
+
+	// 按逻辑表、数据源、实际表、语句类型和路由类型区分的执行单元指标
+	StatementTotal    *CounterVec
+	StatementDuration *HistogramVec
+	StatementErrors   *CounterVec
+	StatementRows     *CounterVec
+}
+
+// RouteType 语句的路由类型
+type RouteType string
+
+const (
+	// RouteSingleShard 路由到单个数据节点
+	RouteSingleShard RouteType = "single-shard"
+	// RouteMultiShard 路由到部分数据节点
+	RouteMultiShard RouteType = "multi-shard"
+	// RouteBroadcast DDL 等语句广播到多个数据节点
+	RouteBroadcast RouteType = "broadcast"
+	// RouteFullRoute 没有可用的分片条件，路由到逻辑表的所有数据节点
+	RouteFullRoute RouteType = "full-route"
+)
+
+// statementLabelNames 语句指标的标签名
+var statementLabelNames = []string{"logic_table", "data_source", "actual_table", "statement_type", "route_type"}
+
+// StatementLabels 语句指标的标签，多个逻辑表或实际表用逗号分隔
+type StatementLabels struct {
+	LogicTable    string
+	DataSource    string
+	ActualTable   string
+	StatementType string
+	RouteType     RouteType
+}
+
+// values 按 statementLabelNames 的顺序获取标签值
+func (l StatementLabels) values() []string {
+	return []string{l.LogicTable, l.DataSource, l.ActualTable, l.StatementType, string(l.RouteType)}
 }
 
 // NewShardingMetrics 创建分片指标
@@ -314,6 +372,13 @@ func NewShardingMetrics() *ShardingMetrics {
 		[]float64{0.01, 0.05, 0.1, 0.5, 1.0, 5.0, 10.0}, 
 		map[string]string{})
 	transactionErrors := NewCounterMetric("sharding_transaction_errors_total", map[string]string{})
+
+	statementTotal := NewCounterVec(collector, "sharding_statement_total", statementLabelNames...)
+	statementDuration := NewHistogramVec(collector, "sharding_statement_duration_seconds",
+		[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0, 5.0},
+		statementLabelNames...)
+	statementErrors := NewCounterVec(collector, "sharding_statement_errors_total", statementLabelNames...)
+	statementRows := NewCounterVec(collector, "sharding_statement_rows_total", statementLabelNames...)
 	
 	// 注册指标
 	collector.RegisterMetric(queryTotal)
@@ -339,6 +404,10 @@ func NewShardingMetrics() *ShardingMetrics {
 		TransactionTotal:    transactionTotal,
 		TransactionDuration: transactionDuration,
 		TransactionErrors:   transactionErrors,
+		StatementTotal:      statementTotal,
+		StatementDuration:   statementDuration,
+		StatementErrors:     statementErrors,
+		StatementRows:       statementRows,
 	}
 }
 
@@ -371,6 +440,27 @@ func (sm *ShardingMetrics) RecordShardingRoute(shardCount int) {
 	}
 }
 
+// RecordStatement 记录语句在一个执行单元上的耗时、错误和返回或影响的行数
+func (sm *ShardingMetrics) RecordStatement(labels StatementLabels, duration time.Duration, rows int64, err error) {
+	values := labels.values()
+	sm.StatementTotal.WithLabelValues(values...).Inc()
+	sm.StatementDuration.WithLabelValues(values...).Observe(duration.Seconds())
+
+	if err != nil {
+		sm.StatementErrors.WithLabelValues(values...).Inc()
+	}
+	if rows > 0 {
+		sm.StatementRows.WithLabelValues(values...).Add(rows)
+	}
+}
+
+// RecordStatementRows 记录语句返回的行数，用于读取完结果集后才知道行数的查询
+func (sm *ShardingMetrics) RecordStatementRows(labels StatementLabels, rows int64) {
+	if rows > 0 {
+		sm.StatementRows.WithLabelValues(labels.values()...).Add(rows)
+	}
+}
+
 // RecordConnection 记录连接
 func (sm *ShardingMetrics) RecordConnection(active int) {
 	sm.ConnectionsTotal.Inc()
@@ -382,6 +472,11 @@ func (sm *ShardingMetrics) GetCollector() *MetricsCollector {
 	return sm.collector
 }
 
+// Handler 获取按 Prometheus 文本格式输出分片指标的 HTTP 处理器
+func (sm *ShardingMetrics) Handler() http.Handler {
+	return NewPrometheusHandler(sm.collector)
+}
+
 // Monitor 监控器接口
 type Monitor interface {
 	// Start 启动监控
@@ -476,8 +571,11 @@ type MetricsExporter interface {
 }
 
 // PrometheusExporter Prometheus 指标导出器
+// Export 将指标按文本格式渲染为快照，ServeHTTP 输出最近一次导出的快照，可以挂载到 endpoint 路径上供 Prometheus 抓取
 type PrometheusExporter struct {
 	endpoint string
+	mu       sync.RWMutex
+	snapshot []byte
 }
 
 // NewPrometheusExporter 创建 Prometheus 导出器
@@ -489,7 +587,28 @@ func NewPrometheusExporter(endpoint string) *PrometheusExporter {
 
 // Export 导出指标到 Prometheus
 func (pe *PrometheusExporter) Export(metrics map[string]Metric) error {
-	// 这里实现 Prometheus 格式的指标导出
-	// 简化实现，实际需要使用 Prometheus 客户端库
+	var buf bytes.Buffer
+	if err := WritePrometheusText(&buf, metrics); err != nil {
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.snapshot = buf.Bytes()
 	return nil
+}
+
+// Endpoint 获取指标的挂载路径
+func (pe *PrometheusExporter) Endpoint() string {
+	return pe.endpoint
+}
+
+// ServeHTTP 输出最近一次导出的指标
+func (pe *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pe.mu.RLock()
+	snapshot := pe.snapshot
+	pe.mu.RUnlock()
+
+	w.Header().Set("Content-Type", PrometheusContentType)
+	w.Write(snapshot)
 }
\ No newline at end of file
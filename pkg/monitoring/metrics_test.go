@@ -2,6 +2,7 @@ package monitoring
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -424,4 +425,86 @@ func BenchmarkShardingMetrics_RecordQuery(b *testing.B) {
 			metrics.RecordQuery(time.Millisecond*10, nil)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestCounterVec(t *testing.T) {
+	collector := NewMetricsCollector()
+	vec := NewCounterVec(collector, "requests_total", "table", "data_source")
+
+	vec.WithLabelValues("t_order", "ds_0").Inc()
+	vec.WithLabelValues("t_order", "ds_0").Add(2)
+	vec.WithLabelValues("t_order", "ds_1").Inc()
+
+	// 每个标签值组合按需创建一个计数器并注册到收集器
+	assert.Len(t, collector.GetAllMetrics(), 2)
+	assert.Same(t, vec.WithLabelValues("t_order", "ds_0"), collector.GetMetric("requests_total", map[string]string{"table": "t_order", "data_source": "ds_0"}))
+	assert.Equal(t, int64(3), vec.WithLabelValues("t_order", "ds_0").GetValue())
+	assert.Equal(t, int64(1), vec.WithLabelValues("t_order", "ds_1").GetValue())
+
+	assert.Panics(t, func() { vec.WithLabelValues("t_order") })
+}
+
+func TestGaugeVec_HistogramVec(t *testing.T) {
+	collector := NewMetricsCollector()
+	gauges := NewGaugeVec(collector, "connections_active", "data_source")
+	gauges.WithLabelValues("ds_0").Set(3)
+	assert.Equal(t, float64(3), gauges.WithLabelValues("ds_0").GetValue())
+
+	histograms := NewHistogramVec(collector, "query_duration_seconds", []float64{0.1, 1}, "type")
+	histograms.WithLabelValues("select").Observe(0.5)
+	histograms.WithLabelValues("select").Observe(2)
+	value := histograms.WithLabelValues("select").GetValue().(map[string]interface{})
+	assert.Equal(t, int64(2), value["count"])
+	assert.Equal(t, []float64{0.1, 1}, histograms.WithLabelValues("update").GetValue().(map[string]interface{})["buckets"])
+
+	// 同名同标签的指标已按其他类型注册
+	conflict := NewCounterVec(collector, "connections_active", "data_source")
+	assert.Panics(t, func() { conflict.WithLabelValues("ds_0") })
+}
+
+func TestMetricVec_Concurrent(t *testing.T) {
+	collector := NewMetricsCollector()
+	vec := NewCounterVec(collector, "requests_total", "worker")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				vec.WithLabelValues("shared").Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, collector.GetAllMetrics(), 1)
+	assert.Equal(t, int64(1000), vec.WithLabelValues("shared").GetValue())
+}
+
+func TestShardingMetrics_RecordStatement(t *testing.T) {
+	metrics := NewShardingMetrics()
+	labels := StatementLabels{
+		LogicTable:    "t_order",
+		DataSource:    "ds_0",
+		ActualTable:   "t_order_1",
+		StatementType: "select",
+		RouteType:     RouteSingleShard,
+	}
+
+	metrics.RecordStatement(labels, 20*time.Millisecond, 0, nil)
+	metrics.RecordStatementRows(labels, 5)
+	metrics.RecordStatement(labels, 30*time.Millisecond, 0, errors.New("query failed"))
+
+	values := labels.values()
+	assert.Equal(t, int64(2), metrics.StatementTotal.WithLabelValues(values...).GetValue())
+	assert.Equal(t, int64(1), metrics.StatementErrors.WithLabelValues(values...).GetValue())
+	assert.Equal(t, int64(5), metrics.StatementRows.WithLabelValues(values...).GetValue())
+	duration := metrics.StatementDuration.WithLabelValues(values...).GetValue().(map[string]interface{})
+	assert.Equal(t, int64(2), duration["count"])
+	assert.InDelta(t, 0.05, duration["sum"], 1e-9)
+
+	assert.NotNil(t, metrics.GetCollector().GetMetric("sharding_statement_total", map[string]string{
+		"logic_table": "t_order", "data_source": "ds_0", "actual_table": "t_order_1", "statement_type": "select", "route_type": "single-shard",
+	}))
+}
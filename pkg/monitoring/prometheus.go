@@ -0,0 +1,208 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType Prometheus 文本格式 0.0.4 的 Content-Type
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// metricNamePattern 合法的指标名称
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	// labelNamePattern 合法的标签名称
+	labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// labelValueReplacer 标签值转义：反斜杠、双引号和换行
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// WritePrometheusText 按 Prometheus 文本格式 0.0.4 输出指标
+// 同名指标合并为一个指标族，指标族按名称排序，族内按标签排序，因此相同的指标总是得到相同的输出；
+// 直方图输出累计的 _bucket 序列（包括 le="+Inf"）以及 _sum 和 _count 序列
+func WritePrometheusText(w io.Writer, metrics map[string]Metric) error {
+	families := make(map[string][]Metric)
+	for _, metric := range metrics {
+		families[metric.GetName()] = append(families[metric.GetName()], metric)
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		if err := writeMetricFamily(&buf, name, families[name]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeMetricFamily 输出一个指标族
+func writeMetricFamily(buf *bytes.Buffer, name string, family []Metric) error {
+	if !metricNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid metric name: %q", name)
+	}
+	metricType := family[0].GetType()
+	for _, metric := range family {
+		if metric.GetType() != metricType {
+			return fmt.Errorf("metric %s is registered with different types", name)
+		}
+		for labelName := range metric.GetLabels() {
+			if !labelNamePattern.MatchString(labelName) || strings.HasPrefix(labelName, "__") || (metricType == Histogram && labelName == "le") {
+				return fmt.Errorf("invalid label name %q of metric %s", labelName, name)
+			}
+		}
+	}
+	sort.Slice(family, func(i, j int) bool {
+		return formatLabels(family[i].GetLabels()) < formatLabels(family[j].GetLabels())
+	})
+
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, prometheusType(metricType))
+	for _, metric := range family {
+		labels := metric.GetLabels()
+		switch metricType {
+		case Histogram:
+			if err := writeHistogram(buf, name, metric); err != nil {
+				return err
+			}
+		default:
+			value, err := sampleValue(metric.GetValue())
+			if err != nil {
+				return fmt.Errorf("invalid value of metric %s: %w", name, err)
+			}
+			writeSample(buf, name, formatLabels(labels), value)
+		}
+	}
+	return nil
+}
+
+// writeHistogram 输出直方图的 _bucket、_sum 和 _count 序列，桶计数转换为累计值
+func writeHistogram(buf *bytes.Buffer, name string, metric Metric) error {
+	value, ok := metric.GetValue().(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid value of histogram %s", name)
+	}
+	buckets, _ := value["buckets"].([]float64)
+	counts, _ := value["counts"].([]int64)
+	sum, _ := value["sum"].(float64)
+	count, _ := value["count"].(int64)
+	if len(counts) != len(buckets)+1 {
+		return fmt.Errorf("histogram %s has %d buckets but %d counts", name, len(buckets), len(counts))
+	}
+
+	labels := metric.GetLabels()
+	var cumulative int64
+	for i, bound := range buckets {
+		cumulative += counts[i]
+		writeSample(buf, name+"_bucket", formatLabelsWith(labels, "le", formatFloat(bound)), float64(cumulative))
+	}
+	writeSample(buf, name+"_bucket", formatLabelsWith(labels, "le", "+Inf"), float64(count))
+	writeSample(buf, name+"_sum", formatLabels(labels), sum)
+	writeSample(buf, name+"_count", formatLabels(labels), float64(count))
+	return nil
+}
+
+// writeSample 输出一行样本
+func writeSample(buf *bytes.Buffer, name, labels string, value float64) {
+	buf.WriteString(name)
+	buf.WriteString(labels)
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+// prometheusType 获取指标类型在文本格式中的名称
+func prometheusType(metricType MetricType) string {
+	switch metricType {
+	case Counter:
+		return "counter"
+	case Gauge:
+		return "gauge"
+	case Histogram:
+		return "histogram"
+	case Summary:
+		return "summary"
+	default:
+		return "untyped"
+	}
+}
+
+// sampleValue 将计数器、仪表盘等指标的值转换为 float64
+func sampleValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("unsupported sample value type: %T", value)
+	}
+}
+
+// formatFloat 按文本格式输出浮点数，无穷大和 NaN 输出为 +Inf、-Inf 和 NaN
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// formatLabels 按标签名排序输出 {name="value",...}，没有标签时为空字符串
+func formatLabels(labels map[string]string) string {
+	return formatLabelsWith(labels, "", "")
+}
+
+// formatLabelsWith 输出标签并在末尾追加一个额外的标签，如直方图桶的 le
+func formatLabelsWith(labels map[string]string, extraName, extraValue string) string {
+	if len(labels) == 0 && extraName == "" {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelValueReplacer.Replace(labels[name])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+labelValueReplacer.Replace(extraValue)+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// NewPrometheusHandler 创建按 Prometheus 文本格式输出收集器中所有指标的 HTTP 处理器，每次抓取时读取最新的指标值
+func NewPrometheusHandler(collector *MetricsCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := WritePrometheusText(&buf, collector.GetAllMetrics()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", PrometheusContentType)
+		w.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,312 @@
+package monitoring
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promSample 文本格式中的一个样本
+type promSample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// promFamily 文本格式中的一个指标族
+type promFamily struct {
+	metricType string
+	samples    []promSample
+}
+
+// parsePrometheusText 解析 Prometheus 文本格式 0.0.4，按 # TYPE 声明的名称分组样本；
+// 直方图的 _bucket、_sum 和 _count 样本归入所属的指标族
+func parsePrometheusText(r io.Reader) (map[string]*promFamily, error) {
+	families := make(map[string]*promFamily)
+	var current string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				if _, exists := families[fields[2]]; exists {
+					return nil, fmt.Errorf("duplicate TYPE line for %s", fields[2])
+				}
+				current = fields[2]
+				families[current] = &promFamily{metricType: fields[3]}
+			}
+			continue
+		}
+
+		sample, err := parseSampleLine(line)
+		if err != nil {
+			return nil, err
+		}
+		family, exists := families[current]
+		if !exists || !strings.HasPrefix(sample.name, current) {
+			return nil, fmt.Errorf("sample %s is not preceded by its TYPE line", sample.name)
+		}
+		family.samples = append(family.samples, sample)
+	}
+	return families, scanner.Err()
+}
+
+// parseSampleLine 解析 name{label="value",...} value 格式的样本行
+func parseSampleLine(line string) (promSample, error) {
+	sample := promSample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ ")
+	if end < 0 {
+		return sample, fmt.Errorf("invalid sample line: %s", line)
+	}
+	sample.name, line = line[:end], line[end:]
+
+	if strings.HasPrefix(line, "{") {
+		line = line[1:]
+		for !strings.HasPrefix(line, "}") {
+			eq := strings.Index(line, `="`)
+			if eq < 0 {
+				return sample, fmt.Errorf("invalid label in: %s", line)
+			}
+			name := line[:eq]
+			line = line[eq+2:]
+
+			var value strings.Builder
+			for {
+				if line == "" {
+					return sample, fmt.Errorf("unterminated label value of %s", name)
+				}
+				c := line[0]
+				line = line[1:]
+				if c == '"' {
+					break
+				}
+				if c == '\\' && line != "" {
+					switch line[0] {
+					case 'n':
+						value.WriteByte('\n')
+					default:
+						value.WriteByte(line[0])
+					}
+					line = line[1:]
+					continue
+				}
+				value.WriteByte(c)
+			}
+			sample.labels[name] = value.String()
+			line = strings.TrimPrefix(line, ",")
+		}
+		line = line[1:]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value of sample %s", sample.name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value of sample %s: %w", sample.name, err)
+	}
+	sample.value = value
+	return sample, nil
+}
+
+// sampleFor 查找名称和标签完全匹配的样本值
+func sampleFor(t *testing.T, family *promFamily, name string, labels map[string]string) float64 {
+	for _, sample := range family.samples {
+		if sample.name == name && assert.ObjectsAreEqual(labels, sample.labels) {
+			return sample.value
+		}
+	}
+	t.Fatalf("sample %s%v not found", name, labels)
+	return 0
+}
+
+func TestWritePrometheusText(t *testing.T) {
+	collector := NewMetricsCollector()
+	counter := NewCounterMetric("requests_total", map[string]string{"table": "t_order", "data_source": "ds_0"})
+	counter.Add(3)
+	other := NewCounterMetric("requests_total", map[string]string{"table": "t_user", "data_source": "ds_1"})
+	other.Inc()
+	gauge := NewGaugeMetric("connections_active", map[string]string{"path": "C:\\data \"primary\"\nreplica"})
+	gauge.Set(2.5)
+	histogram := NewHistogramMetric("query_duration_seconds", []float64{0.1, 0.5, 1}, map[string]string{"type": "select"})
+	for _, v := range []float64{0.05, 0.3, 0.4, 2} {
+		histogram.Observe(v)
+	}
+	for _, metric := range []Metric{counter, other, gauge, histogram} {
+		collector.RegisterMetric(metric)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheusText(&buf, collector.GetAllMetrics()))
+	assert.Equal(t, `# TYPE connections_active gauge
+connections_active{path="C:\\data \"primary\"\nreplica"} 2.5
+# TYPE query_duration_seconds histogram
+query_duration_seconds_bucket{type="select",le="0.1"} 1
+query_duration_seconds_bucket{type="select",le="0.5"} 3
+query_duration_seconds_bucket{type="select",le="1"} 3
+query_duration_seconds_bucket{type="select",le="+Inf"} 4
+query_duration_seconds_sum{type="select"} 2.75
+query_duration_seconds_count{type="select"} 4
+# TYPE requests_total counter
+requests_total{data_source="ds_0",table="t_order"} 3
+requests_total{data_source="ds_1",table="t_user"} 1
+`, buf.String())
+
+	// 输出可以被解析回相同的指标
+	families, err := parsePrometheusText(&buf)
+	require.NoError(t, err)
+	require.Len(t, families, 3)
+	assert.Equal(t, "counter", families["requests_total"].metricType)
+	assert.Equal(t, float64(3), sampleFor(t, families["requests_total"], "requests_total", map[string]string{"table": "t_order", "data_source": "ds_0"}))
+	assert.Equal(t, 2.5, sampleFor(t, families["connections_active"], "connections_active", gauge.GetLabels()))
+	assert.Equal(t, float64(4), sampleFor(t, families["query_duration_seconds"], "query_duration_seconds_bucket", map[string]string{"type": "select", "le": "+Inf"}))
+}
+
+func TestWritePrometheusText_ShardingMetricsRoundTrip(t *testing.T) {
+	metrics := NewShardingMetrics()
+	metrics.RecordQuery(3*1e6, nil)            // 3ms
+	metrics.RecordQuery(200*1e6, nil)          // 200ms
+	metrics.RecordQuery(7*1e9, errQueryFailed) // 7s
+	metrics.RecordShardingRoute(2)
+	metrics.RecordConnection(4)
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheusText(&buf, metrics.GetCollector().GetAllMetrics()))
+	families, err := parsePrometheusText(&buf)
+	require.NoError(t, err)
+
+	// 收集器中的每个指标都有对应的指标族
+	for _, metric := range metrics.GetCollector().GetAllMetrics() {
+		require.Contains(t, families, metric.GetName())
+	}
+	assert.Equal(t, float64(3), sampleFor(t, families["sharding_query_total"], "sharding_query_total", map[string]string{"type": "all"}))
+	assert.Equal(t, float64(1), sampleFor(t, families["sharding_query_errors_total"], "sharding_query_errors_total", map[string]string{}))
+	assert.Equal(t, float64(4), sampleFor(t, families["sharding_connections_active"], "sharding_connections_active", map[string]string{}))
+
+	// 直方图的桶累计递增，+Inf 桶等于 _count
+	duration := families["sharding_query_duration_seconds"]
+	assert.Equal(t, "histogram", duration.metricType)
+	previous := float64(0)
+	for _, sample := range duration.samples {
+		if sample.name != "sharding_query_duration_seconds_bucket" {
+			continue
+		}
+		assert.GreaterOrEqual(t, sample.value, previous)
+		previous = sample.value
+	}
+	assert.Equal(t, float64(1), sampleFor(t, duration, "sharding_query_duration_seconds_bucket", map[string]string{"le": "0.005"}))
+	assert.Equal(t, float64(2), sampleFor(t, duration, "sharding_query_duration_seconds_bucket", map[string]string{"le": "5"}))
+	assert.Equal(t, float64(3), sampleFor(t, duration, "sharding_query_duration_seconds_bucket", map[string]string{"le": "+Inf"}))
+	assert.Equal(t, float64(3), sampleFor(t, duration, "sharding_query_duration_seconds_count", map[string]string{}))
+	assert.InDelta(t, 7.203, sampleFor(t, duration, "sharding_query_duration_seconds_sum", map[string]string{}), 1e-9)
+}
+
+// errQueryFailed 记录失败查询使用的错误
+var errQueryFailed = fmt.Errorf("query failed")
+
+func TestWritePrometheusText_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []Metric
+	}{
+		{"invalid metric name", []Metric{NewCounterMetric("sharding-query", nil)}},
+		{"invalid label name", []Metric{NewCounterMetric("sharding_query", map[string]string{"data-source": "ds_0"})}},
+		{"reserved label name", []Metric{NewCounterMetric("sharding_query", map[string]string{"__name__": "x"})}},
+		{"le label on histogram", []Metric{NewHistogramMetric("sharding_query", []float64{1}, map[string]string{"le": "1"})}},
+		{"conflicting types", []Metric{
+			NewCounterMetric("sharding_query", map[string]string{"table": "a"}),
+			NewGaugeMetric("sharding_query", map[string]string{"table": "b"}),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewMetricsCollector()
+			for _, metric := range tt.metrics {
+				collector.RegisterMetric(metric)
+			}
+			assert.Error(t, WritePrometheusText(io.Discard, collector.GetAllMetrics()))
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "0.005", formatFloat(0.005))
+	assert.Equal(t, "1e+21", formatFloat(1e21))
+}
+
+func TestMetricsCollector_DeterministicKey(t *testing.T) {
+	collector := NewMetricsCollector()
+	labels := map[string]string{"table": "t_order", "data_source": "ds_0", "type": "select"}
+	counter := NewCounterMetric("sharding_query_total", labels)
+	collector.RegisterMetric(counter)
+
+	for i := 0; i < 20; i++ {
+		assert.Same(t, counter, collector.GetMetric("sharding_query_total", map[string]string{"type": "select", "data_source": "ds_0", "table": "t_order"}))
+	}
+	assert.Contains(t, collector.GetAllMetrics(), `sharding_query_total{data_source="ds_0",table="t_order",type="select"}`)
+}
+
+func TestShardingMetrics_Handler(t *testing.T) {
+	metrics := NewShardingMetrics()
+	metrics.RecordShardingRoute(3)
+
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, PrometheusContentType, response.Header.Get("Content-Type"))
+
+	families, err := parsePrometheusText(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), sampleFor(t, families["sharding_cross_shard_queries_total"], "sharding_cross_shard_queries_total", map[string]string{}))
+
+	// 指标无法输出时返回 500
+	collector := NewMetricsCollector()
+	collector.RegisterMetric(NewCounterMetric("invalid-name", nil))
+	recorder := httptest.NewRecorder()
+	NewPrometheusHandler(collector).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	exporter := NewPrometheusExporter("/metrics")
+	assert.Equal(t, "/metrics", exporter.Endpoint())
+
+	collector := NewMetricsCollector()
+	counter := NewCounterMetric("exported_total", nil)
+	counter.Add(5)
+	collector.RegisterMetric(counter)
+	require.NoError(t, exporter.Export(collector.GetAllMetrics()))
+
+	// 导出后的变化在下一次导出前不可见
+	counter.Inc()
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "# TYPE exported_total counter\nexported_total 5\n", recorder.Body.String())
+	assert.Equal(t, PrometheusContentType, recorder.Header().Get("Content-Type"))
+
+	collector.RegisterMetric(NewCounterMetric("bad name", nil))
+	assert.Error(t, exporter.Export(collector.GetAllMetrics()))
+}
@@ -0,0 +1,104 @@
+package monitoring
+
+import "fmt"
+
+// metricVec 指标向量的公共实现，同名指标按标签值组合区分，首次使用某个组合时创建并注册到收集器
+type metricVec struct {
+	collector  *MetricsCollector
+	name       string
+	labelNames []string
+	newMetric  func(labels map[string]string) Metric
+}
+
+// with 获取标签值组合对应的指标，标签值按 labelNames 的顺序给出，数量不一致时 panic
+func (v *metricVec) with(values []string) Metric {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(values)))
+	}
+	labels := make(map[string]string, len(values))
+	for i, name := range v.labelNames {
+		labels[name] = values[i]
+	}
+	return v.collector.getOrRegister(v.name, labels, v.newMetric)
+}
+
+// CounterVec 计数器向量
+type CounterVec struct {
+	vec *metricVec
+}
+
+// NewCounterVec 创建计数器向量，计数器注册到 collector
+func NewCounterVec(collector *MetricsCollector, name string, labelNames ...string) *CounterVec {
+	return &CounterVec{vec: &metricVec{
+		collector:  collector,
+		name:       name,
+		labelNames: labelNames,
+		newMetric: func(labels map[string]string) Metric {
+			return NewCounterMetric(name, labels)
+		},
+	}}
+}
+
+// WithLabelValues 获取标签值组合对应的计数器，不存在时创建
+func (v *CounterVec) WithLabelValues(values ...string) *CounterMetric {
+	metric := v.vec.with(values)
+	counter, ok := metric.(*CounterMetric)
+	if !ok {
+		panic(fmt.Sprintf("metric %s is already registered with a different type", v.vec.name))
+	}
+	return counter
+}
+
+// GaugeVec 仪表盘向量
+type GaugeVec struct {
+	vec *metricVec
+}
+
+// NewGaugeVec 创建仪表盘向量，仪表盘注册到 collector
+func NewGaugeVec(collector *MetricsCollector, name string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{vec: &metricVec{
+		collector:  collector,
+		name:       name,
+		labelNames: labelNames,
+		newMetric: func(labels map[string]string) Metric {
+			return NewGaugeMetric(name, labels)
+		},
+	}}
+}
+
+// WithLabelValues 获取标签值组合对应的仪表盘，不存在时创建
+func (v *GaugeVec) WithLabelValues(values ...string) *GaugeMetric {
+	metric := v.vec.with(values)
+	gauge, ok := metric.(*GaugeMetric)
+	if !ok {
+		panic(fmt.Sprintf("metric %s is already registered with a different type", v.vec.name))
+	}
+	return gauge
+}
+
+// HistogramVec 直方图向量
+type HistogramVec struct {
+	vec *metricVec
+}
+
+// NewHistogramVec 创建直方图向量，所有标签值组合使用相同的桶，直方图注册到 collector
+func NewHistogramVec(collector *MetricsCollector, name string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{vec: &metricVec{
+		collector:  collector,
+		name:       name,
+		labelNames: labelNames,
+		newMetric: func(labels map[string]string) Metric {
+			return NewHistogramMetric(name, buckets, labels)
+		},
+	}}
+}
+
+// WithLabelValues 获取标签值组合对应的直方图，不存在时创建
+func (v *HistogramVec) WithLabelValues(values ...string) *HistogramMetric {
+	metric := v.vec.with(values)
+	histogram, ok := metric.(*HistogramMetric)
+	if !ok {
+		panic(fmt.Sprintf("metric %s is already registered with a different type", v.vec.name))
+	}
+	return histogram
+}
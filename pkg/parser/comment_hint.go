@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"strings"
+)
+
+// CommentHintPrefix SQL 注释提示的前缀，如 /* SHARDING: write_route_only=true */
+const CommentHintPrefix = "SHARDING:"
+
+// 注释提示键
+const (
+	// HintWriteRouteOnly true 时语句只路由到主库，false 时读写分离按读请求路由
+	HintWriteRouteOnly = "write_route_only"
+	// HintDataSource 语句中逻辑表的数据库分片提示值，如 /* SHARDING: ds=ds_1 */
+	HintDataSource = "ds"
+	// HintTable 语句中逻辑表的表分片提示值，如 /* SHARDING: table=t_order_3 */
+	HintTable = "table"
+)
+
+// ParseCommentHints 解析 SQL 中的路由提示注释，返回小写键的提示值和去掉提示注释后的 SQL
+// 支持 /* */、-- 和 MySQL 的 # 注释，字符串字面量中的内容不会被识别为提示
+func ParseCommentHints(sql string) (map[string]string, string) {
+	var hints map[string]string
+	var stripped strings.Builder
+	last := 0
+
+	for _, segment := range splitSQLSegments(sql) {
+		if segment.kind != segmentComment {
+			continue
+		}
+		body, ok := hintBody(sql[segment.start:segment.end])
+		if !ok {
+			continue
+		}
+		if hints == nil {
+			hints = make(map[string]string)
+		}
+		for _, pair := range strings.Split(body, ",") {
+			key, value, found := strings.Cut(pair, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !found || key == "" {
+				continue
+			}
+			hints[key] = strings.TrimSpace(value)
+		}
+		stripped.WriteString(sql[last:segment.start])
+		last = segment.end
+	}
+
+	if hints == nil {
+		return nil, sql
+	}
+	stripped.WriteString(sql[last:])
+	return hints, strings.TrimSpace(stripped.String())
+}
+
+// hintBody 获取提示注释中前缀之后的内容，不是提示注释时 ok 为 false
+func hintBody(comment string) (string, bool) {
+	switch {
+	case strings.HasPrefix(comment, "/*"):
+		comment = strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/")
+		// MySQL 优化器提示 /*+ ... */ 也可携带分片提示
+		comment = strings.TrimPrefix(comment, "+")
+	case strings.HasPrefix(comment, "--"):
+		comment = strings.TrimPrefix(comment, "--")
+	default:
+		comment = strings.TrimPrefix(comment, "#")
+	}
+
+	comment = strings.TrimSpace(comment)
+	if len(comment) < len(CommentHintPrefix) || !strings.EqualFold(comment[:len(CommentHintPrefix)], CommentHintPrefix) {
+		return "", false
+	}
+	return comment[len(CommentHintPrefix):], true
+}
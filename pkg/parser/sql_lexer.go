@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sqlSegmentKind SQL 片段类型
+type sqlSegmentKind int
+
+const (
+	// segmentCode 普通 SQL 文本
+	segmentCode sqlSegmentKind = iota
+	// segmentComment 注释，包括 /* */、-- 和 MySQL 的 #
+	segmentComment
+	// segmentQuoted 字符串字面量、引号标识符和 PostgreSQL 的 $tag$ 字符串
+	segmentQuoted
+)
+
+// sqlSegment SQL 中的一个片段，start 和 end 为字节偏移
+type sqlSegment struct {
+	kind  sqlSegmentKind
+	start int
+	end   int
+}
+
+// splitSQLSegments 将 SQL 切分为代码、注释和引号片段，兼容 MySQL 与 PostgreSQL 的注释及引号语法
+func splitSQLSegments(sql string) []sqlSegment {
+	var segments []sqlSegment
+	codeStart := 0
+	emit := func(kind sqlSegmentKind, start, end int) {
+		if start > codeStart {
+			segments = append(segments, sqlSegment{kind: segmentCode, start: codeStart, end: start})
+		}
+		segments = append(segments, sqlSegment{kind: kind, start: start, end: end})
+		codeStart = end
+	}
+
+	for i := 0; i < len(sql); {
+		switch c := sql[i]; {
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 4
+			}
+			emit(segmentComment, i, end)
+			i = end
+		case c == '-' && strings.HasPrefix(sql[i:], "--"), c == '#' && isMySQLHashComment(sql, i):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			emit(segmentComment, i, end)
+			i = end
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedEnd(sql, i, c)
+			emit(segmentQuoted, i, end)
+			i = end
+		case c == '$':
+			if tag, ok := dollarQuoteTag(sql, i); ok {
+				end := strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					end = len(sql)
+				} else {
+					end += i + 2*len(tag)
+				}
+				emit(segmentQuoted, i, end)
+				i = end
+			} else {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	if codeStart < len(sql) {
+		segments = append(segments, sqlSegment{kind: segmentCode, start: codeStart, end: len(sql)})
+	}
+	return segments
+}
+
+// isMySQLHashComment 判断 # 是否为 MySQL 注释，PostgreSQL 的 #> 等运算符后不跟空白
+func isMySQLHashComment(sql string, i int) bool {
+	return i+1 == len(sql) || unicode.IsSpace(rune(sql[i+1]))
+}
+
+// quotedEnd 查找引号片段的结束位置，支持重复引号和反斜杠转义
+func quotedEnd(sql string, start int, quote byte) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// dollarQuoteTag 识别 PostgreSQL 的 $$ 或 $tag$ 字符串起始标记，$1 等占位符不是起始标记
+func dollarQuoteTag(sql string, start int) (string, bool) {
+	for i := start + 1; i < len(sql); i++ {
+		c := sql[i]
+		if c == '$' {
+			return sql[start : i+1], true
+		}
+		if !(c == '_' || unicode.IsLetter(rune(c)) || (i > start+1 && unicode.IsDigit(rune(c)))) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// sqlTokens 提取 SQL 代码片段中的大写单词和符号，跳过注释和引号片段
+func sqlTokens(sql string) []string {
+	var tokens []string
+	for _, segment := range splitSQLSegments(sql) {
+		if segment.kind != segmentCode {
+			continue
+		}
+		code := sql[segment.start:segment.end]
+		for i := 0; i < len(code); {
+			c := rune(code[i])
+			switch {
+			case unicode.IsSpace(c):
+				i++
+			case isWordChar(c):
+				j := i + 1
+				for j < len(code) && (isWordChar(rune(code[j])) || code[j] == '$') {
+					j++
+				}
+				tokens = append(tokens, strings.ToUpper(code[i:j]))
+				i = j
+			default:
+				tokens = append(tokens, string(c))
+				i++
+			}
+		}
+	}
+	return tokens
+}
+
+// isWordChar 判断是否为关键字或标识符字符
+func isWordChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// stripLeadingComments 去掉 SQL 开头的空白和注释
+func stripLeadingComments(sql string) string {
+	for _, segment := range splitSQLSegments(sql) {
+		if segment.kind == segmentComment {
+			continue
+		}
+		if segment.kind == segmentCode {
+			if rest := strings.TrimLeftFunc(sql[segment.start:segment.end], unicode.IsSpace); rest == "" {
+				continue
+			}
+		}
+		return strings.TrimSpace(sql[segment.start:])
+	}
+	return ""
+}
@@ -126,8 +126,8 @@ func (p *SQLParser) Parse(sql string) (*SQLStatement, error) {
 	case SQLTypeAlter:
 		return p.parseAlter(sql, stmt)
 	default:
-		// 对于其他类型，尝试提取表名
-		stmt.Tables = p.extractTables(sql)
+		// 对于其他类型，尝试提取表名，extractTables 会再次调用 Parse，这里直接使用简单提取
+		stmt.Tables = p.extractTablesSimple(sql)
 		return stmt, nil
 	}
 }
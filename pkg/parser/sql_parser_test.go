@@ -492,4 +492,13 @@ func BenchmarkSQLParser_GetTables(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = parser.GetTables(sql)
 	}
-}
\ No newline at end of file
+}
+
+func TestSQLParser_ParseOther(t *testing.T) {
+	parser := NewSQLParser()
+
+	stmt, err := parser.Parse("WITH recent AS (SELECT * FROM orders) SELECT * FROM recent")
+	assert.NoError(t, err)
+	assert.Equal(t, SQLTypeOther, stmt.Type)
+	assert.Contains(t, stmt.Tables, "orders")
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// StatementAccess 语句的读写访问分类
+type StatementAccess struct {
+	// Type 解析器识别的语句类型
+	Type SQLType
+	// Write 语句修改数据或依赖主库会话状态，需要在主库执行
+	Write bool
+	// LockingRead 带 FOR UPDATE、FOR SHARE 或 LOCK IN SHARE MODE 的加锁读
+	LockingRead bool
+	// SequenceFunction 调用了 nextval()、last_insert_id() 等序列函数
+	SequenceFunction bool
+	// WritableCTE WITH 子句或其主语句中包含写操作
+	WritableCTE bool
+}
+
+// writeKeywords 以这些关键字开头的语句需要在主库执行
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "CREATE": true, "DROP": true, "ALTER": true,
+	"TRUNCATE": true, "REPLACE": true, "MERGE": true, "CALL": true, "EXEC": true,
+}
+
+// writeTypes 解析器识别为写操作的语句类型
+var writeTypes = map[SQLType]bool{
+	SQLTypeInsert: true, SQLTypeUpdate: true, SQLTypeDelete: true,
+	SQLTypeCreate: true, SQLTypeDrop: true, SQLTypeAlter: true,
+}
+
+// sequenceFunctions 修改或读取会话序列状态的函数
+var sequenceFunctions = map[string]bool{
+	"NEXTVAL": true, "SETVAL": true, "CURRVAL": true, "LASTVAL": true, "LAST_INSERT_ID": true,
+}
+
+// cteWriteKeywords WITH 子句中表示写操作的关键字
+var cteWriteKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+}
+
+// ClassifyAccess 判断语句是否需要在主库执行
+// 语句类型由默认解析器识别，加锁读、序列函数和可写 CTE 通过跳过注释与字面量的词法扫描识别
+func (f *ParserFactory) ClassifyAccess(sql string) *StatementAccess {
+	access := &StatementAccess{Type: SQLTypeOther}
+
+	sql = stripLeadingComments(sql)
+	tokens := sqlTokens(sql)
+	if len(tokens) == 0 {
+		return access
+	}
+	if stmt, err := f.Parse(sql); err == nil {
+		access.Type = stmt.Type
+	}
+
+	for i, token := range tokens {
+		next := tokenAt(tokens, i+1)
+		switch {
+		case token == "FOR" && (next == "UPDATE" || next == "SHARE" || next == "NO" && tokenAt(tokens, i+2) == "KEY" || next == "KEY" && tokenAt(tokens, i+2) == "SHARE"):
+			access.LockingRead = true
+		case token == "LOCK" && next == "IN" && tokenAt(tokens, i+2) == "SHARE" && tokenAt(tokens, i+3) == "MODE":
+			access.LockingRead = true
+		case sequenceFunctions[token] && next == "(":
+			access.SequenceFunction = true
+		case token == "NEXT" && next == "VALUE" && tokenAt(tokens, i+2) == "FOR":
+			access.SequenceFunction = true
+		case tokens[0] == "WITH" && cteWriteKeywords[token] && !isLockingKeyword(tokens, i):
+			access.WritableCTE = true
+		}
+	}
+
+	access.Write = writeKeywords[tokens[0]] || writeTypes[access.Type] ||
+		access.LockingRead || access.SequenceFunction || access.WritableCTE
+	return access
+}
+
+// isLockingKeyword 判断 UPDATE 是否属于 FOR UPDATE 或 FOR NO KEY UPDATE 加锁子句
+func isLockingKeyword(tokens []string, i int) bool {
+	previous := tokenAt(tokens, i-1)
+	return previous == "FOR" || previous == "KEY" && tokenAt(tokens, i-2) == "NO"
+}
+
+// tokenAt 获取指定位置的单词，越界时返回空字符串
+func tokenAt(tokens []string, i int) string {
+	if i < 0 || i >= len(tokens) {
+		return ""
+	}
+	return tokens[i]
+}
+
+// plainSelectMarkers 普通 SELECT 中出现这些片段时可能是加锁读或调用了序列函数，需要完整判断
+var plainSelectMarkers = []string{"FOR", "LOCK", "VAL", "LAST_INSERT_ID"}
+
+// PlainStatementWrite 不解析语句，按首个关键字判断普通 SELECT、INSERT、UPDATE、DELETE 是否需要在主库执行
+// 语句含注释、加锁子句、序列函数或以其它关键字开头时 ok 为 false，需要由 ClassifyAccess 判断
+func PlainStatementWrite(sql string) (write bool, ok bool) {
+	if strings.Contains(sql, "/*") || strings.Contains(sql, "--") || strings.Contains(sql, "#") {
+		return false, false
+	}
+
+	sql = strings.TrimLeftFunc(sql, unicode.IsSpace)
+	end := 0
+	for end < len(sql) && isWordChar(rune(sql[end])) {
+		end++
+	}
+	keyword := sql[:end]
+	switch {
+	case strings.EqualFold(keyword, "INSERT"), strings.EqualFold(keyword, "UPDATE"), strings.EqualFold(keyword, "DELETE"):
+		return true, true
+	case strings.EqualFold(keyword, "SELECT"):
+		upper := strings.ToUpper(sql)
+		for _, marker := range plainSelectMarkers {
+			if strings.Contains(upper, marker) {
+				return false, false
+			}
+		}
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserFactory_ClassifyAccess(t *testing.T) {
+	factory := NewParserFactory()
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected StatementAccess
+	}{
+		{"select", "SELECT * FROM users", StatementAccess{Type: SQLTypeSelect}},
+		{"insert", "INSERT INTO users VALUES (1)", StatementAccess{Type: SQLTypeInsert, Write: true}},
+		{"leading comment", "/* trace */ -- note\nUPDATE users SET name = 'a'", StatementAccess{Type: SQLTypeUpdate, Write: true}},
+		{"mysql hash comment", "# note\nDELETE FROM users", StatementAccess{Type: SQLTypeDelete, Write: true}},
+		{"for update", "SELECT * FROM users FOR UPDATE", StatementAccess{Type: SQLTypeSelect, Write: true, LockingRead: true}},
+		{"for no key update", "SELECT * FROM users FOR NO KEY UPDATE", StatementAccess{Type: SQLTypeSelect, Write: true, LockingRead: true}},
+		{"for key share", "SELECT * FROM users FOR KEY SHARE", StatementAccess{Type: SQLTypeSelect, Write: true, LockingRead: true}},
+		{"lock in share mode", "SELECT * FROM users LOCK IN SHARE MODE", StatementAccess{Type: SQLTypeSelect, Write: true, LockingRead: true}},
+		{"nextval", "SELECT nextval('seq')", StatementAccess{Type: SQLTypeSelect, Write: true, SequenceFunction: true}},
+		{"next value for", "SELECT NEXT VALUE FOR seq", StatementAccess{Type: SQLTypeSelect, Write: true, SequenceFunction: true}},
+		{"column named nextval", "SELECT nextval FROM counters", StatementAccess{Type: SQLTypeSelect}},
+		{"writable cte", "WITH d AS (DELETE FROM users RETURNING id) SELECT * FROM d", StatementAccess{Type: SQLTypeOther, Write: true, WritableCTE: true}},
+		{"cte with locking read", "WITH u AS (SELECT * FROM users) SELECT * FROM u FOR UPDATE", StatementAccess{Type: SQLTypeOther, Write: true, LockingRead: true}},
+		{"read-only cte", "WITH u AS (SELECT * FROM users) SELECT * FROM u", StatementAccess{Type: SQLTypeOther}},
+		{"quoted keywords", `SELECT 'FOR UPDATE', "nextval"(1), $$ DELETE $$ FROM users`, StatementAccess{Type: SQLTypeSelect}},
+		{"placeholder", "SELECT * FROM users WHERE id = $1", StatementAccess{Type: SQLTypeSelect}},
+		{"empty", "  /* only a comment */ ", StatementAccess{Type: SQLTypeOther}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, &tt.expected, factory.ClassifyAccess(tt.sql))
+		})
+	}
+}
+
+func TestParseCommentHints(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		hints    map[string]string
+		stripped string
+	}{
+		{
+			name:     "no hint",
+			sql:      "/* trace */ SELECT 1",
+			stripped: "/* trace */ SELECT 1",
+		},
+		{
+			name:     "block comment",
+			sql:      "/* SHARDING: ds=ds_1, table=t_order_3, write_route_only=true */ SELECT * FROM t_order",
+			hints:    map[string]string{"ds": "ds_1", "table": "t_order_3", "write_route_only": "true"},
+			stripped: "SELECT * FROM t_order",
+		},
+		{
+			name:     "optimizer hint style",
+			sql:      "SELECT /*+ sharding: Write_Route_Only=false */ * FROM t_order",
+			hints:    map[string]string{"write_route_only": "false"},
+			stripped: "SELECT  * FROM t_order",
+		},
+		{
+			name:     "line comment",
+			sql:      "SELECT * FROM t_order -- SHARDING: ds=ds_0",
+			hints:    map[string]string{"ds": "ds_0"},
+			stripped: "SELECT * FROM t_order",
+		},
+		{
+			name:     "mysql hash comment",
+			sql:      "# SHARDING: table=t_order_1\nSELECT * FROM t_order",
+			hints:    map[string]string{"table": "t_order_1"},
+			stripped: "SELECT * FROM t_order",
+		},
+		{
+			name:     "hint in dollar quoted string",
+			sql:      "SELECT $$-- SHARDING: ds=ds_0$$ FROM t_order",
+			stripped: "SELECT $$-- SHARDING: ds=ds_0$$ FROM t_order",
+		},
+		{
+			name:     "hint in literal",
+			sql:      "SELECT '/* SHARDING: ds=ds_0 */' FROM t_order",
+			stripped: "SELECT '/* SHARDING: ds=ds_0 */' FROM t_order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hints, stripped := ParseCommentHints(tt.sql)
+			assert.Equal(t, tt.hints, hints)
+			assert.Equal(t, tt.stripped, stripped)
+		})
+	}
+}
+
+func TestPlainStatementWrite(t *testing.T) {
+	factory := NewParserFactory()
+
+	tests := []struct {
+		name  string
+		sql   string
+		write bool
+		ok    bool
+	}{
+		{"select", "SELECT * FROM users WHERE id = ?", false, true},
+		{"lower case insert", "  insert into users values (1)", true, true},
+		{"update", "UPDATE users SET name = 'a'", true, true},
+		{"delete", "DELETE FROM users", true, true},
+		{"for update", "SELECT * FROM users FOR UPDATE", false, false},
+		{"lock in share mode", "SELECT * FROM users LOCK IN SHARE MODE", false, false},
+		{"nextval", "SELECT nextval('seq')", false, false},
+		{"last insert id", "select last_insert_id()", false, false},
+		{"comment hint", "/* write_route_only=true */ SELECT * FROM users", false, false},
+		{"line comment", "SELECT * FROM users -- note", false, false},
+		{"writable cte", "WITH d AS (DELETE FROM users RETURNING id) SELECT * FROM d", false, false},
+		{"other statement", "SHOW TABLES", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write, ok := PlainStatementWrite(tt.sql)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.write, write)
+			// 快速判断的结果必须和完整判断一致
+			if ok {
+				assert.Equal(t, factory.ClassifyAccess(tt.sql).Write, write)
+			}
+		})
+	}
+}
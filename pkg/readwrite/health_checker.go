@@ -0,0 +1,262 @@
+package readwrite
+
+import (
+	"context"
+	"fmt"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/monitoring"
+	"sync"
+	"time"
+)
+
+const (
+	// MetricReplicaHealthy 从库健康状态，1 为健康，0 为已摘除
+	MetricReplicaHealthy = "readwrite_replica_healthy"
+	// MetricHealthCheckFailures 从库健康检查失败次数
+	MetricHealthCheckFailures = "readwrite_health_check_failures_total"
+	// MetricReplicaStateChanges 从库健康状态变化次数
+	MetricReplicaStateChanges = "readwrite_replica_state_changes_total"
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout  = time.Second
+	defaultFailureThreshold    = 3
+	defaultRecoveryThreshold   = 2
+)
+
+// HealthEvent 从库健康状态变化事件
+type HealthEvent struct {
+	// Group 读写分离组名称
+	Group string
+	// Replica 从库数据源名称
+	Replica string
+	// Healthy 变化后的健康状态
+	Healthy bool
+	// Err 导致摘除的最后一次检查错误，恢复时为 nil
+	Err  error
+	Time time.Time
+}
+
+// HealthEventListener 健康状态变化监听器
+type HealthEventListener func(event HealthEvent)
+
+// replicaHealth 单个从库的健康状态
+type replicaHealth struct {
+	index     int
+	name      string
+	healthy   bool
+	failures  int
+	successes int
+
+	healthGauge    *monitoring.GaugeMetric
+	failureCounter *monitoring.CounterMetric
+	changeCounter  *monitoring.CounterMetric
+}
+
+// HealthChecker 读写分离组的从库健康检查器
+// 从库连续失败达到阈值后被摘除，摘除后连续成功达到阈值后恢复
+type HealthChecker struct {
+	splitter  *ReadWriteSplitter
+	config    config.HealthCheckConfig
+	collector *monitoring.MetricsCollector
+	replicas  []*replicaHealth
+	listeners []HealthEventListener
+	running   bool
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	mu        sync.Mutex
+}
+
+// NewHealthChecker 创建健康检查器，未设置的配置项使用默认值，collector 为 nil 时使用独立的收集器
+func NewHealthChecker(splitter *ReadWriteSplitter, cfg *config.HealthCheckConfig, collector *monitoring.MetricsCollector) *HealthChecker {
+	hc := &HealthChecker{
+		splitter:  splitter,
+		collector: collector,
+	}
+	if cfg != nil {
+		hc.config = *cfg
+	}
+	if hc.config.Interval <= 0 {
+		hc.config.Interval = defaultHealthCheckInterval
+	}
+	if hc.config.Timeout <= 0 {
+		hc.config.Timeout = defaultHealthCheckTimeout
+	}
+	if hc.config.FailureThreshold <= 0 {
+		hc.config.FailureThreshold = defaultFailureThreshold
+	}
+	if hc.config.RecoveryThreshold <= 0 {
+		hc.config.RecoveryThreshold = defaultRecoveryThreshold
+	}
+	if hc.collector == nil {
+		hc.collector = monitoring.NewMetricsCollector()
+	}
+
+	group := splitter.config.Name
+	for i, name := range splitter.slaveNames {
+		labels := map[string]string{"group": group, "replica": name}
+		replica := &replicaHealth{
+			index:          i,
+			name:           name,
+			healthy:        true,
+			healthGauge:    monitoring.NewGaugeMetric(MetricReplicaHealthy, labels),
+			failureCounter: monitoring.NewCounterMetric(MetricHealthCheckFailures, labels),
+			changeCounter:  monitoring.NewCounterMetric(MetricReplicaStateChanges, labels),
+		}
+		replica.healthGauge.Set(1)
+		hc.collector.RegisterMetric(replica.healthGauge)
+		hc.collector.RegisterMetric(replica.failureCounter)
+		hc.collector.RegisterMetric(replica.changeCounter)
+		hc.replicas = append(hc.replicas, replica)
+	}
+	return hc
+}
+
+// OnEvent 注册健康状态变化监听器，监听器在检查协程中同步调用
+func (hc *HealthChecker) OnEvent(listener HealthEventListener) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.listeners = append(hc.listeners, listener)
+}
+
+// Start 启动后台健康检查
+func (hc *HealthChecker) Start(ctx context.Context) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.running {
+		return fmt.Errorf("health checker for %s is already running", hc.splitter.config.Name)
+	}
+
+	hc.running = true
+	hc.stopCh = make(chan struct{})
+	hc.doneCh = make(chan struct{})
+	go hc.run(ctx, hc.stopCh, hc.doneCh)
+	return nil
+}
+
+// Stop 停止后台健康检查并等待其退出
+func (hc *HealthChecker) Stop() error {
+	hc.mu.Lock()
+	if !hc.running {
+		hc.mu.Unlock()
+		return nil
+	}
+	hc.running = false
+	close(hc.stopCh)
+	doneCh := hc.doneCh
+	hc.mu.Unlock()
+
+	<-doneCh
+	return nil
+}
+
+// run 定期执行健康检查
+func (hc *HealthChecker) run(ctx context.Context, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(hc.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			hc.CheckOnce(ctx)
+		}
+	}
+}
+
+// CheckOnce 并发检查所有从库一次，并更新读写分离器中的健康状态
+func (hc *HealthChecker) CheckOnce(ctx context.Context) {
+	results := make([]error, len(hc.replicas))
+
+	var wg sync.WaitGroup
+	for i, replica := range hc.replicas {
+		wg.Add(1)
+		go func(i int, replica *replicaHealth) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, hc.config.Timeout)
+			defer cancel()
+			results[i] = hc.splitter.slaveDBS[replica.index].PingContext(checkCtx)
+		}(i, replica)
+	}
+	wg.Wait()
+
+	var events []HealthEvent
+	hc.mu.Lock()
+	for i, replica := range hc.replicas {
+		if event, changed := hc.record(replica, results[i]); changed {
+			events = append(events, event)
+		}
+	}
+	listeners := append([]HealthEventListener(nil), hc.listeners...)
+	hc.mu.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// record 记录一次检查结果，健康状态发生变化时返回事件
+func (hc *HealthChecker) record(replica *replicaHealth, err error) (HealthEvent, bool) {
+	if err != nil {
+		replica.failureCounter.Inc()
+		replica.successes = 0
+		replica.failures++
+		if !replica.healthy || replica.failures < hc.config.FailureThreshold {
+			return HealthEvent{}, false
+		}
+	} else {
+		replica.failures = 0
+		replica.successes++
+		if replica.healthy || replica.successes < hc.config.RecoveryThreshold {
+			return HealthEvent{}, false
+		}
+	}
+
+	replica.healthy = err == nil
+	replica.failures = 0
+	replica.successes = 0
+	replica.changeCounter.Inc()
+	if replica.healthy {
+		replica.healthGauge.Set(1)
+	} else {
+		replica.healthGauge.Set(0)
+	}
+	hc.splitter.setSlaveHealthy(replica.index, replica.healthy)
+
+	return HealthEvent{
+		Group:   hc.splitter.config.Name,
+		Replica: replica.name,
+		Healthy: replica.healthy,
+		Err:     err,
+		Time:    time.Now(),
+	}, true
+}
+
+// IsHealthy 获取从库的健康状态
+func (hc *HealthChecker) IsHealthy(replica string) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	for _, r := range hc.replicas {
+		if r.name == replica {
+			return r.healthy
+		}
+	}
+	return false
+}
+
+// GetCollector 获取指标收集器
+func (hc *HealthChecker) GetCollector() *monitoring.MetricsCollector {
+	return hc.collector
+}
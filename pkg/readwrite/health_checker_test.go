@@ -0,0 +1,183 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/internal/sqltest"
+	"go-sharding/pkg/monitoring"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pingFailures = struct {
+	sync.Mutex
+	down map[string]bool
+}{down: make(map[string]bool)}
+
+// setReplicaDown 设置 DSN 对应的数据库是否不可用
+func setReplicaDown(dsn string, down bool) {
+	pingFailures.Lock()
+	defer pingFailures.Unlock()
+	pingFailures.down[dsn] = down
+}
+
+func init() {
+	// Ping 结果按 DSN 注入故障的模拟驱动
+	sql.Register("readwrite-ping", &sqltest.Driver{Ping: func(dsn string) error {
+		pingFailures.Lock()
+		defer pingFailures.Unlock()
+		if pingFailures.down[dsn] {
+			return driver.ErrBadConn
+		}
+		return nil
+	}})
+}
+
+// newPingSplitter 创建使用可控 Ping 驱动的读写分离器
+func newPingSplitter(t *testing.T, healthCheck *config.HealthCheckConfig) (*ReadWriteSplitter, map[*sql.DB]string) {
+	dataSources := make(map[string]*sql.DB)
+	names := make(map[*sql.DB]string)
+	for _, name := range []string{"master", "slave1", "slave2"} {
+		dsn := t.Name() + "/" + name
+		setReplicaDown(dsn, false)
+		db, err := sql.Open("readwrite-ping", dsn)
+		require.NoError(t, err)
+		dataSources[name] = db
+		names[db] = name
+	}
+
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:                 "rw_0",
+		MasterDataSource:     "master",
+		SlaveDataSources:     []string{"slave1", "slave2"},
+		LoadBalanceAlgorithm: string(RoundRobin),
+		HealthCheck:          healthCheck,
+	}, dataSources)
+	require.NoError(t, err)
+	t.Cleanup(func() { splitter.Close() })
+	return splitter, names
+}
+
+// metricValue 按名称和标签查找指标值
+func metricValue(collector *monitoring.MetricsCollector, name string, labels map[string]string) interface{} {
+	for _, metric := range collector.GetAllMetrics() {
+		if metric.GetName() == name && assert.ObjectsAreEqual(labels, metric.GetLabels()) {
+			return metric.GetValue()
+		}
+	}
+	return nil
+}
+
+func TestHealthChecker_EjectAndRecover(t *testing.T) {
+	splitter, names := newPingSplitter(t, &config.HealthCheckConfig{
+		Enabled:           true,
+		Interval:          time.Hour,
+		FailureThreshold:  2,
+		RecoveryThreshold: 2,
+	})
+	checker := splitter.GetHealthChecker()
+	require.NotNil(t, checker)
+
+	var events []HealthEvent
+	checker.OnEvent(func(event HealthEvent) { events = append(events, event) })
+	ctx := context.Background()
+	slave1 := t.Name() + "/slave1"
+
+	// 未达到失败阈值时仍在轮询中
+	setReplicaDown(slave1, true)
+	checker.CheckOnce(ctx)
+	assert.True(t, checker.IsHealthy("slave1"))
+	assert.Empty(t, events)
+
+	checker.CheckOnce(ctx)
+	assert.False(t, checker.IsHealthy("slave1"))
+	require.Len(t, events, 1)
+	assert.Equal(t, "rw_0", events[0].Group)
+	assert.Equal(t, "slave1", events[0].Replica)
+	assert.False(t, events[0].Healthy)
+	assert.Error(t, events[0].Err)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, "slave2", names[splitter.Route("SELECT 1")])
+	}
+
+	// 连续成功达到恢复阈值后重新加入轮询
+	setReplicaDown(slave1, false)
+	checker.CheckOnce(ctx)
+	assert.False(t, checker.IsHealthy("slave1"))
+	checker.CheckOnce(ctx)
+	assert.True(t, checker.IsHealthy("slave1"))
+	require.Len(t, events, 2)
+	assert.True(t, events[1].Healthy)
+	assert.NoError(t, events[1].Err)
+	assert.ElementsMatch(t, []string{"slave1", "slave2"}, []string{names[splitter.Route("SELECT 1")], names[splitter.Route("SELECT 1")]})
+
+	collector := checker.GetCollector()
+	labels := map[string]string{"group": "rw_0", "replica": "slave1"}
+	assert.Equal(t, float64(1), metricValue(collector, MetricReplicaHealthy, labels))
+	assert.Equal(t, int64(2), metricValue(collector, MetricHealthCheckFailures, labels))
+	assert.Equal(t, int64(2), metricValue(collector, MetricReplicaStateChanges, labels))
+}
+
+func TestHealthChecker_NoHealthyReplica(t *testing.T) {
+	tests := []struct {
+		name             string
+		fallbackToMaster bool
+		expected         []string
+	}{
+		{"fallback to master", true, []string{"master"}},
+		{"keep using replicas", false, []string{"slave1", "slave2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter, names := newPingSplitter(t, &config.HealthCheckConfig{
+				Enabled:          true,
+				Interval:         time.Hour,
+				FailureThreshold: 1,
+				FallbackToMaster: tt.fallbackToMaster,
+			})
+			setReplicaDown(t.Name()+"/slave1", true)
+			setReplicaDown(t.Name()+"/slave2", true)
+			splitter.GetHealthChecker().CheckOnce(context.Background())
+
+			routed := make(map[string]bool)
+			for i := 0; i < 4; i++ {
+				routed[names[splitter.Route("SELECT 1")]] = true
+			}
+			var targets []string
+			for name := range routed {
+				targets = append(targets, name)
+			}
+			assert.ElementsMatch(t, tt.expected, targets)
+		})
+	}
+}
+
+func TestHealthChecker_StartStop(t *testing.T) {
+	splitter, _ := newPingSplitter(t, &config.HealthCheckConfig{
+		Enabled:          true,
+		Interval:         time.Millisecond,
+		FailureThreshold: 1,
+	})
+	checker := splitter.GetHealthChecker()
+	require.NoError(t, splitter.StartHealthCheck(context.Background()))
+	assert.Error(t, checker.Start(context.Background()))
+
+	setReplicaDown(t.Name()+"/slave2", true)
+	assert.Eventually(t, func() bool { return !checker.IsHealthy("slave2") }, time.Second, time.Millisecond)
+
+	require.NoError(t, checker.Stop())
+	require.NoError(t, checker.Stop())
+}
+
+func TestReadWriteSplitter_HealthCheckDisabled(t *testing.T) {
+	splitter, _ := newPingSplitter(t, nil)
+	assert.Nil(t, splitter.GetHealthChecker())
+	assert.NoError(t, splitter.StartHealthCheck(context.Background()))
+}
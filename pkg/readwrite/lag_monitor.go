@@ -0,0 +1,271 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/monitoring"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricReplicaLag 从库复制延迟（秒），探测失败时为 -1
+const MetricReplicaLag = "readwrite_replica_lag_seconds"
+
+const (
+	defaultLagCheckInterval = 5 * time.Second
+	defaultLagCheckTimeout  = time.Second
+)
+
+var (
+	// errReplicationNotRunning 从库未配置复制或复制已停止，延迟未知
+	errReplicationNotRunning = errors.New("replication is not running")
+	// errLagNotProbed 延迟检测尚未探测过该从库或已停止，延迟未知
+	errLagNotProbed = errors.New("replication lag has not been probed")
+)
+
+// LagProber 复制延迟探测器
+type LagProber interface {
+	// ProbeLag 查询从库相对主库的复制延迟
+	ProbeLag(ctx context.Context, db *sql.DB) (time.Duration, error)
+}
+
+// NewLagProber 根据数据库类型创建复制延迟探测器
+func NewLagProber(dbType database.DatabaseType) LagProber {
+	if dbType == database.PostgreSQL {
+		return PostgreSQLLagProber{}
+	}
+	return MySQLLagProber{}
+}
+
+// MySQLLagProber 通过 SHOW REPLICA STATUS 的 Seconds_Behind_Source 探测延迟
+type MySQLLagProber struct{}
+
+// ProbeLag 查询 MySQL 从库的复制延迟
+func (MySQLLagProber) ProbeLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL 8.0.22 之前的版本只支持 SHOW SLAVE STATUS
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, fmt.Errorf("failed to query replica status: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replica status columns: %w", err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to read replica status: %w", err)
+		}
+		return 0, errReplicationNotRunning
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, fmt.Errorf("failed to scan replica status: %w", err)
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		// 复制线程未运行时该列为 NULL
+		if values[i] == nil {
+			return 0, errReplicationNotRunning
+		}
+		seconds, err := strconv.ParseFloat(string(values[i]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: %w", column, values[i], err)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("replica status has no Seconds_Behind_Source column")
+}
+
+// PostgreSQLLagProber 通过 pg_last_xact_replay_timestamp() 探测延迟
+type PostgreSQLLagProber struct{}
+
+// postgreSQLLagQuery 已接收的 WAL 全部回放完毕时延迟为 0，避免主库空闲时延迟被误判为持续增长
+const postgreSQLLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN NULL
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+END`
+
+// ProbeLag 查询 PostgreSQL 从库的复制延迟
+func (PostgreSQLLagProber) ProbeLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	if err := db.QueryRowContext(ctx, postgreSQLLagQuery).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to query replay timestamp: %w", err)
+	}
+	if !seconds.Valid {
+		return 0, errReplicationNotRunning
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// maxStalenessKey 单次查询允许的最大复制延迟在上下文中的键
+type maxStalenessKey struct{}
+
+// WithMaxStaleness 为上下文中的读请求设置允许的最大复制延迟，覆盖配置中的 maxLagSeconds
+// 延迟超过该值或延迟未知的从库会被跳过，没有满足条件的从库时读请求路由到主库；
+// 未启用或未启动延迟检测时所有从库的延迟都未知，读请求总是路由到主库
+func WithMaxStaleness(ctx context.Context, maxStaleness time.Duration) context.Context {
+	return context.WithValue(ctx, maxStalenessKey{}, maxStaleness)
+}
+
+// MaxStalenessFromContext 获取上下文中设置的最大复制延迟
+func MaxStalenessFromContext(ctx context.Context) (time.Duration, bool) {
+	maxStaleness, ok := ctx.Value(maxStalenessKey{}).(time.Duration)
+	return maxStaleness, ok
+}
+
+// LagMonitor 读写分离组的从库复制延迟监控器
+type LagMonitor struct {
+	splitter  *ReadWriteSplitter
+	config    config.ReplicationLagConfig
+	prober    LagProber
+	collector *monitoring.MetricsCollector
+	gauges    []*monitoring.GaugeMetric
+	running   bool
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	mu        sync.Mutex
+}
+
+// NewLagMonitor 创建复制延迟监控器，prober 为 nil 时按 MySQL 探测，collector 为 nil 时使用独立的收集器
+func NewLagMonitor(splitter *ReadWriteSplitter, cfg *config.ReplicationLagConfig, prober LagProber, collector *monitoring.MetricsCollector) *LagMonitor {
+	lm := &LagMonitor{
+		splitter:  splitter,
+		prober:    prober,
+		collector: collector,
+	}
+	if cfg != nil {
+		lm.config = *cfg
+	}
+	if lm.config.Interval <= 0 {
+		lm.config.Interval = defaultLagCheckInterval
+	}
+	if lm.config.Timeout <= 0 {
+		lm.config.Timeout = defaultLagCheckTimeout
+	}
+	if lm.prober == nil {
+		lm.prober = MySQLLagProber{}
+	}
+	if lm.collector == nil {
+		lm.collector = monitoring.NewMetricsCollector()
+	}
+
+	for _, name := range splitter.slaveNames {
+		gauge := monitoring.NewGaugeMetric(MetricReplicaLag, map[string]string{"group": splitter.config.Name, "replica": name})
+		lm.collector.RegisterMetric(gauge)
+		lm.gauges = append(lm.gauges, gauge)
+	}
+	return lm
+}
+
+// SetLagProber 设置复制延迟探测器
+func (lm *LagMonitor) SetLagProber(prober LagProber) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.prober = prober
+}
+
+// Start 启动后台延迟检测，启动后立即探测一次
+func (lm *LagMonitor) Start(ctx context.Context) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if lm.running {
+		return fmt.Errorf("lag monitor for %s is already running", lm.splitter.config.Name)
+	}
+
+	lm.running = true
+	lm.stopCh = make(chan struct{})
+	lm.doneCh = make(chan struct{})
+	go lm.run(ctx, lm.stopCh, lm.doneCh)
+	return nil
+}
+
+// Stop 停止后台延迟检测并等待其退出，停止后所有从库的延迟重置为未知
+func (lm *LagMonitor) Stop() error {
+	lm.mu.Lock()
+	if !lm.running {
+		lm.mu.Unlock()
+		return nil
+	}
+	lm.running = false
+	close(lm.stopCh)
+	doneCh := lm.doneCh
+	lm.mu.Unlock()
+
+	<-doneCh
+	lm.splitter.resetSlaveLags()
+	return nil
+}
+
+// run 定期检测复制延迟
+func (lm *LagMonitor) run(ctx context.Context, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	lm.ProbeOnce(ctx)
+
+	ticker := time.NewTicker(lm.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			lm.ProbeOnce(ctx)
+		}
+	}
+}
+
+// ProbeOnce 并发检测所有从库的复制延迟一次，探测失败的从库视为延迟未知
+func (lm *LagMonitor) ProbeOnce(ctx context.Context) {
+	lm.mu.Lock()
+	prober := lm.prober
+	lm.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, db := range lm.splitter.slaveDBS {
+		wg.Add(1)
+		go func(i int, db *sql.DB) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, lm.config.Timeout)
+			defer cancel()
+
+			lag, err := prober.ProbeLag(probeCtx, db)
+			if err != nil {
+				lm.gauges[i].Set(-1)
+			} else {
+				lm.gauges[i].Set(lag.Seconds())
+			}
+			lm.splitter.setSlaveLag(i, lag, err)
+		}(i, db)
+	}
+	wg.Wait()
+}
+
+// GetCollector 获取指标收集器
+func (lm *LagMonitor) GetCollector() *monitoring.MetricsCollector {
+	return lm.collector
+}
@@ -0,0 +1,236 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLagProber 按数据库返回预设延迟的探测器
+type stubLagProber struct {
+	mu   sync.Mutex
+	lags map[*sql.DB]time.Duration
+	errs map[*sql.DB]error
+}
+
+func (p *stubLagProber) ProbeLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lags[db], p.errs[db]
+}
+
+func TestLagMonitor_SkipsLaggingReplicas(t *testing.T) {
+	masterDB := &sql.DB{}
+	slave1DB := &sql.DB{}
+	slave2DB := &sql.DB{}
+	names := map[*sql.DB]string{masterDB: "master", slave1DB: "slave1", slave2DB: "slave2"}
+
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:                 "rw_0",
+		MasterDataSource:     "master",
+		SlaveDataSources:     []string{"slave1", "slave2"},
+		LoadBalanceAlgorithm: string(RoundRobin),
+		ReplicationLag:       &config.ReplicationLagConfig{Enabled: true, Interval: time.Hour, MaxLagSeconds: 1},
+	}, map[string]*sql.DB{"master": masterDB, "slave1": slave1DB, "slave2": slave2DB})
+	require.NoError(t, err)
+
+	prober := &stubLagProber{
+		lags: map[*sql.DB]time.Duration{slave1DB: 5 * time.Second, slave2DB: 500 * time.Millisecond},
+		errs: map[*sql.DB]error{},
+	}
+	monitor := splitter.GetLagMonitor()
+	require.NotNil(t, monitor)
+	monitor.SetLagProber(prober)
+	monitor.ProbeOnce(context.Background())
+
+	routeAll := func(ctx context.Context) []string {
+		routed := make(map[string]bool)
+		for i := 0; i < 4; i++ {
+			routed[names[splitter.RouteContext(ctx, "SELECT 1")]] = true
+		}
+		var targets []string
+		for name := range routed {
+			targets = append(targets, name)
+		}
+		return targets
+	}
+
+	// 超过 maxLagSeconds 的从库被跳过
+	assert.ElementsMatch(t, []string{"slave2"}, routeAll(context.Background()))
+	assert.Equal(t, "slave2", names[splitter.Route("SELECT 1")])
+
+	// 上下文中的延迟要求覆盖配置
+	assert.ElementsMatch(t, []string{"slave1", "slave2"}, routeAll(WithMaxStaleness(context.Background(), 10*time.Second)))
+	assert.ElementsMatch(t, []string{"master"}, routeAll(WithMaxStaleness(context.Background(), 100*time.Millisecond)))
+
+	// 延迟未知的从库被排除
+	prober.mu.Lock()
+	prober.errs[slave2DB] = errReplicationNotRunning
+	prober.mu.Unlock()
+	monitor.ProbeOnce(context.Background())
+	assert.ElementsMatch(t, []string{"master"}, routeAll(context.Background()))
+
+	collector := monitor.GetCollector()
+	assert.Equal(t, float64(5), metricValue(collector, MetricReplicaLag, map[string]string{"group": "rw_0", "replica": "slave1"}))
+	assert.Equal(t, float64(-1), metricValue(collector, MetricReplicaLag, map[string]string{"group": "rw_0", "replica": "slave2"}))
+}
+
+func TestLagMonitor_UnknownLagBeforeFirstProbe(t *testing.T) {
+	masterDB := &sql.DB{}
+	slave1DB := &sql.DB{}
+	slave2DB := &sql.DB{}
+	dataSources := map[string]*sql.DB{"master": masterDB, "slave1": slave1DB, "slave2": slave2DB}
+	newSplitter := func(lag *config.ReplicationLagConfig) *ReadWriteSplitter {
+		splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+			Name:                 "rw_0",
+			MasterDataSource:     "master",
+			SlaveDataSources:     []string{"slave1", "slave2"},
+			LoadBalanceAlgorithm: string(RoundRobin),
+			ReplicationLag:       lag,
+		}, dataSources)
+		require.NoError(t, err)
+		return splitter
+	}
+
+	// 创建后尚未探测，延迟未知的从库不接收有延迟要求的读请求
+	splitter := newSplitter(&config.ReplicationLagConfig{Enabled: true, Interval: time.Hour, MaxLagSeconds: 1})
+	assert.Same(t, masterDB, splitter.Route("SELECT 1"))
+
+	// 启动后立即探测一次，不必等待第一个检测间隔
+	prober := &stubLagProber{
+		lags: map[*sql.DB]time.Duration{slave1DB: 5 * time.Second, slave2DB: 500 * time.Millisecond},
+		errs: map[*sql.DB]error{},
+	}
+	splitter.GetLagMonitor().SetLagProber(prober)
+	require.NoError(t, splitter.StartLagMonitor(context.Background()))
+	require.Eventually(t, func() bool {
+		return splitter.Route("SELECT 1") == slave2DB
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 4; i++ {
+		assert.Same(t, slave2DB, splitter.Route("SELECT 1"))
+	}
+
+	// 停止后旧的探测结果不再使用
+	splitter.StopBackgroundChecks()
+	assert.Same(t, masterDB, splitter.Route("SELECT 1"))
+
+	// 未启用延迟检测时不限制延迟的读请求照常路由到从库，有延迟要求的读请求路由到主库
+	splitter = newSplitter(nil)
+	assert.NotSame(t, masterDB, splitter.Route("SELECT 1"))
+	assert.Same(t, masterDB, splitter.RouteContext(WithMaxStaleness(context.Background(), time.Minute), "SELECT 1"))
+}
+
+var statusResults = struct {
+	sync.Mutex
+	results map[string]map[string]sqltest.Result // DSN -> 语句前缀 -> 结果
+}{results: make(map[string]map[string]sqltest.Result)}
+
+func init() {
+	// 按 DSN 和语句前缀返回预设结果的模拟驱动，用于验证延迟探测语句
+	sql.Register("readwrite-status", &sqltest.Driver{Handler: func(dsn, query string, args []driver.Value) sqltest.Result {
+		statusResults.Lock()
+		defer statusResults.Unlock()
+		for prefix, result := range statusResults.results[dsn] {
+			if strings.HasPrefix(query, prefix) {
+				return result
+			}
+		}
+		return sqltest.Result{Err: errors.New("unexpected query: " + query)}
+	}})
+}
+
+// openStatusDB 打开返回预设结果的数据库
+func openStatusDB(t *testing.T, results map[string]sqltest.Result) *sql.DB {
+	dsn := t.Name()
+	statusResults.Lock()
+	statusResults.results[dsn] = results
+	statusResults.Unlock()
+
+	db, err := sql.Open("readwrite-status", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMySQLLagProber(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     map[string]sqltest.Result
+		expected    time.Duration
+		expectError bool
+	}{
+		{
+			name: "seconds behind source",
+			results: map[string]sqltest.Result{"SHOW REPLICA STATUS": {
+				Columns: []string{"Replica_IO_State", "Seconds_Behind_Source"},
+				Rows:    [][]driver.Value{{"Waiting for source", "3"}},
+			}},
+			expected: 3 * time.Second,
+		},
+		{
+			name: "legacy slave status",
+			results: map[string]sqltest.Result{
+				"SHOW REPLICA STATUS": {Err: errors.New("syntax error")},
+				"SHOW SLAVE STATUS": {
+					Columns: []string{"Slave_IO_State", "Seconds_Behind_Master"},
+					Rows:    [][]driver.Value{{"Waiting for master", "0"}},
+				},
+			},
+			expected: 0,
+		},
+		{
+			name: "replication stopped",
+			results: map[string]sqltest.Result{"SHOW REPLICA STATUS": {
+				Columns: []string{"Seconds_Behind_Source"},
+				Rows:    [][]driver.Value{{nil}},
+			}},
+			expectError: true,
+		},
+		{
+			name:        "not a replica",
+			results:     map[string]sqltest.Result{"SHOW REPLICA STATUS": {Columns: []string{"Seconds_Behind_Source"}}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openStatusDB(t, tt.results)
+			lag, err := NewLagProber(database.MySQL).ProbeLag(context.Background(), db)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, lag)
+		})
+	}
+}
+
+func TestPostgreSQLLagProber(t *testing.T) {
+	db := openStatusDB(t, map[string]sqltest.Result{"SELECT CASE": {
+		Columns: []string{"lag"},
+		Rows:    [][]driver.Value{{1.5}},
+	}})
+	lag, err := NewLagProber(database.PostgreSQL).ProbeLag(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, lag)
+
+	primary := openStatusDB(t, map[string]sqltest.Result{"SELECT CASE": {
+		Columns: []string{"lag"},
+		Rows:    [][]driver.Value{{nil}},
+	}})
+	_, err = NewLagProber(database.PostgreSQL).ProbeLag(context.Background(), primary)
+	assert.Error(t, err)
+}
@@ -0,0 +1,298 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEWMADecay 新观测延迟在移动平均中的占比
+	defaultEWMADecay = 0.3
+	// ewmaStaleAfter 从库超过该时间没有新的延迟观测时重新探测
+	ewmaStaleAfter = 10 * time.Second
+	// ewmaErrorPenalty 查询失败时计入移动平均的最小延迟
+	ewmaErrorPenalty = time.Second
+)
+
+// Replica 负载均衡的候选从库
+type Replica struct {
+	// Name 从库数据源名称
+	Name string
+	DB   *sql.DB
+	// Weight 从库权重，未配置时为 1
+	Weight int
+}
+
+// LoadBalancer 从库负载均衡器，实现需要并发安全
+type LoadBalancer interface {
+	// Select 从候选从库中选择一个并返回其下标，返回负数时读请求路由到主库
+	Select(replicas []Replica) int
+}
+
+// LatencyObserver 需要观测从库查询延迟的负载均衡器实现该接口
+type LatencyObserver interface {
+	// ObserveLatency 记录一次在从库上执行查询的耗时和结果
+	ObserveLatency(replica string, latency time.Duration, err error)
+}
+
+var (
+	loadBalancers = map[string]func() LoadBalancer{
+		string(RoundRobin):       func() LoadBalancer { return NewRoundRobinBalancer() },
+		string(Random):           func() LoadBalancer { return NewRandomBalancer() },
+		string(Weight):           func() LoadBalancer { return NewWeightBalancer() },
+		string(LeastConnections): func() LoadBalancer { return NewLeastConnectionsBalancer() },
+		string(EWMALatency):      func() LoadBalancer { return NewEWMALatencyBalancer(defaultEWMADecay) },
+	}
+	loadBalancersMu sync.RWMutex
+)
+
+// RegisterLoadBalancer 注册负载均衡算法，之后创建的读写分离器可以通过 loadBalanceAlgorithm 使用该算法
+// 每个读写分离器通过 creator 创建独立的负载均衡器实例
+func RegisterLoadBalancer(algorithm string, creator func() LoadBalancer) {
+	loadBalancersMu.Lock()
+	defer loadBalancersMu.Unlock()
+
+	loadBalancers[algorithm] = creator
+}
+
+// NewLoadBalancer 根据算法名称创建负载均衡器，名称为空时使用轮询
+func NewLoadBalancer(algorithm string) (LoadBalancer, error) {
+	if algorithm == "" {
+		algorithm = string(RoundRobin)
+	}
+
+	loadBalancersMu.RLock()
+	creator, exists := loadBalancers[algorithm]
+	loadBalancersMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unsupported load balance algorithm: %s", algorithm)
+	}
+	return creator(), nil
+}
+
+// GetAvailableLoadBalancers 获取已注册的负载均衡算法
+func GetAvailableLoadBalancers() []string {
+	loadBalancersMu.RLock()
+	defer loadBalancersMu.RUnlock()
+
+	algorithms := make([]string, 0, len(loadBalancers))
+	for algorithm := range loadBalancers {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// RoundRobinBalancer 轮询负载均衡器
+type RoundRobinBalancer struct {
+	next int
+	mu   sync.Mutex
+}
+
+// NewRoundRobinBalancer 创建轮询负载均衡器
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+// Select 依次选择候选从库
+func (b *RoundRobinBalancer) Select(replicas []Replica) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	selected := b.next % len(replicas)
+	b.next = (selected + 1) % len(replicas)
+	return selected
+}
+
+// RandomBalancer 随机负载均衡器
+type RandomBalancer struct {
+	rand *rand.Rand
+	mu   sync.Mutex
+}
+
+// NewRandomBalancer 创建随机负载均衡器
+func NewRandomBalancer() *RandomBalancer {
+	return &RandomBalancer{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Select 随机选择候选从库
+func (b *RandomBalancer) Select(replicas []Replica) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.rand.Intn(len(replicas))
+}
+
+// WeightBalancer 平滑加权轮询负载均衡器
+// 每次选择时所有从库的当前权重加上各自的权重，选出当前权重最大的从库后减去总权重，
+// 使高权重从库的请求均匀地分散在序列中；所有从库权重都为 0 时读请求路由到主库
+type WeightBalancer struct {
+	weights        map[string]int
+	currentWeights map[string]int
+	mu             sync.Mutex
+}
+
+// NewWeightBalancer 创建平滑加权轮询负载均衡器
+func NewWeightBalancer() *WeightBalancer {
+	return &WeightBalancer{
+		weights:        make(map[string]int),
+		currentWeights: make(map[string]int),
+	}
+}
+
+// Select 按权重选择候选从库
+func (b *WeightBalancer) Select(replicas []Replica) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 权重调整后重置当前权重，避免调整前累积的权重影响后续分配
+	for _, replica := range replicas {
+		if weight, exists := b.weights[replica.Name]; exists && weight != replica.Weight {
+			b.currentWeights = make(map[string]int)
+			break
+		}
+	}
+
+	totalWeight := 0
+	selected := -1
+	for i, replica := range replicas {
+		b.weights[replica.Name] = replica.Weight
+		if replica.Weight <= 0 {
+			continue
+		}
+		b.currentWeights[replica.Name] += replica.Weight
+		totalWeight += replica.Weight
+		if selected < 0 || b.currentWeights[replica.Name] > b.currentWeights[replicas[selected].Name] {
+			selected = i
+		}
+	}
+
+	if selected >= 0 {
+		b.currentWeights[replicas[selected].Name] -= totalWeight
+	}
+	return selected
+}
+
+// LeastConnectionsBalancer 最少连接负载均衡器，选择正在使用的连接数最少的从库
+type LeastConnectionsBalancer struct {
+	next int
+	mu   sync.Mutex
+}
+
+// NewLeastConnectionsBalancer 创建最少连接负载均衡器
+func NewLeastConnectionsBalancer() *LeastConnectionsBalancer {
+	return &LeastConnectionsBalancer{}
+}
+
+// Select 根据 sql.DB.Stats().InUse 选择连接数最少的从库，连接数相同时轮流选择
+func (b *LeastConnectionsBalancer) Select(replicas []Replica) int {
+	scores := make([]float64, len(replicas))
+	for i, replica := range replicas {
+		scores[i] = float64(replica.DB.Stats().InUse)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	selected := selectMinScore(scores, b.next)
+	b.next++
+	return selected
+}
+
+// EWMALatencyBalancer 延迟负载均衡器，选择查询延迟指数加权移动平均最低的从库
+// 没有延迟观测或观测已过期的从库优先被选中，以便获取其最新延迟
+type EWMALatencyBalancer struct {
+	decay     float64
+	latencies map[string]ewmaLatency
+	next      int
+	mu        sync.Mutex
+}
+
+// ewmaLatency 从库的延迟移动平均
+type ewmaLatency struct {
+	value    float64
+	observed time.Time
+}
+
+// NewEWMALatencyBalancer 创建延迟负载均衡器，decay 为新观测延迟的占比，不在 (0, 1] 范围内时使用 0.3
+func NewEWMALatencyBalancer(decay float64) *EWMALatencyBalancer {
+	if decay <= 0 || decay > 1 {
+		decay = defaultEWMADecay
+	}
+	return &EWMALatencyBalancer{
+		decay:     decay,
+		latencies: make(map[string]ewmaLatency),
+	}
+}
+
+// Select 选择延迟移动平均最低的从库，延迟相同时轮流选择
+func (b *EWMALatencyBalancer) Select(replicas []Replica) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	scores := make([]float64, len(replicas))
+	for i, replica := range replicas {
+		if latency, exists := b.latencies[replica.Name]; exists && now.Sub(latency.observed) <= ewmaStaleAfter {
+			scores[i] = latency.value
+		}
+	}
+
+	selected := selectMinScore(scores, b.next)
+	b.next++
+	return selected
+}
+
+// ObserveLatency 将查询耗时计入从库的延迟移动平均，失败的查询至少按 1s 计入，取消的查询不计入
+func (b *EWMALatencyBalancer) ObserveLatency(replica string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil && latency < ewmaErrorPenalty {
+		latency = ewmaErrorPenalty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sample := float64(latency)
+	current, exists := b.latencies[replica]
+	if exists {
+		sample = b.decay*sample + (1-b.decay)*current.value
+	}
+	b.latencies[replica] = ewmaLatency{value: sample, observed: time.Now()}
+}
+
+// GetLatencies 获取各从库当前的延迟移动平均
+func (b *EWMALatencyBalancer) GetLatencies() map[string]time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	latencies := make(map[string]time.Duration, len(b.latencies))
+	for name, latency := range b.latencies {
+		latencies[name] = time.Duration(latency.value)
+	}
+	return latencies
+}
+
+// selectMinScore 选择分值最小的下标，多个候选分值相同时按 counter 轮流选择
+func selectMinScore(scores []float64, counter int) int {
+	var ties []int
+	for i, score := range scores {
+		switch {
+		case len(ties) == 0 || score < scores[ties[0]]:
+			ties = append(ties[:0], i)
+		case score == scores[ties[0]]:
+			ties = append(ties, i)
+		}
+	}
+	return ties[counter%len(ties)]
+}
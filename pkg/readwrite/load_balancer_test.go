@@ -0,0 +1,183 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-sharding/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// firstReplicaBalancer 总是选择第一个候选从库的自定义负载均衡器
+type firstReplicaBalancer struct{}
+
+func (firstReplicaBalancer) Select(replicas []Replica) int { return 0 }
+
+// selectNames 连续选择 n 次并返回选中的从库名称
+func selectNames(lb LoadBalancer, replicas []Replica, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		if selected := lb.Select(replicas); selected >= 0 {
+			names[i] = replicas[selected].Name
+		}
+	}
+	return names
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		expected  LoadBalancer
+	}{
+		{"", &RoundRobinBalancer{}},
+		{"round_robin", &RoundRobinBalancer{}},
+		{"random", &RandomBalancer{}},
+		{"weight", &WeightBalancer{}},
+		{"least_connections", &LeastConnectionsBalancer{}},
+		{"ewma_latency", &EWMALatencyBalancer{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			lb, err := NewLoadBalancer(tt.algorithm)
+			require.NoError(t, err)
+			assert.IsType(t, tt.expected, lb)
+		})
+	}
+
+	_, err := NewLoadBalancer("unknown_algorithm")
+	assert.Error(t, err)
+}
+
+func TestRegisterLoadBalancer(t *testing.T) {
+	RegisterLoadBalancer("first_replica", func() LoadBalancer { return firstReplicaBalancer{} })
+	assert.Contains(t, GetAvailableLoadBalancers(), "first_replica")
+
+	master, slave1, slave2 := &sql.DB{}, &sql.DB{}, &sql.DB{}
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:                 "rw_0",
+		MasterDataSource:     "master",
+		SlaveDataSources:     []string{"slave1", "slave2"},
+		LoadBalanceAlgorithm: "first_replica",
+	}, map[string]*sql.DB{"master": master, "slave1": slave1, "slave2": slave2})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Same(t, slave1, splitter.Route("SELECT 1"))
+	}
+
+	// 返回负数时路由到主库
+	splitter.SetLoadBalancer(noReplicaBalancer{})
+	assert.Same(t, master, splitter.Route("SELECT 1"))
+}
+
+// noReplicaBalancer 不选择任何从库的负载均衡器
+type noReplicaBalancer struct{}
+
+func (noReplicaBalancer) Select(replicas []Replica) int { return -1 }
+
+func TestWeightBalancer(t *testing.T) {
+	lb := NewWeightBalancer()
+	replicas := []Replica{{Name: "a", Weight: 5}, {Name: "b", Weight: 1}, {Name: "c", Weight: 1}}
+
+	assert.Equal(t, []string{"a", "a", "b", "a", "c", "a", "a"}, selectNames(lb, replicas, 7))
+
+	// 调整权重后重新开始分配
+	replicas[1].Weight = 0
+	assert.Equal(t, []string{"a", "a", "a", "c", "a", "a"}, selectNames(lb, replicas, 6))
+
+	assert.Equal(t, -1, lb.Select([]Replica{{Name: "a"}, {Name: "b"}}))
+}
+
+func TestLeastConnectionsBalancer(t *testing.T) {
+	ctx := context.Background()
+	replicas := make([]Replica, 3)
+	for i, name := range []string{"slave1", "slave2", "slave3"} {
+		db, err := sql.Open("readwrite-ping", t.Name()+"/"+name)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		replicas[i] = Replica{Name: name, DB: db, Weight: 1}
+	}
+
+	// slave1 占用两个连接，slave2 占用一个连接
+	for _, i := range []int{0, 0, 1} {
+		conn, err := replicas[i].DB.Conn(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+	}
+
+	lb := NewLeastConnectionsBalancer()
+	assert.Equal(t, []string{"slave3", "slave3", "slave3"}, selectNames(lb, replicas, 3))
+
+	conn, err := replicas[2].DB.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	extra, err := replicas[2].DB.Conn(ctx)
+	require.NoError(t, err)
+	defer extra.Close()
+	assert.Equal(t, []string{"slave2", "slave2"}, selectNames(lb, replicas, 2))
+
+	// 连接数相同时轮流选择
+	require.NoError(t, extra.Close())
+	assert.ElementsMatch(t, []string{"slave2", "slave3"}, selectNames(lb, replicas, 2))
+}
+
+func TestEWMALatencyBalancer(t *testing.T) {
+	lb := NewEWMALatencyBalancer(0.5)
+	replicas := []Replica{{Name: "slave1"}, {Name: "slave2"}, {Name: "slave3"}}
+
+	lb.ObserveLatency("slave1", 10*time.Millisecond, nil)
+	lb.ObserveLatency("slave2", 20*time.Millisecond, nil)
+
+	// 没有延迟观测的从库优先被选中
+	assert.Equal(t, "slave3", replicas[lb.Select(replicas)].Name)
+
+	lb.ObserveLatency("slave3", 30*time.Millisecond, nil)
+	assert.Equal(t, []string{"slave1", "slave1"}, selectNames(lb, replicas, 2))
+
+	// 10ms 与 50ms 按 0.5 加权平均为 30ms
+	lb.ObserveLatency("slave1", 50*time.Millisecond, nil)
+	assert.Equal(t, 30*time.Millisecond, lb.GetLatencies()["slave1"])
+	assert.Equal(t, "slave2", replicas[lb.Select(replicas)].Name)
+
+	// 查询失败至少按 1s 计入，取消的查询不计入
+	lb.ObserveLatency("slave2", time.Millisecond, errors.New("connection reset"))
+	assert.Greater(t, lb.GetLatencies()["slave2"], 500*time.Millisecond)
+	lb.ObserveLatency("slave1", time.Hour, context.Canceled)
+	assert.Equal(t, 30*time.Millisecond, lb.GetLatencies()["slave1"])
+	// slave1 与 slave3 延迟相同时轮流选择
+	assert.ElementsMatch(t, []string{"slave1", "slave3"}, selectNames(lb, replicas, 2))
+
+	// 观测过期的从库重新参与探测
+	lb.mu.Lock()
+	stale := lb.latencies["slave2"]
+	stale.observed = time.Now().Add(-2 * ewmaStaleAfter)
+	lb.latencies["slave2"] = stale
+	lb.mu.Unlock()
+	assert.Equal(t, "slave2", replicas[lb.Select(replicas)].Name)
+}
+
+func TestReadWriteSplitter_ObserveLatency(t *testing.T) {
+	master, slave1, slave2 := &sql.DB{}, &sql.DB{}, &sql.DB{}
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:                 "rw_0",
+		MasterDataSource:     "master",
+		SlaveDataSources:     []string{"slave1", "slave2"},
+		LoadBalanceAlgorithm: string(EWMALatency),
+	}, map[string]*sql.DB{"master": master, "slave1": slave1, "slave2": slave2})
+	require.NoError(t, err)
+
+	splitter.ObserveLatency(slave1, 80*time.Millisecond, nil)
+	splitter.ObserveLatency(slave2, 5*time.Millisecond, nil)
+	splitter.ObserveLatency(master, time.Millisecond, nil)
+
+	lb := splitter.GetLoadBalancer().(*EWMALatencyBalancer)
+	assert.Equal(t, map[string]time.Duration{"slave1": 80 * time.Millisecond, "slave2": 5 * time.Millisecond}, lb.GetLatencies())
+	for i := 0; i < 3; i++ {
+		assert.Same(t, slave2, splitter.Route("SELECT 1"))
+	}
+}
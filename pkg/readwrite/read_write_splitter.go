@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"go-sharding/pkg/config"
-	"math/rand"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/parser"
+	"log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +21,10 @@ const (
 	RoundRobin LoadBalanceAlgorithm = "round_robin"
 	Random     LoadBalanceAlgorithm = "random"
 	Weight     LoadBalanceAlgorithm = "weight"
+	// LeastConnections 选择正在使用的连接数最少的从库
+	LeastConnections LoadBalanceAlgorithm = "least_connections"
+	// EWMALatency 选择查询延迟指数加权移动平均最低的从库
+	EWMALatency LoadBalanceAlgorithm = "ewma_latency"
 )
 
 // ReadWriteSplitter 读写分离器
@@ -26,17 +33,29 @@ type ReadWriteSplitter struct {
 	dataSources  map[string]*sql.DB
 	masterDB     *sql.DB
 	slaveDBS     []*sql.DB
-	roundRobinIndex int
+	slaveNames   []string
+	weights      []int
+	loadBalancer LoadBalancer
+	healthy      []bool
+	healthChecker *HealthChecker
+	lags         []time.Duration
+	lagErrs      []error
+	lagMonitor   *LagMonitor
+	positionTracker PositionTracker
+	parserFactory *parser.ParserFactory
 	mutex        sync.RWMutex
-	rand         *rand.Rand
 }
 
 // NewReadWriteSplitter 创建读写分离器
 func NewReadWriteSplitter(cfg *config.ReadWriteSplitConfig, dataSources map[string]*sql.DB) (*ReadWriteSplitter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	splitter := &ReadWriteSplitter{
 		config:      cfg,
 		dataSources: dataSources,
-		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		positionTracker: MySQLPositionTracker{},
 	}
 
 	// 获取主库连接
@@ -53,12 +72,39 @@ func NewReadWriteSplitter(cfg *config.ReadWriteSplitConfig, dataSources map[stri
 			return nil, fmt.Errorf("slave data source %s not found", slaveName)
 		}
 		splitter.slaveDBS = append(splitter.slaveDBS, slaveDB)
+		splitter.slaveNames = append(splitter.slaveNames, slaveName)
+		splitter.weights = append(splitter.weights, cfg.GetSlaveWeight(slaveName))
+	}
+	splitter.healthy = make([]bool, len(splitter.slaveDBS))
+	for i := range splitter.healthy {
+		splitter.healthy[i] = true
+	}
+	// 首次探测之前复制延迟未知，有延迟要求的读请求不会路由到这些从库
+	splitter.lags = make([]time.Duration, len(splitter.slaveDBS))
+	splitter.lagErrs = make([]error, len(splitter.slaveDBS))
+	for i := range splitter.lagErrs {
+		splitter.lagErrs[i] = errLagNotProbed
 	}
 
 	if len(splitter.slaveDBS) == 0 {
 		return nil, fmt.Errorf("at least one slave data source is required")
 	}
 
+	// 未注册的算法按轮询处理
+	loadBalancer, err := NewLoadBalancer(cfg.LoadBalanceAlgorithm)
+	if err != nil {
+		log.Printf("read-write split %s: %v, falling back to %s", cfg.Name, err, RoundRobin)
+		loadBalancer = NewRoundRobinBalancer()
+	}
+	splitter.loadBalancer = loadBalancer
+
+	if cfg.HealthCheck != nil && cfg.HealthCheck.Enabled {
+		splitter.healthChecker = NewHealthChecker(splitter, cfg.HealthCheck, nil)
+	}
+	if cfg.ReplicationLag != nil && cfg.ReplicationLag.Enabled {
+		splitter.lagMonitor = NewLagMonitor(splitter, cfg.ReplicationLag, nil, nil)
+	}
+
 	return splitter, nil
 }
 
@@ -67,78 +113,341 @@ func (rws *ReadWriteSplitter) Route(sql string) *sql.DB {
 	if rws.isWriteSQL(sql) {
 		return rws.masterDB
 	}
-	return rws.selectSlaveDB()
+	return rws.selectSlaveDB(rws.maxStaleness(context.Background()))
 }
 
 // RouteContext 根据 SQL 类型和上下文路由到相应的数据库
+// 上下文绑定了读己之写会话时，会话写入后的读请求在一致性窗口内路由到主库
 func (rws *ReadWriteSplitter) RouteContext(ctx context.Context, sql string) *sql.DB {
-	// 检查上下文中是否强制使用主库
-	if forceMaster, ok := ctx.Value("force_master").(bool); ok && forceMaster {
+	// 检查上下文中是否强制使用主库（事务中的读操作也应该路由到主库）
+	if isForceMaster(ctx) {
 		return rws.masterDB
 	}
 
-	// 检查是否在事务中（事务中的读操作也应该路由到主库）
-	if inTransaction, ok := ctx.Value("in_transaction").(bool); ok && inTransaction {
+	session := SessionFromContext(ctx)
+	if rws.isWriteSQL(sql) {
+		if session != nil {
+			session.markWrite(rws, time.Now())
+		}
 		return rws.masterDB
 	}
 
-	return rws.Route(sql)
+	maxStaleness := rws.maxStaleness(ctx)
+	if session != nil {
+		if mark, ok := session.lastWrite(rws, rws.consistencyWindow()); ok {
+			return rws.selectConsistentDB(ctx, mark, maxStaleness)
+		}
+	}
+	return rws.selectSlaveDB(maxStaleness)
+}
+
+// RouteWrite 将写请求路由到主库，上下文绑定了读己之写会话时记录会话写入
+// 执行写语句时使用，不受 SQL 注释提示影响
+func (rws *ReadWriteSplitter) RouteWrite(ctx context.Context) *sql.DB {
+	if session := SessionFromContext(ctx); session != nil {
+		session.markWrite(rws, time.Now())
+	}
+	return rws.masterDB
 }
 
-// isWriteSQL 判断是否为写操作 SQL
+// consistencyWindow 获取会话写入后读主库的时间窗口
+func (rws *ReadWriteSplitter) consistencyWindow() time.Duration {
+	if sc := rws.config.SessionConsistency; sc != nil && sc.Window > 0 {
+		return sc.Window
+	}
+	return defaultConsistencyWindow
+}
+
+// selectConsistentDB 为一致性窗口内的读请求选择数据库
+// 记录了写入位点时，选中的从库已回放到该位点则使用从库，否则使用主库
+func (rws *ReadWriteSplitter) selectConsistentDB(ctx context.Context, mark writeMark, maxStaleness time.Duration) *sql.DB {
+	if mark.position == "" {
+		return rws.masterDB
+	}
+
+	replica := rws.selectSlaveDB(maxStaleness)
+	if replica == rws.masterDB {
+		return rws.masterDB
+	}
+
+	rws.mutex.RLock()
+	tracker := rws.positionTracker
+	rws.mutex.RUnlock()
+
+	if replayed, err := tracker.HasReplayed(ctx, replica, mark.position); err == nil && replayed {
+		return replica
+	}
+	return rws.masterDB
+}
+
+// CaptureWritePosition 在写入成功后记录主库的复制位点，使从库追上后会话可以提前恢复从库读
+// 上下文未绑定会话或未启用位点跟踪时不做任何事
+func (rws *ReadWriteSplitter) CaptureWritePosition(ctx context.Context) error {
+	session := SessionFromContext(ctx)
+	if session == nil {
+		return nil
+	}
+	if sc := rws.config.SessionConsistency; sc == nil || !sc.TrackPosition {
+		return nil
+	}
+
+	rws.mutex.RLock()
+	tracker := rws.positionTracker
+	rws.mutex.RUnlock()
+
+	position, err := tracker.CurrentPosition(ctx, rws.masterDB)
+	if err != nil {
+		return err
+	}
+	session.setPosition(rws, position)
+	return nil
+}
+
+// maxStaleness 获取读请求允许的最大复制延迟，上下文中的设置优先于配置，0 表示不限制
+// 延迟检测未运行时从库的延迟未知，有延迟要求的读请求路由到主库
+func (rws *ReadWriteSplitter) maxStaleness(ctx context.Context) time.Duration {
+	if maxStaleness, ok := MaxStalenessFromContext(ctx); ok {
+		return maxStaleness
+	}
+	if lag := rws.config.ReplicationLag; lag != nil && lag.Enabled {
+		return time.Duration(lag.MaxLagSeconds * float64(time.Second))
+	}
+	return 0
+}
+
+// isWriteSQL 判断 SQL 是否需要路由到主库
+// 普通的增删改查按首个关键字直接判断；其它语句注释提示 write_route_only 优先，
+// 否则由解析器识别写操作、加锁读、序列函数和可写 CTE
 func (rws *ReadWriteSplitter) isWriteSQL(sql string) bool {
-	sql = strings.TrimSpace(strings.ToUpper(sql))
-	
-	writeKeywords := []string{
-		"INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "ALTER",
-		"TRUNCATE", "REPLACE", "MERGE", "CALL", "EXEC",
+	if write, ok := parser.PlainStatementWrite(sql); ok {
+		return write
+	}
+	hints, stripped := parser.ParseCommentHints(sql)
+	if value, exists := hints[parser.HintWriteRouteOnly]; exists {
+		if writeRouteOnly, err := strconv.ParseBool(value); err == nil {
+			return writeRouteOnly
+		}
+	}
+	return rws.getParserFactory().ClassifyAccess(stripped).Write
+}
+
+// SetParserFactory 设置用于识别读写语句的解析器工厂
+func (rws *ReadWriteSplitter) SetParserFactory(factory *parser.ParserFactory) {
+	rws.mutex.Lock()
+	defer rws.mutex.Unlock()
+
+	rws.parserFactory = factory
+}
+
+// getParserFactory 获取解析器工厂，未设置时使用默认解析器工厂
+func (rws *ReadWriteSplitter) getParserFactory() *parser.ParserFactory {
+	rws.mutex.RLock()
+	defer rws.mutex.RUnlock()
+
+	if rws.parserFactory == nil {
+		return parser.DefaultParserFactory
+	}
+	return rws.parserFactory
+}
+
+// selectSlaveDB 由负载均衡器在健康且复制延迟不超过 maxStaleness 的从库中选择
+// 没有健康的从库时，若健康检查策略允许则路由到主库，否则仍在所有从库中选择；
+// 没有延迟满足要求的从库时路由到主库
+func (rws *ReadWriteSplitter) selectSlaveDB(maxStaleness time.Duration) *sql.DB {
+	rws.mutex.RLock()
+	candidates := rws.healthySlaves()
+	if len(candidates) == 0 {
+		if rws.config.HealthCheck != nil && rws.config.HealthCheck.FallbackToMaster {
+			rws.mutex.RUnlock()
+			return rws.masterDB
+		}
+		candidates = make([]int, len(rws.slaveDBS))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	if maxStaleness > 0 {
+		candidates = rws.freshSlaves(candidates, maxStaleness)
+		if len(candidates) == 0 {
+			rws.mutex.RUnlock()
+			return rws.masterDB
+		}
 	}
 
-	for _, keyword := range writeKeywords {
-		if strings.HasPrefix(sql, keyword) {
-			return true
+	replicas := make([]Replica, len(candidates))
+	for i, index := range candidates {
+		replicas[i] = Replica{Name: rws.slaveNames[index], DB: rws.slaveDBS[index], Weight: rws.weights[index]}
+	}
+	loadBalancer := rws.loadBalancer
+	rws.mutex.RUnlock()
+
+	selected := loadBalancer.Select(replicas)
+	if selected < 0 || selected >= len(replicas) {
+		return rws.masterDB
+	}
+	return replicas[selected].DB
+}
+
+// healthySlaves 获取健康从库的下标
+func (rws *ReadWriteSplitter) healthySlaves() []int {
+	candidates := make([]int, 0, len(rws.slaveDBS))
+	for i, healthy := range rws.healthy {
+		if healthy {
+			candidates = append(candidates, i)
 		}
 	}
+	return candidates
+}
 
-	return false
+// freshSlaves 过滤出复制延迟不超过 maxStaleness 的从库，延迟未知的从库被排除
+func (rws *ReadWriteSplitter) freshSlaves(candidates []int, maxStaleness time.Duration) []int {
+	fresh := make([]int, 0, len(candidates))
+	for _, i := range candidates {
+		if rws.lagErrs[i] == nil && rws.lags[i] <= maxStaleness {
+			fresh = append(fresh, i)
+		}
+	}
+	return fresh
+}
+
+// setSlaveHealthy 设置从库的健康状态
+func (rws *ReadWriteSplitter) setSlaveHealthy(index int, healthy bool) {
+	rws.mutex.Lock()
+	defer rws.mutex.Unlock()
+
+	rws.healthy[index] = healthy
 }
 
-// selectSlaveDB 根据负载均衡算法选择从库
-func (rws *ReadWriteSplitter) selectSlaveDB() *sql.DB {
-	if len(rws.slaveDBS) == 1 {
-		return rws.slaveDBS[0]
+// setSlaveLag 记录从库的复制延迟，err 不为 nil 时延迟未知
+func (rws *ReadWriteSplitter) setSlaveLag(index int, lag time.Duration, err error) {
+	rws.mutex.Lock()
+	defer rws.mutex.Unlock()
+
+	rws.lags[index] = lag
+	rws.lagErrs[index] = err
+}
+
+// resetSlaveLags 将所有从库的复制延迟重置为未知，延迟检测停止后旧的探测结果不再可信
+func (rws *ReadWriteSplitter) resetSlaveLags() {
+	rws.mutex.Lock()
+	defer rws.mutex.Unlock()
+
+	for i := range rws.lagErrs {
+		rws.lags[i] = 0
+		rws.lagErrs[i] = errLagNotProbed
+	}
+}
+
+// SetDatabaseType 设置读写分离组的数据库类型，用于选择复制延迟和写入位点的查询方式
+func (rws *ReadWriteSplitter) SetDatabaseType(dbType database.DatabaseType) {
+	rws.mutex.Lock()
+	rws.positionTracker = NewPositionTracker(dbType)
+	rws.mutex.Unlock()
+
+	if rws.lagMonitor != nil {
+		rws.lagMonitor.SetLagProber(NewLagProber(dbType))
 	}
+}
 
-	switch LoadBalanceAlgorithm(rws.config.LoadBalanceAlgorithm) {
-	case RoundRobin:
-		return rws.selectByRoundRobin()
-	case Random:
-		return rws.selectByRandom()
-	case Weight:
-		// 简化实现，暂时使用轮询
-		return rws.selectByRoundRobin()
-	default:
-		return rws.selectByRoundRobin()
+// StartLagMonitor 启动后台复制延迟检测，未启用延迟检测时不做任何事
+func (rws *ReadWriteSplitter) StartLagMonitor(ctx context.Context) error {
+	if rws.lagMonitor == nil {
+		return nil
 	}
+	return rws.lagMonitor.Start(ctx)
+}
+
+// GetLagMonitor 获取复制延迟监控器，未启用延迟检测时返回 nil
+func (rws *ReadWriteSplitter) GetLagMonitor() *LagMonitor {
+	return rws.lagMonitor
 }
 
-// selectByRoundRobin 轮询选择从库
-func (rws *ReadWriteSplitter) selectByRoundRobin() *sql.DB {
+// StartHealthCheck 启动后台健康检查，未启用健康检查时不做任何事
+func (rws *ReadWriteSplitter) StartHealthCheck(ctx context.Context) error {
+	if rws.healthChecker == nil {
+		return nil
+	}
+	return rws.healthChecker.Start(ctx)
+}
+
+// StopBackgroundChecks 停止后台健康检查和延迟检测，不关闭数据库连接
+func (rws *ReadWriteSplitter) StopBackgroundChecks() {
+	if rws.healthChecker != nil {
+		rws.healthChecker.Stop()
+	}
+	if rws.lagMonitor != nil {
+		rws.lagMonitor.Stop()
+	}
+}
+
+// GetHealthChecker 获取健康检查器，未启用健康检查时返回 nil
+func (rws *ReadWriteSplitter) GetHealthChecker() *HealthChecker {
+	return rws.healthChecker
+}
+
+// SetSlaveWeight 运行时调整从库权重，权重为 0 时该从库不再接收 weight 算法分配的读请求
+func (rws *ReadWriteSplitter) SetSlaveWeight(slave string, weight int) error {
+	if weight < 0 {
+		return fmt.Errorf("weight of slave data source %s must not be negative", slave)
+	}
+
+	rws.mutex.Lock()
+	defer rws.mutex.Unlock()
+
+	for i, name := range rws.slaveNames {
+		if name == slave {
+			rws.weights[i] = weight
+			return nil
+		}
+	}
+	return fmt.Errorf("slave data source %s not found", slave)
+}
+
+// GetSlaveWeights 获取所有从库的当前权重
+func (rws *ReadWriteSplitter) GetSlaveWeights() map[string]int {
+	rws.mutex.RLock()
+	defer rws.mutex.RUnlock()
+
+	weights := make(map[string]int, len(rws.slaveNames))
+	for i, name := range rws.slaveNames {
+		weights[name] = rws.weights[i]
+	}
+	return weights
+}
+
+// SetLoadBalancer 替换从库负载均衡器
+func (rws *ReadWriteSplitter) SetLoadBalancer(loadBalancer LoadBalancer) {
 	rws.mutex.Lock()
 	defer rws.mutex.Unlock()
 
-	db := rws.slaveDBS[rws.roundRobinIndex]
-	rws.roundRobinIndex = (rws.roundRobinIndex + 1) % len(rws.slaveDBS)
-	return db
+	rws.loadBalancer = loadBalancer
 }
 
-// selectByRandom 随机选择从库
-func (rws *ReadWriteSplitter) selectByRandom() *sql.DB {
+// GetLoadBalancer 获取从库负载均衡器
+func (rws *ReadWriteSplitter) GetLoadBalancer() LoadBalancer {
 	rws.mutex.RLock()
 	defer rws.mutex.RUnlock()
 
-	index := rws.rand.Intn(len(rws.slaveDBS))
-	return rws.slaveDBS[index]
+	return rws.loadBalancer
+}
+
+// ObserveLatency 记录在路由结果上执行查询的耗时，负载均衡器实现 LatencyObserver 时用于后续选择
+// db 不是该读写分离组的从库时忽略
+func (rws *ReadWriteSplitter) ObserveLatency(db *sql.DB, latency time.Duration, err error) {
+	rws.mutex.RLock()
+	observer, ok := rws.loadBalancer.(LatencyObserver)
+	rws.mutex.RUnlock()
+	if !ok {
+		return
+	}
+
+	for i, slaveDB := range rws.slaveDBS {
+		if slaveDB == db {
+			observer.ObserveLatency(rws.slaveNames[i], latency, err)
+			return
+		}
+	}
 }
 
 // GetMasterDB 获取主库连接
@@ -177,6 +486,8 @@ func (rws *ReadWriteSplitter) HealthCheck() error {
 func (rws *ReadWriteSplitter) Close() error {
 	var errors []string
 
+	rws.StopBackgroundChecks()
+
 	if err := rws.masterDB.Close(); err != nil {
 		errors = append(errors, fmt.Sprintf("failed to close master DB: %v", err))
 	}
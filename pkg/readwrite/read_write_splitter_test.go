@@ -125,6 +125,20 @@ func TestReadWriteSplitter_isWriteSQL(t *testing.T) {
 		{"with spaces", "  SELECT * FROM users  ", false},
 		{"lowercase insert", "insert into users values (1, 'test')", true},
 		{"mixed case", "Select * From users", false},
+		{"select for update", "SELECT * FROM users WHERE id = 1 FOR UPDATE", true},
+		{"select for share", "SELECT * FROM users WHERE id = 1 FOR SHARE", true},
+		{"lock in share mode", "SELECT * FROM users WHERE id = 1 LOCK IN SHARE MODE", true},
+		{"leading block comment", "/* app=api */ UPDATE users SET name = 'test'", true},
+		{"leading line comment", "-- refresh\nDELETE FROM users WHERE id = 1", true},
+		{"writable cte", "WITH moved AS (DELETE FROM users WHERE id = 1 RETURNING *) SELECT * FROM moved", true},
+		{"cte update", "WITH ids AS (SELECT id FROM users) UPDATE users SET name = 'test' WHERE id IN (SELECT id FROM ids)", true},
+		{"read-only cte", "WITH ids AS (SELECT id FROM users) SELECT * FROM ids", false},
+		{"nextval", "SELECT nextval('users_id_seq')", true},
+		{"last_insert_id", "SELECT LAST_INSERT_ID()", true},
+		{"keyword in literal", "SELECT * FROM users WHERE note = 'FOR UPDATE'", false},
+		{"keyword in comment", "SELECT * FROM users /* FOR UPDATE */", false},
+		{"hint master", "/* SHARDING: write_route_only=true */ SELECT * FROM users", true},
+		{"hint replica", "SELECT nextval('users_id_seq') /* SHARDING: write_route_only=false */", false},
 	}
 
 	for _, tt := range tests {
@@ -212,6 +226,8 @@ func TestReadWriteSplitter_LoadBalance(t *testing.T) {
 		{"round_robin", "round_robin"},
 		{"random", "random"},
 		{"weight", "weight"},
+		{"least_connections", "least_connections"},
+		{"ewma_latency", "ewma_latency"},
 		{"unknown", "unknown_algorithm"},
 	}
 
@@ -306,4 +322,79 @@ func BenchmarkReadWriteSplitter_Route(b *testing.B) {
 			splitter.Route("INSERT INTO users VALUES (1, 'test')")
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestReadWriteSplitter_SelectByWeight(t *testing.T) {
+	masterDB := &sql.DB{}
+	slave1DB := &sql.DB{}
+	slave2DB := &sql.DB{}
+	slave3DB := &sql.DB{}
+
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:                 "test_rw",
+		MasterDataSource:     "master",
+		SlaveDataSources:     []string{"slave1", "slave2", "slave3"},
+		LoadBalanceAlgorithm: string(Weight),
+		SlaveWeights:         map[string]int{"slave1": 5},
+	}, map[string]*sql.DB{
+		"master": masterDB,
+		"slave1": slave1DB,
+		"slave2": slave2DB,
+		"slave3": slave3DB,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"slave1": 5, "slave2": 1, "slave3": 1}, splitter.GetSlaveWeights())
+
+	// sql.DB 的零值彼此相等，按指针识别路由到的数据库
+	names := map[*sql.DB]string{masterDB: "master", slave1DB: "slave1", slave2DB: "slave2", slave3DB: "slave3"}
+	route := func(n int) []string {
+		var selected []string
+		for i := 0; i < n; i++ {
+			selected = append(selected, names[splitter.Route("SELECT * FROM users")])
+		}
+		return selected
+	}
+
+	// 平滑加权轮询将高权重从库的请求分散在序列中
+	assert.Equal(t, []string{"slave1", "slave1", "slave2", "slave1", "slave3", "slave1", "slave1"}, route(7))
+
+	// 摘除 slave1 后读请求只分配给其余从库
+	assert.NoError(t, splitter.SetSlaveWeight("slave1", 0))
+	assert.Equal(t, []string{"slave2", "slave3", "slave2", "slave3"}, route(4))
+
+	// 所有从库都被摘除时读请求路由到主库
+	assert.NoError(t, splitter.SetSlaveWeight("slave2", 0))
+	assert.NoError(t, splitter.SetSlaveWeight("slave3", 0))
+	assert.Equal(t, []string{"master"}, route(1))
+
+	assert.Error(t, splitter.SetSlaveWeight("slave4", 1))
+	assert.Error(t, splitter.SetSlaveWeight("slave1", -1))
+}
+
+func TestNewReadWriteSplitter_InvalidWeights(t *testing.T) {
+	dataSources := map[string]*sql.DB{
+		"master": &sql.DB{},
+		"slave1": &sql.DB{},
+	}
+
+	tests := []struct {
+		name    string
+		weights map[string]int
+	}{
+		{"unknown slave", map[string]int{"slave2": 1}},
+		{"negative weight", map[string]int{"slave1": -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+				Name:                 "test_rw",
+				MasterDataSource:     "master",
+				SlaveDataSources:     []string{"slave1"},
+				LoadBalanceAlgorithm: string(Weight),
+				SlaveWeights:         tt.weights,
+			}, dataSources)
+			assert.Error(t, err)
+		})
+	}
+}
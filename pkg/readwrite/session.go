@@ -0,0 +1,171 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-sharding/pkg/database"
+	"sync"
+	"time"
+)
+
+// defaultConsistencyWindow 未配置会话一致性时写入后读主库的时间窗口
+const defaultConsistencyWindow = 5 * time.Second
+
+// Session 读己之写会话
+// 会话内通过读写分离器写入后，同一读写分离组的读请求在一致性窗口内路由到主库；
+// 启用写入位点跟踪时，从库回放到写入的 GTID/LSN 后提前恢复从库读
+type Session struct {
+	writes map[*ReadWriteSplitter]writeMark
+	mu     sync.Mutex
+}
+
+// writeMark 会话在读写分离组上的最后一次写入
+type writeMark struct {
+	at       time.Time
+	position string
+}
+
+// NewSession 创建读己之写会话
+func NewSession() *Session {
+	return &Session{
+		writes: make(map[*ReadWriteSplitter]writeMark),
+	}
+}
+
+// markWrite 记录写入时间，清除上一次写入的位点
+func (s *Session) markWrite(splitter *ReadWriteSplitter, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes[splitter] = writeMark{at: at}
+}
+
+// setPosition 记录最后一次写入后主库的位点
+func (s *Session) setPosition(splitter *ReadWriteSplitter, position string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mark, exists := s.writes[splitter]; exists {
+		mark.position = position
+		s.writes[splitter] = mark
+	}
+}
+
+// lastWrite 获取一致性窗口内的最后一次写入，过期的写入记录会被清除
+func (s *Session) lastWrite(splitter *ReadWriteSplitter, window time.Duration) (writeMark, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mark, exists := s.writes[splitter]
+	if !exists {
+		return writeMark{}, false
+	}
+	if time.Since(mark.at) > window {
+		delete(s.writes, splitter)
+		return writeMark{}, false
+	}
+	return mark, true
+}
+
+// Reset 清除会话中的所有写入记录
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes = make(map[*ReadWriteSplitter]writeMark)
+}
+
+// sessionKey 会话在上下文中的键
+type sessionKey struct{}
+
+// forceMasterKey 强制主库在上下文中的键
+type forceMasterKey struct{}
+
+// WithSession 在上下文中绑定读己之写会话
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext 获取上下文中绑定的会话
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// WithForceMaster 使上下文中的所有请求都路由到主库
+func WithForceMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey{}, true)
+}
+
+// isForceMaster 判断上下文是否要求使用主库，兼容旧的字符串键
+func isForceMaster(ctx context.Context) bool {
+	if forceMaster, ok := ctx.Value(forceMasterKey{}).(bool); ok && forceMaster {
+		return true
+	}
+	if forceMaster, ok := ctx.Value("force_master").(bool); ok && forceMaster {
+		return true
+	}
+	if inTransaction, ok := ctx.Value("in_transaction").(bool); ok && inTransaction {
+		return true
+	}
+	return false
+}
+
+// PositionTracker 复制位点跟踪器，用于判断从库是否已回放到指定写入
+type PositionTracker interface {
+	// CurrentPosition 获取主库当前的复制位点
+	CurrentPosition(ctx context.Context, master *sql.DB) (string, error)
+	// HasReplayed 判断从库是否已回放到指定位点
+	HasReplayed(ctx context.Context, replica *sql.DB, position string) (bool, error)
+}
+
+// NewPositionTracker 根据数据库类型创建复制位点跟踪器
+func NewPositionTracker(dbType database.DatabaseType) PositionTracker {
+	if dbType == database.PostgreSQL {
+		return PostgreSQLPositionTracker{}
+	}
+	return MySQLPositionTracker{}
+}
+
+// MySQLPositionTracker 基于 GTID 的位点跟踪器
+type MySQLPositionTracker struct{}
+
+// CurrentPosition 获取主库已执行的 GTID 集合
+func (MySQLPositionTracker) CurrentPosition(ctx context.Context, master *sql.DB) (string, error) {
+	var gtidSet string
+	if err := master.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtidSet); err != nil {
+		return "", fmt.Errorf("failed to query gtid_executed: %w", err)
+	}
+	return gtidSet, nil
+}
+
+// HasReplayed 判断写入的 GTID 集合是否已包含在从库已执行的 GTID 集合中
+func (MySQLPositionTracker) HasReplayed(ctx context.Context, replica *sql.DB, position string) (bool, error) {
+	var replayed bool
+	if err := replica.QueryRowContext(ctx, "SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)", position).Scan(&replayed); err != nil {
+		return false, fmt.Errorf("failed to compare gtid set: %w", err)
+	}
+	return replayed, nil
+}
+
+// PostgreSQLPositionTracker 基于 WAL LSN 的位点跟踪器
+type PostgreSQLPositionTracker struct{}
+
+// CurrentPosition 获取主库当前的 WAL 写入位置
+func (PostgreSQLPositionTracker) CurrentPosition(ctx context.Context, master *sql.DB) (string, error) {
+	var lsn string
+	if err := master.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn); err != nil {
+		return "", fmt.Errorf("failed to query current wal lsn: %w", err)
+	}
+	return lsn, nil
+}
+
+// HasReplayed 判断从库回放的 WAL 位置是否已到达写入位置
+func (PostgreSQLPositionTracker) HasReplayed(ctx context.Context, replica *sql.DB, position string) (bool, error) {
+	var replayed sql.NullBool
+	if err := replica.QueryRowContext(ctx, "SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn", position).Scan(&replayed); err != nil {
+		return false, fmt.Errorf("failed to compare replay lsn: %w", err)
+	}
+	return replayed.Valid && replayed.Bool, nil
+}
@@ -0,0 +1,129 @@
+package readwrite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubPositionTracker 返回预设位点的跟踪器
+type stubPositionTracker struct {
+	position string
+	replayed map[*sql.DB]bool
+}
+
+func (p *stubPositionTracker) CurrentPosition(ctx context.Context, master *sql.DB) (string, error) {
+	return p.position, nil
+}
+
+func (p *stubPositionTracker) HasReplayed(ctx context.Context, replica *sql.DB, position string) (bool, error) {
+	return p.replayed[replica], nil
+}
+
+// newSessionSplitter 创建单从库的读写分离器，返回按指针识别数据库的名称表
+func newSessionSplitter(t *testing.T, consistency *config.SessionConsistencyConfig) (*ReadWriteSplitter, *sql.DB, *sql.DB, map[*sql.DB]string) {
+	masterDB := &sql.DB{}
+	slaveDB := &sql.DB{}
+	splitter, err := NewReadWriteSplitter(&config.ReadWriteSplitConfig{
+		Name:               "rw_0",
+		MasterDataSource:   "master",
+		SlaveDataSources:   []string{"slave1"},
+		SessionConsistency: consistency,
+	}, map[string]*sql.DB{"master": masterDB, "slave1": slaveDB})
+	require.NoError(t, err)
+	return splitter, masterDB, slaveDB, map[*sql.DB]string{masterDB: "master", slaveDB: "slave1"}
+}
+
+func TestSession_ReadYourWritesWindow(t *testing.T) {
+	splitter, _, _, names := newSessionSplitter(t, &config.SessionConsistencyConfig{Window: 50 * time.Millisecond})
+	session := NewSession()
+	ctx := WithSession(context.Background(), session)
+
+	assert.Equal(t, "slave1", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+	assert.Equal(t, "master", names[splitter.RouteContext(ctx, "UPDATE users SET name = 'a'")])
+
+	// 写入后的读请求在窗口内路由到主库，其他会话不受影响
+	assert.Equal(t, "master", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+	assert.Equal(t, "slave1", names[splitter.RouteContext(context.Background(), "SELECT * FROM users")])
+	assert.Equal(t, "slave1", names[splitter.RouteContext(WithSession(context.Background(), NewSession()), "SELECT * FROM users")])
+
+	// 窗口过期后恢复从库读
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "slave1", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+
+	splitter.RouteContext(ctx, "DELETE FROM users WHERE id = 1")
+	session.Reset()
+	assert.Equal(t, "slave1", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+}
+
+func TestSession_TrackPosition(t *testing.T) {
+	splitter, _, slaveDB, names := newSessionSplitter(t, &config.SessionConsistencyConfig{Window: time.Hour, TrackPosition: true})
+	tracker := &stubPositionTracker{position: "uuid:1-5", replayed: map[*sql.DB]bool{}}
+	splitter.positionTracker = tracker
+
+	ctx := WithSession(context.Background(), NewSession())
+	splitter.RouteContext(ctx, "INSERT INTO users VALUES (1)")
+	require.NoError(t, splitter.CaptureWritePosition(ctx))
+
+	// 从库未回放到写入位点时读主库，回放后提前恢复从库读
+	assert.Equal(t, "master", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+	tracker.replayed[slaveDB] = true
+	assert.Equal(t, "slave1", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+
+	// 未绑定会话时不记录位点
+	assert.NoError(t, splitter.CaptureWritePosition(context.Background()))
+}
+
+func TestReadWriteSplitter_RouteWrite(t *testing.T) {
+	splitter, _, _, names := newSessionSplitter(t, &config.SessionConsistencyConfig{Window: time.Hour})
+	ctx := WithSession(context.Background(), NewSession())
+
+	assert.Equal(t, "master", names[splitter.RouteWrite(context.Background())])
+	assert.Equal(t, "slave1", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+
+	// 写请求记录会话写入，之后的读请求在窗口内读主库
+	assert.Equal(t, "master", names[splitter.RouteWrite(ctx)])
+	assert.Equal(t, "master", names[splitter.RouteContext(ctx, "SELECT * FROM users")])
+}
+
+func TestWithForceMaster(t *testing.T) {
+	splitter, _, _, names := newSessionSplitter(t, nil)
+	assert.Equal(t, "master", names[splitter.RouteContext(WithForceMaster(context.Background()), "SELECT * FROM users")])
+	assert.Equal(t, "slave1", names[splitter.RouteContext(context.Background(), "SELECT * FROM users")])
+}
+
+func TestPositionTrackers(t *testing.T) {
+	mysqlDB := openStatusDB(t, map[string]sqltest.Result{
+		"SELECT @@GLOBAL.gtid_executed": {Columns: []string{"gtid"}, Rows: [][]driver.Value{{"uuid:1-5"}}},
+		"SELECT GTID_SUBSET":            {Columns: []string{"subset"}, Rows: [][]driver.Value{{int64(1)}}},
+	})
+	tracker := NewPositionTracker(database.MySQL)
+	position, err := tracker.CurrentPosition(context.Background(), mysqlDB)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid:1-5", position)
+	replayed, err := tracker.HasReplayed(context.Background(), mysqlDB, position)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+
+	t.Run("postgresql", func(t *testing.T) {
+		pgDB := openStatusDB(t, map[string]sqltest.Result{
+			"SELECT pg_current_wal_lsn()":   {Columns: []string{"lsn"}, Rows: [][]driver.Value{{"0/3000148"}}},
+			"SELECT pg_last_wal_replay_lsn": {Columns: []string{"replayed"}, Rows: [][]driver.Value{{false}}},
+		})
+		tracker := NewPositionTracker(database.PostgreSQL)
+		position, err := tracker.CurrentPosition(context.Background(), pgDB)
+		require.NoError(t, err)
+		assert.Equal(t, "0/3000148", position)
+		replayed, err := tracker.HasReplayed(context.Background(), pgDB, position)
+		require.NoError(t, err)
+		assert.False(t, replayed)
+	})
+}
@@ -0,0 +1,293 @@
+package rewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExecutionUnit 执行单元，对应一个数据源上的一张实际表
+type ExecutionUnit struct {
+	DataSource  string
+	LogicTable  string
+	ActualTable string
+	SQL         string
+	Parameters  []interface{}
+}
+
+// RewriteExecutionUnits 按路由结果逐个重写 SQL，每个执行单元只包含一张实际表
+// 与 Rewrite 不同，这里不会把同一数据源上的多张表合并为 UNION 查询，适用于 DML 语句
+func (r *SQLRewriter) RewriteExecutionUnits(ctx *RewriteContext) ([]*ExecutionUnit, error) {
+	if len(ctx.LogicTables) != 1 {
+		return nil, fmt.Errorf("execution units require exactly one logic table, got %d", len(ctx.LogicTables))
+	}
+
+	logicTable := ctx.LogicTables[0]
+	units := make([]*ExecutionUnit, 0, len(ctx.RouteResults))
+	for _, route := range ctx.RouteResults {
+		units = append(units, &ExecutionUnit{
+			DataSource:  route.DataSource,
+			LogicTable:  logicTable,
+			ActualTable: route.Table,
+			SQL:         r.replaceTableName(ctx.OriginalSQL, logicTable, route.Table),
+			Parameters:  ctx.Parameters,
+		})
+	}
+
+	return units, nil
+}
+
+var (
+	updateStatementRegex = regexp.MustCompile(`(?is)^UPDATE\s+(.+?)\s+SET\s+`)
+	deleteStatementRegex = regexp.MustCompile(`(?is)^DELETE\s+FROM\s+`)
+)
+
+// BuildBeforeImage 为 UPDATE/DELETE 执行单元生成 SELECT ... FOR UPDATE 前镜像查询
+// 前镜像查询复用原语句的 WHERE 条件，并只保留 WHERE 条件中使用的参数
+func (r *SQLRewriter) BuildBeforeImage(unit *ExecutionUnit) (*ExecutionUnit, error) {
+	sql := strings.TrimSpace(r.removeComments(unit.SQL))
+	sql = strings.TrimSuffix(sql, ";")
+
+	var tableClause, conditionClause string
+	var skippedParams []placeholder
+
+	if m := updateStatementRegex.FindStringSubmatchIndex(sql); m != nil {
+		tableClause = strings.TrimSpace(sql[m[2]:m[3]])
+		rest := sql[m[1]:]
+		whereIndex := findTopLevelKeyword(rest, "WHERE")
+		if whereIndex < 0 {
+			skippedParams = findPlaceholders(rest)
+			conditionClause = ""
+		} else {
+			skippedParams = findPlaceholders(rest[:whereIndex])
+			conditionClause = rest[whereIndex:]
+		}
+	} else if m := deleteStatementRegex.FindStringIndex(sql); m != nil {
+		rest := sql[m[1]:]
+		whereIndex := findTopLevelKeyword(rest, "WHERE")
+		if whereIndex < 0 {
+			tableClause = strings.TrimSpace(rest)
+		} else {
+			tableClause = strings.TrimSpace(rest[:whereIndex])
+			conditionClause = rest[whereIndex:]
+		}
+	} else {
+		return nil, fmt.Errorf("before image is only supported for UPDATE and DELETE statements: %s", unit.SQL)
+	}
+
+	conditionSQL, params, err := rebindPlaceholders(conditionClause, skippedParams, unit.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	selectSQL := "SELECT * FROM " + tableClause
+	if conditionSQL != "" {
+		selectSQL += " " + strings.TrimSpace(conditionSQL)
+	}
+	selectSQL += " FOR UPDATE"
+
+	return &ExecutionUnit{
+		DataSource:  unit.DataSource,
+		LogicTable:  unit.LogicTable,
+		ActualTable: unit.ActualTable,
+		SQL:         selectSQL,
+		Parameters:  params,
+	}, nil
+}
+
+// UpdatedColumns 获取 UPDATE 语句 SET 子句赋值的列名，列名去掉表名限定和引号
+func (r *SQLRewriter) UpdatedColumns(sql string) ([]string, error) {
+	sql = strings.TrimSpace(r.removeComments(sql))
+	m := updateStatementRegex.FindStringIndex(sql)
+	if m == nil {
+		return nil, fmt.Errorf("not an UPDATE statement: %s", sql)
+	}
+
+	setClause := sql[m[1]:]
+	if whereIndex := findTopLevelKeyword(setClause, "WHERE"); whereIndex >= 0 {
+		setClause = setClause[:whereIndex]
+	}
+
+	var columns []string
+	for _, assignment := range splitTopLevel(setClause, ',') {
+		eq := strings.Index(assignment, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid assignment in SET clause: %s", strings.TrimSpace(assignment))
+		}
+		column := strings.TrimSpace(assignment[:eq])
+		if dot := strings.LastIndex(column, "."); dot >= 0 {
+			column = column[dot+1:]
+		}
+		columns = append(columns, strings.Trim(column, "`\""))
+	}
+	return columns, nil
+}
+
+// CountPlaceholders 统计字符串字面量之外的参数占位符数量，支持 ? 和 $n 两种形式
+// $n 占位符必须按出现顺序从 $1 开始编号，否则参数位置与占位符位置不一致，返回错误
+func (r *SQLRewriter) CountPlaceholders(sql string) (int, error) {
+	placeholders := findPlaceholders(sql)
+	for i, p := range placeholders {
+		if p.index != 0 && p.index != i+1 {
+			return 0, fmt.Errorf("placeholder $%d at position %d is out of order", p.index, i+1)
+		}
+	}
+	return len(placeholders), nil
+}
+
+// splitTopLevel 按不在括号和字符串字面量中的分隔符拆分字符串
+func splitTopLevel(sql string, sep byte) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	var quote byte
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c == '\\' && i+1 < len(sql) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+
+	return append(parts, sql[last:])
+}
+
+// placeholder 参数占位符
+type placeholder struct {
+	start int
+	end   int
+	index int // $n 形式的参数序号（从 1 开始），? 形式为 0
+}
+
+// findPlaceholders 查找字符串字面量之外的参数占位符
+func findPlaceholders(sql string) []placeholder {
+	var result []placeholder
+	var quote byte
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c == '\\' && i+1 < len(sql) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '?':
+			result = append(result, placeholder{start: i, end: i + 1})
+		case '$':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				index, _ := strconv.Atoi(sql[i+1 : j])
+				result = append(result, placeholder{start: i, end: j, index: index})
+				i = j - 1
+			}
+		}
+	}
+
+	return result
+}
+
+// rebindPlaceholders 提取条件子句使用的参数，$n 占位符会被重新编号
+func rebindPlaceholders(condition string, skipped []placeholder, args []interface{}) (string, []interface{}, error) {
+	placeholders := findPlaceholders(condition)
+	if len(placeholders) == 0 {
+		return condition, nil, nil
+	}
+
+	var builder strings.Builder
+	var params []interface{}
+	last := 0
+	for i, p := range placeholders {
+		var argIndex int
+		if p.index > 0 {
+			argIndex = p.index - 1
+		} else {
+			argIndex = len(skipped) + i
+		}
+		if argIndex >= len(args) {
+			return "", nil, fmt.Errorf("missing parameter for placeholder %s", condition[p.start:p.end])
+		}
+		params = append(params, args[argIndex])
+
+		builder.WriteString(condition[last:p.start])
+		if p.index > 0 {
+			builder.WriteString("$" + strconv.Itoa(len(params)))
+		} else {
+			builder.WriteString("?")
+		}
+		last = p.end
+	}
+	builder.WriteString(condition[last:])
+
+	return builder.String(), params, nil
+}
+
+// findTopLevelKeyword 查找不在括号和字符串字面量中的关键字位置
+func findTopLevelKeyword(sql, keyword string) int {
+	upper := strings.ToUpper(sql)
+	depth := 0
+	var quote byte
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c == '\\' && i+1 < len(sql) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		default:
+			if depth == 0 && strings.HasPrefix(upper[i:], keyword) &&
+				(i == 0 || !isIdentifierChar(sql[i-1])) &&
+				(i+len(keyword) == len(sql) || !isIdentifierChar(sql[i+len(keyword)])) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// isIdentifierChar 判断是否为标识符字符
+func isIdentifierChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,177 @@
+package rewrite
+
+import (
+	"go-sharding/pkg/routing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLRewriter_RewriteExecutionUnits(t *testing.T) {
+	rewriter := NewSQLRewriter()
+
+	units, err := rewriter.RewriteExecutionUnits(&RewriteContext{
+		OriginalSQL: "UPDATE t_order SET status = ? WHERE user_id = ?",
+		LogicTables: []string{"t_order"},
+		RouteResults: []*routing.RouteResult{
+			{DataSource: "ds_0", Table: "t_order_0"},
+			{DataSource: "ds_0", Table: "t_order_1"},
+		},
+		Parameters: []interface{}{"PAID", 10},
+	})
+	require.NoError(t, err)
+	require.Len(t, units, 2)
+
+	assert.Equal(t, "ds_0", units[0].DataSource)
+	assert.Equal(t, "t_order", units[0].LogicTable)
+	assert.Equal(t, "t_order_0", units[0].ActualTable)
+	assert.Equal(t, "UPDATE t_order_0 SET status = ? WHERE user_id = ?", units[0].SQL)
+	assert.Equal(t, "UPDATE t_order_1 SET status = ? WHERE user_id = ?", units[1].SQL)
+
+	_, err = rewriter.RewriteExecutionUnits(&RewriteContext{
+		OriginalSQL: "UPDATE t_order SET status = ?",
+		LogicTables: []string{"t_order", "t_order_item"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSQLRewriter_BuildBeforeImage(t *testing.T) {
+	rewriter := NewSQLRewriter()
+
+	tests := []struct {
+		name           string
+		sql            string
+		params         []interface{}
+		expectedSQL    string
+		expectedParams []interface{}
+		expectError    bool
+	}{
+		{
+			name:           "update with question mark placeholders",
+			sql:            "UPDATE t_order_0 SET status = ?, amount = ? WHERE user_id = ? AND order_id = ?",
+			params:         []interface{}{"PAID", 100, 10, 20},
+			expectedSQL:    "SELECT * FROM t_order_0 WHERE user_id = ? AND order_id = ? FOR UPDATE",
+			expectedParams: []interface{}{10, 20},
+		},
+		{
+			name:           "update with postgresql placeholders",
+			sql:            "UPDATE t_order_0 SET status = $1 WHERE user_id = $2",
+			params:         []interface{}{"PAID", 10},
+			expectedSQL:    "SELECT * FROM t_order_0 WHERE user_id = $1 FOR UPDATE",
+			expectedParams: []interface{}{10},
+		},
+		{
+			name:           "update with alias and literal containing where",
+			sql:            "UPDATE t_order_0 o SET remark = 'where ?' WHERE o.user_id = ?",
+			params:         []interface{}{10},
+			expectedSQL:    "SELECT * FROM t_order_0 o WHERE o.user_id = ? FOR UPDATE",
+			expectedParams: []interface{}{10},
+		},
+		{
+			name:           "update with subquery in set clause",
+			sql:            "UPDATE t_order_0 SET amount = (SELECT price FROM t_price WHERE id = ?) WHERE user_id = ?",
+			params:         []interface{}{1, 10},
+			expectedSQL:    "SELECT * FROM t_order_0 WHERE user_id = ? FOR UPDATE",
+			expectedParams: []interface{}{10},
+		},
+		{
+			name:           "delete with leading comment",
+			sql:            "/* app */ DELETE FROM t_order_1 WHERE order_id = ?;",
+			params:         []interface{}{20},
+			expectedSQL:    "SELECT * FROM t_order_1 WHERE order_id = ? FOR UPDATE",
+			expectedParams: []interface{}{20},
+		},
+		{
+			name:        "delete without where",
+			sql:         "DELETE FROM t_order_1",
+			expectedSQL: "SELECT * FROM t_order_1 FOR UPDATE",
+		},
+		{
+			name:        "select is not supported",
+			sql:         "SELECT * FROM t_order_0",
+			expectError: true,
+		},
+		{
+			name:        "missing parameter",
+			sql:         "DELETE FROM t_order_1 WHERE order_id = ?",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := &ExecutionUnit{
+				DataSource:  "ds_0",
+				LogicTable:  "t_order",
+				ActualTable: "t_order_0",
+				SQL:         tt.sql,
+				Parameters:  tt.params,
+			}
+
+			result, err := rewriter.BuildBeforeImage(unit)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSQL, result.SQL)
+			assert.Equal(t, tt.expectedParams, result.Parameters)
+			assert.Equal(t, unit.DataSource, result.DataSource)
+			assert.Equal(t, unit.ActualTable, result.ActualTable)
+		})
+	}
+}
+
+func TestSQLRewriter_UpdatedColumns(t *testing.T) {
+	rewriter := NewSQLRewriter()
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected []string
+	}{
+		{"single column", "UPDATE t_order_0 SET status = ? WHERE order_id = ?", []string{"status"}},
+		{"qualified and quoted", "UPDATE t_order_0 o SET o.`status` = 'PAID', \"amount\" = amount + 1 WHERE o.id = 1", []string{"status", "amount"}},
+		{"function arguments", "UPDATE t_order_0 SET remark = CONCAT(remark, ',', ?), id = ? WHERE id = ?", []string{"remark", "id"}},
+		{"without where", "/* hint */ UPDATE t_order_0 SET status = 'a,b'", []string{"status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := rewriter.UpdatedColumns(tt.sql)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, columns)
+		})
+	}
+
+	_, err := rewriter.UpdatedColumns("DELETE FROM t_order_0 WHERE id = 1")
+	assert.Error(t, err)
+}
+
+func TestSQLRewriter_CountPlaceholders(t *testing.T) {
+	rewriter := NewSQLRewriter()
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected int
+	}{
+		{"question marks", "INSERT INTO t_order (order_id, user_id) VALUES (?, ?)", 2},
+		{"numbered", "INSERT INTO t_order (order_id, user_id, status) VALUES ($1, $2, $3)", 3},
+		{"string literals", "INSERT INTO t_order (remark, user_id) VALUES ('why?', ?)", 1},
+		{"dollar in literal", "UPDATE t_order SET remark = '$1' WHERE id = $1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := rewriter.CountPlaceholders(tt.sql)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, count)
+		})
+	}
+
+	_, err := rewriter.CountPlaceholders("INSERT INTO t_order (order_id, user_id) VALUES ($2, $1)")
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"sync"
+)
+
+// Hint 强制路由提示，按逻辑表指定数据库和表的分片值，分片值只对配置了 hint 分片策略的逻辑表生效；
+// 直接路由值作用于任意逻辑表，并优先于同一逻辑表的分片值
+// Hint 通过 WithHint 绑定到上下文，可以在多个 goroutine 中并发读写
+type Hint struct {
+	databaseValues  map[string][]interface{}
+	tableValues     map[string][]interface{}
+	databaseRoutes  map[string][]interface{}
+	tableRoutes     map[string][]interface{}
+	masterRouteOnly bool
+	mu              sync.RWMutex
+}
+
+// NewHint 创建强制路由提示
+func NewHint() *Hint {
+	return &Hint{
+		databaseValues: make(map[string][]interface{}),
+		tableValues:    make(map[string][]interface{}),
+		databaseRoutes: make(map[string][]interface{}),
+		tableRoutes:    make(map[string][]interface{}),
+	}
+}
+
+// Clone 复制强制路由提示，修改副本不影响原提示
+func (h *Hint) Clone() *Hint {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return &Hint{
+		databaseValues:  cloneHintValues(h.databaseValues),
+		tableValues:     cloneHintValues(h.tableValues),
+		databaseRoutes:  cloneHintValues(h.databaseRoutes),
+		tableRoutes:     cloneHintValues(h.tableRoutes),
+		masterRouteOnly: h.masterRouteOnly,
+	}
+}
+
+// cloneHintValues 复制按逻辑表保存的提示值
+func cloneHintValues(values map[string][]interface{}) map[string][]interface{} {
+	cloned := make(map[string][]interface{}, len(values))
+	for logicTable, tableValues := range values {
+		cloned[logicTable] = append([]interface{}(nil), tableValues...)
+	}
+	return cloned
+}
+
+// AddDatabaseShardingValue 为逻辑表添加数据库分片值，多个值时路由到所有匹配的数据源
+func (h *Hint) AddDatabaseShardingValue(logicTable string, value interface{}) *Hint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.databaseValues[logicTable] = append(h.databaseValues[logicTable], value)
+	return h
+}
+
+// AddTableShardingValue 为逻辑表添加表分片值，多个值时路由到所有匹配的真实表
+func (h *Hint) AddTableShardingValue(logicTable string, value interface{}) *Hint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.tableValues[logicTable] = append(h.tableValues[logicTable], value)
+	return h
+}
+
+// AddDatabaseRoute 为逻辑表添加直接路由的数据源，用于 SQL 注释提示
+// hint 分片策略的逻辑表按分片值计算；其他逻辑表的值必须是数据源名称
+func (h *Hint) AddDatabaseRoute(logicTable string, value interface{}) *Hint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.databaseRoutes[logicTable] = append(h.databaseRoutes[logicTable], value)
+	return h
+}
+
+// AddTableRoute 为逻辑表添加直接路由的真实表，用于 SQL 注释提示
+// hint 分片策略的逻辑表按分片值计算；其他逻辑表的值必须是真实表名称
+func (h *Hint) AddTableRoute(logicTable string, value interface{}) *Hint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.tableRoutes[logicTable] = append(h.tableRoutes[logicTable], value)
+	return h
+}
+
+// SetMasterRouteOnly 设置读请求也路由到主库
+func (h *Hint) SetMasterRouteOnly() *Hint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.masterRouteOnly = true
+	return h
+}
+
+// DatabaseShardingValues 获取逻辑表的数据库分片值
+func (h *Hint) DatabaseShardingValues(logicTable string) []interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]interface{}(nil), h.databaseValues[logicTable]...)
+}
+
+// TableShardingValues 获取逻辑表的表分片值
+func (h *Hint) TableShardingValues(logicTable string) []interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]interface{}(nil), h.tableValues[logicTable]...)
+}
+
+// DatabaseRoutes 获取逻辑表直接路由的数据源
+func (h *Hint) DatabaseRoutes(logicTable string) []interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]interface{}(nil), h.databaseRoutes[logicTable]...)
+}
+
+// TableRoutes 获取逻辑表直接路由的真实表
+func (h *Hint) TableRoutes(logicTable string) []interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]interface{}(nil), h.tableRoutes[logicTable]...)
+}
+
+// IsMasterRouteOnly 是否仅路由到主库
+func (h *Hint) IsMasterRouteOnly() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.masterRouteOnly
+}
+
+// hintKey 强制路由提示在上下文中的键
+type hintKey struct{}
+
+// WithHint 在上下文中绑定强制路由提示
+func WithHint(ctx context.Context, hint *Hint) context.Context {
+	return context.WithValue(ctx, hintKey{}, hint)
+}
+
+// HintFromContext 获取上下文中绑定的强制路由提示，未绑定时返回 nil
+func HintFromContext(ctx context.Context) *Hint {
+	hint, _ := ctx.Value(hintKey{}).(*Hint)
+	return hint
+}
@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"go-sharding/pkg/config"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// routeTargets 将路由结果转换为 数据源.表 形式
+func routeTargets(results []*RouteResult) []string {
+	targets := make([]string, len(results))
+	for i, result := range results {
+		targets[i] = result.DataSource + "." + result.Table
+	}
+	return targets
+}
+
+func TestShardingRouter_RouteWithHint(t *testing.T) {
+	router := NewShardingRouter(nil, &config.ShardingRuleConfig{
+		Tables: map[string]*config.TableRuleConfig{
+			"t_order": {
+				ActualDataNodes:  "ds_${0..1}.t_order_${0..1}",
+				DatabaseStrategy: &config.ShardingStrategyConfig{Algorithm: "ds_${value % 2}", Type: "hint"},
+				TableStrategy:    &config.ShardingStrategyConfig{Type: "hint"},
+			},
+			"t_user": {
+				ActualDataNodes:  "ds_${0..1}.t_user",
+				DatabaseStrategy: &config.ShardingStrategyConfig{ShardingColumn: "user_id", Algorithm: "ds_${user_id % 2}", Type: "inline"},
+			},
+		},
+	})
+
+	tests := []struct {
+		name       string
+		logicTable string
+		hint       *Hint
+		expected   []string
+	}{
+		{
+			name:       "no hint",
+			logicTable: "t_order",
+			expected:   []string{"ds_0.t_order_0", "ds_0.t_order_1", "ds_1.t_order_0", "ds_1.t_order_1"},
+		},
+		{
+			name:       "database hint",
+			logicTable: "t_order",
+			hint:       NewHint().AddDatabaseShardingValue("t_order", 3),
+			expected:   []string{"ds_1.t_order_0", "ds_1.t_order_1"},
+		},
+		{
+			name:       "database and table hint",
+			logicTable: "t_order",
+			hint:       NewHint().AddDatabaseShardingValue("t_order", 2).AddTableShardingValue("t_order", "t_order_1"),
+			expected:   []string{"ds_0.t_order_1"},
+		},
+		{
+			name:       "multiple hint values",
+			logicTable: "t_order",
+			hint:       NewHint().AddDatabaseShardingValue("t_order", 1).AddDatabaseShardingValue("t_order", 3).AddTableShardingValue("t_order", "t_order_0"),
+			expected:   []string{"ds_1.t_order_0"},
+		},
+		{
+			name:       "hint for another table",
+			logicTable: "t_order",
+			hint:       NewHint().AddDatabaseShardingValue("t_user", 1),
+			expected:   []string{"ds_0.t_order_0", "ds_0.t_order_1", "ds_1.t_order_0", "ds_1.t_order_1"},
+		},
+		{
+			name:       "table without hint strategy",
+			logicTable: "t_user",
+			hint:       NewHint().AddDatabaseShardingValue("t_user", 1),
+			expected:   []string{"ds_0.t_user", "ds_1.t_user"},
+		},
+		{
+			name:       "direct route on table without hint strategy",
+			logicTable: "t_user",
+			hint:       NewHint().AddDatabaseRoute("t_user", "ds_1"),
+			expected:   []string{"ds_1.t_user"},
+		},
+		{
+			name:       "direct route overrides hint values",
+			logicTable: "t_order",
+			hint:       NewHint().AddDatabaseShardingValue("t_order", 2).AddDatabaseRoute("t_order", "3").AddTableRoute("t_order", "t_order_0"),
+			expected:   []string{"ds_1.t_order_0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.hint != nil {
+				ctx = WithHint(ctx, tt.hint)
+			}
+
+			results, err := router.RouteContext(ctx, tt.logicTable, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, routeTargets(results))
+		})
+	}
+
+	// 提示的数据源不在实际数据节点中
+	ctx := WithHint(context.Background(), NewHint().AddDatabaseShardingValue("t_order", "ds_9"))
+	_, err := router.RouteContext(ctx, "t_order", nil)
+	assert.Error(t, err)
+
+	// 未配置 hint 分片策略的逻辑表无法计算数字提示值
+	ctx = WithHint(context.Background(), NewHint().AddDatabaseRoute("t_user", "1"))
+	_, err = router.RouteContext(ctx, "t_user", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hint value 1 must name the target")
+}
+
+func TestHint_Clone(t *testing.T) {
+	hint := NewHint().AddDatabaseShardingValue("t_order", 1).AddTableRoute("t_order", "t_order_0").SetMasterRouteOnly()
+	cloned := hint.Clone().AddDatabaseRoute("t_order", "ds_1").AddTableRoute("t_order", "t_order_1")
+
+	assert.Equal(t, []interface{}{1}, cloned.DatabaseShardingValues("t_order"))
+	assert.Equal(t, []interface{}{"t_order_0", "t_order_1"}, cloned.TableRoutes("t_order"))
+	assert.True(t, cloned.IsMasterRouteOnly())
+
+	// 修改副本不影响原提示
+	assert.Empty(t, hint.DatabaseRoutes("t_order"))
+	assert.Equal(t, []interface{}{"t_order_0"}, hint.TableRoutes("t_order"))
+}
+
+func TestHint_Concurrent(t *testing.T) {
+	hint := NewHint()
+	ctx := WithHint(context.Background(), hint)
+	assert.Same(t, hint, HintFromContext(ctx))
+	assert.Nil(t, HintFromContext(context.Background()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			HintFromContext(ctx).AddDatabaseShardingValue("t_order", i).AddTableShardingValue("t_order", i)
+			HintFromContext(ctx).DatabaseShardingValues("t_order")
+			HintFromContext(ctx).IsMasterRouteOnly()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, hint.DatabaseShardingValues("t_order"), 10)
+	assert.Len(t, hint.TableShardingValues("t_order"), 10)
+	assert.False(t, hint.IsMasterRouteOnly())
+	assert.True(t, hint.SetMasterRouteOnly().IsMasterRouteOnly())
+}
@@ -1,11 +1,25 @@
 package routing
 
 import (
+	"context"
 	"fmt"
+	"go-sharding/pkg/algorithm"
 	"go-sharding/pkg/config"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+)
+
+const (
+	// hintStrategyType hint 分片策略类型，分片值来自上下文中的强制路由提示
+	hintStrategyType = "hint"
+	// standardStrategyType standard 分片策略类型，使用 AlgorithmFactory 创建的分片算法
+	standardStrategyType = "standard"
+	// hintValueVariable hint 分片策略表达式中表示提示值的变量名
+	hintValueVariable = "value"
+	// geneKeyGeneratorType 在主键中嵌入分片列基因的主键生成器类型
+	geneKeyGeneratorType = "gene"
 )
 
 // RouteResult 路由结果
@@ -17,24 +31,46 @@ type RouteResult struct {
 // Router 路由器接口
 type Router interface {
 	Route(logicTable string, shardingValues map[string]interface{}) ([]*RouteResult, error)
+	// RouteContext 执行路由，使用上下文中绑定的强制路由提示
+	RouteContext(ctx context.Context, logicTable string, shardingValues map[string]interface{}) ([]*RouteResult, error)
 }
 
 // ShardingRouter 分片路由器
 type ShardingRouter struct {
-	dataSources  map[string]*config.DataSourceConfig
-	shardingRule *config.ShardingRuleConfig
+	dataSources      map[string]*config.DataSourceConfig
+	shardingRule     *config.ShardingRuleConfig
+	algorithmFactory *algorithm.AlgorithmFactory
+	algorithms       map[*config.ShardingStrategyConfig]algorithm.ShardingAlgorithm
+	algorithmsMu     sync.Mutex
 }
 
 // NewShardingRouter 创建分片路由器
 func NewShardingRouter(dataSources map[string]*config.DataSourceConfig, shardingRule *config.ShardingRuleConfig) *ShardingRouter {
 	return &ShardingRouter{
-		dataSources:  dataSources,
-		shardingRule: shardingRule,
+		dataSources:      dataSources,
+		shardingRule:     shardingRule,
+		algorithmFactory: algorithm.NewAlgorithmFactory(),
+		algorithms:       make(map[*config.ShardingStrategyConfig]algorithm.ShardingAlgorithm),
 	}
 }
 
+// SetAlgorithmFactory 设置创建 standard 策略分片算法的工厂，用于使用自定义注册的算法
+func (r *ShardingRouter) SetAlgorithmFactory(factory *algorithm.AlgorithmFactory) {
+	r.algorithmsMu.Lock()
+	defer r.algorithmsMu.Unlock()
+
+	r.algorithmFactory = factory
+	r.algorithms = make(map[*config.ShardingStrategyConfig]algorithm.ShardingAlgorithm)
+}
+
 // Route 执行路由
 func (r *ShardingRouter) Route(logicTable string, shardingValues map[string]interface{}) ([]*RouteResult, error) {
+	return r.RouteContext(context.Background(), logicTable, shardingValues)
+}
+
+// RouteContext 执行路由，配置了 hint 分片策略的逻辑表使用上下文中绑定的强制路由提示计算分片，
+// 提示中的直接路由值作用于任意逻辑表
+func (r *ShardingRouter) RouteContext(ctx context.Context, logicTable string, shardingValues map[string]interface{}) ([]*RouteResult, error) {
 	tableRule, exists := r.shardingRule.Tables[logicTable]
 	if !exists {
 		return nil, fmt.Errorf("table rule not found for table: %s", logicTable)
@@ -46,10 +82,17 @@ func (r *ShardingRouter) Route(logicTable string, shardingValues map[string]inte
 		return nil, fmt.Errorf("failed to parse actual data nodes: %w", err)
 	}
 
+	// 强制路由提示的分片值只作用于 hint 分片策略，直接路由值作用于任意分片策略
+	var databaseHints, tableHints []interface{}
+	if hint := HintFromContext(ctx); hint != nil {
+		databaseHints = hintValues(tableRule.DatabaseStrategy, hint.DatabaseShardingValues(logicTable), hint.DatabaseRoutes(logicTable))
+		tableHints = hintValues(tableRule.TableStrategy, hint.TableShardingValues(logicTable), hint.TableRoutes(logicTable))
+	}
+
 	var results []*RouteResult
 
-	// 如果没有分片值，返回所有数据节点
-	if len(shardingValues) == 0 {
+	// 如果没有分片值和强制路由提示，返回所有数据节点
+	if len(shardingValues) == 0 && len(databaseHints) == 0 && len(tableHints) == 0 {
 		for _, node := range dataNodes {
 			results = append(results, &RouteResult{
 				DataSource: node.DataSource,
@@ -59,38 +102,58 @@ func (r *ShardingRouter) Route(logicTable string, shardingValues map[string]inte
 		return results, nil
 	}
 
+	var allDataSources []string
+	for _, node := range dataNodes {
+		if !contains(allDataSources, node.DataSource) {
+			allDataSources = append(allDataSources, node.DataSource)
+		}
+	}
+
 	// 计算数据库分片
 	var targetDataSources []string
-	if tableRule.DatabaseStrategy != nil {
-		ds, err := r.calculateSharding(tableRule.DatabaseStrategy, shardingValues)
+	switch {
+	case len(databaseHints) > 0:
+		ds, err := r.calculateHintSharding(tableRule.DatabaseStrategy, databaseHints)
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate database sharding: %w", err)
 		}
 		targetDataSources = ds
-	} else {
-		// 如果没有数据库分片策略，使用所有数据源
-		for _, node := range dataNodes {
-			if !contains(targetDataSources, node.DataSource) {
-				targetDataSources = append(targetDataSources, node.DataSource)
-			}
+	case tableRule.DatabaseStrategy != nil && !isHintStrategy(tableRule.DatabaseStrategy) && len(shardingValues) > 0:
+		ds, err := r.calculateSharding(tableRule.DatabaseStrategy, shardingValues, allDataSources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate database sharding: %w", err)
+		}
+		targetDataSources = ds
+	default:
+		// 如果没有数据库分片策略或分片值，使用所有数据源
+		targetDataSources = allDataSources
+	}
+
+	var availableTables []string
+	for _, node := range dataNodes {
+		if contains(targetDataSources, node.DataSource) && !contains(availableTables, node.Table) {
+			availableTables = append(availableTables, node.Table)
 		}
 	}
 
 	// 计算表分片
 	var targetTables []string
-	if tableRule.TableStrategy != nil {
-		tables, err := r.calculateSharding(tableRule.TableStrategy, shardingValues)
+	switch {
+	case len(tableHints) > 0:
+		tables, err := r.calculateHintSharding(tableRule.TableStrategy, tableHints)
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate table sharding: %w", err)
 		}
 		targetTables = tables
-	} else {
-		// 如果没有表分片策略，使用目标数据源下的所有表
-		for _, node := range dataNodes {
-			if contains(targetDataSources, node.DataSource) && !contains(targetTables, node.Table) {
-				targetTables = append(targetTables, node.Table)
-			}
+	case tableRule.TableStrategy != nil && !isHintStrategy(tableRule.TableStrategy) && len(shardingValues) > 0:
+		tables, err := r.calculateSharding(tableRule.TableStrategy, shardingValues, availableTables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate table sharding: %w", err)
 		}
+		targetTables = tables
+	default:
+		// 如果没有表分片策略或分片值，使用目标数据源下的所有表
+		targetTables = availableTables
 	}
 
 	// 组合结果
@@ -113,15 +176,224 @@ func (r *ShardingRouter) Route(logicTable string, shardingValues map[string]inte
 	return results, nil
 }
 
+// Validate 检查分片列类型，创建所有 standard 策略的分片算法，并检查实际数据节点是否覆盖算法的所有分片
+func (r *ShardingRouter) Validate() error {
+	if r.shardingRule == nil {
+		return nil
+	}
+	for logicTable, tableRule := range r.shardingRule.Tables {
+		dataNodes, err := r.parseActualDataNodes(tableRule.ActualDataNodes)
+		if err != nil {
+			return fmt.Errorf("failed to parse actual data nodes of table %s: %w", logicTable, err)
+		}
+
+		var dataSources, tables []string
+		for _, node := range dataNodes {
+			if !contains(dataSources, node.DataSource) {
+				dataSources = append(dataSources, node.DataSource)
+			}
+			if !contains(tables, node.Table) {
+				tables = append(tables, node.Table)
+			}
+		}
+
+		if err := r.validateStrategy(tableRule.DatabaseStrategy, dataSources); err != nil {
+			return fmt.Errorf("invalid database strategy of table %s: %w", logicTable, err)
+		}
+		if err := r.validateStrategy(tableRule.TableStrategy, tables); err != nil {
+			return fmt.Errorf("invalid table strategy of table %s: %w", logicTable, err)
+		}
+		if err := r.validateKeyGenerator(tableRule); err != nil {
+			return fmt.Errorf("invalid key generator of table %s: %w", logicTable, err)
+		}
+	}
+	return nil
+}
+
+// stringKeyColumnTypes 字符串主键生成器类型到主键作为分片列时允许的列类型
+var stringKeyColumnTypes = map[string][]algorithm.ColumnType{
+	"uuid":   {algorithm.ColumnTypeAny, algorithm.ColumnTypeString, algorithm.ColumnTypeBytes, algorithm.ColumnTypeUUID},
+	"uuidv4": {algorithm.ColumnTypeAny, algorithm.ColumnTypeString, algorithm.ColumnTypeBytes, algorithm.ColumnTypeUUID},
+	"uuidv7": {algorithm.ColumnTypeAny, algorithm.ColumnTypeString, algorithm.ColumnTypeBytes, algorithm.ColumnTypeUUID},
+	"ulid":   {algorithm.ColumnTypeAny, algorithm.ColumnTypeString, algorithm.ColumnTypeBytes},
+	"ksuid":  {algorithm.ColumnTypeAny, algorithm.ColumnTypeString, algorithm.ColumnTypeBytes},
+}
+
+// validateKeyGenerator 检查主键生成器与分片策略匹配
+func (r *ShardingRouter) validateKeyGenerator(tableRule *config.TableRuleConfig) error {
+	keyGenerator := tableRule.KeyGenerator
+	if keyGenerator == nil {
+		return nil
+	}
+	if columnTypes, exists := stringKeyColumnTypes[keyGenerator.Type]; exists {
+		return validateStringKeyColumn(tableRule, columnTypes)
+	}
+	if keyGenerator.Type == geneKeyGeneratorType {
+		return r.validateGeneKeyGenerator(tableRule)
+	}
+	return nil
+}
+
+// validateStringKeyColumn 检查以字符串主键作为分片列的策略声明的列类型能够接收字符串主键
+func validateStringKeyColumn(tableRule *config.TableRuleConfig, columnTypes []algorithm.ColumnType) error {
+	keyGenerator := tableRule.KeyGenerator
+	for _, strategy := range []*config.ShardingStrategyConfig{tableRule.DatabaseStrategy, tableRule.TableStrategy} {
+		if strategy == nil {
+			continue
+		}
+		columns := append([]string{strategy.ShardingColumn}, strategy.AlternateShardingColumns...)
+		if !contains(columns, keyGenerator.Column) {
+			continue
+		}
+
+		columnType, err := algorithm.ParseColumnType(strategy.ColumnType)
+		if err != nil {
+			return err
+		}
+		allowed := false
+		for _, t := range columnTypes {
+			allowed = allowed || t == columnType
+		}
+		if !allowed {
+			return fmt.Errorf("%s key generator produces string keys, but sharding column %s has type %s", keyGenerator.Type, keyGenerator.Column, columnType)
+		}
+	}
+	return nil
+}
+
+// validateGeneKeyGenerator 检查基因主键生成器与 GENE_MOD 分片策略匹配：
+// 策略的分片列是提供基因的列，且生成器与算法的基因位宽度相同
+func (r *ShardingRouter) validateGeneKeyGenerator(tableRule *config.TableRuleConfig) error {
+	keyGenerator := tableRule.KeyGenerator
+
+	geneColumn, _ := keyGenerator.Props["gene-column"].(string)
+	if geneColumn == "" {
+		return fmt.Errorf("gene-column is required for gene key generator")
+	}
+	geneBits, err := algorithm.ConvertToInt(keyGenerator.Props["gene-bits"])
+	if err != nil {
+		return fmt.Errorf("invalid gene-bits: %w", err)
+	}
+
+	found := false
+	for _, strategy := range []*config.ShardingStrategyConfig{tableRule.DatabaseStrategy, tableRule.TableStrategy} {
+		if strategy == nil || strategy.Type != standardStrategyType {
+			continue
+		}
+		shardingAlgorithm, err := r.shardingAlgorithm(strategy)
+		if err != nil {
+			return err
+		}
+		geneAlgorithm, ok := shardingAlgorithm.(*algorithm.GeneShardingAlgorithm)
+		if !ok {
+			continue
+		}
+		if strategy.ShardingColumn != geneColumn {
+			return fmt.Errorf("gene sharding strategy uses column %s, but key generator takes its gene from %s", strategy.ShardingColumn, geneColumn)
+		}
+		if int64(geneAlgorithm.GeneBits()) != geneBits {
+			return fmt.Errorf("key generator has %d gene bits, but gene sharding algorithm has %d", geneBits, geneAlgorithm.GeneBits())
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("gene key generator requires a GENE_MOD sharding strategy on column %s", geneColumn)
+	}
+	return nil
+}
+
+// validateStrategy 检查分片列类型是否支持，以及 standard 策略的分片算法能否创建和目标是否完整
+func (r *ShardingRouter) validateStrategy(strategy *config.ShardingStrategyConfig, targets []string) error {
+	if strategy == nil {
+		return nil
+	}
+	if _, err := algorithm.ParseColumnType(strategy.ColumnType); err != nil {
+		return err
+	}
+	if strategy.Type != standardStrategyType {
+		return nil
+	}
+
+	shardingAlgorithm, err := r.shardingAlgorithm(strategy)
+	if err != nil {
+		return err
+	}
+	if validator, ok := shardingAlgorithm.(algorithm.TargetValidator); ok {
+		return validator.ValidateTargets(targets)
+	}
+	return nil
+}
+
 // DataNode 数据节点
 type DataNode struct {
 	DataSource string
 	Table      string
 }
 
-// parseActualDataNodes 解析实际数据节点表达式
+// ActualDataNodes 获取逻辑表的所有实际数据节点
+func (r *ShardingRouter) ActualDataNodes(logicTable string) ([]*DataNode, error) {
+	tableRule, exists := r.shardingRule.Tables[logicTable]
+	if !exists {
+		return nil, fmt.Errorf("table rule not found for table: %s", logicTable)
+	}
+
+	dataNodes, err := r.parseActualDataNodes(tableRule.ActualDataNodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse actual data nodes: %w", err)
+	}
+	return dataNodes, nil
+}
+
+// ActualDataSources 获取逻辑表实际数据节点中引用的数据源，按出现顺序去重
+func (r *ShardingRouter) ActualDataSources(logicTable string) ([]string, error) {
+	dataNodes, err := r.ActualDataNodes(logicTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataSources []string
+	for _, node := range dataNodes {
+		if !contains(dataSources, node.DataSource) {
+			dataSources = append(dataSources, node.DataSource)
+		}
+	}
+	return dataSources, nil
+}
+
+// parseActualDataNodes 解析实际数据节点表达式，多个表达式之间用逗号分隔，如 ds_0.t_order_0,ds_1.t_order_1
 func (r *ShardingRouter) parseActualDataNodes(expression string) ([]*DataNode, error) {
 	var nodes []*DataNode
+	for _, part := range splitDataNodeExpressions(expression) {
+		partNodes, err := r.parseDataNodeExpression(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, partNodes...)
+	}
+	return nodes, nil
+}
+
+// splitDataNodeExpressions 按不在 ${} 内的逗号拆分实际数据节点表达式
+func splitDataNodeExpressions(expression string) []string {
+	var parts []string
+	braceLevel, start := 0, 0
+	for i, char := range expression {
+		switch {
+		case char == '{':
+			braceLevel++
+		case char == '}':
+			braceLevel--
+		case char == ',' && braceLevel == 0:
+			parts = append(parts, expression[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, expression[start:])
+}
+
+// parseDataNodeExpression 解析单个实际数据节点表达式
+func (r *ShardingRouter) parseDataNodeExpression(expression string) ([]*DataNode, error) {
+	var nodes []*DataNode
 
 	// 支持格式: ds_${0..1}.t_order_${0..1}
 	// 需要找到不在 ${} 内的点作为分隔符
@@ -213,21 +485,133 @@ func (r *ShardingRouter) parseRangeExpression(pattern string) ([]string, error)
 	return []string{pattern}, nil
 }
 
-// calculateSharding 计算分片结果
-func (r *ShardingRouter) calculateSharding(strategy *config.ShardingStrategyConfig, shardingValues map[string]interface{}) ([]string, error) {
-	if strategy.Type == "" || strategy.Type == "inline" {
+// calculateSharding 计算分片结果，availableTargets 为可选的数据源或真实表
+func (r *ShardingRouter) calculateSharding(strategy *config.ShardingStrategyConfig, shardingValues map[string]interface{}, availableTargets []string) ([]string, error) {
+	switch strategy.Type {
+	case "", "inline":
 		return r.calculateInlineSharding(strategy, shardingValues)
+	case standardStrategyType:
+		return r.calculateStandardSharding(strategy, shardingValues, availableTargets)
 	}
 
 	return nil, fmt.Errorf("unsupported sharding strategy type: %s", strategy.Type)
 }
 
+// calculateStandardSharding 使用分片算法计算分片
+// 分片值为 *algorithm.Range 时按范围分片，为 []interface{} 时按 IN 查询分片
+func (r *ShardingRouter) calculateStandardSharding(strategy *config.ShardingStrategyConfig, shardingValues map[string]interface{}, availableTargets []string) ([]string, error) {
+	column, value, exists := standardShardingValue(strategy, shardingValues)
+	if !exists {
+		return nil, fmt.Errorf("sharding column %s not found in sharding values", strategy.ShardingColumn)
+	}
+	value, err := coerceShardingValue(strategy, value)
+	if err != nil {
+		return nil, err
+	}
+
+	shardingAlgorithm, err := r.shardingAlgorithm(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	shardingValue := &algorithm.ShardingValue{ColumnName: column}
+	switch v := value.(type) {
+	case *algorithm.Range:
+		shardingValue.Range = v
+	case []interface{}:
+		shardingValue.Values = v
+	default:
+		shardingValue.Value = v
+	}
+	return shardingAlgorithm.DoSharding(availableTargets, shardingValue)
+}
+
+// standardShardingValue 获取 standard 策略的分片列和分片值，分片列没有分片值时依次使用备选分片列
+func standardShardingValue(strategy *config.ShardingStrategyConfig, shardingValues map[string]interface{}) (string, interface{}, bool) {
+	for _, column := range append([]string{strategy.ShardingColumn}, strategy.AlternateShardingColumns...) {
+		if value, exists := shardingValues[column]; exists {
+			return column, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// shardingAlgorithm 获取 standard 策略的分片算法，每个策略只创建一次
+func (r *ShardingRouter) shardingAlgorithm(strategy *config.ShardingStrategyConfig) (algorithm.ShardingAlgorithm, error) {
+	r.algorithmsMu.Lock()
+	defer r.algorithmsMu.Unlock()
+
+	if shardingAlgorithm, exists := r.algorithms[strategy]; exists {
+		return shardingAlgorithm, nil
+	}
+	shardingAlgorithm, err := r.algorithmFactory.CreateAlgorithm(strategy.Algorithm, strategy.Props)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sharding algorithm %s: %w", strategy.Algorithm, err)
+	}
+	r.algorithms[strategy] = shardingAlgorithm
+	return shardingAlgorithm, nil
+}
+
+// hintValues 选择作用于分片策略的提示值，直接路由值优先于分片值，分片值只作用于 hint 分片策略
+func hintValues(strategy *config.ShardingStrategyConfig, shardingValues, routes []interface{}) []interface{} {
+	if len(routes) > 0 {
+		return routes
+	}
+	if isHintStrategy(strategy) {
+		return shardingValues
+	}
+	return nil
+}
+
+// calculateHintSharding 根据强制路由提示计算分片
+// hint 策略的 algorithm 是以 value 为变量的内联表达式，例如 ds_${value % 2}；
+// 未配置 algorithm 或提示值是非数字的字符串时，提示值即为目标名称；其他策略的提示值必须是目标名称
+func (r *ShardingRouter) calculateHintSharding(strategy *config.ShardingStrategyConfig, hintValues []interface{}) ([]string, error) {
+	var targets []string
+	for _, value := range hintValues {
+		target := fmt.Sprint(value)
+		if !isHintStrategy(strategy) && !isTargetName(value) {
+			return nil, fmt.Errorf("hint value %v must name the target because the strategy is not %s", value, hintStrategyType)
+		}
+		if strategy != nil && strategy.Algorithm != "" && !isTargetName(value) {
+			result, err := r.evaluateInlineExpression(strategy.Algorithm, hintValueVariable, value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate hint algorithm: %w", err)
+			}
+			target = result
+		}
+		if !contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// isTargetName 判断提示值是否直接指定了目标名称
+func isTargetName(value interface{}) bool {
+	name, ok := value.(string)
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(name)
+	return err != nil
+}
+
+// isHintStrategy 判断是否为 hint 分片策略
+func isHintStrategy(strategy *config.ShardingStrategyConfig) bool {
+	return strategy != nil && strategy.Type == hintStrategyType
+}
+
 // calculateInlineSharding 计算内联分片
 func (r *ShardingRouter) calculateInlineSharding(strategy *config.ShardingStrategyConfig, shardingValues map[string]interface{}) ([]string, error) {
 	value, exists := shardingValues[strategy.ShardingColumn]
 	if !exists {
 		return nil, fmt.Errorf("sharding column %s not found in sharding values", strategy.ShardingColumn)
 	}
+	value, err := coerceShardingValue(strategy, value)
+	if err != nil {
+		return nil, err
+	}
 
 	// 计算表达式结果
 	result, err := r.evaluateInlineExpression(strategy.Algorithm, strategy.ShardingColumn, value)
@@ -239,58 +623,81 @@ func (r *ShardingRouter) calculateInlineSharding(strategy *config.ShardingStrate
 }
 
 // evaluateInlineExpression 计算内联表达式
-func (r *ShardingRouter) evaluateInlineExpression(algorithm, shardingColumn string, shardingValue interface{}) (string, error) {
-	// 将分片值转换为整数
-	var intValue int
-	switch v := shardingValue.(type) {
-	case int:
-		intValue = v
-	case int32:
-		intValue = int(v)
-	case int64:
-		intValue = int(v)
-	case float32:
-		intValue = int(v)
-	case float64:
-		intValue = int(v)
-	case string:
-		var err error
-		intValue, err = strconv.Atoi(v)
-		if err != nil {
-			return "", fmt.Errorf("cannot convert sharding value to integer: %v", v)
-		}
-	default:
-		return "", fmt.Errorf("unsupported sharding value type: %T", v)
-	}
-
+// 取模表达式要求分片值可以无损转换为整数，变量替换表达式使用分片值的字符串形式，如字符串租户编码
+func (r *ShardingRouter) evaluateInlineExpression(expression, shardingColumn string, shardingValue interface{}) (string, error) {
 	// 支持模运算表达式: ds_${user_id % 2} 或 t_order_${order_id % 2}
 	modRegex := regexp.MustCompile(`^(.+)\$\{` + regexp.QuoteMeta(shardingColumn) + `\s*%\s*(\d+)\}(.*)$`)
-	matches := modRegex.FindStringSubmatch(algorithm)
-	
+	matches := modRegex.FindStringSubmatch(expression)
+
 	if len(matches) == 4 {
-		prefix := matches[1]
-		mod, err := strconv.Atoi(matches[2])
+		intValue, err := algorithm.ConvertToInt(shardingValue)
 		if err != nil {
+			return "", fmt.Errorf("cannot convert sharding value to integer: %w", err)
+		}
+		prefix := matches[1]
+		mod, err := strconv.ParseInt(matches[2], 10, 64)
+		if err != nil || mod == 0 {
 			return "", fmt.Errorf("invalid modulo in expression: %s", matches[2])
 		}
 		suffix := matches[3]
-		
+
 		result := intValue % mod
-		return prefix + strconv.Itoa(result) + suffix, nil
+		return prefix + strconv.FormatInt(result, 10) + suffix, nil
 	}
 
 	// 支持简单的变量替换: ds_${user_id}
 	simpleRegex := regexp.MustCompile(`^(.+)\$\{` + regexp.QuoteMeta(shardingColumn) + `\}(.*)$`)
-	simpleMatches := simpleRegex.FindStringSubmatch(algorithm)
-	
+	simpleMatches := simpleRegex.FindStringSubmatch(expression)
+
 	if len(simpleMatches) == 3 {
 		prefix := simpleMatches[1]
 		suffix := simpleMatches[2]
-		return prefix + strconv.Itoa(intValue) + suffix, nil
+		return prefix + algorithm.ConvertToString(shardingValue) + suffix, nil
 	}
 
 	// 如果没有匹配到任何表达式，直接返回算法字符串
-	return algorithm, nil
+	return expression, nil
+}
+
+// coerceShardingValue 按策略声明的列类型转换分片值，IN 查询和范围查询的每个值都会转换
+func coerceShardingValue(strategy *config.ShardingStrategyConfig, value interface{}) (interface{}, error) {
+	columnType, err := algorithm.ParseColumnType(strategy.ColumnType)
+	if err != nil {
+		return nil, err
+	}
+
+	coerce := func(v interface{}) (interface{}, error) {
+		coerced, err := algorithm.CoerceValue(v, columnType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of sharding column %s: %w", strategy.ShardingColumn, err)
+		}
+		return coerced, nil
+	}
+
+	switch v := value.(type) {
+	case *algorithm.Range:
+		coerced := &algorithm.Range{}
+		if v.Start != nil {
+			if coerced.Start, err = coerce(v.Start); err != nil {
+				return nil, err
+			}
+		}
+		if v.End != nil {
+			if coerced.End, err = coerce(v.End); err != nil {
+				return nil, err
+			}
+		}
+		return coerced, nil
+	case []interface{}:
+		coerced := make([]interface{}, len(v))
+		for i, item := range v {
+			if coerced[i], err = coerce(item); err != nil {
+				return nil, err
+			}
+		}
+		return coerced, nil
+	}
+	return coerce(value)
 }
 
 // isValidDataNode 检查数据节点是否有效
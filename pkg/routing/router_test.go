@@ -1,10 +1,14 @@
 package routing
 
 import (
+	"database/sql"
+	"go-sharding/pkg/algorithm"
 	"go-sharding/pkg/config"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewShardingRouter(t *testing.T) {
@@ -379,7 +383,7 @@ func TestShardingRouter_calculateSharding(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			results, err := router.calculateSharding(tt.strategy, tt.shardingValues)
+			results, err := router.calculateSharding(tt.strategy, tt.shardingValues, nil)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -544,4 +548,255 @@ func BenchmarkShardingRouter_parseActualDataNodes(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, _ = router.parseActualDataNodes(expression)
 	}
-}
\ No newline at end of file
+}
+
+func TestShardingRouter_RouteStandardStrategy(t *testing.T) {
+	router := NewShardingRouter(nil, &config.ShardingRuleConfig{
+		Tables: map[string]*config.TableRuleConfig{
+			"t_log": {
+				ActualDataNodes: "ds_0.t_log_${[202401, 202402, 202403]}",
+				TableStrategy: &config.ShardingStrategyConfig{
+					ShardingColumn: "created_at",
+					Algorithm:      "INTERVAL",
+					Type:           "standard",
+					Props: map[string]interface{}{
+						"datetime-lower":          "2024-01-01 00:00:00",
+						"datetime-interval-unit":  "MONTHS",
+						"sharding-suffix-pattern": "200601",
+					},
+				},
+			},
+		},
+	})
+
+	results, err := router.Route("t_log", map[string]interface{}{"created_at": "2024-02-10 08:00:00"})
+	require.NoError(t, err)
+	assert.Equal(t, []*RouteResult{{DataSource: "ds_0", Table: "t_log_202402"}}, results)
+
+	results, err = router.Route("t_log", map[string]interface{}{
+		"created_at": &algorithm.Range{Start: "2024-02-10 00:00:00", End: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0.t_log_202402", "ds_0.t_log_202403"}, routeTargets(results))
+
+	_, err = router.Route("t_log", map[string]interface{}{"created_at": "2023-12-31 00:00:00"})
+	assert.Error(t, err)
+}
+
+func TestShardingRouter_Validate(t *testing.T) {
+	newRouter := func(actualDataNodes, algorithmType string) *ShardingRouter {
+		return NewShardingRouter(nil, &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: actualDataNodes,
+					TableStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn: "order_id",
+						Algorithm:      algorithmType,
+						Type:           "standard",
+						Props:          map[string]interface{}{"sharding-ranges": "100,200"},
+					},
+				},
+			},
+		})
+	}
+
+	router := newRouter("ds_0.t_order_${0..2}", "BOUNDARY_RANGE")
+	require.NoError(t, router.Validate())
+	results, err := router.Route("t_order", map[string]interface{}{"order_id": 150})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0.t_order_1"}, routeTargets(results))
+
+	err = newRouter("ds_0.t_order_${0..1}", "BOUNDARY_RANGE").Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no target found for shard 2")
+
+	err = newRouter("ds_0.t_order_${0..2}", "UNKNOWN").Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported sharding algorithm")
+}
+
+func TestShardingRouter_ValidateStringKeyGenerator(t *testing.T) {
+	tests := []struct {
+		name          string
+		generatorType string
+		columnType    string
+		expectError   bool
+	}{
+		{"uuidv7 with uuid column", "uuidv7", "UUID", false},
+		{"uuid with untyped column", "uuid", "", false},
+		{"ulid with varchar column", "ulid", "VARCHAR", false},
+		{"ksuid with binary column", "ksuid", "BINARY", false},
+		{"ulid with uuid column", "ulid", "UUID", true},
+		{"uuidv7 with bigint column", "uuidv7", "BIGINT", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewShardingRouter(nil, &config.ShardingRuleConfig{
+				Tables: map[string]*config.TableRuleConfig{
+					"t_order": {
+						ActualDataNodes: "ds_0.t_order_${0..1}",
+						TableStrategy: &config.ShardingStrategyConfig{
+							ShardingColumn: "order_id",
+							ColumnType:     tt.columnType,
+							Algorithm:      "HASH_MOD",
+							Type:           "standard",
+							Props:          map[string]interface{}{"sharding-count": 2},
+						},
+						KeyGenerator: &config.KeyGeneratorConfig{Column: "order_id", Type: tt.generatorType},
+					},
+				},
+			})
+
+			err := router.Validate()
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "produces string keys")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestShardingRouter_RouteAutoTable(t *testing.T) {
+	rule := &config.ShardingRuleConfig{
+		AutoTables: map[string]*config.AutoTableRuleConfig{
+			"t_order": {
+				ActualDataSources: []string{"ds_0", "ds_1"},
+				ShardingColumn:    "order_id",
+				ShardingCount:     4,
+			},
+		},
+	}
+	require.NoError(t, rule.ResolveAutoTables())
+
+	router := NewShardingRouter(nil, rule)
+	require.NoError(t, router.Validate())
+
+	tests := []struct {
+		orderID  int
+		expected string
+	}{
+		{orderID: 0, expected: "ds_0.t_order_0"},
+		{orderID: 1, expected: "ds_1.t_order_1"},
+		{orderID: 6, expected: "ds_0.t_order_2"},
+		{orderID: 7, expected: "ds_1.t_order_3"},
+	}
+	for _, tt := range tests {
+		results, err := router.Route("t_order", map[string]interface{}{"order_id": tt.orderID})
+		require.NoError(t, err)
+		assert.Equal(t, []string{tt.expected}, routeTargets(results))
+	}
+
+	results, err := router.Route("t_order", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0.t_order_0", "ds_1.t_order_1", "ds_0.t_order_2", "ds_1.t_order_3"}, routeTargets(results))
+
+	dataSources, err := router.ActualDataSources("t_order")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0", "ds_1"}, dataSources)
+}
+
+func TestShardingRouter_RouteTypedValues(t *testing.T) {
+	router := NewShardingRouter(nil, &config.ShardingRuleConfig{
+		Tables: map[string]*config.TableRuleConfig{
+			"t_tenant": {
+				ActualDataNodes: "ds_${[tenant_a, tenant_b]}.t_tenant",
+				DatabaseStrategy: &config.ShardingStrategyConfig{
+					ShardingColumn: "tenant",
+					Algorithm:      "ds_${tenant}",
+				},
+			},
+			"t_order": {
+				ActualDataNodes: "ds_0.t_order_${0..1}",
+				TableStrategy: &config.ShardingStrategyConfig{
+					ShardingColumn: "order_id",
+					Algorithm:      "t_order_${order_id % 2}",
+					ColumnType:     "BIGINT",
+				},
+			},
+			"t_session": {
+				ActualDataNodes: "ds_0.t_session_${0..3}",
+				TableStrategy: &config.ShardingStrategyConfig{
+					ShardingColumn: "session_id",
+					Algorithm:      "CONSISTENT_HASH",
+					Type:           "standard",
+					ColumnType:     "UUID",
+				},
+			},
+		},
+	})
+	require.NoError(t, router.Validate())
+
+	results, err := router.Route("t_tenant", map[string]interface{}{"tenant": []byte("tenant_b")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_tenant_b.t_tenant"}, routeTargets(results))
+
+	results, err = router.Route("t_order", map[string]interface{}{"order_id": sql.NullInt64{Int64: 7, Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0.t_order_1"}, routeTargets(results))
+
+	results, err = router.Route("t_order", map[string]interface{}{"order_id": []byte("8")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_0.t_order_0"}, routeTargets(results))
+
+	// 浮点数不会被截断
+	_, err = router.Route("t_order", map[string]interface{}{"order_id": 7.5})
+	assert.Error(t, err)
+
+	// 同一个 UUID 的不同表示路由到同一张表
+	upper, err := router.Route("t_session", map[string]interface{}{"session_id": "F47AC10B-58CC-4372-A567-0E02B2C3D479"})
+	require.NoError(t, err)
+	raw, err := router.Route("t_session", map[string]interface{}{"session_id": []byte{
+		0xf4, 0x7a, 0xc1, 0x0b, 0x58, 0xcc, 0x43, 0x72, 0xa5, 0x67, 0x0e, 0x02, 0xb2, 0xc3, 0xd4, 0x79,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, routeTargets(upper), routeTargets(raw))
+
+	router.shardingRule.Tables["t_order"].TableStrategy.ColumnType = "JSON"
+	assert.Error(t, router.Validate())
+}
+
+func TestShardingRouter_RouteGeneStrategy(t *testing.T) {
+	newRule := func(keyGeneBits int, geneColumn string) *config.ShardingRuleConfig {
+		return &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: "ds_${0..3}.t_order",
+					DatabaseStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn:           "user_id",
+						AlternateShardingColumns: []string{"order_id"},
+						Algorithm:                "GENE_MOD",
+						Type:                     "standard",
+						Props:                    map[string]interface{}{"sharding-count": 4, "gene-bits": 4},
+					},
+					KeyGenerator: &config.KeyGeneratorConfig{
+						Column: "order_id",
+						Type:   "gene",
+						Props:  map[string]interface{}{"gene-column": geneColumn, "gene-bits": keyGeneBits},
+					},
+				},
+			},
+		}
+	}
+
+	router := NewShardingRouter(nil, newRule(4, "user_id"))
+	require.NoError(t, router.Validate())
+
+	byUser, err := router.Route("t_order", map[string]interface{}{"user_id": 6})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ds_2.t_order"}, routeTargets(byUser))
+
+	byOrder, err := router.Route("t_order", map[string]interface{}{"order_id": int64(987654321)<<4 | 6})
+	require.NoError(t, err)
+	assert.Equal(t, routeTargets(byUser), routeTargets(byOrder))
+
+	err = NewShardingRouter(nil, newRule(3, "user_id")).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gene bits")
+
+	err = NewShardingRouter(nil, newRule(4, "tenant_id")).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tenant_id")
+}
@@ -0,0 +1,192 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/rewrite"
+	"go-sharding/pkg/transaction"
+	"strings"
+)
+
+// ExecATContext 以 AT 模式执行 UPDATE/DELETE 语句
+// 每个数据源上的分支在本地事务中执行路由后的 DML，并写入前后镜像 undo log 后立即本地提交，
+// 全局回滚时由 ATTransactionImpl 根据 undo log 自动补偿
+func (db *ShardingDB) ExecATContext(ctx context.Context, tx *transaction.ATTransactionImpl, query string, args ...interface{}) (*ShardingResult, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
+	sqlType := statementKeyword(query)
+	if sqlType != "UPDATE" && sqlType != "DELETE" {
+		return nil, fmt.Errorf("AT mode only supports UPDATE and DELETE statements, got %s", sqlType)
+	}
+
+	logicTables := db.extractLogicTables(query)
+	if len(logicTables) != 1 {
+		return nil, fmt.Errorf("AT mode requires exactly one sharding table, got %d", len(logicTables))
+	}
+	logicTable := logicTables[0]
+
+	shardingValues := db.extractShardingValues(query, args)
+	routeResults, err := db.dataSource.router.RouteContext(ctx, logicTable, shardingValues)
+	if err != nil {
+		return nil, fmt.Errorf("routing failed for table %s: %w", logicTable, err)
+	}
+
+	units, err := db.dataSource.rewriter.RewriteExecutionUnits(&rewrite.RewriteContext{
+		OriginalSQL:  query,
+		LogicTables:  logicTables,
+		RouteResults: routeResults,
+		Parameters:   args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
+	}
+
+	primaryKeys, err := db.primaryKeys(logicTable)
+	if err != nil {
+		return nil, err
+	}
+	if sqlType == "UPDATE" {
+		if err := checkPrimaryKeysUnchanged(db.dataSource.rewriter, query, logicTable, primaryKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	var totalAffected int64
+	for _, group := range groupUnitsByDataSource(units) {
+		affected, err := db.executeATBranch(ctx, tx, sqlType, primaryKeys, group)
+		if err != nil {
+			return nil, err
+		}
+		totalAffected += affected
+	}
+
+	return &ShardingResult{affectedRows: totalAffected}, nil
+}
+
+// executeATBranch 在单个数据源上以本地事务执行一组 AT 执行单元
+func (db *ShardingDB) executeATBranch(ctx context.Context, tx *transaction.ATTransactionImpl, sqlType string, primaryKeys []string, units []*rewrite.ExecutionUnit) (int64, error) {
+	dataSource := units[0].DataSource
+	conn, exists := db.dataSource.dataSources[dataSource]
+	if !exists {
+		return 0, fmt.Errorf("data source %s not found", dataSource)
+	}
+
+	localTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin branch on %s: %w", dataSource, err)
+	}
+
+	var affected int64
+	for _, unit := range units {
+		beforeImage, err := db.dataSource.rewriter.BuildBeforeImage(unit)
+		if err != nil {
+			localTx.Rollback()
+			return 0, fmt.Errorf("failed to build before image: %w", err)
+		}
+
+		var result sql.Result
+		result, err = tx.ExecuteUnit(ctx, localTx, &transaction.ATExecutionUnit{
+			DataSource:            unit.DataSource,
+			TableName:             unit.ActualTable,
+			SQLType:               sqlType,
+			SQL:                   unit.SQL,
+			Parameters:            unit.Parameters,
+			BeforeImageSQL:        beforeImage.SQL,
+			BeforeImageParameters: beforeImage.Parameters,
+			PrimaryKeys:           primaryKeys,
+		})
+		if err != nil {
+			localTx.Rollback()
+			return 0, fmt.Errorf("exec failed on %s: %w", dataSource, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			affected += n
+		}
+	}
+
+	if err := localTx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit branch on %s: %w", dataSource, err)
+	}
+	return affected, nil
+}
+
+// primaryKeys 获取逻辑表的主键列
+func (db *ShardingDB) primaryKeys(logicTable string) ([]string, error) {
+	return primaryKeysOf(db.dataSource.configuredTables, logicTable)
+}
+
+// primaryKeysOf 获取逻辑表的主键列，优先使用 primaryKeyColumns，其次使用主键生成器配置的列，都没有配置时返回错误
+func primaryKeysOf(tables map[string]*config.TableRuleConfig, logicTable string) ([]string, error) {
+	tableConfig, exists := tables[logicTable]
+	if exists && len(tableConfig.PrimaryKeyColumns) > 0 {
+		return tableConfig.PrimaryKeyColumns, nil
+	}
+	if exists && tableConfig.KeyGenerator != nil && tableConfig.KeyGenerator.Column != "" {
+		return []string{tableConfig.KeyGenerator.Column}, nil
+	}
+	return nil, fmt.Errorf("primary key columns of table %s are not configured, set primaryKeyColumns to use AT mode", logicTable)
+}
+
+// checkPrimaryKeysUnchanged 检查 UPDATE 语句没有修改主键列
+// AT 模式按主键查询后镜像并在补偿时定位数据，主键被修改后补偿会误判为脏写
+func checkPrimaryKeysUnchanged(rewriter *rewrite.SQLRewriter, query, logicTable string, primaryKeys []string) error {
+	columns, err := rewriter.UpdatedColumns(query)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		for _, pk := range primaryKeys {
+			if strings.EqualFold(column, pk) {
+				return fmt.Errorf("%w: column %s of table %s", transaction.ErrPrimaryKeyUpdated, column, logicTable)
+			}
+		}
+	}
+	return nil
+}
+
+// groupUnitsByDataSource 按数据源分组执行单元，保持路由顺序
+func groupUnitsByDataSource(units []*rewrite.ExecutionUnit) [][]*rewrite.ExecutionUnit {
+	var groups [][]*rewrite.ExecutionUnit
+	index := make(map[string]int)
+	for _, unit := range units {
+		i, exists := index[unit.DataSource]
+		if !exists {
+			i = len(groups)
+			index[unit.DataSource] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], unit)
+	}
+	return groups
+}
+
+// statementKeyword 获取 SQL 语句的第一个关键字（忽略前导注释）
+func statementKeyword(query string) string {
+	query = strings.TrimSpace(query)
+	for {
+		if strings.HasPrefix(query, "/*") {
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+			query = strings.TrimSpace(query[end+2:])
+		} else if strings.HasPrefix(query, "--") {
+			end := strings.Index(query, "\n")
+			if end < 0 {
+				return ""
+			}
+			query = strings.TrimSpace(query[end+1:])
+		} else {
+			break
+		}
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], "(;"))
+}
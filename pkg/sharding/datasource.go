@@ -5,12 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
 	"go-sharding/pkg/id"
 	"go-sharding/pkg/merge"
+	"go-sharding/pkg/monitoring"
 	"go-sharding/pkg/rewrite"
 	"go-sharding/pkg/routing"
+	"go-sharding/pkg/transaction"
 	"regexp"
 	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ShardingDataSource 分片数据源
@@ -22,14 +28,33 @@ type ShardingDataSource struct {
 	rewriter         *rewrite.SQLRewriter
 	merger           *merge.ResultMerger
 	idGenerator      id.Generator
+	databaseTypes    map[string]database.DatabaseType
+	undoLogOnce      sync.Once
+	undoLogManager   *transaction.UndoLogManager
+	// geneGenerators 配置了 gene 主键生成器的逻辑表的生成器
+	geneGenerators map[string]id.GeneAwareGenerator
+	// keyGenerators 配置了 segment 或字符串主键生成器的逻辑表的生成器
+	keyGenerators map[string]id.KeyGenerator
+	// metrics 语句执行指标
+	metrics *monitoring.ShardingMetrics
+	// dataNodeCounts 各逻辑表的实际数据节点数量，用于识别全路由
+	dataNodeCounts map[string]int
+	// tracerProvider 创建语句 span 使用的 TracerProvider，为 nil 时使用全局 TracerProvider
+	tracerProvider trace.TracerProvider
 }
 
 // NewShardingDataSource 创建分片数据源
 func NewShardingDataSource(cfg *config.ShardingConfig) (*ShardingDataSource, error) {
+	if err := cfg.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid sharding rule: %w", err)
+	}
+
 	ds := &ShardingDataSource{
 		dataSources:      make(map[string]*sql.DB),
 		shardingRule:     cfg.ShardingRule,
 		configuredTables: cfg.ShardingRule.Tables,
+		databaseTypes:    make(map[string]database.DatabaseType),
+		metrics:          monitoring.NewShardingMetrics(),
 	}
 
 	// 初始化数据源连接
@@ -48,10 +73,19 @@ func NewShardingDataSource(cfg *config.ShardingConfig) (*ShardingDataSource, err
 		}
 
 		ds.dataSources[name] = db
+		ds.databaseTypes[name] = resolveDatabaseType(dsConfig.DriverName)
 	}
 
 	// 创建路由器
 	router := routing.NewShardingRouter(cfg.DataSources, ds.shardingRule)
+	if err := router.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sharding rule: %w", err)
+	}
+	counts, err := dataNodeCounts(router, ds.configuredTables)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sharding rule: %w", err)
+	}
+	ds.dataNodeCounts = counts
 
 	// 创建 SQL 重写器
 	rewriter := rewrite.NewSQLRewriter()
@@ -74,6 +108,22 @@ func NewShardingDataSource(cfg *config.ShardingConfig) (*ShardingDataSource, err
 	ds.merger = merger
 	ds.idGenerator = idGenerator
 
+	ds.geneGenerators = make(map[string]id.GeneAwareGenerator)
+	for logicTable, tableRule := range ds.configuredTables {
+		if tableRule.KeyGenerator == nil || tableRule.KeyGenerator.Type != geneKeyGeneratorType {
+			continue
+		}
+		generator, err := newGeneGenerator(factory, tableRule.KeyGenerator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key generator of table %s: %w", logicTable, err)
+		}
+		ds.geneGenerators[logicTable] = generator
+	}
+
+	if ds.keyGenerators, err = ds.newKeyGenerators(factory); err != nil {
+		return nil, err
+	}
+
 	return ds, nil
 }
 
@@ -92,6 +142,17 @@ func (ds *ShardingDataSource) GetConfiguredTables() map[string]*config.TableRule
 	return ds.configuredTables
 }
 
+// Metrics 获取语句执行指标，可以通过 Metrics().Handler() 暴露给 Prometheus
+func (ds *ShardingDataSource) Metrics() *monitoring.ShardingMetrics {
+	return ds.metrics
+}
+
+// SetTracerProvider 设置创建语句 span 使用的 TracerProvider，需要在执行语句前设置
+// 未设置时使用 otel 的全局 TracerProvider，应用没有配置全局 TracerProvider 时不记录 span
+func (ds *ShardingDataSource) SetTracerProvider(provider trace.TracerProvider) {
+	ds.tracerProvider = provider
+}
+
 // DB 获取分片数据库连接
 func (ds *ShardingDataSource) DB() *ShardingDB {
 	return &ShardingDB{
@@ -121,57 +182,72 @@ func (db *ShardingDB) Query(query string, args ...interface{}) (*ShardingRows, e
 
 // QueryContext 执行查询（带上下文）
 func (db *ShardingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*ShardingRows, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
+	// 上下文中绑定了事务时在事务中执行
+	if tx := ambientShardingTx(ctx, db); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+
 	// 提取逻辑表名
-	logicTables := db.extractLogicTables(query)
+	ctx, metrics := db.newStatementMetrics(ctx, "query", query)
+	logicTables, _ := metrics.parse(ctx, func() ([]string, error) {
+		return db.extractLogicTables(query), nil
+	})
 	if len(logicTables) == 0 {
 		// 如果没有分片表，直接在第一个数据源执行
-		return db.executeQueryOnFirstDataSource(ctx, query, args...)
-	}
-
-	// 提取分片值
-	shardingValues := db.extractShardingValues(query, args)
-
-	// 路由计算
-	var allRouteResults []*routing.RouteResult
-	for _, logicTable := range logicTables {
-		routeResults, err := db.dataSource.router.Route(logicTable, shardingValues)
-		if err != nil {
-			return nil, fmt.Errorf("routing failed for table %s: %w", logicTable, err)
-		}
-		allRouteResults = append(allRouteResults, routeResults...)
+		return db.executeQueryOnFirstDataSource(ctx, metrics, query, args...)
 	}
 
-	// SQL 重写
-	rewriteCtx := &rewrite.RewriteContext{
-		OriginalSQL:  query,
-		LogicTables:  logicTables,
-		RouteResults: allRouteResults,
-		Parameters:   args,
-	}
-	rewriteResults, err := db.dataSource.rewriter.Rewrite(rewriteCtx)
+	// 路由并重写
+	stmt, err := db.routeStatement(ctx, query, args, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
+		return nil, metrics.routeFailed(err)
 	}
+	metrics.routed(stmt)
+
 	// 执行查询
 	var allRows []*sql.Rows
-	for _, rewriteResult := range rewriteResults {
+	for _, rewriteResult := range stmt.rewriteResults {
 		conn := db.dataSource.dataSources[rewriteResult.DataSource]
-		rows, err := conn.QueryContext(ctx, rewriteResult.SQL, rewriteResult.Parameters...)
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		rows, err := conn.QueryContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
+		unit.end(0, err)
 		if err != nil {
 			// 关闭已打开的结果集
 			for _, r := range allRows {
 				r.Close()
 			}
-			return nil, fmt.Errorf("query failed on %s: %w", rewriteResult.DataSource, err)
+			return nil, metrics.finish(0, fmt.Errorf("query failed on %s: %w", rewriteResult.DataSource, err))
 		}
 		allRows = append(allRows, rows)
 	}
 
+	return finishShardingRows(ctx, metrics, stmt, allRows), nil
+}
+
+// finishShardingRows 在合并阶段构造查询结果，只返回第一个结果集，行数在关闭结果集时记录在其数据源上
+func finishShardingRows(ctx context.Context, metrics *statementMetrics, stmt *routedStatement, allRows []*sql.Rows) *ShardingRows {
+	var result *ShardingRows
+	metrics.merge(ctx, func() {
+		result = newShardingRows(allRows)
+	})
+	if len(allRows) == 0 {
+		metrics.finish(0, nil)
+		return result
+	}
+	result.onClose = metrics.finishQuery(stmt.rewriteResults[0].DataSource)
+	return result
+}
+
+// newShardingRows 构造分片查询结果
+func newShardingRows(allRows []*sql.Rows) *ShardingRows {
 	// 简化处理：如果只有一个结果集，直接返回
 	if len(allRows) == 1 {
 		return &ShardingRows{
 			rows: allRows[0],
-		}, nil
+		}
 	}
 
 	// 多个结果集需要合并，这里简化处理，返回第一个
@@ -182,32 +258,36 @@ func (db *ShardingDB) QueryContext(ctx context.Context, query string, args ...in
 		}
 		return &ShardingRows{
 			rows: allRows[0],
-		}, nil
+		}
 	}
-	
-	return &ShardingRows{}, nil
+
+	return &ShardingRows{}
 }
 
 // executeQueryOnFirstDataSource 在第一个数据源执行查询
-func (db *ShardingDB) executeQueryOnFirstDataSource(ctx context.Context, query string, args ...interface{}) (*ShardingRows, error) {
+func (db *ShardingDB) executeQueryOnFirstDataSource(ctx context.Context, metrics *statementMetrics, query string, args ...interface{}) (*ShardingRows, error) {
+	var firstName string
 	var firstDB *sql.DB
-	for _, conn := range db.dataSource.dataSources {
-		firstDB = conn
+	for name, conn := range db.dataSource.dataSources {
+		firstName, firstDB = name, conn
 		break
 	}
 
 	if firstDB == nil {
-		return nil, fmt.Errorf("no database connection available")
+		return nil, metrics.finish(0, fmt.Errorf("no database connection available"))
 	}
 
-	rows, err := firstDB.QueryContext(ctx, query, args...)
+	unitCtx, unit := metrics.startUnit(ctx, firstName, query)
+	rows, err := firstDB.QueryContext(unitCtx, query, args...)
+	unit.end(0, err)
 	if err != nil {
-		return nil, err
+		return nil, metrics.finish(0, err)
 	}
 
 	return &ShardingRows{
 		rows:    rows,
 		columns: nil,
+		onClose: metrics.finishQuery(firstName),
 	}, nil
 }
 
@@ -218,30 +298,99 @@ func (db *ShardingDB) Exec(query string, args ...interface{}) (*ShardingResult,
 
 // ExecContext 执行非查询语句（带上下文）
 func (db *ShardingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (*ShardingResult, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
+	// 上下文中绑定了事务时在事务中执行
+	if tx := ambientShardingTx(ctx, db); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+
 	// 提取逻辑表名
-	logicTables := db.extractLogicTables(query)
+	ctx, metrics := db.newStatementMetrics(ctx, "exec", query)
+	logicTables, _ := metrics.parse(ctx, func() ([]string, error) {
+		return db.extractLogicTables(query), nil
+	})
 	if len(logicTables) == 0 {
 		// 如果没有分片表，直接在第一个数据源执行
-		return db.executeExecOnFirstDataSource(ctx, query, args...)
+		return db.executeExecOnFirstDataSource(ctx, metrics, query, args...)
+	}
+
+	// 路由并重写
+	stmt, err := db.routeStatement(ctx, query, args, true)
+	if err != nil {
+		return nil, metrics.routeFailed(err)
+	}
+	metrics.routed(stmt)
+
+	// 执行语句
+	var results []sql.Result
+	for _, rewriteResult := range stmt.rewriteResults {
+		conn := db.dataSource.dataSources[rewriteResult.DataSource]
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		result, err := conn.ExecContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
+		if err != nil {
+			unit.end(0, err)
+			return nil, metrics.finish(0, fmt.Errorf("exec failed on %s: %w", rewriteResult.DataSource, err))
+		}
+		affected, _ := result.RowsAffected()
+		unit.end(affected, nil)
+		results = append(results, result)
+	}
+
+	merged := mergeExecResults(ctx, metrics, results)
+	return merged, metrics.finish(merged.affectedRows, nil)
+}
+
+// mergeExecResults 在合并阶段汇总各执行单元影响的行数，最后插入的 ID 取最后一个非零值
+func mergeExecResults(ctx context.Context, metrics *statementMetrics, results []sql.Result) *ShardingResult {
+	merged := &ShardingResult{}
+	metrics.merge(ctx, func() {
+		for _, result := range results {
+			if affected, err := result.RowsAffected(); err == nil {
+				merged.affectedRows += affected
+			}
+			if insertID, err := result.LastInsertId(); err == nil && insertID > 0 {
+				merged.lastInsertID = insertID
+			}
+		}
+	})
+	return merged
+}
+
+// routeStatement 路由并重写语句，exec 为 true 时为 INSERT 语句生成主键
+// 不涉及分片表的语句路由到默认数据源
+func (db *ShardingDB) routeStatement(ctx context.Context, query string, args []interface{}, exec bool) (*routedStatement, error) {
+	logicTables := db.extractLogicTables(query)
+	if len(logicTables) == 0 {
+		return newDefaultRoutedStatement(query, args, defaultDataSourceName(db.dataSource.dataSources)), nil
 	}
 
 	// 对于 INSERT 语句，可能需要生成 ID
-	if strings.ToUpper(strings.TrimSpace(query)[:6]) == "INSERT" {
-		query, args = db.handleInsertWithGeneratedID(query, args, logicTables[0])
+	if exec && statementKeyword(query) == "INSERT" {
+		var err error
+		if query, args, err = db.handleInsertWithGeneratedID(query, args, logicTables[0]); err != nil {
+			return nil, err
+		}
 	}
 
 	// 提取分片值
 	shardingValues := db.extractShardingValues(query, args)
 
 	// 路由计算
+	routeCtx, routeSpan := startPhaseSpan(ctx, "sharding.route")
 	var allRouteResults []*routing.RouteResult
 	for _, logicTable := range logicTables {
-		routeResults, err := db.dataSource.router.Route(logicTable, shardingValues)
+		routeResults, err := db.dataSource.router.RouteContext(routeCtx, logicTable, shardingValues)
 		if err != nil {
-			return nil, fmt.Errorf("routing failed for table %s: %w", logicTable, err)
+			err = fmt.Errorf("routing failed for table %s: %w", logicTable, err)
+			endSpan(routeSpan, err)
+			return nil, err
 		}
 		allRouteResults = append(allRouteResults, routeResults...)
 	}
+	routeSpan.SetAttributes(attrShardCount.Int(len(allRouteResults)))
+	routeSpan.End()
 
 	// SQL 重写
 	rewriteCtx := &rewrite.RewriteContext{
@@ -250,55 +399,98 @@ func (db *ShardingDB) ExecContext(ctx context.Context, query string, args ...int
 		RouteResults: allRouteResults,
 		Parameters:   args,
 	}
+	_, rewriteSpan := startPhaseSpan(ctx, "sharding.rewrite")
 	rewriteResults, err := db.dataSource.rewriter.Rewrite(rewriteCtx)
+	endSpan(rewriteSpan, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
 	}
-	// 执行语句
-	var totalAffected int64
-	var lastInsertID int64
 
-	for _, rewriteResult := range rewriteResults {
-		conn := db.dataSource.dataSources[rewriteResult.DataSource]
-		result, err := conn.ExecContext(ctx, rewriteResult.SQL, rewriteResult.Parameters...)
-		if err != nil {
-			return nil, fmt.Errorf("exec failed on %s: %w", rewriteResult.DataSource, err)
-		}
+	return &routedStatement{
+		logicTables:    logicTables,
+		rewriteContext: rewriteCtx,
+		rewriteResults: rewriteResults,
+	}, nil
+}
 
-		if affected, err := result.RowsAffected(); err == nil {
-			totalAffected += affected
-		}
+// BeginTx 开始分片事务，opts 为 nil 时使用本地事务
+func (db *ShardingDB) BeginTx(ctx context.Context, opts *TxOptions) (*ShardingTx, error) {
+	return newShardingTx(ctx, db, opts)
+}
 
-		if insertID, err := result.LastInsertId(); err == nil && insertID > 0 {
-			lastInsertID = insertID
-		}
+// Begin 开始本地分片事务
+func (db *ShardingDB) Begin() (*ShardingTx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// owner 获取所属的分片数据源，同一数据源创建的 ShardingDB 共享事务
+func (db *ShardingDB) owner() interface{} {
+	return db.dataSource
+}
+
+// branchDB 获取分支事务使用的连接池
+func (db *ShardingDB) branchDB(dataSource string) (*sql.DB, error) {
+	conn, exists := db.dataSource.dataSources[dataSource]
+	if !exists {
+		return nil, fmt.Errorf("data source %s not found", dataSource)
 	}
+	return conn, nil
+}
 
-	return &ShardingResult{
-		affectedRows: totalAffected,
-		lastInsertID: lastInsertID,
-	}, nil
+// databaseType 获取数据源的数据库类型
+func (db *ShardingDB) databaseType(dataSource string) database.DatabaseType {
+	if dbType, exists := db.dataSource.databaseTypes[dataSource]; exists {
+		return dbType
+	}
+	return database.MySQL
+}
+
+// undoLogs 获取 undo 日志管理器，undo log 按各数据源的数据库类型读写
+func (db *ShardingDB) undoLogs() *transaction.UndoLogManager {
+	ds := db.dataSource
+	ds.undoLogOnce.Do(func() {
+		ds.undoLogManager = transaction.NewUndoLogManager(ds.dataSources, database.MySQL)
+		for name := range ds.dataSources {
+			ds.undoLogManager.SetDatabaseType(name, db.databaseType(name))
+		}
+	})
+	return ds.undoLogManager
+}
+
+// sqlRewriter 获取 SQL 重写器
+func (db *ShardingDB) sqlRewriter() *rewrite.SQLRewriter {
+	return db.dataSource.rewriter
+}
+
+// newStatementMetrics 创建逻辑语句的指标记录和父 span
+func (db *ShardingDB) newStatementMetrics(ctx context.Context, operation, query string) (context.Context, *statementMetrics) {
+	return newStatementMetrics(ctx, tracerFrom(db.dataSource.tracerProvider), db.dataSource.metrics, db.dataSource.dataNodeCounts, operation, query)
 }
 
 // executeExecOnFirstDataSource 在第一个数据源执行非查询语句
-func (db *ShardingDB) executeExecOnFirstDataSource(ctx context.Context, query string, args ...interface{}) (*ShardingResult, error) {
+func (db *ShardingDB) executeExecOnFirstDataSource(ctx context.Context, metrics *statementMetrics, query string, args ...interface{}) (*ShardingResult, error) {
+	var firstName string
 	var firstDB *sql.DB
-	for _, conn := range db.dataSource.dataSources {
-		firstDB = conn
+	for name, conn := range db.dataSource.dataSources {
+		firstName, firstDB = name, conn
 		break
 	}
 
 	if firstDB == nil {
-		return nil, fmt.Errorf("no database connection available")
+		return nil, metrics.finish(0, fmt.Errorf("no database connection available"))
 	}
 
-	result, err := firstDB.ExecContext(ctx, query, args...)
+	unitCtx, unit := metrics.startUnit(ctx, firstName, query)
+	result, err := firstDB.ExecContext(unitCtx, query, args...)
 	if err != nil {
-		return nil, err
+		unit.end(0, err)
+		return nil, metrics.finish(0, err)
 	}
 
 	affected, _ := result.RowsAffected()
 	lastID, _ := result.LastInsertId()
+	unit.end(affected, nil)
+	metrics.finish(affected, nil)
 
 	return &ShardingResult{
 		affectedRows: affected,
@@ -307,10 +499,10 @@ func (db *ShardingDB) executeExecOnFirstDataSource(ctx context.Context, query st
 }
 
 // handleInsertWithGeneratedID 处理带生成 ID 的插入语句
-func (db *ShardingDB) handleInsertWithGeneratedID(query string, args []interface{}, logicTable string) (string, []interface{}) {
+func (db *ShardingDB) handleInsertWithGeneratedID(query string, args []interface{}, logicTable string) (string, []interface{}, error) {
 	tableConfig, exists := db.dataSource.configuredTables[logicTable]
 	if !exists || tableConfig.KeyGenerator == nil {
-		return query, args
+		return query, args, nil
 	}
 
 	// 简化实现：如果是 INSERT 语句且配置了主键生成器，生成 ID
@@ -318,17 +510,33 @@ func (db *ShardingDB) handleInsertWithGeneratedID(query string, args []interface
 		generatedID, err := db.dataSource.idGenerator.NextID()
 		if err != nil {
 			// 如果生成 ID 失败，返回原始参数
-			return query, args
+			return query, args, nil
 		}
 		// 这里应该解析 SQL 并插入生成的 ID
 		// 简化实现，假设 ID 是第一个参数
 		newArgs := make([]interface{}, len(args)+1)
 		newArgs[0] = generatedID
 		copy(newArgs[1:], args)
-		return query, newArgs
+		return query, newArgs, nil
+	}
+
+	if generator, exists := db.dataSource.geneGenerators[logicTable]; exists {
+		newArgs, err := generateGeneKey(generator, tableConfig.KeyGenerator, query, args)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate key for table %s: %w", logicTable, err)
+		}
+		return query, newArgs, nil
 	}
 
-	return query, args
+	if generator, exists := db.dataSource.keyGenerators[logicTable]; exists {
+		newArgs, err := generateKey(generator, tableConfig.KeyGenerator, query, args)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate key for table %s: %w", logicTable, err)
+		}
+		return query, newArgs, nil
+	}
+
+	return query, args, nil
 }
 
 // extractLogicTables 提取逻辑表名
@@ -344,6 +552,15 @@ func (db *ShardingDB) extractLogicTables(query string) []string {
 
 // extractShardingValues 提取分片值
 func (db *ShardingDB) extractShardingValues(query string, args []interface{}) map[string]interface{} {
+	// INSERT 语句的每一列都对应一个占位符时，按列名取分片值
+	if columns := insertPlaceholderColumns(query, len(args)); columns != nil {
+		shardingValues := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			shardingValues[column] = args[i]
+		}
+		return shardingValues
+	}
+
 	shardingValues := make(map[string]interface{})
 	
 	// 使用正则表达式提取 WHERE 条件中的分片值
@@ -386,13 +603,19 @@ func (db *ShardingDB) extractShardingValues(query string, args []interface{}) ma
 
 // ShardingRows 分片查询结果
 type ShardingRows struct {
-	rows    *sql.Rows
-	columns []string
+	rows     *sql.Rows
+	columns  []string
+	rowCount int64
+	onClose  func(rows int64) // 关闭时记录读取的行数
 }
 
 // Next 移动到下一行
 func (sr *ShardingRows) Next() bool {
-	return sr.rows.Next()
+	if !sr.rows.Next() {
+		return false
+	}
+	sr.rowCount++
+	return true
 }
 
 // Scan 扫描当前行
@@ -415,6 +638,10 @@ func (sr *ShardingRows) Columns() ([]string, error) {
 
 // Close 关闭结果集
 func (sr *ShardingRows) Close() error {
+	if sr.onClose != nil {
+		sr.onClose(sr.rowCount)
+		sr.onClose = nil
+	}
 	return sr.rows.Close()
 }
 
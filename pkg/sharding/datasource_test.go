@@ -388,6 +388,30 @@ func TestShardingDataSource_Close(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestShardingDataSource_AutoTables(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	ds, err := NewShardingDataSource(&config.ShardingConfig{
+		DataSources: map[string]*config.DataSourceConfig{
+			"ds_0": {DriverName: "recording", URL: prefix + "ds_0"},
+			"ds_1": {DriverName: "recording", URL: prefix + "ds_1"},
+		},
+		ShardingRule: &config.ShardingRuleConfig{
+			AutoTables: map[string]*config.AutoTableRuleConfig{
+				"t_order": {ActualDataSources: []string{"ds_0", "ds_1"}, ShardingColumn: "order_id", ShardingCount: 4},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer ds.Close()
+	assert.Contains(t, ds.GetConfiguredTables(), "t_order")
+
+	_, err = ds.DB().ExecContext(context.Background(), "UPDATE t_order SET status = 'PAID' WHERE order_id = ?", 3)
+	require.NoError(t, err)
+
+	assert.Empty(t, recordingLogFor(prefix+"ds_0").executed())
+	assert.Equal(t, []string{"UPDATE t_order_3 SET status = 'PAID' WHERE order_id = ?"}, recordingLogFor(prefix+"ds_1").executed())
+}
+
 // Benchmark tests
 func BenchmarkShardingDataSource_Query(b *testing.B) {
 	config := &config.ShardingConfig{
@@ -419,4 +443,16 @@ func BenchmarkShardingDataSource_Query(b *testing.B) {
 			rows.Close()
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestShardingDB_CommentHintsOnInlineTable(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+
+	// 注释提示直接指定 inline 分片表的数据源和真实表，不再全路由
+	rows, err := db.QueryContext(context.Background(), "/* SHARDING: ds=ds_1, table=t_order_1 */ SELECT * FROM t_order WHERE status = 'NEW'")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{"SELECT * FROM t_order_1 WHERE status = 'NEW'"}, ds1.executed())
+	assert.Empty(t, ds0.executed())
+}
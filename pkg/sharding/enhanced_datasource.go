@@ -5,11 +5,17 @@ import (
 	"database/sql"
 	"fmt"
 	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/monitoring"
 	"go-sharding/pkg/parser"
 	"go-sharding/pkg/readwrite"
 	"go-sharding/pkg/routing"
 	"go-sharding/pkg/rewrite"
+	"go-sharding/pkg/transaction"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // EnhancedShardingDB 增强的分片数据库，支持读写分离
@@ -21,10 +27,22 @@ type EnhancedShardingDB struct {
 	rewriter         *rewrite.SQLRewriter
 	parserFactory    *parser.ParserFactory
 	mutex            sync.RWMutex
+	undoLogOnce      sync.Once
+	undoLogManager   *transaction.UndoLogManager
+	metrics          *monitoring.ShardingMetrics
+	dataNodeCounts   map[string]int
+	tracerProvider   trace.TracerProvider
 }
 
 // NewEnhancedShardingDB 创建增强的分片数据库实例
 func NewEnhancedShardingDB(cfg *config.ShardingConfig) (*EnhancedShardingDB, error) {
+	if err := cfg.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid sharding rule: %w", err)
+	}
+	if err := cfg.ValidateReadWriteGroups(); err != nil {
+		return nil, fmt.Errorf("invalid read-write splits: %w", err)
+	}
+
 	db := &EnhancedShardingDB{
 		config:             cfg,
 		dataSources:        make(map[string]*sql.DB),
@@ -32,21 +50,66 @@ func NewEnhancedShardingDB(cfg *config.ShardingConfig) (*EnhancedShardingDB, err
 		router:             routing.NewShardingRouter(cfg.DataSources, cfg.ShardingRule),
 		rewriter:           rewrite.NewSQLRewriter(),
 		parserFactory:      parser.DefaultParserFactory,
+		metrics:            monitoring.NewShardingMetrics(),
+		dataNodeCounts:     make(map[string]int),
+	}
+
+	if err := db.validateDataNodes(); err != nil {
+		return nil, err
+	}
+	if err := db.router.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sharding rule: %w", err)
+	}
+	if cfg.ShardingRule != nil {
+		counts, err := dataNodeCounts(db.router, cfg.ShardingRule.Tables)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sharding rule: %w", err)
+		}
+		db.dataNodeCounts = counts
 	}
 
 	// 初始化数据源连接
 	if err := db.initDataSources(); err != nil {
+		db.closeDataSources()
 		return nil, fmt.Errorf("failed to initialize data sources: %w", err)
 	}
 
 	// 初始化读写分离器
 	if err := db.initReadWriteSplitters(); err != nil {
+		db.closeDataSources()
 		return nil, fmt.Errorf("failed to initialize read-write splitters: %w", err)
 	}
 
 	return db, nil
 }
 
+// validateDataNodes 校验实际数据节点引用的数据源
+// 数据节点可以引用读写分离组或不属于任何组的物理数据源，组内的主从库只能通过组引用
+func (db *EnhancedShardingDB) validateDataNodes() error {
+	if db.config.ShardingRule == nil {
+		return nil
+	}
+
+	for tableName := range db.config.ShardingRule.Tables {
+		dataSources, err := db.router.ActualDataSources(tableName)
+		if err != nil {
+			return fmt.Errorf("invalid actual data nodes for table %s: %w", tableName, err)
+		}
+		for _, dataSource := range dataSources {
+			if _, isGroup := db.config.ReadWriteSplits[dataSource]; isGroup {
+				continue
+			}
+			if _, exists := db.config.DataSources[dataSource]; !exists {
+				return fmt.Errorf("data source %s referenced by table %s not found", dataSource, tableName)
+			}
+			if group := db.config.ReadWriteGroupOf(dataSource); group != "" {
+				return fmt.Errorf("data source %s referenced by table %s belongs to read-write split %s, reference the group instead", dataSource, tableName, group)
+			}
+		}
+	}
+	return nil
+}
+
 // initDataSources 初始化数据源连接
 func (db *EnhancedShardingDB) initDataSources() error {
 	for name, dsConfig := range db.config.DataSources {
@@ -71,20 +134,50 @@ func (db *EnhancedShardingDB) initDataSources() error {
 	return nil
 }
 
-// initReadWriteSplitters 初始化读写分离器
+// initReadWriteSplitters 初始化读写分离器，失败时停止已经启动的后台健康检查和延迟检测
 func (db *EnhancedShardingDB) initReadWriteSplitters() error {
 	for name, rwConfig := range db.config.ReadWriteSplits {
 		splitter, err := readwrite.NewReadWriteSplitter(rwConfig, db.dataSources)
 		if err != nil {
+			db.stopReadWriteSplitters()
 			return fmt.Errorf("failed to create read-write splitter %s: %w", name, err)
 		}
 
+		// 后台健康检查和延迟检测随读写分离器关闭而停止
+		if err := splitter.StartHealthCheck(context.Background()); err != nil {
+			splitter.StopBackgroundChecks()
+			db.stopReadWriteSplitters()
+			return fmt.Errorf("failed to start health check for %s: %w", name, err)
+		}
+		splitter.SetDatabaseType(db.databaseType(name))
+		if err := splitter.StartLagMonitor(context.Background()); err != nil {
+			splitter.StopBackgroundChecks()
+			db.stopReadWriteSplitters()
+			return fmt.Errorf("failed to start lag monitor for %s: %w", name, err)
+		}
+
 		db.readWriteSplitters[name] = splitter
 	}
 
 	return nil
 }
 
+// stopReadWriteSplitters 停止已创建的读写分离器的后台任务，数据库连接由 closeDataSources 关闭
+func (db *EnhancedShardingDB) stopReadWriteSplitters() {
+	for name, splitter := range db.readWriteSplitters {
+		splitter.StopBackgroundChecks()
+		delete(db.readWriteSplitters, name)
+	}
+}
+
+// closeDataSources 关闭已打开的数据源连接，用于构造失败时释放资源
+func (db *EnhancedShardingDB) closeDataSources() {
+	for name, sqlDB := range db.dataSources {
+		sqlDB.Close()
+		delete(db.dataSources, name)
+	}
+}
+
 // Query 执行查询语句
 func (db *EnhancedShardingDB) Query(query string, args ...interface{}) (*EnhancedShardingRows, error) {
 	return db.QueryContext(context.Background(), query, args...)
@@ -92,60 +185,50 @@ func (db *EnhancedShardingDB) Query(query string, args ...interface{}) (*Enhance
 
 // QueryContext 执行查询语句（带上下文）
 func (db *EnhancedShardingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*EnhancedShardingRows, error) {
-	// 解析 SQL 语句
-	stmt, err := db.parserFactory.Parse(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse SQL: %w", err)
-	}
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
 
-	// 提取逻辑表名
-	configuredTables := make(map[string]bool)
-	if db.config.ShardingRule != nil {
-		for tableName := range db.config.ShardingRule.Tables {
-			configuredTables[tableName] = true
+	// 上下文中绑定了事务时在事务中执行，语句的指标和 span 由事务记录
+	if tx := ambientShardingTx(ctx, db); tx != nil {
+		stmt, err := db.parserFactory.Parse(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SQL: %w", err)
+		}
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
 		}
+		return &EnhancedShardingRows{rows: rows.rows, sqlType: stmt.Type, onClose: rows.onClose}, nil
 	}
 
-	logicTables := db.rewriter.ExtractLogicTables(query, configuredTables)
-	if len(logicTables) == 0 {
-		// 没有分片表，直接执行
-		return db.executeNonShardedQuery(ctx, query, args...)
+	// 解析 SQL 语句
+	ctx, metrics := db.newStatementMetrics(ctx, "query", query)
+	stmt, logicTables, err := db.parseStatement(ctx, metrics, query)
+	if err != nil {
+		return nil, metrics.routeFailed(err)
 	}
 
-	// 路由计算
-	var routes []*routing.RouteResult
-	if len(logicTables) > 0 {
-		// 提取分片值（简化实现，实际应该从 SQL 和参数中解析）
-		shardingValues := make(map[string]interface{})
-		for i, arg := range args {
-			shardingValues[fmt.Sprintf("param_%d", i)] = arg
-		}
-		
-		// 对每个逻辑表进行路由
-		for _, table := range logicTables {
-			tableRoutes, err := db.router.Route(table, shardingValues)
-			if err != nil {
-				return nil, fmt.Errorf("failed to route query for table %s: %w", table, err)
-			}
-			routes = append(routes, tableRoutes...)
-		}
-	}
+	// 强制路由提示要求仅主库路由时读请求也在主库执行
+	ctx = withHintMasterRoute(ctx)
 
-	// SQL 重写
-	rewriteCtx := &rewrite.RewriteContext{
-		OriginalSQL:  query,
-		LogicTables:  logicTables,
-		RouteResults: routes,
-		Parameters:   args,
+	if len(logicTables) == 0 {
+		// 没有分片表，直接执行
+		return db.executeNonShardedQuery(ctx, metrics, query, args...)
 	}
 
-	rewriteResults, err := db.rewriter.Rewrite(rewriteCtx)
+	// 路由并重写
+	routed, err := db.routeStatement(ctx, query, args, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
+		return nil, metrics.routeFailed(err)
 	}
+	metrics.routed(routed)
 
 	// 执行查询
-	return db.executeShardedQuery(ctx, stmt, rewriteResults)
+	rows, err := db.executeShardedQuery(ctx, stmt, routed.rewriteResults, metrics)
+	if err != nil {
+		return nil, metrics.finish(0, err)
+	}
+	return rows, nil
 }
 
 // Exec 执行非查询语句
@@ -155,13 +238,64 @@ func (db *EnhancedShardingDB) Exec(query string, args ...interface{}) (*Enhanced
 
 // ExecContext 执行非查询语句（带上下文）
 func (db *EnhancedShardingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (*EnhancedShardingResult, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
+	// 上下文中绑定了事务时在事务中执行，语句的指标和 span 由事务记录
+	if tx := ambientShardingTx(ctx, db); tx != nil {
+		if _, err := db.parserFactory.Parse(query); err != nil {
+			return nil, fmt.Errorf("failed to parse SQL: %w", err)
+		}
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &EnhancedShardingResult{result: result}, nil
+	}
+
 	// 解析 SQL 语句
-	stmt, err := db.parserFactory.Parse(query)
+	ctx, metrics := db.newStatementMetrics(ctx, "exec", query)
+	stmt, logicTables, err := db.parseStatement(ctx, metrics, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse SQL: %w", err)
+		return nil, metrics.routeFailed(err)
 	}
 
-	// 提取逻辑表名
+	if len(logicTables) == 0 {
+		// 没有分片表，直接执行
+		return db.executeNonShardedExec(ctx, metrics, query, args...)
+	}
+
+	// 路由并重写
+	routed, err := db.routeStatement(ctx, query, args, true)
+	if err != nil {
+		return nil, metrics.routeFailed(err)
+	}
+	metrics.routed(routed)
+
+	// 执行语句
+	result, err := db.executeShardedExec(ctx, stmt, routed.rewriteResults, metrics)
+	if err != nil {
+		return nil, metrics.finish(0, err)
+	}
+	return result, metrics.finish(result.rowsAffected, nil)
+}
+
+// parseStatement 在解析阶段的 span 中解析语句并提取涉及的逻辑表
+func (db *EnhancedShardingDB) parseStatement(ctx context.Context, metrics *statementMetrics, query string) (*parser.SQLStatement, []string, error) {
+	var stmt *parser.SQLStatement
+	logicTables, err := metrics.parse(ctx, func() ([]string, error) {
+		parsed, err := db.parserFactory.Parse(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SQL: %w", err)
+		}
+		stmt = parsed
+		return db.extractLogicTables(query), nil
+	})
+	return stmt, logicTables, err
+}
+
+// extractLogicTables 提取语句涉及的逻辑表
+func (db *EnhancedShardingDB) extractLogicTables(query string) []string {
 	configuredTables := make(map[string]bool)
 	if db.config.ShardingRule != nil {
 		for tableName := range db.config.ShardingRule.Tables {
@@ -169,30 +303,39 @@ func (db *EnhancedShardingDB) ExecContext(ctx context.Context, query string, arg
 		}
 	}
 
-	logicTables := db.rewriter.ExtractLogicTables(query, configuredTables)
+	return db.rewriter.ExtractLogicTables(query, configuredTables)
+}
+
+// routeStatement 路由并重写语句，不涉及分片表的语句路由到默认数据源
+func (db *EnhancedShardingDB) routeStatement(ctx context.Context, query string, args []interface{}, exec bool) (*routedStatement, error) {
+	logicTables := db.extractLogicTables(query)
 	if len(logicTables) == 0 {
-		// 没有分片表，直接执行
-		return db.executeNonShardedExec(ctx, query, args...)
+		if len(db.readWriteSplitters) > 0 {
+			return newDefaultRoutedStatement(query, args, defaultDataSourceName(db.readWriteSplitters)), nil
+		}
+		return newDefaultRoutedStatement(query, args, defaultDataSourceName(db.dataSources)), nil
+	}
+
+	// 提取分片值（简化实现，实际应该从 SQL 和参数中解析）
+	shardingValues := make(map[string]interface{})
+	for i, arg := range args {
+		shardingValues[fmt.Sprintf("param_%d", i)] = arg
 	}
 
-	// 路由计算
+	// 对每个逻辑表进行路由
+	routeCtx, routeSpan := startPhaseSpan(ctx, "sharding.route")
 	var routes []*routing.RouteResult
-	if len(logicTables) > 0 {
-		// 提取分片值（简化实现，实际应该从 SQL 和参数中解析）
-		shardingValues := make(map[string]interface{})
-		for i, arg := range args {
-			shardingValues[fmt.Sprintf("param_%d", i)] = arg
-		}
-		
-		// 对每个逻辑表进行路由
-		for _, table := range logicTables {
-			tableRoutes, err := db.router.Route(table, shardingValues)
-			if err != nil {
-				return nil, fmt.Errorf("failed to route query for table %s: %w", table, err)
-			}
-			routes = append(routes, tableRoutes...)
+	for _, table := range logicTables {
+		tableRoutes, err := db.router.RouteContext(routeCtx, table, shardingValues)
+		if err != nil {
+			err = fmt.Errorf("failed to route query for table %s: %w", table, err)
+			endSpan(routeSpan, err)
+			return nil, err
 		}
+		routes = append(routes, tableRoutes...)
 	}
+	routeSpan.SetAttributes(attrShardCount.Int(len(routes)))
+	routeSpan.End()
 
 	// SQL 重写
 	rewriteCtx := &rewrite.RewriteContext{
@@ -202,86 +345,201 @@ func (db *EnhancedShardingDB) ExecContext(ctx context.Context, query string, arg
 		Parameters:   args,
 	}
 
+	_, rewriteSpan := startPhaseSpan(ctx, "sharding.rewrite")
 	rewriteResults, err := db.rewriter.Rewrite(rewriteCtx)
+	endSpan(rewriteSpan, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
 	}
 
-	// 执行语句
-	return db.executeShardedExec(ctx, stmt, rewriteResults)
+	return &routedStatement{
+		logicTables:    logicTables,
+		rewriteContext: rewriteCtx,
+		rewriteResults: rewriteResults,
+	}, nil
+}
+
+// BeginTx 开始分片事务，opts 为 nil 时使用本地事务
+// 事务内的所有语句都在主库上执行
+func (db *EnhancedShardingDB) BeginTx(ctx context.Context, opts *TxOptions) (*ShardingTx, error) {
+	return newShardingTx(ctx, db, opts)
+}
+
+// Begin 开始本地分片事务
+func (db *EnhancedShardingDB) Begin() (*ShardingTx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// owner 获取所属的数据源，即数据库自身
+func (db *EnhancedShardingDB) owner() interface{} {
+	return db
+}
+
+// branchDB 获取分支事务使用的连接池，读写分离数据源返回主库
+func (db *EnhancedShardingDB) branchDB(dataSource string) (*sql.DB, error) {
+	if splitter, exists := db.readWriteSplitters[dataSource]; exists {
+		return splitter.GetMasterDB(), nil
+	}
+	if sqlDB, exists := db.dataSources[dataSource]; exists {
+		return sqlDB, nil
+	}
+	return nil, fmt.Errorf("data source %s not found", dataSource)
+}
+
+// databaseType 获取数据源的数据库类型，读写分离数据源取主库的类型
+func (db *EnhancedShardingDB) databaseType(dataSource string) database.DatabaseType {
+	if rwConfig, exists := db.config.ReadWriteSplits[dataSource]; exists {
+		dataSource = rwConfig.MasterDataSource
+	}
+	if dsConfig, exists := db.config.DataSources[dataSource]; exists {
+		return resolveDatabaseType(dsConfig.DriverName)
+	}
+	return database.MySQL
+}
+
+// undoLogs 获取 undo 日志管理器，undo log 按各数据源主库的数据库类型写入主库
+func (db *EnhancedShardingDB) undoLogs() *transaction.UndoLogManager {
+	db.undoLogOnce.Do(func() {
+		masters := make(map[string]*sql.DB, len(db.dataSources)+len(db.readWriteSplitters))
+		for name, sqlDB := range db.dataSources {
+			masters[name] = sqlDB
+		}
+		for name, splitter := range db.readWriteSplitters {
+			masters[name] = splitter.GetMasterDB()
+		}
+		db.undoLogManager = transaction.NewUndoLogManager(masters, database.MySQL)
+		for name := range masters {
+			db.undoLogManager.SetDatabaseType(name, db.databaseType(name))
+		}
+	})
+	return db.undoLogManager
+}
+
+// sqlRewriter 获取 SQL 重写器
+func (db *EnhancedShardingDB) sqlRewriter() *rewrite.SQLRewriter {
+	return db.rewriter
+}
+
+// newStatementMetrics 创建逻辑语句的指标记录和父 span
+func (db *EnhancedShardingDB) newStatementMetrics(ctx context.Context, operation, query string) (context.Context, *statementMetrics) {
+	return newStatementMetrics(ctx, tracerFrom(db.tracerProvider), db.metrics, db.dataNodeCounts, operation, query)
+}
+
+// Metrics 获取语句执行指标，可以通过 Metrics().Handler() 暴露给 Prometheus
+func (db *EnhancedShardingDB) Metrics() *monitoring.ShardingMetrics {
+	return db.metrics
+}
+
+// SetTracerProvider 设置创建语句 span 使用的 TracerProvider，需要在执行语句前设置
+// 未设置时使用 otel 的全局 TracerProvider
+func (db *EnhancedShardingDB) SetTracerProvider(provider trace.TracerProvider) {
+	db.tracerProvider = provider
+}
+
+// primaryKeys 获取逻辑表的主键列
+func (db *EnhancedShardingDB) primaryKeys(logicTable string) ([]string, error) {
+	if db.config.ShardingRule == nil {
+		return primaryKeysOf(nil, logicTable)
+	}
+	return primaryKeysOf(db.config.ShardingRule.Tables, logicTable)
 }
 
 // executeNonShardedQuery 执行非分片查询
-func (db *EnhancedShardingDB) executeNonShardedQuery(ctx context.Context, query string, args ...interface{}) (*EnhancedShardingRows, error) {
+func (db *EnhancedShardingDB) executeNonShardedQuery(ctx context.Context, metrics *statementMetrics, query string, args ...interface{}) (*EnhancedShardingRows, error) {
 	// 选择第一个数据源或使用读写分离
+	var targetName string
 	var targetDB *sql.DB
+	var readSplitter *readwrite.ReadWriteSplitter
 	
 	if len(db.readWriteSplitters) > 0 {
 		// 使用第一个读写分离器
-		for _, splitter := range db.readWriteSplitters {
+		for name, splitter := range db.readWriteSplitters {
+			targetName = name
 			targetDB = splitter.RouteContext(ctx, query)
+			readSplitter = splitter
 			break
 		}
 	} else {
 		// 使用第一个数据源
-		for _, sqlDB := range db.dataSources {
-			targetDB = sqlDB
+		for name, sqlDB := range db.dataSources {
+			targetName, targetDB = name, sqlDB
 			break
 		}
 	}
 
 	if targetDB == nil {
-		return nil, fmt.Errorf("no available data source")
+		return nil, metrics.finish(0, fmt.Errorf("no available data source"))
 	}
 
-	rows, err := targetDB.QueryContext(ctx, query, args...)
+	unitCtx, unit := metrics.startUnit(ctx, targetName, query)
+	start := time.Now()
+	rows, err := targetDB.QueryContext(unitCtx, query, args...)
+	if readSplitter != nil {
+		readSplitter.ObserveLatency(targetDB, time.Since(start), err)
+	}
+	unit.end(0, err)
 	if err != nil {
-		return nil, err
+		return nil, metrics.finish(0, err)
 	}
 
-	return &EnhancedShardingRows{rows: rows}, nil
+	return &EnhancedShardingRows{rows: rows, onClose: metrics.finishQuery(targetName)}, nil
 }
 
 // executeNonShardedExec 执行非分片语句
-func (db *EnhancedShardingDB) executeNonShardedExec(ctx context.Context, query string, args ...interface{}) (*EnhancedShardingResult, error) {
+func (db *EnhancedShardingDB) executeNonShardedExec(ctx context.Context, metrics *statementMetrics, query string, args ...interface{}) (*EnhancedShardingResult, error) {
 	// 选择第一个数据源或使用读写分离
+	var targetName string
 	var targetDB *sql.DB
+	var writeSplitter *readwrite.ReadWriteSplitter
 	
 	if len(db.readWriteSplitters) > 0 {
 		// 使用第一个读写分离器
-		for _, splitter := range db.readWriteSplitters {
-			targetDB = splitter.RouteContext(ctx, query)
+		for name, splitter := range db.readWriteSplitters {
+			targetName = name
+			targetDB = splitter.RouteWrite(ctx)
+			writeSplitter = splitter
 			break
 		}
 	} else {
 		// 使用第一个数据源
-		for _, sqlDB := range db.dataSources {
-			targetDB = sqlDB
+		for name, sqlDB := range db.dataSources {
+			targetName, targetDB = name, sqlDB
 			break
 		}
 	}
 
 	if targetDB == nil {
-		return nil, fmt.Errorf("no available data source")
+		return nil, metrics.finish(0, fmt.Errorf("no available data source"))
 	}
 
-	result, err := targetDB.ExecContext(ctx, query, args...)
+	unitCtx, unit := metrics.startUnit(ctx, targetName, query)
+	result, err := targetDB.ExecContext(unitCtx, query, args...)
 	if err != nil {
-		return nil, err
+		unit.end(0, err)
+		return nil, metrics.finish(0, err)
+	}
+	affected, _ := result.RowsAffected()
+	unit.end(affected, nil)
+	metrics.finish(affected, nil)
+
+	if writeSplitter != nil {
+		// 位点记录失败时会话在一致性窗口内继续读主库
+		writeSplitter.CaptureWritePosition(ctx)
 	}
 
 	return &EnhancedShardingResult{result: result}, nil
 }
 
 // executeShardedQuery 执行分片查询
-func (db *EnhancedShardingDB) executeShardedQuery(ctx context.Context, stmt *parser.SQLStatement, rewriteResults []*rewrite.RewriteResult) (*EnhancedShardingRows, error) {
+func (db *EnhancedShardingDB) executeShardedQuery(ctx context.Context, stmt *parser.SQLStatement, rewriteResults []*rewrite.RewriteResult, metrics *statementMetrics) (*EnhancedShardingRows, error) {
 	var allRows []*sql.Rows
 
 	for _, rewriteResult := range rewriteResults {
 		var targetDB *sql.DB
 
 		// 检查是否有对应的读写分离器
-		if splitter, exists := db.readWriteSplitters[rewriteResult.DataSource]; exists {
+		splitter, isSplitter := db.readWriteSplitters[rewriteResult.DataSource]
+		if isSplitter {
 			targetDB = splitter.RouteContext(ctx, rewriteResult.SQL)
 		} else {
 			// 直接使用数据源
@@ -292,7 +550,13 @@ func (db *EnhancedShardingDB) executeShardedQuery(ctx context.Context, stmt *par
 			return nil, fmt.Errorf("data source %s not found", rewriteResult.DataSource)
 		}
 
-		rows, err := targetDB.QueryContext(ctx, rewriteResult.SQL, rewriteResult.Parameters...)
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		start := time.Now()
+		rows, err := targetDB.QueryContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
+		if isSplitter {
+			splitter.ObserveLatency(targetDB, time.Since(start), err)
+		}
+		unit.end(0, err)
 		if err != nil {
 			// 关闭已打开的 rows
 			for _, r := range allRows {
@@ -304,24 +568,30 @@ func (db *EnhancedShardingDB) executeShardedQuery(ctx context.Context, stmt *par
 		allRows = append(allRows, rows)
 	}
 
-	return &EnhancedShardingRows{
-		rows:     allRows[0], // 简化实现，返回第一个结果
-		allRows:  allRows,
-		sqlType:  stmt.Type,
-	}, nil
+	var result *EnhancedShardingRows
+	metrics.merge(ctx, func() {
+		result = &EnhancedShardingRows{
+			rows:     allRows[0], // 简化实现，返回第一个结果
+			allRows:  allRows,
+			sqlType:  stmt.Type,
+		}
+	})
+	result.onClose = metrics.finishQuery(rewriteResults[0].DataSource)
+	return result, nil
 }
 
 // executeShardedExec 执行分片语句
-func (db *EnhancedShardingDB) executeShardedExec(ctx context.Context, stmt *parser.SQLStatement, rewriteResults []*rewrite.RewriteResult) (*EnhancedShardingResult, error) {
-	var totalRowsAffected int64
-	var lastInsertId int64
+func (db *EnhancedShardingDB) executeShardedExec(ctx context.Context, stmt *parser.SQLStatement, rewriteResults []*rewrite.RewriteResult, metrics *statementMetrics) (*EnhancedShardingResult, error) {
+	var results []sql.Result
 
 	for _, rewriteResult := range rewriteResults {
 		var targetDB *sql.DB
 
 		// 检查是否有对应的读写分离器
-		if splitter, exists := db.readWriteSplitters[rewriteResult.DataSource]; exists {
-			targetDB = splitter.RouteContext(ctx, rewriteResult.SQL)
+		splitter, isSplitter := db.readWriteSplitters[rewriteResult.DataSource]
+		if isSplitter {
+			// 写语句总是在读写分离组的主库上执行
+			targetDB = splitter.RouteWrite(ctx)
 		} else {
 			// 直接使用数据源
 			targetDB = db.dataSources[rewriteResult.DataSource]
@@ -331,23 +601,26 @@ func (db *EnhancedShardingDB) executeShardedExec(ctx context.Context, stmt *pars
 			return nil, fmt.Errorf("data source %s not found", rewriteResult.DataSource)
 		}
 
-		result, err := targetDB.ExecContext(ctx, rewriteResult.SQL, rewriteResult.Parameters...)
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		result, err := targetDB.ExecContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
 		if err != nil {
+			unit.end(0, err)
 			return nil, fmt.Errorf("failed to execute statement on %s: %w", rewriteResult.DataSource, err)
 		}
-
-		if rowsAffected, err := result.RowsAffected(); err == nil {
-			totalRowsAffected += rowsAffected
+		if isSplitter {
+			// 位点记录失败时会话在一致性窗口内继续读主库
+			splitter.CaptureWritePosition(ctx)
 		}
 
-		if insertId, err := result.LastInsertId(); err == nil && insertId > 0 {
-			lastInsertId = insertId
-		}
+		rowsAffected, _ := result.RowsAffected()
+		unit.end(rowsAffected, nil)
+		results = append(results, result)
 	}
 
+	merged := mergeExecResults(ctx, metrics, results)
 	return &EnhancedShardingResult{
-		rowsAffected: totalRowsAffected,
-		lastInsertId: lastInsertId,
+		rowsAffected: merged.affectedRows,
+		lastInsertId: merged.lastInsertID,
 	}, nil
 }
 
@@ -403,17 +676,20 @@ func (db *EnhancedShardingDB) HealthCheck() error {
 
 // EnhancedShardingRows 增强的分片查询结果
 type EnhancedShardingRows struct {
-	rows    *sql.Rows
-	allRows []*sql.Rows
-	sqlType parser.SQLType
+	rows     *sql.Rows
+	allRows  []*sql.Rows
+	sqlType  parser.SQLType
+	rowCount int64
+	onClose  func(rows int64) // 关闭时记录读取的行数
 }
 
 // Next 移动到下一行
 func (r *EnhancedShardingRows) Next() bool {
-	if r.rows == nil {
+	if r.rows == nil || !r.rows.Next() {
 		return false
 	}
-	return r.rows.Next()
+	r.rowCount++
+	return true
 }
 
 // Scan 扫描当前行数据
@@ -436,6 +712,11 @@ func (r *EnhancedShardingRows) Columns() ([]string, error) {
 func (r *EnhancedShardingRows) Close() error {
 	var errors []string
 
+	if r.onClose != nil {
+		r.onClose(r.rowCount)
+		r.onClose = nil
+	}
+
 	if r.rows != nil {
 		if err := r.rows.Close(); err != nil {
 			errors = append(errors, err.Error())
@@ -3,9 +3,12 @@ package sharding
 import (
 	"context"
 	"go-sharding/pkg/config"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewEnhancedShardingDB(t *testing.T) {
@@ -271,3 +274,171 @@ func BenchmarkEnhancedShardingDB_ExecContext(b *testing.B) {
 		_, _ = db.ExecContext(ctx, "SELECT 1")
 	}
 }
+
+// newReadWriteGroupConfig 创建以读写分离组 ds_0、ds_1 作为分片数据源的配置
+func newReadWriteGroupConfig(prefix, actualDataNodes string) *config.ShardingConfig {
+	dataSources := make(map[string]*config.DataSourceConfig)
+	for _, name := range []string{"ds_0_primary", "ds_0_replica", "ds_1_primary", "ds_1_replica"} {
+		dataSources[name] = &config.DataSourceConfig{DriverName: "recording", URL: prefix + name}
+	}
+
+	return &config.ShardingConfig{
+		DataSources: dataSources,
+		ReadWriteSplits: map[string]*config.ReadWriteSplitConfig{
+			"ds_0": {MasterDataSource: "ds_0_primary", SlaveDataSources: []string{"ds_0_replica"}},
+			"ds_1": {MasterDataSource: "ds_1_primary", SlaveDataSources: []string{"ds_1_replica"}},
+		},
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {ActualDataNodes: actualDataNodes},
+			},
+		},
+	}
+}
+
+func TestEnhancedShardingDB_ReadWriteGroups(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	db, err := NewEnhancedShardingDB(newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "UPDATE t_order SET status = 'PAID'")
+	require.NoError(t, err)
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM t_order")
+	require.NoError(t, err)
+	rows.Close()
+
+	for _, group := range []string{"ds_0", "ds_1"} {
+		assert.Equal(t, []string{"UPDATE t_order SET status = 'PAID'"}, recordingLogFor(prefix+group+"_primary").executed())
+		assert.Equal(t, []string{"SELECT * FROM t_order"}, recordingLogFor(prefix+group+"_replica").executed())
+	}
+	assert.Equal(t, "ds_0", db.config.ReadWriteSplits["ds_0"].Name)
+}
+
+func TestEnhancedShardingDB_ReadWriteGroupValidation(t *testing.T) {
+	tests := []struct {
+		name            string
+		actualDataNodes string
+		errorMsg        string
+	}{
+		{"group member", "ds_0_primary.t_order", "data source ds_0_primary referenced by table t_order belongs to read-write split ds_0"},
+		{"unknown data source", "ds_${0..2}.t_order", "data source ds_2 referenced by table t_order not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEnhancedShardingDB(newReadWriteGroupConfig("recording://"+t.Name()+"/", tt.actualDataNodes))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+
+	cfg := newReadWriteGroupConfig("recording://"+t.Name()+"/", "ds_0.t_order")
+	cfg.ReadWriteSplits["ds_0_replica"] = &config.ReadWriteSplitConfig{MasterDataSource: "ds_1_primary", SlaveDataSources: []string{"ds_1_replica"}}
+	_, err := NewEnhancedShardingDB(cfg)
+	assert.Error(t, err)
+}
+
+func TestEnhancedShardingDB_StopsBackgroundChecksOnInitFailure(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	cfg := newReadWriteGroupConfig("recording://"+t.Name()+"/", "ds_${0..1}.t_order")
+	for _, rwConfig := range cfg.ReadWriteSplits {
+		rwConfig.HealthCheck = &config.HealthCheckConfig{Enabled: true, Interval: time.Hour}
+		rwConfig.ReplicationLag = &config.ReplicationLagConfig{Enabled: true, Interval: time.Hour}
+	}
+	// 没有从库的读写分离组在其它组启动后台任务之后才可能创建失败
+	cfg.DataSources["ds_2_primary"] = &config.DataSourceConfig{DriverName: "recording", URL: "recording://" + t.Name() + "/ds_2_primary"}
+	cfg.ReadWriteSplits["ds_2"] = &config.ReadWriteSplitConfig{MasterDataSource: "ds_2_primary"}
+
+	_, err := NewEnhancedShardingDB(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one slave data source is required")
+	// 已启动的健康检查和延迟检测协程以及数据源连接都已释放
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestEnhancedShardingDB_Hint(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	cfg := newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order")
+	cfg.ShardingRule.Tables["t_order"].DatabaseStrategy = &config.ShardingStrategyConfig{Algorithm: "ds_${value % 2}", Type: "hint"}
+	db, err := NewEnhancedShardingDB(cfg)
+	require.NoError(t, err)
+	defer db.Close()
+
+	hint := NewHint().AddDatabaseShardingValue("t_order", 7)
+	rows, err := db.QueryContext(WithHint(context.Background(), hint), "SELECT * FROM t_order")
+	require.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, []string{"SELECT * FROM t_order"}, recordingLogFor(prefix+"ds_1_replica").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0_replica").executed())
+
+	// 仅主库路由时读请求在读写分离组的主库执行
+	rows, err = db.QueryContext(WithHint(context.Background(), hint.SetMasterRouteOnly()), "SELECT * FROM t_order")
+	require.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, []string{"SELECT * FROM t_order"}, recordingLogFor(prefix+"ds_1_primary").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0_primary").executed())
+}
+
+func TestEnhancedShardingDB_CommentHints(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	cfg := newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order_${0..3}")
+	cfg.ShardingRule.Tables["t_order"].DatabaseStrategy = &config.ShardingStrategyConfig{Algorithm: "ds_${value % 2}", Type: "hint"}
+	cfg.ShardingRule.Tables["t_order"].TableStrategy = &config.ShardingStrategyConfig{Algorithm: "t_order_${value % 4}", Type: "hint"}
+	db, err := NewEnhancedShardingDB(cfg)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := []string{
+		"/* SHARDING: ds=ds_1, table=t_order_3 */ SELECT * FROM t_order",
+		"SELECT * FROM t_order -- SHARDING: ds=2, table=t_order_1",
+		"# SHARDING: ds=ds_1, table=6, write_route_only=true\nSELECT * FROM t_order",
+	}
+	for _, query := range queries {
+		rows, err := db.QueryContext(ctx, query)
+		require.NoError(t, err)
+		rows.Close()
+	}
+	_, err = db.ExecContext(ctx, "UPDATE /*+ SHARDING: ds=ds_0, table=t_order_0 */ t_order SET status = 'PAID'")
+	require.NoError(t, err)
+
+	// 提示注释从发往数据源的 SQL 中去掉
+	assert.Equal(t, []string{"SELECT * FROM t_order_3"}, recordingLogFor(prefix+"ds_1_replica").executed())
+	assert.Equal(t, []string{"SELECT * FROM t_order_2"}, recordingLogFor(prefix+"ds_1_primary").executed())
+	assert.Equal(t, []string{"SELECT * FROM t_order_1"}, recordingLogFor(prefix+"ds_0_replica").executed())
+	assert.Equal(t, []string{"UPDATE  t_order_0 SET status = 'PAID'"}, recordingLogFor(prefix+"ds_0_primary").executed())
+}
+
+func TestEnhancedShardingDB_CommentHintsWithoutHintStrategy(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	db, err := NewEnhancedShardingDB(newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 注释提示与上下文提示合并，上下文要求的仅主库路由保留，原提示不被修改
+	hint := NewHint().SetMasterRouteOnly()
+	ctx := WithHint(context.Background(), hint)
+	rows, err := db.QueryContext(ctx, "/* SHARDING: ds=ds_1 */ SELECT * FROM t_order")
+	require.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, []string{"SELECT * FROM t_order"}, recordingLogFor(prefix+"ds_1_primary").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_1_replica").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0_primary").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0_replica").executed())
+	assert.Empty(t, hint.DatabaseRoutes("t_order"))
+
+	// 没有 hint 分片策略时数字提示值无法计算目标
+	_, err = db.QueryContext(context.Background(), "/* SHARDING: ds=1 */ SELECT * FROM t_order")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package sharding
+
+import (
+	"fmt"
+	"go-sharding/pkg/algorithm"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/id"
+)
+
+// geneKeyGeneratorType 在主键中嵌入分片列基因的主键生成器类型
+const geneKeyGeneratorType = "gene"
+
+// newGeneGenerator 按主键生成器配置创建基因雪花算法生成器
+func newGeneGenerator(factory *id.GeneratorFactory, cfg *config.KeyGeneratorConfig) (id.GeneAwareGenerator, error) {
+	generatorConfig := make(map[string]interface{})
+	for prop, key := range map[string]string{"worker-id": "workerID", "datacenter-id": "datacenterID", "gene-bits": "geneBits"} {
+		value, exists := cfg.Props[prop]
+		if !exists {
+			continue
+		}
+		intValue, err := algorithm.ConvertToInt(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", prop, err)
+		}
+		generatorConfig[key] = intValue
+	}
+
+	generator, err := factory.CreateGenerator(geneKeyGeneratorType, generatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	return generator.(id.GeneAwareGenerator), nil
+}
+
+// generateGeneKey 为单行 INSERT 语句生成嵌入基因的主键，并插入到主键列在列清单中的位置
+// 语句的列清单必须包含主键列和基因列，且除主键外每一列都对应一个占位符；已经传入主键时不再生成
+func generateGeneKey(generator id.GeneAwareGenerator, cfg *config.KeyGeneratorConfig, query string, args []interface{}) ([]interface{}, error) {
+	columns, keyIndex, missing, err := missingKeyPosition(cfg.Column, query, args)
+	if err != nil || !missing {
+		return args, err
+	}
+
+	geneColumn, _ := cfg.Props["gene-column"].(string)
+	geneIndex := indexOfColumn(columns, geneColumn)
+	if geneIndex < 0 {
+		return nil, fmt.Errorf("gene column %s not found in INSERT column list", geneColumn)
+	}
+	// 调用方没有传入主键，主键列之后的列对应的参数位置前移一位
+	if geneIndex > keyIndex {
+		geneIndex--
+	}
+
+	gene, err := algorithm.ConvertToInt(args[geneIndex])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value of gene column %s: %w", geneColumn, err)
+	}
+	generatedID, err := generator.NextIDWithGene(gene)
+	if err != nil {
+		return nil, err
+	}
+	return insertKeyArg(args, keyIndex, generatedID), nil
+}
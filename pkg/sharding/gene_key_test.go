@@ -0,0 +1,95 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/id"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingDB_GeneKeyGenerator(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	dataSources := make(map[string]*config.DataSourceConfig)
+	for _, name := range []string{"ds_0", "ds_1", "ds_2", "ds_3"} {
+		dataSources[name] = &config.DataSourceConfig{DriverName: "recording", URL: prefix + name}
+	}
+	ds, err := NewShardingDataSource(&config.ShardingConfig{
+		DataSources: dataSources,
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: "ds_${0..3}.t_order",
+					DatabaseStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn:           "user_id",
+						AlternateShardingColumns: []string{"order_id"},
+						Algorithm:                "GENE_MOD",
+						Type:                     "standard",
+						Props:                    map[string]interface{}{"sharding-count": 4, "gene-bits": 4},
+					},
+					KeyGenerator: &config.KeyGeneratorConfig{
+						Column: "order_id",
+						Type:   "gene",
+						Props:  map[string]interface{}{"gene-column": "user_id", "gene-bits": 4, "worker-id": 1},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer ds.Close()
+	db := ds.DB()
+	ctx := context.Background()
+
+	// 插入时生成的 order_id 嵌入 user_id 的基因，并插入到主键列的位置
+	routed, err := db.routeStatement(ctx, "INSERT INTO t_order (user_id, order_id, amount) VALUES (?, ?, ?)", []interface{}{7, 100}, true)
+	require.NoError(t, err)
+	args := routed.rewriteContext.Parameters
+	require.Len(t, args, 3)
+	assert.Equal(t, 7, args[0])
+	assert.Equal(t, 100, args[2])
+	orderID := args[1].(int64)
+	assert.Equal(t, int64(7), id.GeneOf(orderID, 4))
+	require.Len(t, routed.rewriteContext.RouteResults, 1)
+	assert.Equal(t, "ds_3", routed.rewriteContext.RouteResults[0].DataSource)
+
+	// 只按 order_id 查询时路由到同一个数据源
+	_, err = db.ExecContext(ctx, "UPDATE t_order SET status = 'PAID' WHERE order_id = ?", orderID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"UPDATE t_order SET status = 'PAID' WHERE order_id = ?"}, recordingLogFor(prefix+"ds_3").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0").executed())
+
+	// 缺少基因列时拒绝插入
+	_, err = db.ExecContext(ctx, "INSERT INTO t_order (order_id, amount) VALUES (?, ?)", 100)
+	assert.Error(t, err)
+}
+
+func TestNewShardingDataSource_InvalidGeneKeyGenerator(t *testing.T) {
+	_, err := NewShardingDataSource(&config.ShardingConfig{
+		DataSources: map[string]*config.DataSourceConfig{
+			"ds_0": {DriverName: "recording", URL: "recording://" + t.Name() + "/ds_0"},
+		},
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: "ds_0.t_order_${0..15}",
+					TableStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn: "user_id",
+						Algorithm:      "GENE_MOD",
+						Type:           "standard",
+						Props:          map[string]interface{}{"sharding-count": 16},
+					},
+					KeyGenerator: &config.KeyGeneratorConfig{
+						Column: "order_id",
+						Type:   "gene",
+						Props:  map[string]interface{}{"gene-column": "user_id", "gene-bits": 2},
+					},
+				},
+			},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gene bits")
+}
@@ -0,0 +1,63 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/parser"
+	"go-sharding/pkg/readwrite"
+	"go-sharding/pkg/routing"
+	"strconv"
+)
+
+// Hint 强制路由提示，按逻辑表指定数据库和表的分片值，并可以要求读请求路由到主库
+type Hint = routing.Hint
+
+// NewHint 创建强制路由提示
+func NewHint() *Hint {
+	return routing.NewHint()
+}
+
+// WithHint 在上下文中绑定强制路由提示，使用该上下文执行的语句按提示路由
+// 提示只作用于配置了 hint 分片策略的逻辑表，不同请求使用各自的上下文互不影响
+func WithHint(ctx context.Context, hint *Hint) context.Context {
+	return routing.WithHint(ctx, hint)
+}
+
+// HintFromContext 获取上下文中绑定的强制路由提示
+func HintFromContext(ctx context.Context) *Hint {
+	return routing.HintFromContext(ctx)
+}
+
+// withHintMasterRoute 强制路由提示要求仅主库路由时，使读请求路由到读写分离组的主库
+func withHintMasterRoute(ctx context.Context) context.Context {
+	if hint := routing.HintFromContext(ctx); hint != nil && hint.IsMasterRouteOnly() {
+		return readwrite.WithForceMaster(ctx)
+	}
+	return ctx
+}
+
+// withCommentHints 解析 SQL 中的路由提示注释，返回绑定了对应强制路由提示的上下文和去掉提示注释的 SQL
+// ds 和 table 作为语句中所有逻辑表的直接路由值，write_route_only=true 时读请求也路由到主库；
+// 注释提示合并到上下文中已绑定的强制路由提示的副本中，同一逻辑表上优先于上下文提示，没有提示注释时原样返回
+func withCommentHints(ctx context.Context, query string, extractLogicTables func(string) []string) (context.Context, string) {
+	hints, stripped := parser.ParseCommentHints(query)
+	if hints == nil {
+		return ctx, query
+	}
+
+	hint := routing.NewHint()
+	if existing := routing.HintFromContext(ctx); existing != nil {
+		hint = existing.Clone()
+	}
+	for _, table := range extractLogicTables(stripped) {
+		if value, exists := hints[parser.HintDataSource]; exists {
+			hint.AddDatabaseRoute(table, value)
+		}
+		if value, exists := hints[parser.HintTable]; exists {
+			hint.AddTableRoute(table, value)
+		}
+	}
+	if masterOnly, err := strconv.ParseBool(hints[parser.HintWriteRouteOnly]); err == nil && masterOnly {
+		hint.SetMasterRouteOnly()
+	}
+	return routing.WithHint(ctx, hint), stripped
+}
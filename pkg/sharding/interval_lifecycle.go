@@ -0,0 +1,282 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-sharding/pkg/algorithm"
+	"go-sharding/pkg/database"
+	"sync"
+	"time"
+)
+
+// 过期分片表的处理方式
+const (
+	// ExpiredTableKeep 保留过期分片表
+	ExpiredTableKeep = ""
+	// ExpiredTableDrop 删除过期分片表
+	ExpiredTableDrop = "drop"
+	// ExpiredTableArchive 将过期分片表重命名为归档表
+	ExpiredTableArchive = "archive"
+)
+
+const (
+	defaultLifecycleCheckInterval = time.Hour
+	defaultLifecycleCreateAhead   = 1
+	defaultArchivePrefix          = "archive_"
+)
+
+// IntervalTableLifecycleConfig 时间分片表生命周期配置
+type IntervalTableLifecycleConfig struct {
+	// TablePrefix 真实表名前缀，真实表名为前缀加分片后缀，如 t_order_
+	TablePrefix string
+	// TemplateTable 创建分片表时复制结构的模板表
+	TemplateTable string
+	// CreateAhead 除当前分片外提前创建的未来分片数量，默认为 1
+	CreateAhead int
+	// Retention 分片结束时间早于当前时间减去保留时长时视为过期，为 0 时不处理过期分片
+	Retention time.Duration
+	// ExpiredAction 过期分片表的处理方式：drop 删除，archive 重命名为 ArchivePrefix 加原表名，默认保留
+	ExpiredAction string
+	// ArchivePrefix 归档表名前缀，默认为 archive_
+	ArchivePrefix string
+	// Interval 检查间隔，默认为 1 小时
+	Interval time.Duration
+}
+
+// IntervalTableLifecycle 时间分片表生命周期任务
+// 定期按模板表在每个数据源上提前创建未来的分片表，并按保留时长删除或归档过期的分片表
+type IntervalTableLifecycle struct {
+	algorithm     *algorithm.IntervalShardingAlgorithm
+	dataSources   map[string]*sql.DB
+	databaseTypes map[string]database.DatabaseType
+	config        IntervalTableLifecycleConfig
+	now           func() time.Time
+	running       bool
+	stopCh        chan struct{}
+	doneCh        chan struct{}
+	mu            sync.Mutex
+	// expiredFrom 每个数据源上尚未处理的最早过期分片的起始时间，处理成功后前移，避免每次从时间下界重复处理
+	expiredFrom map[string]time.Time
+}
+
+// NewIntervalTableLifecycle 创建时间分片表生命周期任务，databaseTypes 中未指定的数据源按 MySQL 处理
+func NewIntervalTableLifecycle(intervalAlgorithm *algorithm.IntervalShardingAlgorithm, dataSources map[string]*sql.DB,
+	databaseTypes map[string]database.DatabaseType, cfg IntervalTableLifecycleConfig) (*IntervalTableLifecycle, error) {
+	if intervalAlgorithm == nil {
+		return nil, fmt.Errorf("interval sharding algorithm is required")
+	}
+	if cfg.TablePrefix == "" || cfg.TemplateTable == "" {
+		return nil, fmt.Errorf("table prefix and template table are required")
+	}
+	switch cfg.ExpiredAction {
+	case ExpiredTableKeep, ExpiredTableDrop, ExpiredTableArchive:
+	default:
+		return nil, fmt.Errorf("unsupported expired table action: %s", cfg.ExpiredAction)
+	}
+
+	if cfg.CreateAhead <= 0 {
+		cfg.CreateAhead = defaultLifecycleCreateAhead
+	}
+	if cfg.ArchivePrefix == "" {
+		cfg.ArchivePrefix = defaultArchivePrefix
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultLifecycleCheckInterval
+	}
+
+	return &IntervalTableLifecycle{
+		algorithm:     intervalAlgorithm,
+		dataSources:   dataSources,
+		databaseTypes: databaseTypes,
+		config:        cfg,
+		now:           time.Now,
+		expiredFrom:   make(map[string]time.Time),
+	}, nil
+}
+
+// TableName 获取时间所在分片的真实表名
+func (l *IntervalTableLifecycle) TableName(t time.Time) string {
+	return l.config.TablePrefix + l.algorithm.Suffix(t)
+}
+
+// UpcomingTables 获取当前分片及需要提前创建的未来分片的真实表名，不超过算法的时间上界
+func (l *IntervalTableLifecycle) UpcomingTables(now time.Time) []string {
+	var tables []string
+	start := l.algorithm.IntervalStart(now)
+	if start.Before(l.algorithm.Lower()) {
+		start = l.algorithm.IntervalStart(l.algorithm.Lower())
+	}
+	for i := 0; i <= l.config.CreateAhead; i++ {
+		if upper := l.algorithm.Upper(); !upper.IsZero() && start.After(upper) {
+			break
+		}
+		tables = append(tables, l.TableName(start))
+		start = l.algorithm.NextIntervalStart(start)
+	}
+	return tables
+}
+
+// ExpiredTables 获取结束时间早于保留窗口的所有分片的真实表名，未配置保留时长时返回空
+func (l *IntervalTableLifecycle) ExpiredTables(now time.Time) []string {
+	var tables []string
+	for _, start := range l.expiredIntervals(l.algorithm.IntervalStart(l.algorithm.Lower()), now) {
+		tables = append(tables, l.TableName(start))
+	}
+	return tables
+}
+
+// expiredIntervals 获取从 from 开始、结束时间早于保留窗口的分片的起始时间，未配置保留时长时返回空
+func (l *IntervalTableLifecycle) expiredIntervals(from, now time.Time) []time.Time {
+	if l.config.Retention <= 0 {
+		return nil
+	}
+
+	var starts []time.Time
+	cutoff := now.Add(-l.config.Retention)
+	for start := from; !l.algorithm.NextIntervalStart(start).After(cutoff); start = l.algorithm.NextIntervalStart(start) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// Start 启动后台生命周期任务，启动时立即执行一次
+func (l *IntervalTableLifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running {
+		return fmt.Errorf("lifecycle of %s tables is already running", l.config.TablePrefix)
+	}
+
+	l.running = true
+	l.stopCh = make(chan struct{})
+	l.doneCh = make(chan struct{})
+	go l.run(ctx, l.stopCh, l.doneCh)
+	return nil
+}
+
+// Stop 停止后台生命周期任务并等待其退出
+func (l *IntervalTableLifecycle) Stop() error {
+	l.mu.Lock()
+	if !l.running {
+		l.mu.Unlock()
+		return nil
+	}
+	l.running = false
+	close(l.stopCh)
+	doneCh := l.doneCh
+	l.mu.Unlock()
+
+	<-doneCh
+	return nil
+}
+
+// run 定期执行生命周期任务，单次执行失败时等待下一次检查重试
+func (l *IntervalTableLifecycle) run(ctx context.Context, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(l.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		_ = l.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 在每个数据源上创建未来的分片表并处理过期的分片表，返回所有数据源上的错误
+// 过期分片只处理上次成功处理之后新过期的部分，某张表处理失败时下次从该表开始重试
+func (l *IntervalTableLifecycle) RunOnce(ctx context.Context) error {
+	now := l.now()
+	upcoming := l.UpcomingTables(now)
+
+	var errs []error
+	for name, db := range l.dataSources {
+		dbType := l.databaseTypes[name]
+		for _, table := range upcoming {
+			if _, err := db.ExecContext(ctx, createTableLikeSQL(dbType, table, l.config.TemplateTable)); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create table %s on %s: %w", table, name, err))
+			}
+		}
+		if l.config.ExpiredAction == ExpiredTableKeep {
+			continue
+		}
+		for _, start := range l.expiredIntervals(l.expiredStart(name), now) {
+			table := l.TableName(start)
+			if err := l.expireTable(ctx, db, dbType, table); err != nil {
+				errs = append(errs, fmt.Errorf("failed to %s table %s on %s: %w", l.config.ExpiredAction, table, name, err))
+				break
+			}
+			l.setExpiredStart(name, l.algorithm.NextIntervalStart(start))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expiredStart 获取数据源上尚未处理的最早过期分片的起始时间，从未处理过时为算法时间下界所在的分片
+func (l *IntervalTableLifecycle) expiredStart(dataSource string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if start, exists := l.expiredFrom[dataSource]; exists {
+		return start
+	}
+	return l.algorithm.IntervalStart(l.algorithm.Lower())
+}
+
+// setExpiredStart 记录数据源上过期分片已处理到的位置
+func (l *IntervalTableLifecycle) setExpiredStart(dataSource string, start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.expiredFrom[dataSource] = start
+}
+
+// expireTable 按配置删除或归档过期的分片表
+func (l *IntervalTableLifecycle) expireTable(ctx context.Context, db *sql.DB, dbType database.DatabaseType, table string) error {
+	switch l.config.ExpiredAction {
+	case ExpiredTableDrop:
+		_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS "+table)
+		return err
+	case ExpiredTableArchive:
+		// 表已归档或从未创建时跳过
+		exists, err := tableExists(ctx, db, dbType, table)
+		if err != nil || !exists {
+			return err
+		}
+		_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s", table, l.config.ArchivePrefix+table))
+		return err
+	}
+	return nil
+}
+
+// createTableLikeSQL 生成按模板表创建分片表的语句
+func createTableLikeSQL(dbType database.DatabaseType, table, template string) string {
+	if dbType == database.PostgreSQL {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", table, template)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", table, template)
+}
+
+// tableExists 判断当前库或 schema 中是否存在指定表
+func tableExists(ctx context.Context, db *sql.DB, dbType database.DatabaseType, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	if dbType == database.PostgreSQL {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
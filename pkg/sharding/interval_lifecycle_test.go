@@ -0,0 +1,135 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"go-sharding/pkg/algorithm"
+	"go-sharding/pkg/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMonthlyLifecycle 创建 2024 年起按月分片、当前时间为 2024-04-15 的生命周期任务
+func newMonthlyLifecycle(t *testing.T, driverName string, cfg IntervalTableLifecycleConfig) (*IntervalTableLifecycle, *recordingLog) {
+	shardingAlgorithm, err := algorithm.NewIntervalShardingAlgorithm(map[string]interface{}{
+		"datetime-lower":          "2024-01-01 00:00:00",
+		"datetime-upper":          "2024-06-30 23:59:59",
+		"datetime-interval-unit":  "MONTHS",
+		"sharding-suffix-pattern": "200601",
+	})
+	require.NoError(t, err)
+
+	dsn := driverName + "://" + t.Name() + "/ds_0"
+	db, err := sql.Open(driverName, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	dbType, err := database.GlobalDatabaseTypeRegistry.GetDatabaseType(driverName)
+	require.NoError(t, err)
+	lifecycle, err := NewIntervalTableLifecycle(shardingAlgorithm.(*algorithm.IntervalShardingAlgorithm),
+		map[string]*sql.DB{"ds_0": db}, map[string]database.DatabaseType{"ds_0": dbType}, cfg)
+	require.NoError(t, err)
+	lifecycle.now = func() time.Time { return time.Date(2024, 4, 15, 0, 0, 0, 0, time.Local) }
+	return lifecycle, recordingLogFor(dsn)
+}
+
+func TestIntervalTableLifecycle_RunOnce(t *testing.T) {
+	lifecycle, log := newMonthlyLifecycle(t, "recording", IntervalTableLifecycleConfig{
+		TablePrefix:   "t_order_",
+		TemplateTable: "t_order_template",
+		CreateAhead:   3,
+		Retention:     30 * 24 * time.Hour,
+		ExpiredAction: ExpiredTableDrop,
+	})
+
+	require.NoError(t, lifecycle.RunOnce(context.Background()))
+	assert.Equal(t, []string{
+		// 不超过算法的时间上界
+		"CREATE TABLE IF NOT EXISTS t_order_202404 LIKE t_order_template",
+		"CREATE TABLE IF NOT EXISTS t_order_202405 LIKE t_order_template",
+		"CREATE TABLE IF NOT EXISTS t_order_202406 LIKE t_order_template",
+		// 3 月 16 日之前结束的分片已过期
+		"DROP TABLE IF EXISTS t_order_202401",
+		"DROP TABLE IF EXISTS t_order_202402",
+	}, log.executed())
+}
+
+func TestIntervalTableLifecycle_ExpiresOnlyNewIntervals(t *testing.T) {
+	lifecycle, log := newMonthlyLifecycle(t, "recording", IntervalTableLifecycleConfig{
+		TablePrefix:   "t_order_",
+		TemplateTable: "t_order_template",
+		CreateAhead:   1,
+		Retention:     30 * 24 * time.Hour,
+		ExpiredAction: ExpiredTableDrop,
+	})
+	dropped := func() []string {
+		var statements []string
+		for _, statement := range log.executed() {
+			if strings.HasPrefix(statement, "DROP") {
+				statements = append(statements, statement)
+			}
+		}
+		return statements
+	}
+
+	// 第二张表删除失败时停在该表，下次从该表重试
+	log.mu.Lock()
+	log.failOn = "DROP TABLE IF EXISTS t_order_202402"
+	log.mu.Unlock()
+	require.Error(t, lifecycle.RunOnce(context.Background()))
+	require.NoError(t, lifecycle.RunOnce(context.Background()))
+	assert.Equal(t, []string{
+		"DROP TABLE IF EXISTS t_order_202401",
+		"DROP TABLE IF EXISTS t_order_202402",
+		"DROP TABLE IF EXISTS t_order_202402",
+	}, dropped())
+
+	// 没有新过期的分片时不再执行 DROP
+	require.NoError(t, lifecycle.RunOnce(context.Background()))
+	assert.Len(t, dropped(), 3)
+
+	// 时间推进后只处理新过期的分片
+	lifecycle.now = func() time.Time { return time.Date(2024, 5, 15, 0, 0, 0, 0, time.Local) }
+	require.NoError(t, lifecycle.RunOnce(context.Background()))
+	assert.Equal(t, "DROP TABLE IF EXISTS t_order_202403", dropped()[3])
+	assert.Len(t, dropped(), 4)
+}
+
+func TestIntervalTableLifecycle_Archive(t *testing.T) {
+	lifecycle, log := newMonthlyLifecycle(t, "recording-postgres", IntervalTableLifecycleConfig{
+		TablePrefix:   "t_log_",
+		TemplateTable: "t_log_template",
+		Retention:     60 * 24 * time.Hour,
+		ExpiredAction: ExpiredTableArchive,
+	})
+
+	require.NoError(t, lifecycle.RunOnce(context.Background()))
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS t_log_202404 (LIKE t_log_template INCLUDING ALL)",
+		"CREATE TABLE IF NOT EXISTS t_log_202405 (LIKE t_log_template INCLUDING ALL)",
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1",
+		"ALTER TABLE t_log_202401 RENAME TO archive_t_log_202401",
+	}, log.executed())
+}
+
+func TestIntervalTableLifecycle_StartStop(t *testing.T) {
+	lifecycle, log := newMonthlyLifecycle(t, "recording", IntervalTableLifecycleConfig{
+		TablePrefix:   "t_order_",
+		TemplateTable: "t_order_template",
+	})
+
+	require.NoError(t, lifecycle.Start(context.Background()))
+	assert.Error(t, lifecycle.Start(context.Background()))
+	assert.Eventually(t, func() bool { return len(log.executed()) == 2 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, lifecycle.Stop())
+	require.NoError(t, lifecycle.Stop())
+
+	_, err := NewIntervalTableLifecycle(lifecycle.algorithm, nil, nil, IntervalTableLifecycleConfig{
+		TablePrefix: "t_order_", TemplateTable: "t_order_template", ExpiredAction: "truncate",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package sharding
+
+import (
+	"fmt"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/id"
+	"go-sharding/pkg/parser"
+	"go-sharding/pkg/rewrite"
+	"strings"
+)
+
+// newKeyGenerators 为配置了 segment 或字符串主键生成器（uuid、uuidv7、ulid、ksuid）的逻辑表创建生成器
+// 字符串主键生成器的 format 属性为 binary 时生成二进制主键
+func (ds *ShardingDataSource) newKeyGenerators(factory *id.GeneratorFactory) (map[string]id.KeyGenerator, error) {
+	generators := make(map[string]id.KeyGenerator)
+	sharedSegments := make(map[string]id.KeyGenerator)
+	for logicTable, tableRule := range ds.configuredTables {
+		cfg := tableRule.KeyGenerator
+		if cfg == nil {
+			continue
+		}
+
+		var generator id.KeyGenerator
+		var err error
+		switch {
+		case cfg.Type == segmentKeyGeneratorType:
+			generator, err = ds.newSegmentGenerator(factory, logicTable, cfg, sharedSegments)
+		case id.IsStringKeyGeneratorType(cfg.Type):
+			generator, err = factory.CreateKeyGenerator(cfg.Type, map[string]interface{}{"format": cfg.Props["format"]})
+			if err != nil {
+				err = fmt.Errorf("failed to create key generator of table %s: %w", logicTable, err)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		generators[logicTable] = generator
+	}
+	return generators, nil
+}
+
+// generateKey 为单行 INSERT 语句生成主键，并插入到主键列在列清单中的位置，已经传入主键时不再生成
+func generateKey(generator id.KeyGenerator, cfg *config.KeyGeneratorConfig, query string, args []interface{}) ([]interface{}, error) {
+	_, keyIndex, missing, err := missingKeyPosition(cfg.Column, query, args)
+	if err != nil || !missing {
+		return args, err
+	}
+
+	key, err := generator.NextKey()
+	if err != nil {
+		return nil, err
+	}
+	return insertKeyArg(args, keyIndex, key), nil
+}
+
+// missingKeyPosition 检查单行 INSERT 语句是否需要生成主键，返回列清单和主键列位置
+// 语句的列清单必须包含主键列，且除主键外每一列都对应一个占位符；已经传入主键时 missing 为 false
+func missingKeyPosition(keyColumn, query string, args []interface{}) (columns []string, keyIndex int, missing bool, err error) {
+	columns = insertColumns(query)
+	keyIndex = indexOfColumn(columns, keyColumn)
+	if keyIndex < 0 {
+		return nil, -1, false, fmt.Errorf("key column %s not found in INSERT column list", keyColumn)
+	}
+	if count, err := rewrite.NewSQLRewriter().CountPlaceholders(query); err != nil || count != len(columns) {
+		return nil, -1, false, fmt.Errorf("key generation requires a single-row INSERT with a placeholder for every column")
+	}
+	if len(args) == len(columns) {
+		return columns, keyIndex, false, nil
+	}
+	if len(args) != len(columns)-1 {
+		return nil, -1, false, fmt.Errorf("INSERT has %d columns but %d arguments", len(columns), len(args))
+	}
+	return columns, keyIndex, true, nil
+}
+
+// insertKeyArg 将生成的主键插入到参数列表的主键列位置
+func insertKeyArg(args []interface{}, keyIndex int, key interface{}) []interface{} {
+	newArgs := make([]interface{}, 0, len(args)+1)
+	newArgs = append(newArgs, args[:keyIndex]...)
+	newArgs = append(newArgs, key)
+	return append(newArgs, args[keyIndex:]...)
+}
+
+// insertPlaceholderColumns 获取 INSERT 语句的列清单，只有每一列都对应一个占位符时才返回
+func insertPlaceholderColumns(query string, argCount int) []string {
+	if statementKeyword(query) != "INSERT" {
+		return nil
+	}
+	columns := insertColumns(query)
+	if len(columns) == 0 || len(columns) != argCount {
+		return nil
+	}
+	if count, err := rewrite.NewSQLRewriter().CountPlaceholders(query); err != nil || count != argCount {
+		return nil
+	}
+	return columns
+}
+
+// insertColumns 获取 INSERT 语句的列清单
+func insertColumns(query string) []string {
+	stmt, err := parser.NewSQLParser().Parse(query)
+	if err != nil {
+		return nil
+	}
+	return stmt.Columns
+}
+
+// indexOfColumn 获取列在列清单中的位置，不区分大小写
+func indexOfColumn(columns []string, column string) int {
+	for i, c := range columns {
+		if column != "" && strings.EqualFold(c, column) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,141 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/algorithm"
+	"go-sharding/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingDB_StringKeyGenerator(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	dataSources := make(map[string]*config.DataSourceConfig)
+	for _, name := range []string{"ds_0", "ds_1", "ds_2", "ds_3"} {
+		dataSources[name] = &config.DataSourceConfig{DriverName: "recording", URL: prefix + name}
+	}
+	ds, err := NewShardingDataSource(&config.ShardingConfig{
+		DataSources: dataSources,
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: "ds_${0..3}.t_order",
+					DatabaseStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn: "order_id",
+						ColumnType:     "UUID",
+						Algorithm:      "HASH_MOD",
+						Type:           "standard",
+						Props:          map[string]interface{}{"sharding-count": 4},
+					},
+					KeyGenerator: &config.KeyGeneratorConfig{
+						Column: "order_id",
+						Type:   "uuidv7",
+						Props:  map[string]interface{}{"format": "binary"},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer ds.Close()
+	db := ds.DB()
+	ctx := context.Background()
+
+	// 生成的二进制 UUID 插入到主键列的位置
+	routed, err := db.routeStatement(ctx, "INSERT INTO t_order (order_id, user_id) VALUES (?, ?)", []interface{}{7}, true)
+	require.NoError(t, err)
+	args := routed.rewriteContext.Parameters
+	require.Len(t, args, 2)
+	require.IsType(t, []byte{}, args[0])
+	assert.Len(t, args[0], 16)
+	assert.Equal(t, 7, args[1])
+	require.Len(t, routed.rewriteContext.RouteResults, 1)
+	inserted := routed.rewriteContext.RouteResults[0].DataSource
+
+	// 按 UUID 列类型转换后，文本形式的主键路由到插入时的数据源
+	key, err := algorithm.ConvertToUUID(args[0])
+	require.NoError(t, err)
+	routed, err = db.routeStatement(ctx, "SELECT * FROM t_order WHERE order_id = ?", []interface{}{key.String()}, false)
+	require.NoError(t, err)
+	require.Len(t, routed.rewriteContext.RouteResults, 1)
+	assert.Equal(t, inserted, routed.rewriteContext.RouteResults[0].DataSource)
+}
+
+func TestNewShardingDataSource_InvalidStringKeyGenerator(t *testing.T) {
+	tests := []struct {
+		name           string
+		shardingColumn string
+		keyConfig      *config.KeyGeneratorConfig
+		errorMsg       string
+	}{
+		{
+			name:           "unsupported format",
+			shardingColumn: "user_id",
+			keyConfig:      &config.KeyGeneratorConfig{Column: "order_id", Type: "ulid", Props: map[string]interface{}{"format": "hex"}},
+			errorMsg:       "unsupported key format",
+		},
+		{
+			name:           "integer sharding column",
+			shardingColumn: "order_id",
+			keyConfig:      &config.KeyGeneratorConfig{Column: "order_id", Type: "ksuid"},
+			errorMsg:       "produces string keys",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewShardingDataSource(&config.ShardingConfig{
+				DataSources: map[string]*config.DataSourceConfig{
+					"ds_0": {DriverName: "recording", URL: "recording://" + t.Name() + "/ds_0"},
+				},
+				ShardingRule: &config.ShardingRuleConfig{
+					Tables: map[string]*config.TableRuleConfig{
+						"t_order": {
+							ActualDataNodes: "ds_0.t_order_${0..1}",
+							TableStrategy: &config.ShardingStrategyConfig{
+								ShardingColumn: tt.shardingColumn,
+								ColumnType:     "BIGINT",
+								Algorithm:      "MOD",
+								Type:           "standard",
+								Props:          map[string]interface{}{"sharding-count": 2},
+							},
+							KeyGenerator: tt.keyConfig,
+						},
+					},
+				},
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestMissingKeyPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		keyIndex int
+		missing  bool
+	}{
+		{"question marks", "INSERT INTO t_order (order_id, user_id) VALUES (?, ?)", []interface{}{7}, 0, true},
+		{"numbered", "INSERT INTO t_order (user_id, order_id, remark) VALUES ($1, $2, $3)", []interface{}{7, "a"}, 1, true},
+		{"key provided", "INSERT INTO t_order (order_id, user_id) VALUES ($1, $2)", []interface{}{1, 7}, 0, false},
+		{"question mark in literal", "INSERT INTO t_order (order_id, user_id, remark) VALUES (?, ?, 'why?')", []interface{}{7}, -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, keyIndex, missing, err := missingKeyPosition("order_id", tt.query, tt.args)
+			if tt.keyIndex < 0 {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.keyIndex, keyIndex)
+			assert.Equal(t, tt.missing, missing)
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"go-sharding/pkg/parser"
 	"go-sharding/pkg/routing"
 	"go-sharding/pkg/rewrite"
+	"go-sharding/pkg/transaction"
 	"strings"
 	
 	_ "github.com/lib/pq" // PostgreSQL 驱动
@@ -71,6 +72,9 @@ func (ds *PostgreSQLShardingDataSource) DB() *PostgreSQLDB {
 
 // QueryContext 执行 PostgreSQL 查询（带上下文）
 func (db *PostgreSQLDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*ShardingRows, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
 	// 验证 PostgreSQL SQL 语法
 	if err := db.pgDataSource.pgParser.ValidatePostgreSQLSQL(query); err != nil {
 		return nil, fmt.Errorf("invalid PostgreSQL SQL: %w", err)
@@ -96,6 +100,9 @@ func (db *PostgreSQLDB) Query(query string, args ...interface{}) (*ShardingRows,
 
 // ExecContext 执行 PostgreSQL 命令（带上下文）
 func (db *PostgreSQLDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, db.extractLogicTables)
+
 	// 验证 PostgreSQL SQL 语法
 	if err := db.pgDataSource.pgParser.ValidatePostgreSQLSQL(query); err != nil {
 		return nil, fmt.Errorf("invalid PostgreSQL SQL: %w", err)
@@ -104,6 +111,15 @@ func (db *PostgreSQLDB) ExecContext(ctx context.Context, query string, args ...i
 	// 转换参数占位符
 	pgQuery, pgArgs := db.convertToPostgreSQLParams(query, args)
 	
+	// 上下文中绑定了事务时在事务中执行
+	if tx := ambientShardingTx(ctx, db.ShardingDB); tx != nil {
+		result, err := tx.ExecContext(ctx, pgQuery, pgArgs...)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	
 	// 提取逻辑表名
 	logicTables := db.extractLogicTables(pgQuery)
 	if len(logicTables) == 0 {
@@ -117,7 +133,7 @@ func (db *PostgreSQLDB) ExecContext(ctx context.Context, query string, args ...i
 	// 路由计算
 	var allRouteResults []*routing.RouteResult
 	for _, logicTable := range logicTables {
-		routeResults, err := db.pgDataSource.router.Route(logicTable, shardingValues)
+		routeResults, err := db.pgDataSource.router.RouteContext(ctx, logicTable, shardingValues)
 		if err != nil {
 			return nil, fmt.Errorf("routing failed for table %s: %w", logicTable, err)
 		}
@@ -228,28 +244,26 @@ func (db *PostgreSQLDB) executeOnFirstDataSource(ctx context.Context, query stri
 	return firstDB.ExecContext(ctx, query, args...)
 }
 
-// BeginTx 开始 PostgreSQL 事务
+// BeginTx 开始 PostgreSQL 分片本地事务
 func (db *PostgreSQLDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*PostgreSQLTx, error) {
-	// 对于分片环境，这里简化处理，只在第一个数据源开始事务
-	// 实际生产环境中需要实现分布式事务
-	var firstDB *sql.DB
-	for _, conn := range db.pgDataSource.dataSources {
-		firstDB = conn
-		break
+	txOptions := &TxOptions{Type: transaction.LocalTransaction}
+	if opts != nil {
+		txOptions.Isolation = opts.Isolation
+		txOptions.ReadOnly = opts.ReadOnly
 	}
-	
-	if firstDB == nil {
-		return nil, fmt.Errorf("no database connection available")
-	}
-	
-	tx, err := firstDB.BeginTx(ctx, opts)
+	return db.BeginShardingTx(ctx, txOptions)
+}
+
+// BeginShardingTx 开始指定类型的 PostgreSQL 分片事务
+func (db *PostgreSQLDB) BeginShardingTx(ctx context.Context, opts *TxOptions) (*PostgreSQLTx, error) {
+	tx, err := db.ShardingDB.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	
+
 	return &PostgreSQLTx{
-		Tx:     tx,
-		pgDB:   db,
+		shardingTx: tx,
+		pgDB:       db,
 	}, nil
 }
 
@@ -258,14 +272,14 @@ func (db *PostgreSQLDB) Begin() (*PostgreSQLTx, error) {
 	return db.BeginTx(context.Background(), nil)
 }
 
-// PostgreSQLTx PostgreSQL 事务
+// PostgreSQLTx PostgreSQL 事务，语句经过路由和重写后在对应数据源的分支事务中执行
 type PostgreSQLTx struct {
-	*sql.Tx
-	pgDB *PostgreSQLDB
+	shardingTx *ShardingTx
+	pgDB       *PostgreSQLDB
 }
 
-// QueryContext 在事务中执行查询
-func (tx *PostgreSQLTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+// QueryContext 在事务中执行查询，关闭返回的结果集时记录读取的行数；没有路由到任何数据源时返回错误
+func (tx *PostgreSQLTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*ShardingRows, error) {
 	// 验证 PostgreSQL SQL 语法
 	if err := tx.pgDB.pgDataSource.pgParser.ValidatePostgreSQLSQL(query); err != nil {
 		return nil, fmt.Errorf("invalid PostgreSQL SQL: %w", err)
@@ -274,11 +288,18 @@ func (tx *PostgreSQLTx) QueryContext(ctx context.Context, query string, args ...
 	// 转换参数占位符
 	pgQuery, pgArgs := tx.pgDB.convertToPostgreSQLParams(query, args)
 	
-	return tx.Tx.QueryContext(ctx, pgQuery, pgArgs...)
+	rows, err := tx.shardingTx.QueryContext(ctx, pgQuery, pgArgs...)
+	if err != nil {
+		return nil, err
+	}
+	if rows.rows == nil {
+		return nil, fmt.Errorf("query was not routed to any data source: %s", query)
+	}
+	return rows, nil
 }
 
 // Query 在事务中执行查询
-func (tx *PostgreSQLTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+func (tx *PostgreSQLTx) Query(query string, args ...interface{}) (*ShardingRows, error) {
 	return tx.QueryContext(context.Background(), query, args...)
 }
 
@@ -292,7 +313,12 @@ func (tx *PostgreSQLTx) ExecContext(ctx context.Context, query string, args ...i
 	// 转换参数占位符
 	pgQuery, pgArgs := tx.pgDB.convertToPostgreSQLParams(query, args)
 	
-	return tx.Tx.ExecContext(ctx, pgQuery, pgArgs...)
+	result, err := tx.shardingTx.ExecContext(ctx, pgQuery, pgArgs...)
+	if err != nil {
+		// 避免将类型为 *ShardingResult 的 nil 包装为非 nil 的 sql.Result
+		return nil, err
+	}
+	return result, nil
 }
 
 // Exec 在事务中执行命令
@@ -300,6 +326,21 @@ func (tx *PostgreSQLTx) Exec(query string, args ...interface{}) (sql.Result, err
 	return tx.ExecContext(context.Background(), query, args...)
 }
 
+// Commit 提交事务
+func (tx *PostgreSQLTx) Commit() error {
+	return tx.shardingTx.Commit(context.Background())
+}
+
+// Rollback 回滚事务
+func (tx *PostgreSQLTx) Rollback() error {
+	return tx.shardingTx.Rollback(context.Background())
+}
+
+// ShardingTx 获取底层的分片事务
+func (tx *PostgreSQLTx) ShardingTx() *ShardingTx {
+	return tx.shardingTx
+}
+
 // GetPostgreSQLDialect 获取 PostgreSQL 方言
 func (ds *PostgreSQLShardingDataSource) GetPostgreSQLDialect() database.DatabaseDialect {
 	return ds.dialect
@@ -497,4 +497,35 @@ func BenchmarkPostgreSQLDB_convertToPostgreSQLParams(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, _ = db.convertToPostgreSQLParams(query, args)
 	}
-}
\ No newline at end of file
+}
+
+func TestPostgreSQLTx(t *testing.T) {
+	prefix := "recording-postgres://" + t.Name() + "/"
+	pgDS, err := NewPostgreSQLShardingDataSource(newRecordingShardingConfig(prefix, "recording-postgres"))
+	require.NoError(t, err)
+	defer pgDS.Close()
+	db := pgDS.DB()
+	ds1 := recordingLogFor(prefix + "ds_1")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// 查询返回分片结果集，关闭时记录读取的行数
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM t_order WHERE user_id = ? AND order_id = ?", 1, 3)
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(1), sumMetric(db.dataSource.Metrics(), "sharding_statement_rows_total", map[string]string{"statement_type": "select"}))
+
+	// 执行失败时返回值为 nil 接口
+	ds1.mu.Lock()
+	ds1.failOn = "UPDATE"
+	ds1.mu.Unlock()
+	result, err := tx.ExecContext(ctx, "UPDATE t_order SET status = 'PAID' WHERE user_id = ? AND order_id = ?", 1, 3)
+	require.Error(t, err)
+	assert.True(t, result == nil)
+
+	require.NoError(t, tx.Rollback())
+}
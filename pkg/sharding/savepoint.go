@@ -0,0 +1,204 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-sharding/pkg/transaction"
+	"regexp"
+	"strings"
+)
+
+// savepointAction 保存点语句类型
+type savepointAction int
+
+const (
+	// savepointCreate SAVEPOINT name
+	savepointCreate savepointAction = iota
+	// savepointRollback ROLLBACK TO [SAVEPOINT] name
+	savepointRollback
+	// savepointRelease RELEASE [SAVEPOINT] name
+	savepointRelease
+)
+
+var (
+	// savepointNamePattern 合法的保存点名称
+	savepointNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// savepointStatementPattern 保存点语句，MySQL 与 PostgreSQL 语法一致
+	savepointStatementPattern = regexp.MustCompile(`(?i)^\s*(?:(SAVEPOINT)|(ROLLBACK)(?:\s+WORK)?\s+TO|(RELEASE))(?:\s+SAVEPOINT)?\s+([A-Za-z_][A-Za-z0-9_]*)\s*;?\s*$`)
+)
+
+// txSavepoint 分片事务的保存点
+type txSavepoint struct {
+	name string
+	// branches 创建保存点时已开启的分支数，之后开启的分支在回滚到该保存点时会被关闭
+	branches int
+}
+
+// parseSavepointStatement 解析保存点语句，不是保存点语句时 ok 为 false
+func parseSavepointStatement(query string) (action savepointAction, name string, ok bool) {
+	matches := savepointStatementPattern.FindStringSubmatch(query)
+	if matches == nil {
+		return 0, "", false
+	}
+
+	switch {
+	case matches[1] != "":
+		action = savepointCreate
+	case matches[2] != "":
+		action = savepointRollback
+	default:
+		action = savepointRelease
+	}
+	return action, matches[4], true
+}
+
+// Savepoint 在所有已开启的分支上创建保存点，之后开启的分支也会补建该保存点
+func (t *ShardingTx) Savepoint(ctx context.Context, name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return err
+	}
+	return t.savepoint(ctx, name)
+}
+
+// RollbackToSavepoint 将所有分支回滚到保存点，并关闭保存点之后开启的分支
+func (t *ShardingTx) RollbackToSavepoint(ctx context.Context, name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return err
+	}
+	return t.rollbackToSavepoint(ctx, name)
+}
+
+// ReleaseSavepoint 在所有分支上释放保存点及其之后创建的保存点
+func (t *ShardingTx) ReleaseSavepoint(ctx context.Context, name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return err
+	}
+	return t.releaseSavepoint(ctx, name)
+}
+
+// execSavepointStatement 执行保存点语句
+func (t *ShardingTx) execSavepointStatement(ctx context.Context, action savepointAction, name string) (*ShardingResult, error) {
+	var err error
+	switch action {
+	case savepointCreate:
+		err = t.savepoint(ctx, name)
+	case savepointRollback:
+		err = t.rollbackToSavepoint(ctx, name)
+	default:
+		err = t.releaseSavepoint(ctx, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ShardingResult{}, nil
+}
+
+// savepoint 创建保存点，同名保存点会被新的保存点替换
+func (t *ShardingTx) savepoint(ctx context.Context, name string) error {
+	if err := t.checkSavepointSupported(name); err != nil {
+		return err
+	}
+
+	for _, dataSource := range t.branchOrder {
+		if _, err := t.branches[dataSource].execContext(ctx, "SAVEPOINT "+name); err != nil {
+			return fmt.Errorf("failed to create savepoint %s on %s: %w", name, dataSource, err)
+		}
+	}
+
+	if i := t.findSavepoint(name); i >= 0 {
+		t.savepoints = append(t.savepoints[:i], t.savepoints[i+1:]...)
+	}
+	t.savepoints = append(t.savepoints, txSavepoint{name: name, branches: len(t.branchOrder)})
+	return nil
+}
+
+// rollbackToSavepoint 回滚到保存点，保存点本身保留，之后创建的保存点被丢弃
+func (t *ShardingTx) rollbackToSavepoint(ctx context.Context, name string) error {
+	if err := t.checkSavepointSupported(name); err != nil {
+		return err
+	}
+	i := t.findSavepoint(name)
+	if i < 0 {
+		return fmt.Errorf("savepoint %s does not exist", name)
+	}
+	sp := t.savepoints[i]
+
+	var errs []error
+	for _, dataSource := range t.branchOrder[:sp.branches] {
+		if _, err := t.branches[dataSource].execContext(ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rollback to savepoint %s on %s: %w", name, dataSource, err))
+		}
+	}
+
+	// 保存点之后开启的分支整体回滚并关闭，再次访问时重新开启
+	for _, dataSource := range t.branchOrder[sp.branches:] {
+		if err := t.branches[dataSource].tx.Rollback(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close branch on %s: %w", dataSource, err))
+		}
+		delete(t.branches, dataSource)
+	}
+	t.branchOrder = t.branchOrder[:sp.branches]
+	t.savepoints = t.savepoints[:i+1]
+
+	return errors.Join(errs...)
+}
+
+// releaseSavepoint 释放保存点
+func (t *ShardingTx) releaseSavepoint(ctx context.Context, name string) error {
+	if err := t.checkSavepointSupported(name); err != nil {
+		return err
+	}
+	i := t.findSavepoint(name)
+	if i < 0 {
+		return fmt.Errorf("savepoint %s does not exist", name)
+	}
+
+	for _, dataSource := range t.branchOrder {
+		if _, err := t.branches[dataSource].execContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+			return fmt.Errorf("failed to release savepoint %s on %s: %w", name, dataSource, err)
+		}
+	}
+	t.savepoints = t.savepoints[:i]
+	return nil
+}
+
+// replaySavepoints 在新开启的分支上补建已有的保存点
+func (t *ShardingTx) replaySavepoints(branch *txBranch) error {
+	for _, sp := range t.savepoints {
+		if _, err := branch.execContext(t.ctx, "SAVEPOINT "+sp.name); err != nil {
+			return fmt.Errorf("failed to replay savepoint %s on %s: %w", sp.name, branch.dataSource, err)
+		}
+	}
+	return nil
+}
+
+// findSavepoint 查找保存点的位置，不存在时返回 -1
+func (t *ShardingTx) findSavepoint(name string) int {
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(t.savepoints[i].name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkSavepointSupported 检查事务类型和保存点名称
+func (t *ShardingTx) checkSavepointSupported(name string) error {
+	if t.txType == transaction.XATransaction {
+		return fmt.Errorf("savepoints are not supported in XA sharding transaction %s", t.id)
+	}
+	if !savepointNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name: %q", name)
+	}
+	return nil
+}
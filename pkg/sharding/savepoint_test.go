@@ -0,0 +1,114 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/transaction"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSavepointStatement(t *testing.T) {
+	tests := []struct {
+		query  string
+		action savepointAction
+		name   string
+		ok     bool
+	}{
+		{"SAVEPOINT sp1", savepointCreate, "sp1", true},
+		{"  savepoint sp_2;", savepointCreate, "sp_2", true},
+		{"ROLLBACK TO sp1", savepointRollback, "sp1", true},
+		{"ROLLBACK TO SAVEPOINT sp1", savepointRollback, "sp1", true},
+		{"rollback work to savepoint sp1", savepointRollback, "sp1", true},
+		{"RELEASE SAVEPOINT sp1", savepointRelease, "sp1", true},
+		{"RELEASE sp1", savepointRelease, "sp1", true},
+		{"ROLLBACK", 0, "", false},
+		{"SAVEPOINT sp1; DROP TABLE t_order", 0, "", false},
+		{"UPDATE t_order SET status = 'SAVEPOINT x'", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			action, name, ok := parseSavepointStatement(tt.query)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.action, action)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestShardingTx_Savepoints(t *testing.T) {
+	for _, driverName := range []string{"recording", "recording-postgres"} {
+		t.Run(driverName, func(t *testing.T) {
+			db, ds0, ds1 := newRecordingShardingDB(t, driverName)
+			ctx := context.Background()
+
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+
+			_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, "SAVEPOINT sp1")
+			require.NoError(t, err)
+
+			// sp1 之后开启的分支补建 sp1
+			_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+			require.NoError(t, err)
+			require.NoError(t, tx.Savepoint(ctx, "sp2"))
+
+			// 回滚到 sp1 时关闭 sp1 之后开启的分支，并丢弃 sp2
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT sp1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"ds_1"}, tx.GetDataSources())
+			assert.Error(t, tx.ReleaseSavepoint(ctx, "sp2"))
+
+			// 再次访问时重新开启分支并补建 sp1
+			_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+			require.NoError(t, err)
+			require.NoError(t, tx.ReleaseSavepoint(ctx, "sp1"))
+			require.NoError(t, tx.Commit(ctx))
+
+			assert.Equal(t, []string{
+				"BEGIN",
+				"UPDATE t_order_1 SET status = 'PAID' WHERE user_id = ? AND order_id = ?",
+				"SAVEPOINT sp1",
+				"SAVEPOINT sp2",
+				"ROLLBACK TO SAVEPOINT sp1",
+				"RELEASE SAVEPOINT sp1",
+				"COMMIT",
+			}, ds1.executed())
+			assert.Equal(t, []string{
+				"BEGIN",
+				"SAVEPOINT sp1",
+				"UPDATE t_order_0 SET status = 'PAID' WHERE user_id = ? AND order_id = ?",
+				"SAVEPOINT sp2",
+				"ROLLBACK",
+				"BEGIN",
+				"SAVEPOINT sp1",
+				"UPDATE t_order_0 SET status = 'PAID' WHERE user_id = ? AND order_id = ?",
+				"RELEASE SAVEPOINT sp1",
+				"COMMIT",
+			}, ds0.executed())
+		})
+	}
+}
+
+func TestShardingTx_SavepointErrors(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	assert.Error(t, tx.Savepoint(ctx, "sp1; DROP TABLE t_order"))
+	assert.Error(t, tx.RollbackToSavepoint(ctx, "missing"))
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT missing")
+	assert.Error(t, err)
+
+	xaTx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.XATransaction})
+	require.NoError(t, err)
+	defer xaTx.Rollback(ctx)
+	assert.Error(t, xaTx.Savepoint(ctx, "sp1"))
+}
@@ -0,0 +1,67 @@
+package sharding
+
+import (
+	"fmt"
+	"go-sharding/pkg/algorithm"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/id"
+	"time"
+)
+
+// segmentKeyGeneratorType 从数据库号段表批量预留主键的主键生成器类型
+const segmentKeyGeneratorType = "segment"
+
+// newSegmentGenerator 为逻辑表创建号段生成器
+// 属性：data-source 号段表所在的数据源（必填），key 号段表中的 biz_tag（默认为逻辑表名），table 号段表名，
+// step 初始步长，max-step 最大步长，segment-duration 号段目标消耗时长（如 15m）；
+// 数据源、号段表和 key 都相同的逻辑表通过 shared 共享同一个生成器
+func (ds *ShardingDataSource) newSegmentGenerator(factory *id.GeneratorFactory, logicTable string, cfg *config.KeyGeneratorConfig, shared map[string]id.KeyGenerator) (id.KeyGenerator, error) {
+	dataSource, _ := cfg.Props["data-source"].(string)
+	db, exists := ds.dataSources[dataSource]
+	if !exists {
+		return nil, fmt.Errorf("segment data source %q of table %s not found", dataSource, logicTable)
+	}
+	key, _ := cfg.Props["key"].(string)
+	if key == "" {
+		key = logicTable
+	}
+	table, _ := cfg.Props["table"].(string)
+	if table == "" {
+		table = id.DefaultSegmentTable
+	}
+
+	sharedKey := dataSource + "." + table + "." + key
+	if generator, exists := shared[sharedKey]; exists {
+		return generator, nil
+	}
+
+	generatorConfig := map[string]interface{}{
+		"store": id.NewDBSegmentStore(db, ds.databaseTypes[dataSource], table),
+		"key":   key,
+	}
+	for prop, configKey := range map[string]string{"step": "step", "max-step": "maxStep"} {
+		value, exists := cfg.Props[prop]
+		if !exists {
+			continue
+		}
+		intValue, err := algorithm.ConvertToInt(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of table %s: %w", prop, logicTable, err)
+		}
+		generatorConfig[configKey] = intValue
+	}
+	if value, exists := cfg.Props["segment-duration"]; exists {
+		duration, err := time.ParseDuration(fmt.Sprint(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment-duration of table %s: %w", logicTable, err)
+		}
+		generatorConfig["duration"] = duration
+	}
+
+	generator, err := factory.CreateKeyGenerator(segmentKeyGeneratorType, generatorConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key generator of table %s: %w", logicTable, err)
+	}
+	shared[sharedKey] = generator
+	return generator, nil
+}
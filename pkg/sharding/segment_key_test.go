@@ -0,0 +1,95 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSegmentKeyConfig 创建 t_order 和 t_order_item 按 order_id 分库、主键由 ds_seq 上的号段表生成的配置
+func newSegmentKeyConfig(prefix string, props map[string]interface{}) *config.ShardingConfig {
+	dataSources := make(map[string]*config.DataSourceConfig)
+	for _, name := range []string{"ds_0", "ds_1", "ds_seq"} {
+		dataSources[name] = &config.DataSourceConfig{DriverName: "recording", URL: prefix + name}
+	}
+	tables := make(map[string]*config.TableRuleConfig)
+	for _, table := range []string{"t_order", "t_order_item"} {
+		tables[table] = &config.TableRuleConfig{
+			ActualDataNodes: "ds_${0..1}." + table,
+			DatabaseStrategy: &config.ShardingStrategyConfig{
+				ShardingColumn: "order_id",
+				Algorithm:      "MOD",
+				Type:           "standard",
+				Props:          map[string]interface{}{"sharding-count": 2},
+			},
+			KeyGenerator: &config.KeyGeneratorConfig{Column: "order_id", Type: "segment", Props: props},
+		}
+	}
+	return &config.ShardingConfig{
+		DataSources:  dataSources,
+		ShardingRule: &config.ShardingRuleConfig{Tables: tables},
+	}
+}
+
+func TestShardingDB_SegmentKeyGenerator(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	ds, err := NewShardingDataSource(newSegmentKeyConfig(prefix, map[string]interface{}{
+		"data-source": "ds_seq",
+		"key":         "order",
+		"step":        1000,
+	}))
+	require.NoError(t, err)
+	defer ds.Close()
+	db := ds.DB()
+	ctx := context.Background()
+
+	// 共享同一个号段 key 的逻辑表使用同一个生成器
+	assert.Same(t, ds.keyGenerators["t_order"], ds.keyGenerators["t_order_item"])
+
+	// 号段表返回 max_id 1000，步长 1000 时第一个号段从 1 开始
+	routed, err := db.routeStatement(ctx, "INSERT INTO t_order (user_id, order_id, amount) VALUES (?, ?, ?)", []interface{}{7, 100}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{7, int64(1), 100}, routed.rewriteContext.Parameters)
+	require.Len(t, routed.rewriteContext.RouteResults, 1)
+	assert.Equal(t, "ds_1", routed.rewriteContext.RouteResults[0].DataSource)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"UPDATE sequence SET max_id = max_id + ? WHERE biz_tag = ?",
+		"SELECT max_id FROM sequence WHERE biz_tag = ?",
+		"COMMIT",
+	}, recordingLogFor(prefix+"ds_seq").executed())
+
+	routed, err = db.routeStatement(ctx, "INSERT INTO t_order_item (order_id, sku) VALUES (?, ?)", []interface{}{"A-1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(2), "A-1"}, routed.rewriteContext.Parameters)
+	assert.Equal(t, "ds_0", routed.rewriteContext.RouteResults[0].DataSource)
+
+	// 已经传入主键时不再生成
+	routed, err = db.routeStatement(ctx, "INSERT INTO t_order (order_id, user_id) VALUES (?, ?)", []interface{}{int64(41), 7}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(41), 7}, routed.rewriteContext.Parameters)
+}
+
+func TestNewShardingDataSource_InvalidSegmentKeyGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		props    map[string]interface{}
+		errorMsg string
+	}{
+		{"missing data source", map[string]interface{}{}, "segment data source"},
+		{"unknown data source", map[string]interface{}{"data-source": "ds_9"}, "segment data source"},
+		{"invalid step", map[string]interface{}{"data-source": "ds_seq", "step": "abc"}, "invalid step"},
+		{"invalid duration", map[string]interface{}{"data-source": "ds_seq", "segment-duration": "soon"}, "invalid segment-duration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewShardingDataSource(newSegmentKeyConfig("recording://"+t.Name()+"/", tt.props))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
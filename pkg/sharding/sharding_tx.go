@@ -0,0 +1,551 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/rewrite"
+	"go-sharding/pkg/transaction"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TxOptions 分片事务选项
+type TxOptions struct {
+	// Type 事务类型，支持 LocalTransaction（尽力而为）、XATransaction 和 BaseTransaction，默认为本地事务
+	Type transaction.TransactionType
+	// Isolation 分支事务的隔离级别
+	Isolation sql.IsolationLevel
+	// ReadOnly 分支事务是否只读
+	ReadOnly bool
+}
+
+// routedStatement 路由并重写后的语句
+type routedStatement struct {
+	logicTables    []string
+	rewriteContext *rewrite.RewriteContext
+	rewriteResults []*rewrite.RewriteResult
+}
+
+// newDefaultRoutedStatement 创建路由到默认数据源的语句
+func newDefaultRoutedStatement(query string, args []interface{}, dataSource string) *routedStatement {
+	return &routedStatement{
+		rewriteContext: &rewrite.RewriteContext{
+			OriginalSQL: query,
+			Parameters:  args,
+		},
+		rewriteResults: []*rewrite.RewriteResult{
+			{SQL: query, Parameters: args, DataSource: dataSource},
+		},
+	}
+}
+
+// txRouter 分片事务依赖的路由与连接能力，由 ShardingDB 和 EnhancedShardingDB 实现
+type txRouter interface {
+	// extractLogicTables 提取语句涉及的逻辑表
+	extractLogicTables(query string) []string
+	// routeStatement 路由并重写语句
+	routeStatement(ctx context.Context, query string, args []interface{}, exec bool) (*routedStatement, error)
+	// branchDB 获取分支事务使用的连接池，读写分离数据源返回主库
+	branchDB(dataSource string) (*sql.DB, error)
+	// databaseType 获取数据源的数据库类型
+	databaseType(dataSource string) database.DatabaseType
+	// undoLogs 获取 BASE 事务使用的 undo 日志管理器
+	undoLogs() *transaction.UndoLogManager
+	// sqlRewriter 获取 SQL 重写器
+	sqlRewriter() *rewrite.SQLRewriter
+	// primaryKeys 获取逻辑表的主键列
+	primaryKeys(logicTable string) ([]string, error)
+	// newStatementMetrics 创建逻辑语句的指标记录和父 span
+	newStatementMetrics(ctx context.Context, operation, query string) (context.Context, *statementMetrics)
+	// owner 获取路由器所属的数据源，用于识别上下文中的事务是否属于当前数据源
+	owner() interface{}
+}
+
+// txBranch 分片事务在单个数据源上的分支
+type txBranch struct {
+	dataSource string
+	tx         *sql.Tx
+	conn       *sql.Conn
+}
+
+// queryContext 在分支上执行查询
+func (b *txBranch) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if b.tx != nil {
+		return b.tx.QueryContext(ctx, query, args...)
+	}
+	return b.conn.QueryContext(ctx, query, args...)
+}
+
+// execContext 在分支上执行非查询语句
+func (b *txBranch) execContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if b.tx != nil {
+		return b.tx.ExecContext(ctx, query, args...)
+	}
+	return b.conn.ExecContext(ctx, query, args...)
+}
+
+// ShardingTx 分片事务
+// 每条语句都经过路由和重写，首次访问某个数据源时才在该数据源上开启分支事务，
+// 提交或回滚时作用于所有已开启的分支
+type ShardingTx struct {
+	id          string
+	txType      transaction.TransactionType
+	status      transaction.TransactionStatus
+	router      txRouter
+	ctx         context.Context
+	txOptions   *sql.TxOptions
+	branches    map[string]*txBranch
+	branchOrder []string
+	savepoints  []txSavepoint
+	xa          *transaction.XATransactionImpl
+	at          *transaction.ATTransactionImpl
+	mu          sync.Mutex
+}
+
+// newShardingTx 创建并开始分片事务
+func newShardingTx(ctx context.Context, router txRouter, opts *TxOptions) (*ShardingTx, error) {
+	if opts == nil {
+		opts = &TxOptions{Type: transaction.LocalTransaction}
+	}
+
+	id := transaction.GenerateTransactionID()
+	tx := &ShardingTx{
+		id:       id,
+		txType:   opts.Type,
+		status:   transaction.StatusActive,
+		router:   router,
+		ctx:      ctx,
+		branches: make(map[string]*txBranch),
+		txOptions: &sql.TxOptions{
+			Isolation: opts.Isolation,
+			ReadOnly:  opts.ReadOnly,
+		},
+	}
+
+	switch opts.Type {
+	case transaction.LocalTransaction:
+	case transaction.XATransaction:
+		tx.xa = transaction.NewXATransaction(id, nil)
+	case transaction.BaseTransaction:
+		tx.at = transaction.NewATTransaction(id, router.undoLogs())
+	default:
+		return nil, fmt.Errorf("unsupported sharding transaction type: %v", opts.Type)
+	}
+
+	if err := tx.Begin(ctx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ambientShardingTx 获取上下文中属于同一数据源的分片事务
+// 通过 transaction.WithTransaction 绑定事务后，数据源上的语句会自动在该事务中执行
+func ambientShardingTx(ctx context.Context, router txRouter) *ShardingTx {
+	tx, ok := transaction.GetTransactionFromContext(ctx).(*ShardingTx)
+	if !ok || tx.router.owner() != router.owner() {
+		return nil
+	}
+	return tx
+}
+
+// Begin 开始事务，分支事务在首次访问数据源时才会开启
+func (t *ShardingTx) Begin(ctx context.Context) error {
+	if t.xa != nil {
+		return t.xa.Begin(ctx)
+	}
+	if t.at != nil {
+		return t.at.Begin(ctx)
+	}
+	return nil
+}
+
+// Query 在事务中执行查询
+func (t *ShardingTx) Query(query string, args ...interface{}) (*ShardingRows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext 在事务中执行查询（带上下文）
+func (t *ShardingTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*ShardingRows, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return nil, err
+	}
+
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, t.router.extractLogicTables)
+
+	ctx, metrics := t.router.newStatementMetrics(ctx, "query", query)
+	metrics.parse(ctx, func() ([]string, error) {
+		return t.router.extractLogicTables(query), nil
+	})
+	stmt, err := t.router.routeStatement(ctx, query, args, false)
+	if err != nil {
+		return nil, metrics.routeFailed(err)
+	}
+	metrics.routed(stmt)
+
+	var allRows []*sql.Rows
+	for _, rewriteResult := range stmt.rewriteResults {
+		branch, err := t.branch(rewriteResult.DataSource)
+		if err != nil {
+			closeRows(allRows)
+			return nil, metrics.finish(0, err)
+		}
+
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		rows, err := branch.queryContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
+		unit.end(0, err)
+		if err != nil {
+			closeRows(allRows)
+			return nil, metrics.finish(0, fmt.Errorf("query failed on %s: %w", rewriteResult.DataSource, err))
+		}
+		allRows = append(allRows, rows)
+	}
+
+	return finishShardingRows(ctx, metrics, stmt, allRows), nil
+}
+
+// Exec 在事务中执行非查询语句
+func (t *ShardingTx) Exec(query string, args ...interface{}) (*ShardingResult, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext 在事务中执行非查询语句（带上下文）
+func (t *ShardingTx) ExecContext(ctx context.Context, query string, args ...interface{}) (*ShardingResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return nil, err
+	}
+
+	// 解析并去掉 SQL 注释中的路由提示
+	ctx, query = withCommentHints(ctx, query, t.router.extractLogicTables)
+
+	// 保存点语句作用于所有分支，不经过路由
+	if action, name, ok := parseSavepointStatement(query); ok {
+		return t.execSavepointStatement(ctx, action, name)
+	}
+
+	ctx, metrics := t.router.newStatementMetrics(ctx, "exec", query)
+	metrics.parse(ctx, func() ([]string, error) {
+		return t.router.extractLogicTables(query), nil
+	})
+	stmt, err := t.router.routeStatement(ctx, query, args, true)
+	if err != nil {
+		return nil, metrics.routeFailed(err)
+	}
+	metrics.routed(stmt)
+
+	// BASE 事务的 UPDATE/DELETE 记录 undo log，以便在部分分支提交失败时补偿
+	if t.at != nil && len(stmt.logicTables) == 1 {
+		if sqlType := statementKeyword(stmt.rewriteContext.OriginalSQL); sqlType == "UPDATE" || sqlType == "DELETE" {
+			result, err := t.execWithUndoLog(ctx, stmt, sqlType, metrics)
+			if err != nil {
+				return nil, metrics.finish(0, err)
+			}
+			return result, metrics.finish(result.affectedRows, nil)
+		}
+	}
+
+	var results []sql.Result
+	for _, rewriteResult := range stmt.rewriteResults {
+		branch, err := t.branch(rewriteResult.DataSource)
+		if err != nil {
+			return nil, metrics.finish(0, err)
+		}
+
+		unitCtx, unit := metrics.startUnit(ctx, rewriteResult.DataSource, rewriteResult.SQL)
+		result, err := branch.execContext(unitCtx, rewriteResult.SQL, rewriteResult.Parameters...)
+		if err != nil {
+			unit.end(0, err)
+			return nil, metrics.finish(0, fmt.Errorf("exec failed on %s: %w", rewriteResult.DataSource, err))
+		}
+		affected, _ := result.RowsAffected()
+		unit.end(affected, nil)
+		results = append(results, result)
+	}
+
+	merged := mergeExecResults(ctx, metrics, results)
+	return merged, metrics.finish(merged.affectedRows, nil)
+}
+
+// execWithUndoLog 按执行单元执行 UPDATE/DELETE 并在分支事务中写入 undo log
+func (t *ShardingTx) execWithUndoLog(ctx context.Context, stmt *routedStatement, sqlType string, metrics *statementMetrics) (*ShardingResult, error) {
+	rewriter := t.router.sqlRewriter()
+	units, err := rewriter.RewriteExecutionUnits(stmt.rewriteContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewrite SQL: %w", err)
+	}
+
+	primaryKeys, err := t.router.primaryKeys(stmt.logicTables[0])
+	if err != nil {
+		return nil, err
+	}
+	if sqlType == "UPDATE" {
+		if err := checkPrimaryKeysUnchanged(rewriter, stmt.rewriteContext.OriginalSQL, stmt.logicTables[0], primaryKeys); err != nil {
+			return nil, err
+		}
+	}
+	var results []sql.Result
+	for _, unit := range units {
+		branch, err := t.branch(unit.DataSource)
+		if err != nil {
+			return nil, err
+		}
+
+		beforeImage, err := rewriter.BuildBeforeImage(unit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build before image: %w", err)
+		}
+
+		unitCtx, statementUnit := metrics.startUnit(ctx, unit.DataSource, unit.SQL)
+		result, err := t.at.ExecuteUnit(unitCtx, branch.tx, &transaction.ATExecutionUnit{
+			DataSource:            unit.DataSource,
+			TableName:             unit.ActualTable,
+			SQLType:               sqlType,
+			SQL:                   unit.SQL,
+			Parameters:            unit.Parameters,
+			BeforeImageSQL:        beforeImage.SQL,
+			BeforeImageParameters: beforeImage.Parameters,
+			PrimaryKeys:           primaryKeys,
+		})
+		if err != nil {
+			statementUnit.end(0, err)
+			return nil, fmt.Errorf("exec failed on %s: %w", unit.DataSource, err)
+		}
+		affected, _ := result.RowsAffected()
+		statementUnit.end(affected, nil)
+		results = append(results, result)
+	}
+
+	return mergeExecResults(ctx, metrics, results), nil
+}
+
+// Commit 提交所有已开启的分支事务
+func (t *ShardingTx) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return err
+	}
+
+	var err error
+	switch t.txType {
+	case transaction.XATransaction:
+		err = t.xa.Commit(ctx)
+		t.closeConns()
+		t.status = t.xa.GetStatus()
+	case transaction.BaseTransaction:
+		err = t.commitBase(ctx)
+	default:
+		err = t.commitLocal()
+	}
+	return err
+}
+
+// commitLocal 依次提交本地分支事务，任一分支失败时继续提交其余分支
+// 至少一个分支提交成功时错误中列出已提交的数据源
+func (t *ShardingTx) commitLocal() error {
+	var errs []error
+	var committed []string
+	for _, dataSource := range t.branchOrder {
+		if err := t.branches[dataSource].tx.Commit(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to commit branch on %s: %w", dataSource, err))
+			continue
+		}
+		committed = append(committed, dataSource)
+	}
+
+	if len(errs) > 0 {
+		t.status = transaction.StatusFailed
+		if len(committed) > 0 {
+			return fmt.Errorf("sharding transaction %s partially committed on %s: %w", t.id, strings.Join(committed, ", "), errors.Join(errs...))
+		}
+		return fmt.Errorf("failed to commit sharding transaction %s: %w", t.id, errors.Join(errs...))
+	}
+
+	t.status = transaction.StatusCommitted
+	return nil
+}
+
+// commitBase 依次提交分支事务，某个分支提交失败时回滚其余分支并根据 undo log 补偿已提交的分支
+func (t *ShardingTx) commitBase(ctx context.Context) error {
+	for i, dataSource := range t.branchOrder {
+		commitErr := t.branches[dataSource].tx.Commit()
+		if commitErr == nil {
+			continue
+		}
+
+		for _, rest := range t.branchOrder[i+1:] {
+			t.branches[rest].tx.Rollback()
+		}
+
+		if err := t.at.Rollback(ctx); err != nil {
+			t.status = transaction.StatusFailed
+			return fmt.Errorf("failed to commit branch on %s and compensation failed: %w", dataSource, errors.Join(commitErr, err))
+		}
+
+		t.status = transaction.StatusRolledBack
+		return fmt.Errorf("failed to commit branch on %s, committed branches have been compensated: %w", dataSource, commitErr)
+	}
+
+	t.status = transaction.StatusCommitted
+	// undo log 清理失败不影响提交结果，残留日志不会再被使用
+	t.at.Commit(ctx)
+	return nil
+}
+
+// Rollback 回滚所有已开启的分支事务
+func (t *ShardingTx) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkActive(); err != nil {
+		return err
+	}
+
+	if t.txType == transaction.XATransaction {
+		err := t.xa.Rollback(ctx)
+		t.closeConns()
+		t.status = transaction.StatusRolledBack
+		return err
+	}
+
+	var errs []error
+	for _, dataSource := range t.branchOrder {
+		if err := t.branches[dataSource].tx.Rollback(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rollback branch on %s: %w", dataSource, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		t.status = transaction.StatusFailed
+		return errors.Join(errs...)
+	}
+
+	t.status = transaction.StatusRolledBack
+	return nil
+}
+
+// GetStatus 获取事务状态
+func (t *ShardingTx) GetStatus() transaction.TransactionStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// GetID 获取事务 ID
+func (t *ShardingTx) GetID() string {
+	return t.id
+}
+
+// GetType 获取事务类型
+func (t *ShardingTx) GetType() transaction.TransactionType {
+	return t.txType
+}
+
+// GetDataSources 获取已开启分支的数据源，按开启顺序排列
+func (t *ShardingTx) GetDataSources() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dataSources := make([]string, len(t.branchOrder))
+	copy(dataSources, t.branchOrder)
+	return dataSources
+}
+
+// checkActive 检查事务是否处于活跃状态
+func (t *ShardingTx) checkActive() error {
+	if t.status != transaction.StatusActive {
+		return fmt.Errorf("sharding transaction %s is not in active status", t.id)
+	}
+	return nil
+}
+
+// branch 获取数据源上的分支事务，不存在时开启新分支
+func (t *ShardingTx) branch(dataSource string) (*txBranch, error) {
+	if branch, exists := t.branches[dataSource]; exists {
+		return branch, nil
+	}
+
+	db, err := t.router.branchDB(dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分支的生命周期与 BeginTx 的上下文绑定，与 database/sql 的语义一致
+	branch := &txBranch{dataSource: dataSource}
+	if t.txType == transaction.XATransaction {
+		conn, err := db.Conn(t.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection on %s: %w", dataSource, err)
+		}
+		if _, err := t.xa.StartBranch(t.ctx, dataSource, conn, t.router.databaseType(dataSource)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		branch.conn = conn
+	} else {
+		tx, err := db.BeginTx(t.ctx, t.txOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin branch on %s: %w", dataSource, err)
+		}
+		branch.tx = tx
+
+		// 新分支补建已有的保存点，保证所有分支上的保存点一致
+		if err := t.replaySavepoints(branch); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	t.branches[dataSource] = branch
+	t.branchOrder = append(t.branchOrder, dataSource)
+	return branch, nil
+}
+
+// closeConns 释放 XA 分支占用的连接
+func (t *ShardingTx) closeConns() {
+	for _, branch := range t.branches {
+		if branch.conn != nil {
+			branch.conn.Close()
+		}
+	}
+}
+
+// closeRows 关闭已打开的结果集
+func closeRows(allRows []*sql.Rows) {
+	for _, rows := range allRows {
+		rows.Close()
+	}
+}
+
+// defaultDataSourceName 获取默认数据源名称（按名称排序的第一个）
+func defaultDataSourceName[T any](dataSources map[string]T) string {
+	names := make([]string, 0, len(dataSources))
+	for name := range dataSources {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// resolveDatabaseType 根据驱动名解析数据库类型，无法识别时按 MySQL 处理
+func resolveDatabaseType(driverName string) database.DatabaseType {
+	dbType, err := database.GlobalDatabaseTypeRegistry.GetDatabaseType(driverName)
+	if err != nil {
+		return database.MySQL
+	}
+	return dbType
+}
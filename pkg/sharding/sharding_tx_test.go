@@ -0,0 +1,441 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"go-sharding/pkg/transaction"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLog 单个 DSN 的语句记录与故障注入
+type recordingLog struct {
+	mu         sync.Mutex
+	statements []string
+	failOn     string // 第一条匹配该前缀的语句返回错误
+}
+
+var recordingLogs = struct {
+	sync.Mutex
+	logs map[string]*recordingLog
+}{logs: make(map[string]*recordingLog)}
+
+// recordingLogFor 获取 DSN 对应的语句记录
+func recordingLogFor(dsn string) *recordingLog {
+	recordingLogs.Lock()
+	defer recordingLogs.Unlock()
+
+	log, exists := recordingLogs.logs[dsn]
+	if !exists {
+		log = &recordingLog{}
+		recordingLogs.logs[dsn] = log
+	}
+	return log
+}
+
+func (l *recordingLog) record(statement string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.statements = append(l.statements, statement)
+	if l.failOn != "" && strings.HasPrefix(statement, l.failOn) {
+		l.failOn = ""
+		return errors.New("injected failure: " + statement)
+	}
+	return nil
+}
+
+func (l *recordingLog) executed() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.statements...)
+}
+
+// handleRecording 记录每个 DSN 上执行的语句，查询返回固定的结果集
+func handleRecording(dsn, query string, args []driver.Value) sqltest.Result {
+	if err := recordingLogFor(dsn).record(query); err != nil {
+		return sqltest.Result{Err: err}
+	}
+
+	switch {
+	case strings.HasPrefix(query, "SELECT rollback_info"):
+		return sqltest.Result{Columns: []string{"rollback_info"}}
+	case strings.HasPrefix(query, "SELECT max_id"):
+		return sqltest.Result{Columns: []string{"max_id"}, Rows: [][]driver.Value{{int64(1000)}}}
+	case strings.HasPrefix(query, "SELECT COUNT(*)"):
+		return sqltest.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+	}
+	return sqltest.Result{
+		Columns:  []string{"id", "status"},
+		Rows:     [][]driver.Value{{int64(1), "NEW"}},
+		Affected: 1,
+	}
+}
+
+func init() {
+	// 记录每个 DSN 上执行语句的模拟驱动
+	sql.Register("recording", &sqltest.Driver{Handler: handleRecording})
+	sql.Register("recording-postgres", &sqltest.Driver{Handler: handleRecording})
+	database.GlobalDatabaseTypeRegistry.Register("recording", database.MySQL)
+	database.GlobalDatabaseTypeRegistry.Register("recording-postgres", database.PostgreSQL)
+}
+
+// newRecordingShardingDB 创建使用记录驱动的两库两表分片数据库
+func newRecordingShardingDB(t *testing.T, driverName string) (*ShardingDB, *recordingLog, *recordingLog) {
+	prefix := driverName + "://" + t.Name() + "/"
+	ds, err := NewShardingDataSource(newRecordingShardingConfig(prefix, driverName))
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	return ds.DB(), recordingLogFor(prefix + "ds_0"), recordingLogFor(prefix + "ds_1")
+}
+
+// newRecordingShardingConfig 创建两库两表的 t_order 分片配置，数据源使用 driverName 驱动
+func newRecordingShardingConfig(prefix, driverName string) *config.ShardingConfig {
+	return &config.ShardingConfig{
+		DataSources: map[string]*config.DataSourceConfig{
+			"ds_0": {DriverName: driverName, URL: prefix + "ds_0"},
+			"ds_1": {DriverName: driverName, URL: prefix + "ds_1"},
+		},
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {
+					ActualDataNodes: "ds_${0..1}.t_order_${0..1}",
+					DatabaseStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn: "user_id",
+						Algorithm:      "ds_${user_id % 2}",
+						Type:           "inline",
+					},
+					TableStrategy: &config.ShardingStrategyConfig{
+						ShardingColumn: "order_id",
+						Algorithm:      "t_order_${order_id % 2}",
+						Type:           "inline",
+					},
+					PrimaryKeyColumns: []string{"id"},
+				},
+			},
+		},
+	}
+}
+
+const updateOrderSQL = "UPDATE t_order SET status = 'PAID' WHERE user_id = ? AND order_id = ?"
+
+func TestShardingTx_LocalCommit(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, transaction.LocalTransaction, tx.GetType())
+	assert.Empty(t, tx.GetDataSources())
+
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.NoError(t, err)
+
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM t_order WHERE user_id = ? AND order_id = ?", 1, 3)
+	require.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, []string{"ds_1", "ds_0"}, tx.GetDataSources())
+	require.NoError(t, tx.Commit(ctx))
+	assert.Equal(t, transaction.StatusCommitted, tx.GetStatus())
+
+	assert.Equal(t, []string{
+		"BEGIN",
+		"UPDATE t_order_1 SET status = 'PAID' WHERE user_id = ? AND order_id = ?",
+		"SELECT * FROM t_order_1 WHERE user_id = ? AND order_id = ?",
+		"COMMIT",
+	}, ds1.executed())
+	assert.Equal(t, []string{
+		"BEGIN",
+		"UPDATE t_order_0 SET status = 'PAID' WHERE user_id = ? AND order_id = ?",
+		"COMMIT",
+	}, ds0.executed())
+
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	assert.Error(t, err)
+	assert.Error(t, tx.Commit(ctx))
+}
+
+func TestShardingTx_LocalRollback(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.LocalTransaction})
+	require.NoError(t, err)
+
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	// 不涉及分片表的语句路由到默认数据源
+	_, err = tx.ExecContext(ctx, "UPDATE t_config SET value = 1")
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, transaction.StatusRolledBack, tx.GetStatus())
+	assert.Equal(t, []string{"BEGIN", "UPDATE t_config SET value = 1", "ROLLBACK"}, ds0.executed())
+	assert.Equal(t, "ROLLBACK", ds1.executed()[len(ds1.executed())-1])
+}
+
+func TestShardingDB_AmbientTransaction(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+	other, otherDS0, otherDS1 := newRecordingShardingDB(t, "recording-postgres")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	txCtx := transaction.WithTransaction(ctx, tx)
+
+	// 同一数据源的其他 ShardingDB 也使用上下文中的事务
+	_, err = db.dataSource.DB().ExecContext(txCtx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	rows, err := db.QueryContext(txCtx, "SELECT * FROM t_order WHERE user_id = ? AND order_id = ?", 2, 4)
+	require.NoError(t, err)
+	rows.Close()
+
+	// 其他数据源忽略不属于自己的事务
+	_, err = other.ExecContext(txCtx, "UPDATE t_config SET value = 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"UPDATE t_config SET value = 1"}, append(otherDS0.executed(), otherDS1.executed()...))
+
+	assert.Equal(t, []string{"ds_1", "ds_0"}, tx.GetDataSources())
+	require.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, []string{"BEGIN", "SELECT * FROM t_order_0 WHERE user_id = ? AND order_id = ?", "ROLLBACK"}, ds0.executed())
+	assert.Equal(t, []string{"BEGIN", "UPDATE t_order_1 SET status = 'PAID' WHERE user_id = ? AND order_id = ?", "ROLLBACK"}, ds1.executed())
+
+	// 事务结束后绑定该事务的上下文不再自动提交语句
+	_, err = db.ExecContext(txCtx, updateOrderSQL, 1, 3)
+	assert.Error(t, err)
+}
+
+func TestShardingTx_LocalCommitFailure(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+	ds0.failOn = "COMMIT"
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+
+	err = tx.Commit(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to commit branch on ds_0")
+	assert.Contains(t, err.Error(), "partially committed on ds_1")
+	assert.Equal(t, transaction.StatusFailed, tx.GetStatus())
+	// 尽力而为：其余分支仍然提交
+	assert.Contains(t, ds1.executed(), "COMMIT")
+}
+
+func TestShardingTx_LocalCommitFailureNothingCommitted(t *testing.T) {
+	db, ds0, _ := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+	ds0.failOn = "COMMIT"
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.NoError(t, err)
+
+	// 唯一的分支提交失败时没有分支提交成功，不报告部分提交
+	err = tx.Commit(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to commit sharding transaction")
+	assert.NotContains(t, err.Error(), "partially committed")
+	assert.Equal(t, transaction.StatusFailed, tx.GetStatus())
+}
+
+func TestShardingTx_XA(t *testing.T) {
+	tests := []struct {
+		name           string
+		driverName     string
+		expectedCommit []string
+		expectedAbort  []string
+	}{
+		{
+			name:           "mysql",
+			driverName:     "recording",
+			expectedCommit: []string{"XA START", "UPDATE t_order_1", "XA END", "XA PREPARE", "XA COMMIT"},
+			expectedAbort:  []string{"XA START", "UPDATE t_order_1", "XA END", "XA ROLLBACK"},
+		},
+		{
+			name:           "postgresql",
+			driverName:     "recording-postgres",
+			expectedCommit: []string{"BEGIN", "UPDATE t_order_1", "PREPARE TRANSACTION", "COMMIT PREPARED"},
+			expectedAbort:  []string{"BEGIN", "UPDATE t_order_1", "ROLLBACK"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/commit", func(t *testing.T) {
+			ctx := context.Background()
+			db, _, ds1 := newRecordingShardingDB(t, tt.driverName)
+
+			tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.XATransaction})
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+			require.NoError(t, err)
+			require.NoError(t, tx.Commit(ctx))
+
+			assert.Equal(t, transaction.StatusCommitted, tx.GetStatus())
+			statements := ds1.executed()
+			assertStatementPrefixes(t, tt.expectedCommit, statements)
+			assert.Contains(t, statements[len(statements)-1], tx.GetID()+"_ds_1")
+		})
+
+		t.Run(tt.name+"/rollback", func(t *testing.T) {
+			ctx := context.Background()
+			db, _, ds1 := newRecordingShardingDB(t, tt.driverName)
+
+			tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.XATransaction})
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+			require.NoError(t, err)
+			require.NoError(t, tx.Rollback(ctx))
+
+			assert.Equal(t, transaction.StatusRolledBack, tx.GetStatus())
+			assertStatementPrefixes(t, tt.expectedAbort, ds1.executed())
+		})
+	}
+}
+
+func TestShardingTx_BaseCompensatesOnCommitFailure(t *testing.T) {
+	db, ds0, ds1 := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+	ds0.failOn = "COMMIT"
+
+	tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.BaseTransaction})
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.NoError(t, err)
+
+	assertStatementPrefixes(t, []string{
+		"BEGIN",
+		"SELECT * FROM t_order_1 WHERE user_id = ? AND order_id = ? FOR UPDATE",
+		"UPDATE t_order_1",
+		"SELECT * FROM t_order_1 WHERE (id = ?)",
+		"INSERT INTO undo_log",
+	}, ds1.executed())
+
+	err = tx.Commit(ctx)
+	require.Error(t, err)
+	assert.Equal(t, transaction.StatusRolledBack, tx.GetStatus())
+	// 已提交的 ds_1 分支根据 undo log 补偿
+	assert.Contains(t, ds1.executed(), "SELECT rollback_info FROM undo_log WHERE xid = ? ORDER BY id DESC FOR UPDATE")
+}
+
+func TestShardingTx_BaseUndoLogUsesDataSourceType(t *testing.T) {
+	db, _, ds1 := newRecordingShardingDB(t, "recording-postgres")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.BaseTransaction})
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE t_order SET status = 'PAID' WHERE user_id = $1 AND order_id = $2", 1, 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+
+	assert.Contains(t, ds1.executed(), "SELECT * FROM t_order_1 WHERE (id = $1)")
+	assert.Contains(t, ds1.executed(), "DELETE FROM undo_log WHERE xid = $1")
+}
+
+func TestShardingTx_BaseRejectsPrimaryKeyUpdate(t *testing.T) {
+	db, _, ds1 := newRecordingShardingDB(t, "recording")
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &TxOptions{Type: transaction.BaseTransaction})
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	_, err = tx.ExecContext(ctx, "UPDATE t_order SET id = ?, status = 'PAID' WHERE user_id = ? AND order_id = ?", 9, 1, 3)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, transaction.ErrPrimaryKeyUpdated))
+	for _, statement := range ds1.executed() {
+		assert.False(t, strings.HasPrefix(statement, "UPDATE t_order_1"), statement)
+	}
+}
+
+func TestPrimaryKeysOf(t *testing.T) {
+	tables := map[string]*config.TableRuleConfig{
+		"t_order":      {PrimaryKeyColumns: []string{"user_id", "order_id"}, KeyGenerator: &config.KeyGeneratorConfig{Column: "order_id"}},
+		"t_order_item": {KeyGenerator: &config.KeyGeneratorConfig{Column: "item_id"}},
+		"t_user":       {},
+	}
+
+	primaryKeys, err := primaryKeysOf(tables, "t_order")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user_id", "order_id"}, primaryKeys)
+
+	primaryKeys, err = primaryKeysOf(tables, "t_order_item")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item_id"}, primaryKeys)
+
+	_, err = primaryKeysOf(tables, "t_user")
+	assert.Error(t, err)
+	_, err = primaryKeysOf(tables, "t_unknown")
+	assert.Error(t, err)
+}
+
+func TestShardingTx_UnsupportedType(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	_, err := db.BeginTx(context.Background(), &TxOptions{Type: transaction.ATTransaction})
+	assert.Error(t, err)
+}
+
+// assertStatementPrefixes 断言语句依次以给定前缀开头
+func assertStatementPrefixes(t *testing.T, prefixes, statements []string) {
+	t.Helper()
+	require.Len(t, statements, len(prefixes), "statements: %v", statements)
+	for i, prefix := range prefixes {
+		assert.True(t, strings.HasPrefix(statements[i], prefix), "statement %d: expected prefix %q, got %q", i, prefix, statements[i])
+	}
+}
+
+func TestEnhancedShardingDB_BeginTxUsesMaster(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	db, err := NewEnhancedShardingDB(&config.ShardingConfig{
+		DataSources: map[string]*config.DataSourceConfig{
+			"ds_0_primary": {DriverName: "recording", URL: prefix + "ds_0_primary"},
+			"ds_0_slave":   {DriverName: "recording", URL: prefix + "ds_0_slave"},
+		},
+		ShardingRule: &config.ShardingRuleConfig{
+			Tables: map[string]*config.TableRuleConfig{
+				"t_order": {ActualDataNodes: "ds_0.t_order"},
+			},
+		},
+		ReadWriteSplits: map[string]*config.ReadWriteSplitConfig{
+			"ds_0": {
+				Name:             "ds_0",
+				MasterDataSource: "ds_0_primary",
+				SlaveDataSources: []string{"ds_0_slave"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM t_order WHERE id = ?", 1)
+	require.NoError(t, err)
+	rows.Close()
+	require.NoError(t, tx.Commit(ctx))
+
+	assert.Equal(t, []string{"BEGIN", "SELECT * FROM t_order WHERE id = ?", "COMMIT"}, recordingLogFor(prefix+"ds_0_primary").executed())
+	assert.Empty(t, recordingLogFor(prefix+"ds_0_slave").executed())
+}
@@ -0,0 +1,205 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/config"
+	"go-sharding/pkg/monitoring"
+	"go-sharding/pkg/routing"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ddlKeywords 广播到所有数据节点的 DDL 语句关键字
+var ddlKeywords = map[string]bool{"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true}
+
+// statementMetrics 一条逻辑语句的指标和链路追踪记录
+// 每条逻辑语句对应一个父 span，解析、路由、重写、合并以及每个执行单元各对应一个子 span；
+// 执行单元的指标按数据源记录
+type statementMetrics struct {
+	metrics        *monitoring.ShardingMetrics
+	dataNodeCounts map[string]int
+	keyword        string
+	logicTable     string
+	routeType      monitoring.RouteType
+	actualTables   map[string]string // 数据源上的实际表，多个实际表用逗号分隔
+	start          time.Time
+	span           trace.Span
+}
+
+// newStatementMetrics 开始记录一条逻辑语句并创建语句的父 span，返回的上下文携带该 span
+// operation 为 query 或 exec，dataNodeCounts 为各逻辑表的数据节点数量，用于识别全路由
+func newStatementMetrics(ctx context.Context, tracer trace.Tracer, metrics *monitoring.ShardingMetrics, dataNodeCounts map[string]int, operation, query string) (context.Context, *statementMetrics) {
+	m := &statementMetrics{
+		metrics:        metrics,
+		dataNodeCounts: dataNodeCounts,
+		keyword:        statementKeyword(query),
+		routeType:      monitoring.RouteSingleShard,
+		start:          time.Now(),
+	}
+	ctx, m.span = tracer.Start(ctx, "sharding."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrStatementType.String(m.statementType()),
+			attrSQLFingerprint.String(sqlFingerprint(query)),
+		))
+	return ctx, m
+}
+
+// parse 在解析阶段的 span 中解析语句并返回涉及的逻辑表
+func (m *statementMetrics) parse(ctx context.Context, parse func() ([]string, error)) ([]string, error) {
+	_, span := startPhaseSpan(ctx, "sharding.parse")
+	logicTables, err := parse()
+	endSpan(span, err)
+
+	m.logicTable = strings.Join(logicTables, ",")
+	m.span.SetAttributes(attrLogicTables.String(m.logicTable))
+	return logicTables, err
+}
+
+// routed 记录语句的路由结果，不涉及分片表的语句按单分片记录
+func (m *statementMetrics) routed(stmt *routedStatement) {
+	if len(stmt.logicTables) == 0 {
+		return
+	}
+
+	routes := stmt.rewriteContext.RouteResults
+	m.actualTables = actualTablesByDataSource(routes)
+	m.routeType = m.classifyRoute(stmt.logicTables, len(routes))
+	m.metrics.RecordShardingRoute(len(routes))
+	m.span.SetAttributes(attrRouteType.String(string(m.routeType)), attrShardCount.Int(len(routes)))
+}
+
+// classifyRoute 按路由到的数据节点数量识别路由类型
+func (m *statementMetrics) classifyRoute(logicTables []string, routeCount int) monitoring.RouteType {
+	if routeCount <= 1 {
+		return monitoring.RouteSingleShard
+	}
+	if ddlKeywords[m.keyword] {
+		return monitoring.RouteBroadcast
+	}
+
+	total := 0
+	for _, logicTable := range logicTables {
+		total += m.dataNodeCounts[logicTable]
+	}
+	if routeCount >= total {
+		return monitoring.RouteFullRoute
+	}
+	return monitoring.RouteMultiShard
+}
+
+// statementType 获取小写的语句类型，无法识别时为 other
+func (m *statementMetrics) statementType() string {
+	if m.keyword == "" {
+		return "other"
+	}
+	return strings.ToLower(m.keyword)
+}
+
+// labels 获取数据源上执行单元的标签，dataSource 为空时表示语句在路由前失败
+func (m *statementMetrics) labels(dataSource string) monitoring.StatementLabels {
+	return monitoring.StatementLabels{
+		LogicTable:    m.logicTable,
+		DataSource:    dataSource,
+		ActualTable:   m.actualTables[dataSource],
+		StatementType: m.statementType(),
+		RouteType:     m.routeType,
+	}
+}
+
+// statementUnit 一个执行单元的指标和 span
+type statementUnit struct {
+	statement  *statementMetrics
+	dataSource string
+	start      time.Time
+	span       trace.Span
+}
+
+// startUnit 开始在数据源上执行一个执行单元，返回的上下文携带执行单元的 span
+func (m *statementMetrics) startUnit(ctx context.Context, dataSource, sql string) (context.Context, *statementUnit) {
+	ctx, span := startPhaseSpan(ctx, "sharding.execute",
+		attrDataSource.String(dataSource),
+		attrActualTable.String(m.actualTables[dataSource]),
+		attrSQLFingerprint.String(sqlFingerprint(sql)),
+	)
+	return ctx, &statementUnit{statement: m, dataSource: dataSource, start: time.Now(), span: span}
+}
+
+// end 记录执行单元的耗时、影响的行数和错误，查询的行数在关闭结果集时记录
+func (u *statementUnit) end(rows int64, err error) {
+	m := u.statement
+	m.metrics.RecordStatement(m.labels(u.dataSource), time.Since(u.start), rows, err)
+	if err == nil && rows > 0 {
+		u.span.SetAttributes(attrRows.Int64(rows))
+	}
+	endSpan(u.span, err)
+}
+
+// merge 在合并阶段的 span 中合并各执行单元的结果
+func (m *statementMetrics) merge(ctx context.Context, merge func()) {
+	_, span := startPhaseSpan(ctx, "sharding.merge")
+	merge()
+	span.End()
+}
+
+// routeFailed 记录路由或重写失败的语句并返回 err
+func (m *statementMetrics) routeFailed(err error) error {
+	m.metrics.RecordStatement(m.labels(""), time.Since(m.start), 0, err)
+	return m.finish(0, err)
+}
+
+// finish 记录整条逻辑语句的耗时、行数和错误并结束父 span，返回 err 以便在返回语句中直接调用
+func (m *statementMetrics) finish(rows int64, err error) error {
+	m.metrics.RecordQuery(time.Since(m.start), err)
+	if err == nil {
+		m.span.SetAttributes(attrRows.Int64(rows))
+	}
+	endSpan(m.span, err)
+	return err
+}
+
+// finishQuery 记录执行成功的查询，返回关闭结果集时调用的回调
+// 回调在 dataSource 上记录读取的行数并结束父 span，因此查询的父 span 覆盖读取结果集的时间
+func (m *statementMetrics) finishQuery(dataSource string) func(rows int64) {
+	m.metrics.RecordQuery(time.Since(m.start), nil)
+	labels := m.labels(dataSource)
+	return func(rows int64) {
+		m.metrics.RecordStatementRows(labels, rows)
+		m.span.SetAttributes(attrRows.Int64(rows))
+		m.span.End()
+	}
+}
+
+// actualTablesByDataSource 按数据源汇总路由到的实际表
+func actualTablesByDataSource(routes []*routing.RouteResult) map[string]string {
+	grouped := make(map[string][]string)
+	for _, route := range routes {
+		if !slices.Contains(grouped[route.DataSource], route.Table) {
+			grouped[route.DataSource] = append(grouped[route.DataSource], route.Table)
+		}
+	}
+
+	actualTables := make(map[string]string, len(grouped))
+	for dataSource, tables := range grouped {
+		sort.Strings(tables)
+		actualTables[dataSource] = strings.Join(tables, ",")
+	}
+	return actualTables
+}
+
+// dataNodeCounts 获取各逻辑表的实际数据节点数量
+func dataNodeCounts(router *routing.ShardingRouter, tables map[string]*config.TableRuleConfig) (map[string]int, error) {
+	counts := make(map[string]int, len(tables))
+	for logicTable := range tables {
+		dataNodes, err := router.ActualDataNodes(logicTable)
+		if err != nil {
+			return nil, err
+		}
+		counts[logicTable] = len(dataNodes)
+	}
+	return counts, nil
+}
@@ -0,0 +1,158 @@
+package sharding
+
+import (
+	"context"
+	"go-sharding/pkg/monitoring"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sumMetric 汇总名称相同且包含 labels 中所有标签的计数器
+func sumMetric(metrics *monitoring.ShardingMetrics, name string, labels map[string]string) int64 {
+	var sum int64
+	for _, metric := range metrics.GetCollector().GetAllMetrics() {
+		if metric.GetName() != name {
+			continue
+		}
+		matched := true
+		for label, value := range labels {
+			if metric.GetLabels()[label] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			sum += metric.GetValue().(int64)
+		}
+	}
+	return sum
+}
+
+func TestShardingDB_StatementMetrics(t *testing.T) {
+	db, ds0, _ := newRecordingShardingDB(t, "recording")
+	metrics := db.dataSource.Metrics()
+	ctx := context.Background()
+
+	// 单分片更新
+	_, err := db.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	single := map[string]string{
+		"logic_table":    "t_order",
+		"data_source":    "ds_1",
+		"actual_table":   "t_order_1",
+		"statement_type": "update",
+		"route_type":     "single-shard",
+	}
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_total", single))
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_rows_total", single))
+	histogram := metrics.GetCollector().GetMetric("sharding_statement_duration_seconds", single)
+	require.NotNil(t, histogram)
+	assert.Equal(t, int64(1), histogram.GetValue().(map[string]interface{})["count"])
+
+	// 没有分片条件的查询路由到所有数据节点，返回的行数在关闭结果集时记录
+	rows, err := db.QueryContext(ctx, "SELECT * FROM t_order")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	fullRoute := map[string]string{"statement_type": "select", "route_type": "full-route"}
+	assert.Equal(t, int64(2), sumMetric(metrics, "sharding_statement_total", fullRoute))
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_rows_total", fullRoute))
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_total", map[string]string{
+		"data_source": "ds_0", "actual_table": "t_order_0,t_order_1", "route_type": "full-route",
+	}))
+
+	// DDL 广播到所有数据节点
+	_, err = db.ExecContext(ctx, "ALTER TABLE t_order ADD COLUMN remark VARCHAR(64)")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), sumMetric(metrics, "sharding_statement_total", map[string]string{"statement_type": "alter", "route_type": "broadcast"}))
+
+	// 执行失败按数据源记录错误
+	ds0.mu.Lock()
+	ds0.failOn = "UPDATE"
+	ds0.mu.Unlock()
+	_, err = db.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.Error(t, err)
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_errors_total", map[string]string{"data_source": "ds_0", "actual_table": "t_order_0"}))
+
+	// 路由失败的语句没有数据源
+	_, err = db.ExecContext(ctx, "UPDATE t_order SET status = 'PAID' WHERE order_id = ?", 3)
+	require.Error(t, err)
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_errors_total", map[string]string{"data_source": "", "logic_table": "t_order"}))
+
+	// 逻辑语句的全局指标
+	assert.Equal(t, int64(5), metrics.QueryTotal.GetValue())
+	assert.Equal(t, int64(2), metrics.QueryErrors.GetValue())
+	assert.Equal(t, int64(4), metrics.ShardingRoutes.GetValue())
+	assert.Equal(t, int64(2), metrics.CrossShardQueries.GetValue())
+}
+
+func TestShardingTx_StatementMetrics(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	metrics := db.dataSource.Metrics()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+
+	assert.Equal(t, int64(1), sumMetric(metrics, "sharding_statement_total", map[string]string{
+		"data_source": "ds_1", "actual_table": "t_order_1", "statement_type": "update", "route_type": "single-shard",
+	}))
+}
+
+func TestStatementMetrics_ClassifyRoute(t *testing.T) {
+	counts := map[string]int{"t_order": 4, "t_order_item": 4}
+	tests := []struct {
+		name        string
+		query       string
+		logicTables []string
+		routeCount  int
+		expected    monitoring.RouteType
+	}{
+		{"single shard", "SELECT * FROM t_order", []string{"t_order"}, 1, monitoring.RouteSingleShard},
+		{"multi shard", "SELECT * FROM t_order", []string{"t_order"}, 2, monitoring.RouteMultiShard},
+		{"full route", "SELECT * FROM t_order", []string{"t_order"}, 4, monitoring.RouteFullRoute},
+		{"join multi shard", "SELECT * FROM t_order JOIN t_order_item", []string{"t_order", "t_order_item"}, 4, monitoring.RouteMultiShard},
+		{"join full route", "SELECT * FROM t_order JOIN t_order_item", []string{"t_order", "t_order_item"}, 8, monitoring.RouteFullRoute},
+		{"broadcast", "TRUNCATE TABLE t_order", []string{"t_order"}, 4, monitoring.RouteBroadcast},
+		{"single node ddl", "TRUNCATE TABLE t_order", []string{"t_order"}, 1, monitoring.RouteSingleShard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, m := newStatementMetrics(context.Background(), tracerFrom(nil), monitoring.NewShardingMetrics(), counts, "query", tt.query)
+			assert.Equal(t, tt.expected, m.classifyRoute(tt.logicTables, tt.routeCount))
+		})
+	}
+}
+
+func TestEnhancedShardingDB_StatementMetrics(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	db, err := NewEnhancedShardingDB(newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "UPDATE t_order SET status = 'PAID'")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT * FROM t_order")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+
+	// 读写分离组按组名记录数据源
+	for _, group := range []string{"ds_0", "ds_1"} {
+		assert.Equal(t, int64(1), sumMetric(db.Metrics(), "sharding_statement_rows_total", map[string]string{
+			"logic_table": "t_order", "data_source": group, "actual_table": "t_order", "statement_type": "update", "route_type": "full-route",
+		}))
+	}
+	assert.Equal(t, int64(2), sumMetric(db.Metrics(), "sharding_statement_total", map[string]string{"statement_type": "select", "route_type": "full-route"}))
+	assert.Equal(t, int64(1), sumMetric(db.Metrics(), "sharding_statement_rows_total", map[string]string{"statement_type": "select"}))
+	assert.Equal(t, int64(2), db.Metrics().QueryTotal.GetValue())
+}
@@ -0,0 +1,83 @@
+package sharding
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 分片数据源创建 span 使用的 tracer 名称
+const tracerName = "go-sharding/pkg/sharding"
+
+// span 属性
+const (
+	// attrLogicTables 语句涉及的逻辑表，多个逻辑表用逗号分隔
+	attrLogicTables = attribute.Key("sharding.logic_tables")
+	// attrStatementType 语句类型，如 select、update
+	attrStatementType = attribute.Key("sharding.statement_type")
+	// attrRouteType 路由类型
+	attrRouteType = attribute.Key("sharding.route_type")
+	// attrShardCount 路由到的数据节点数量
+	attrShardCount = attribute.Key("sharding.shard_count")
+	// attrDataSource 执行单元所在的数据源
+	attrDataSource = attribute.Key("sharding.data_source")
+	// attrActualTable 执行单元访问的实际表，多个实际表用逗号分隔
+	attrActualTable = attribute.Key("sharding.actual_table")
+	// attrSQLFingerprint 去掉字面量后的 SQL 指纹
+	attrSQLFingerprint = attribute.Key("sharding.sql.fingerprint")
+	// attrRows 返回或影响的行数
+	attrRows = attribute.Key("sharding.rows")
+)
+
+var (
+	// stringLiteralPattern SQL 字符串字面量
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	// numberLiteralPattern 数字字面量，不匹配标识符中的数字和 $n 占位符
+	numberLiteralPattern = regexp.MustCompile(`(^|[^\w$.])-?\d+(?:\.\d+)?`)
+	// placeholderListPattern 多个占位符组成的列表，如 IN (?, ?, ?)
+	placeholderListPattern = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	// whitespacePattern 连续的空白字符
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// sqlFingerprint 生成 SQL 指纹：字面量替换为 ?，占位符列表合并为 (?+)，连续空白合并为一个空格，
+// 参数不同的同一条语句得到相同的指纹，且不会在 span 中暴露参数值
+func sqlFingerprint(query string) string {
+	fingerprint := stringLiteralPattern.ReplaceAllString(query, "?")
+	fingerprint = numberLiteralPattern.ReplaceAllString(fingerprint, "${1}?")
+	fingerprint = placeholderListPattern.ReplaceAllString(fingerprint, "(?+)")
+	fingerprint = whitespacePattern.ReplaceAllString(fingerprint, " ")
+	return strings.TrimSpace(fingerprint)
+}
+
+// tracerFrom 获取 tracer，provider 为 nil 时使用全局 TracerProvider，未设置全局 TracerProvider 时不记录 span
+func tracerFrom(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// startPhaseSpan 在语句的父 span 下开始一个阶段的 span，上下文中没有语句 span 时不记录
+func startPhaseSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	return parent.TracerProvider().Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束 span，err 不为空时记录错误并将状态设置为 Error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
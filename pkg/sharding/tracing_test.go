@@ -0,0 +1,225 @@
+package sharding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTracingExporter 创建同步导出到内存的 TracerProvider
+func newTracingExporter(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return provider, exporter
+}
+
+// spansByName 按名称分组导出的 span
+func spansByName(exporter *tracetest.InMemoryExporter) map[string][]tracetest.SpanStub {
+	grouped := make(map[string][]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		grouped[span.Name] = append(grouped[span.Name], span)
+	}
+	return grouped
+}
+
+// spanAttribute 获取 span 的属性值
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestShardingDB_TracingQuery(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	provider, exporter := newTracingExporter(t)
+	db.dataSource.SetTracerProvider(provider)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM t_order WHERE status = 'NEW'")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+
+	// 父 span 在关闭结果集时结束
+	spans := spansByName(exporter)
+	assert.Empty(t, spans["sharding.query"])
+	require.NoError(t, rows.Close())
+	spans = spansByName(exporter)
+
+	require.Len(t, spans["sharding.query"], 1)
+	parent := spans["sharding.query"][0]
+	for _, name := range []string{"sharding.parse", "sharding.route", "sharding.rewrite", "sharding.merge"} {
+		require.Len(t, spans[name], 1, name)
+		assert.Equal(t, parent.SpanContext.SpanID(), spans[name][0].Parent.SpanID(), name)
+		assert.Equal(t, parent.SpanContext.TraceID(), spans[name][0].SpanContext.TraceID(), name)
+	}
+
+	fingerprint, _ := spanAttribute(parent, attrSQLFingerprint)
+	assert.Equal(t, "SELECT * FROM t_order WHERE status = ?", fingerprint.AsString())
+	routeType, _ := spanAttribute(parent, attrRouteType)
+	assert.Equal(t, "full-route", routeType.AsString())
+	shardCount, _ := spanAttribute(spans["sharding.route"][0], attrShardCount)
+	assert.Equal(t, int64(4), shardCount.AsInt64())
+	rowCount, _ := spanAttribute(parent, attrRows)
+	assert.Equal(t, int64(1), rowCount.AsInt64())
+
+	// 每个数据源一个执行单元
+	var dataSources []string
+	for _, span := range spans["sharding.execute"] {
+		assert.Equal(t, parent.SpanContext.SpanID(), span.Parent.SpanID())
+		dataSource, _ := spanAttribute(span, attrDataSource)
+		dataSources = append(dataSources, dataSource.AsString())
+		actualTable, _ := spanAttribute(span, attrActualTable)
+		assert.Equal(t, "t_order_0,t_order_1", actualTable.AsString())
+		fingerprint, _ := spanAttribute(span, attrSQLFingerprint)
+		assert.NotContains(t, fingerprint.AsString(), "NEW")
+	}
+	assert.ElementsMatch(t, []string{"ds_0", "ds_1"}, dataSources)
+}
+
+func TestShardingDB_TracingExec(t *testing.T) {
+	db, ds0, _ := newRecordingShardingDB(t, "recording")
+	provider, exporter := newTracingExporter(t)
+	db.dataSource.SetTracerProvider(provider)
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+
+	spans := spansByName(exporter)
+	require.Len(t, spans["sharding.exec"], 1)
+	require.Len(t, spans["sharding.execute"], 1)
+	parent := spans["sharding.exec"][0]
+	execute := spans["sharding.execute"][0]
+	assert.Equal(t, parent.SpanContext.SpanID(), execute.Parent.SpanID())
+
+	statementType, _ := spanAttribute(parent, attrStatementType)
+	assert.Equal(t, "update", statementType.AsString())
+	logicTables, _ := spanAttribute(parent, attrLogicTables)
+	assert.Equal(t, "t_order", logicTables.AsString())
+	dataSource, _ := spanAttribute(execute, attrDataSource)
+	assert.Equal(t, "ds_1", dataSource.AsString())
+	rowCount, _ := spanAttribute(execute, attrRows)
+	assert.Equal(t, int64(1), rowCount.AsInt64())
+	assert.Equal(t, codes.Unset, parent.Status.Code)
+
+	// 执行失败时执行单元和父 span 都记录错误
+	exporter.Reset()
+	ds0.mu.Lock()
+	ds0.failOn = "UPDATE"
+	ds0.mu.Unlock()
+	_, err = db.ExecContext(ctx, updateOrderSQL, 2, 4)
+	require.Error(t, err)
+
+	spans = spansByName(exporter)
+	require.Len(t, spans["sharding.execute"], 1)
+	assert.Equal(t, codes.Error, spans["sharding.execute"][0].Status.Code)
+	require.Len(t, spans["sharding.exec"], 1)
+	assert.Equal(t, codes.Error, spans["sharding.exec"][0].Status.Code)
+	require.NotEmpty(t, spans["sharding.exec"][0].Events)
+	assert.Equal(t, "exception", spans["sharding.exec"][0].Events[0].Name)
+	assert.Empty(t, spans["sharding.merge"])
+
+	// 路由失败时在路由阶段的 span 上记录错误
+	exporter.Reset()
+	_, err = db.ExecContext(ctx, "UPDATE t_order SET status = 'PAID' WHERE order_id = ?", 3)
+	require.Error(t, err)
+
+	spans = spansByName(exporter)
+	require.Len(t, spans["sharding.route"], 1)
+	assert.Equal(t, codes.Error, spans["sharding.route"][0].Status.Code)
+	assert.Empty(t, spans["sharding.execute"])
+}
+
+func TestShardingTx_Tracing(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	provider, exporter := newTracingExporter(t)
+	db.dataSource.SetTracerProvider(provider)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+
+	spans := spansByName(exporter)
+	require.Len(t, spans["sharding.exec"], 1)
+	require.Len(t, spans["sharding.execute"], 1)
+	dataSource, _ := spanAttribute(spans["sharding.execute"][0], attrDataSource)
+	assert.Equal(t, "ds_1", dataSource.AsString())
+}
+
+func TestEnhancedShardingDB_Tracing(t *testing.T) {
+	prefix := "recording://" + t.Name() + "/"
+	db, err := NewEnhancedShardingDB(newReadWriteGroupConfig(prefix, "ds_${0..1}.t_order"))
+	require.NoError(t, err)
+	defer db.Close()
+	provider, exporter := newTracingExporter(t)
+	db.SetTracerProvider(provider)
+
+	_, err = db.ExecContext(context.Background(), "UPDATE t_order SET status = 'PAID'")
+	require.NoError(t, err)
+
+	spans := spansByName(exporter)
+	require.Len(t, spans["sharding.exec"], 1)
+	parent := spans["sharding.exec"][0]
+	for _, name := range []string{"sharding.parse", "sharding.route", "sharding.rewrite", "sharding.merge"} {
+		require.Len(t, spans[name], 1, name)
+		assert.Equal(t, parent.SpanContext.SpanID(), spans[name][0].Parent.SpanID(), name)
+	}
+
+	// 读写分离组按组名记录数据源
+	var dataSources []string
+	for _, span := range spans["sharding.execute"] {
+		dataSource, _ := spanAttribute(span, attrDataSource)
+		dataSources = append(dataSources, dataSource.AsString())
+	}
+	assert.ElementsMatch(t, []string{"ds_0", "ds_1"}, dataSources)
+	rowCount, _ := spanAttribute(parent, attrRows)
+	assert.Equal(t, int64(2), rowCount.AsInt64())
+}
+
+func TestShardingDB_TracingDisabled(t *testing.T) {
+	db, _, _ := newRecordingShardingDB(t, "recording")
+	_, exporter := newTracingExporter(t)
+
+	// 没有设置 TracerProvider 时使用全局的空实现，不记录 span
+	_, err := db.ExecContext(context.Background(), updateOrderSQL, 1, 3)
+	require.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans())
+}
+
+func TestSQLFingerprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"placeholders", "SELECT * FROM t_order WHERE user_id = ?", "SELECT * FROM t_order WHERE user_id = ?"},
+		{"string literal", "UPDATE t_order SET status = 'PAID' WHERE order_id = 3", "UPDATE t_order SET status = ? WHERE order_id = ?"},
+		{"escaped quote", "SELECT * FROM t_user WHERE name = 'O''Brien'", "SELECT * FROM t_user WHERE name = ?"},
+		{"negative and decimal", "SELECT * FROM t_order WHERE amount > -1.5", "SELECT * FROM t_order WHERE amount > ?"},
+		{"digits in identifiers", "SELECT * FROM t_order_1 WHERE col2 = 7", "SELECT * FROM t_order_1 WHERE col2 = ?"},
+		{"postgres placeholders", "SELECT * FROM t_order WHERE user_id = $1", "SELECT * FROM t_order WHERE user_id = $1"},
+		{"in list", "SELECT * FROM t_order WHERE order_id IN (1, 2, 3)", "SELECT * FROM t_order WHERE order_id IN (?+)"},
+		{"whitespace", "SELECT *\n\tFROM  t_order ", "SELECT * FROM t_order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sqlFingerprint(tt.query))
+		})
+	}
+}
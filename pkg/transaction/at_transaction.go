@@ -0,0 +1,135 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ATTransactionImpl AT 模式事务实现（自动补偿的 BASE 事务）
+// 每个分支在本地事务中执行 DML 并同时写入 undo log，全局回滚时根据 undo log 自动生成补偿
+type ATTransactionImpl struct {
+	id          string
+	status      TransactionStatus
+	manager     *UndoLogManager
+	dataSources []string
+	mu          sync.RWMutex
+	startTime   time.Time
+}
+
+// NewATTransaction 创建 AT 事务
+func NewATTransaction(id string, manager *UndoLogManager) *ATTransactionImpl {
+	return &ATTransactionImpl{
+		id:        id,
+		status:    StatusActive,
+		manager:   manager,
+		startTime: time.Now(),
+	}
+}
+
+// Begin 开始 AT 事务
+func (t *ATTransactionImpl) Begin(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != StatusActive {
+		return fmt.Errorf("AT transaction %s is not in active status", t.id)
+	}
+	return nil
+}
+
+// ExecuteUnit 在分支本地事务中执行 DML 并记录 undo log
+func (t *ATTransactionImpl) ExecuteUnit(ctx context.Context, tx *sql.Tx, unit *ATExecutionUnit) (sql.Result, error) {
+	t.mu.Lock()
+	if t.status != StatusActive {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("AT transaction %s is not in active status", t.id)
+	}
+	if !containsString(t.dataSources, unit.DataSource) {
+		t.dataSources = append(t.dataSources, unit.DataSource)
+	}
+	t.mu.Unlock()
+
+	return t.manager.ExecuteUnit(ctx, tx, t.id, unit)
+}
+
+// Commit 提交 AT 事务，分支已在本地提交，这里只清理 undo log
+func (t *ATTransactionImpl) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != StatusActive {
+		return fmt.Errorf("AT transaction %s is not in active status", t.id)
+	}
+
+	t.status = StatusCommitted
+
+	var errs []error
+	for _, ds := range t.dataSources {
+		if err := t.manager.Purge(ctx, t.id, ds); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// undo log 清理失败不影响提交结果，残留日志不会再被使用
+	return errors.Join(errs...)
+}
+
+// Rollback 回滚 AT 事务，按分支逆序执行补偿
+func (t *ATTransactionImpl) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status == StatusCommitted {
+		return fmt.Errorf("AT transaction %s is already committed", t.id)
+	}
+
+	for i := len(t.dataSources) - 1; i >= 0; i-- {
+		if err := t.manager.Compensate(ctx, t.id, t.dataSources[i]); err != nil {
+			t.status = StatusFailed
+			return fmt.Errorf("failed to rollback AT transaction %s: %w", t.id, err)
+		}
+	}
+
+	t.status = StatusRolledBack
+	return nil
+}
+
+// GetStatus 获取事务状态
+func (t *ATTransactionImpl) GetStatus() TransactionStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
+
+// GetID 获取事务 ID
+func (t *ATTransactionImpl) GetID() string {
+	return t.id
+}
+
+// GetType 获取事务类型
+func (t *ATTransactionImpl) GetType() TransactionType {
+	return ATTransaction
+}
+
+// GetDataSources 获取参与事务的数据源
+func (t *ATTransactionImpl) GetDataSources() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	dataSources := make([]string, len(t.dataSources))
+	copy(dataSources, t.dataSources)
+	return dataSources
+}
+
+// containsString 检查字符串切片是否包含指定元素
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
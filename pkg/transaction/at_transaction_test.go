@@ -0,0 +1,282 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"go-sharding/pkg/database"
+	"go-sharding/pkg/internal/sqltest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedLog 记录模拟数据库上执行过的语句
+type scriptedLog struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (l *scriptedLog) executed() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.statements...)
+}
+
+// openScriptedDB 打开按 handler 返回预设结果的模拟数据库，并记录所有执行过的语句
+func openScriptedDB(t *testing.T, handler func(query string, args []driver.Value) sqltest.Result) (*sql.DB, *scriptedLog) {
+	log := &scriptedLog{}
+	db := sqltest.Open(t, "", func(dsn, query string, args []driver.Value) sqltest.Result {
+		log.mu.Lock()
+		log.statements = append(log.statements, query)
+		log.mu.Unlock()
+		if handler == nil {
+			return sqltest.Result{Affected: 1}
+		}
+		return handler(query, args)
+	})
+	db.SetMaxOpenConns(1)
+	return db, log
+}
+
+var orderColumns = []string{"id", "user_id", "status"}
+
+func TestUndoLogManager_ExecuteUnitAndCompensate(t *testing.T) {
+	var undoLogInfo string
+	currentStatus := "NEW"
+
+	db, d := openScriptedDB(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT * FROM t_order_0"):
+			return sqltest.Result{Columns: orderColumns, Rows: [][]driver.Value{{int64(1), int64(10), []byte(currentStatus)}}}
+		case strings.HasPrefix(query, "UPDATE t_order_0 SET status = ? WHERE user_id"):
+			currentStatus = asString(args[0])
+			return sqltest.Result{Affected: 1}
+		case strings.HasPrefix(query, "UPDATE t_order_0 SET"):
+			currentStatus = asString(args[1])
+			return sqltest.Result{Affected: 1}
+		case strings.HasPrefix(query, "INSERT INTO undo_log"):
+			undoLogInfo = args[2].(string)
+			return sqltest.Result{Affected: 1}
+		case strings.HasPrefix(query, "SELECT rollback_info FROM undo_log"):
+			return sqltest.Result{Columns: []string{"rollback_info"}, Rows: [][]driver.Value{{undoLogInfo}}}
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	manager := NewUndoLogManager(map[string]*sql.DB{"ds_0": db}, "mysql")
+	tx := NewATTransaction("at-tx-1", manager)
+	ctx := context.Background()
+	require.NoError(t, tx.Begin(ctx))
+
+	localTx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	result, err := tx.ExecuteUnit(ctx, localTx, &ATExecutionUnit{
+		DataSource:            "ds_0",
+		TableName:             "t_order_0",
+		SQLType:               "UPDATE",
+		SQL:                   "UPDATE t_order_0 SET status = ? WHERE user_id = ?",
+		Parameters:            []interface{}{"PAID", 10},
+		BeforeImageSQL:        "SELECT * FROM t_order_0 WHERE user_id = ? FOR UPDATE",
+		BeforeImageParameters: []interface{}{10},
+		PrimaryKeys:           []string{"id"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, localTx.Commit())
+
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, "PAID", currentStatus)
+	assert.Contains(t, undoLogInfo, `"sqlType":"UPDATE"`)
+	assert.Equal(t, []string{"ds_0"}, tx.GetDataSources())
+
+	require.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, StatusRolledBack, tx.GetStatus())
+	assert.Equal(t, "NEW", currentStatus)
+
+	statements := d.executed()
+	assert.Contains(t, statements, "UPDATE t_order_0 SET user_id = ?, status = ? WHERE id = ?")
+	assert.Contains(t, statements, "DELETE FROM undo_log WHERE xid = ?")
+}
+
+func TestUndoLogManager_RejectsPrimaryKeyUpdate(t *testing.T) {
+	db, d := openScriptedDB(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT * FROM t_order_0 WHERE user_id"):
+			return sqltest.Result{Columns: []string{"id", "user_id"}, Rows: [][]driver.Value{{int64(1), int64(10)}}}
+		case strings.HasPrefix(query, "SELECT * FROM t_order_0 WHERE (id"):
+			// 主键已被修改为 2，按原主键查不到后镜像
+			return sqltest.Result{Columns: []string{"id", "user_id"}}
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	manager := NewUndoLogManager(map[string]*sql.DB{"ds_0": db}, "mysql")
+	ctx := context.Background()
+	localTx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = manager.ExecuteUnit(ctx, localTx, "at-tx-5", &ATExecutionUnit{
+		DataSource:            "ds_0",
+		TableName:             "t_order_0",
+		SQLType:               "UPDATE",
+		SQL:                   "UPDATE t_order_0 SET id = ? WHERE user_id = ?",
+		Parameters:            []interface{}{2, 10},
+		BeforeImageSQL:        "SELECT * FROM t_order_0 WHERE user_id = ? FOR UPDATE",
+		BeforeImageParameters: []interface{}{10},
+		PrimaryKeys:           []string{"id"},
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPrimaryKeyUpdated))
+	require.NoError(t, localTx.Rollback())
+
+	for _, statement := range d.executed() {
+		assert.False(t, strings.HasPrefix(statement, "INSERT INTO undo_log"), statement)
+	}
+}
+
+func TestUndoLogManager_CompensateDetectsDirtyWrite(t *testing.T) {
+	undoLog := &UndoLog{
+		XID:         "at-tx-2",
+		BranchID:    "ds_0",
+		TableName:   "t_order_0",
+		SQLType:     "UPDATE",
+		PrimaryKeys: []string{"id"},
+		BeforeImage: &TableImage{TableName: "t_order_0", Rows: []Row{{Fields: []Field{
+			NewField("id", int64(1)), NewField("status", "NEW"),
+		}}}},
+		AfterImage: &TableImage{TableName: "t_order_0", Rows: []Row{{Fields: []Field{
+			NewField("id", int64(1)), NewField("status", "PAID"),
+		}}}},
+	}
+	info := mustMarshalUndoLog(t, undoLog)
+
+	db, d := openScriptedDB(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT rollback_info"):
+			return sqltest.Result{Columns: []string{"rollback_info"}, Rows: [][]driver.Value{{info}}}
+		case strings.HasPrefix(query, "SELECT * FROM t_order_0"):
+			// 其他事务已将状态改为 SHIPPED
+			return sqltest.Result{Columns: []string{"id", "status"}, Rows: [][]driver.Value{{int64(1), "SHIPPED"}}}
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	manager := NewUndoLogManager(map[string]*sql.DB{"ds_0": db}, "mysql")
+	err := manager.Compensate(context.Background(), "at-tx-2", "ds_0")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDirtyWrite))
+
+	statements := d.executed()
+	assert.Contains(t, statements, "ROLLBACK")
+	assert.NotContains(t, statements, "DELETE FROM undo_log WHERE xid = ?")
+}
+
+func TestUndoLogManager_BuildCompensation(t *testing.T) {
+	manager := NewUndoLogManager(nil, "postgresql")
+	before := &TableImage{TableName: "t_order_1", Rows: []Row{{Fields: []Field{
+		NewField("id", int64(7)), NewField("user_id", int64(11)), NewField("status", "NEW"),
+	}}}}
+
+	statements, err := manager.BuildCompensation(&UndoLog{
+		TableName:   "t_order_1",
+		SQLType:     "UPDATE",
+		PrimaryKeys: []string{"id"},
+		BeforeImage: before,
+	})
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, "UPDATE t_order_1 SET user_id = $1, status = $2 WHERE id = $3", statements[0].SQL)
+	assert.Equal(t, []interface{}{int64(11), "NEW", int64(7)}, statements[0].Parameters)
+
+	statements, err = manager.BuildCompensation(&UndoLog{
+		TableName:   "t_order_1",
+		SQLType:     "DELETE",
+		PrimaryKeys: []string{"id"},
+		BeforeImage: before,
+	})
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, "INSERT INTO t_order_1 (id, user_id, status) VALUES ($1, $2, $3)", statements[0].SQL)
+}
+
+func TestUndoLogManager_DatabaseTypePerDataSource(t *testing.T) {
+	mysqlDB, mysqlDriver := openScriptedDB(t, nil)
+	pgDB, pgDriver := openScriptedDB(t, nil)
+	manager := NewUndoLogManager(map[string]*sql.DB{"ds_mysql": mysqlDB, "ds_pg": pgDB}, database.MySQL)
+	manager.SetDatabaseType("ds_pg", database.PostgreSQL)
+	ctx := context.Background()
+
+	require.NoError(t, manager.Purge(ctx, "at-tx-4", "ds_mysql"))
+	require.NoError(t, manager.Purge(ctx, "at-tx-4", "ds_pg"))
+	assert.Contains(t, mysqlDriver.executed(), "DELETE FROM undo_log WHERE xid = ?")
+	assert.Contains(t, pgDriver.executed(), "DELETE FROM undo_log WHERE xid = $1")
+
+	statements, err := manager.BuildCompensation(&UndoLog{
+		BranchID:    "ds_pg",
+		TableName:   "t_order_1",
+		SQLType:     "DELETE",
+		PrimaryKeys: []string{"id"},
+		BeforeImage: &TableImage{TableName: "t_order_1", Rows: []Row{{Fields: []Field{NewField("id", int64(7))}}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, "INSERT INTO t_order_1 (id) VALUES ($1)", statements[0].SQL)
+	assert.Contains(t, manager.CreateTableSQL("ds_pg"), "BIGSERIAL")
+	assert.Contains(t, manager.CreateTableSQL("ds_mysql"), "AUTO_INCREMENT")
+}
+
+func TestTransactionManager_ATDatabaseTypes(t *testing.T) {
+	pgDB, pgDriver := openScriptedDB(t, nil)
+	tm := NewTransactionManager()
+	require.NoError(t, tm.RegisterDataSourceWithType("ds_pg", pgDB, database.PostgreSQL))
+
+	tx, err := tm.Begin(context.Background(), ATTransaction)
+	require.NoError(t, err)
+	atTx := tx.(*ATTransactionImpl)
+	atTx.dataSources = []string{"ds_pg"}
+	require.NoError(t, atTx.Commit(context.Background()))
+	assert.Contains(t, pgDriver.executed(), "DELETE FROM undo_log WHERE xid = $1")
+}
+
+func TestField_RoundTrip(t *testing.T) {
+	values := []interface{}{nil, int64(1 << 60), uint64(1 << 63), 3.25, true, "text", []byte{0, 1, 2}}
+	for _, value := range values {
+		field := NewField("col", value)
+		restored, err := field.SQLValue()
+		require.NoError(t, err)
+		assert.Equal(t, value, restored)
+	}
+}
+
+func TestATTransaction_Commit(t *testing.T) {
+	db, d := openScriptedDB(t, nil)
+	manager := NewUndoLogManager(map[string]*sql.DB{"ds_0": db}, "mysql")
+	tx := NewATTransaction("at-tx-3", manager)
+	tx.dataSources = []string{"ds_0"}
+
+	require.NoError(t, tx.Commit(context.Background()))
+	assert.Equal(t, StatusCommitted, tx.GetStatus())
+	assert.Equal(t, ATTransaction, tx.GetType())
+	assert.Contains(t, d.executed(), "DELETE FROM undo_log WHERE xid = ?")
+
+	assert.Error(t, tx.Rollback(context.Background()))
+}
+
+func mustMarshalUndoLog(t *testing.T, undoLog *UndoLog) string {
+	data, err := json.Marshal(undoLog)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// asString 将驱动参数转换为字符串（MySQL 驱动以 []byte 返回文本列）
+func asString(value driver.Value) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value.(string)
+}
@@ -0,0 +1,172 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-sharding/pkg/monitoring"
+)
+
+const (
+	// MetricTransactionsReaped 被回收的过期事务数
+	MetricTransactionsReaped = "sharding_transactions_reaped_total"
+	// MetricTransactionReapErrors 回收过期事务失败次数
+	MetricTransactionReapErrors = "sharding_transaction_reap_errors_total"
+	// MetricTransactionsActive 当前活跃事务数
+	MetricTransactionsActive = "sharding_transactions_active"
+)
+
+// defaultReapInterval 默认回收间隔
+const defaultReapInterval = 10 * time.Second
+
+// Expirable 可判断是否过期的事务
+type Expirable interface {
+	// IsExpired 检查事务是否过期
+	IsExpired() bool
+}
+
+// TransactionReaper 事务回收器
+// 定期扫描事务管理器，回滚过期的活跃事务；AT 与 BASE 事务的回滚会触发补偿
+type TransactionReaper struct {
+	manager   *TransactionManagerImpl
+	interval  time.Duration
+	collector *monitoring.MetricsCollector
+	reaped    map[TransactionType]*monitoring.CounterMetric
+	errors    *monitoring.CounterMetric
+	active    *monitoring.GaugeMetric
+	running   bool
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	mu        sync.Mutex
+}
+
+// NewTransactionReaper 创建事务回收器，interval 不大于 0 时使用默认间隔，collector 为 nil 时使用独立的收集器
+func NewTransactionReaper(manager *TransactionManagerImpl, interval time.Duration, collector *monitoring.MetricsCollector) *TransactionReaper {
+	if interval <= 0 {
+		interval = defaultReapInterval
+	}
+	if collector == nil {
+		collector = monitoring.NewMetricsCollector()
+	}
+
+	r := &TransactionReaper{
+		manager:   manager,
+		interval:  interval,
+		collector: collector,
+		reaped:    make(map[TransactionType]*monitoring.CounterMetric),
+		errors:    monitoring.NewCounterMetric(MetricTransactionReapErrors, map[string]string{}),
+		active:    monitoring.NewGaugeMetric(MetricTransactionsActive, map[string]string{}),
+	}
+	collector.RegisterMetric(r.errors)
+	collector.RegisterMetric(r.active)
+
+	for _, txType := range []TransactionType{LocalTransaction, XATransaction, BaseTransaction, ATTransaction} {
+		counter := monitoring.NewCounterMetric(MetricTransactionsReaped, map[string]string{"type": transactionTypeName(txType)})
+		collector.RegisterMetric(counter)
+		r.reaped[txType] = counter
+	}
+	return r
+}
+
+// Start 启动后台回收协程
+func (r *TransactionReaper) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return fmt.Errorf("transaction reaper is already running")
+	}
+
+	r.running = true
+	r.stopCh = make(chan struct{})
+	r.doneCh = make(chan struct{})
+	go r.run(ctx, r.stopCh, r.doneCh)
+	return nil
+}
+
+// Stop 停止后台回收协程并等待其退出
+func (r *TransactionReaper) Stop() error {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return nil
+	}
+	r.running = false
+	close(r.stopCh)
+	doneCh := r.doneCh
+	r.mu.Unlock()
+
+	<-doneCh
+	return nil
+}
+
+// run 定期执行回收
+func (r *TransactionReaper) run(ctx context.Context, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			r.ReapOnce(ctx)
+		}
+	}
+}
+
+// ReapOnce 执行一次回收，返回成功回滚的过期事务数
+func (r *TransactionReaper) ReapOnce(ctx context.Context) int {
+	expired, active := r.manager.sweep(time.Now())
+
+	// 回收器停止时不应中断正在进行的补偿
+	rollbackCtx := context.WithoutCancel(ctx)
+
+	reaped := 0
+	for _, tx := range expired {
+		if err := tx.Rollback(rollbackCtx); err != nil {
+			r.errors.Inc()
+			// 回滚失败但仍处于活跃状态的事务留待下次回收重试
+			if tx.GetStatus() == StatusActive {
+				active++
+			}
+			continue
+		}
+
+		r.manager.forget(tx.GetID())
+		if counter, exists := r.reaped[tx.GetType()]; exists {
+			counter.Inc()
+		}
+		reaped++
+	}
+
+	r.active.Set(float64(active))
+	return reaped
+}
+
+// GetCollector 获取指标收集器
+func (r *TransactionReaper) GetCollector() *monitoring.MetricsCollector {
+	return r.collector
+}
+
+// transactionTypeName 获取事务类型在指标标签中的名称
+func transactionTypeName(txType TransactionType) string {
+	switch txType {
+	case LocalTransaction:
+		return "local"
+	case XATransaction:
+		return "xa"
+	case BaseTransaction:
+		return "base"
+	case ATTransaction:
+		return "at"
+	default:
+		return "unknown"
+	}
+}
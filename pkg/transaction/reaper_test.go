@@ -0,0 +1,126 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionReaper_ReapOnce(t *testing.T) {
+	db, d := openScriptedDB(t, nil)
+	tm := NewTransactionManager()
+	require.NoError(t, tm.RegisterDataSource("ds_0", db))
+	ctx := context.Background()
+
+	// 本地事务的开始时间会被改写为超过管理器超时时间
+	expiredLocal, err := tm.Begin(ctx, LocalTransaction)
+	require.NoError(t, err)
+
+	// 自身已过期的 BASE 事务
+	expiredBase := NewBASETransaction("base-tx-1")
+	expiredBase.SetTimeout(time.Nanosecond)
+	tm.Track(expiredBase)
+
+	// 已提交的事务只会被清理，不计入回收
+	committed := NewBASETransaction("base-tx-2")
+	committed.status = StatusCommitted
+	tm.Track(committed)
+
+	// 未超时的事务保持活跃
+	alive := NewBASETransaction("base-tx-3")
+	tm.Track(alive)
+
+	tm.SetTransactionTimeout(time.Hour)
+	tm.startTimes[expiredLocal.GetID()] = time.Now().Add(-2 * time.Hour)
+
+	reaper := NewTransactionReaper(tm, time.Minute, nil)
+	assert.Equal(t, 2, reaper.ReapOnce(ctx))
+
+	assert.Equal(t, StatusRolledBack, expiredLocal.GetStatus())
+	assert.Contains(t, d.executed(), "ROLLBACK")
+	assert.Nil(t, tm.GetTransaction(WithTransaction(ctx, expiredLocal)))
+	assert.Nil(t, tm.GetTransaction(WithTransaction(ctx, expiredBase)))
+	assert.Nil(t, tm.GetTransaction(WithTransaction(ctx, committed)))
+	assert.Same(t, alive, tm.GetTransaction(WithTransaction(ctx, alive)))
+	assert.Equal(t, StatusActive, alive.GetStatus())
+
+	collector := reaper.GetCollector()
+	assert.Equal(t, int64(1), collector.GetMetric(MetricTransactionsReaped, map[string]string{"type": "local"}).GetValue())
+	assert.Equal(t, int64(1), collector.GetMetric(MetricTransactionsReaped, map[string]string{"type": "base"}).GetValue())
+	assert.Equal(t, int64(0), collector.GetMetric(MetricTransactionReapErrors, map[string]string{}).GetValue())
+	assert.Equal(t, float64(1), collector.GetMetric(MetricTransactionsActive, map[string]string{}).GetValue())
+}
+
+func TestTransactionReaper_StartStop(t *testing.T) {
+	tm := NewTransactionManager()
+	tx := NewBASETransaction("base-tx-1")
+	tx.SetTimeout(time.Nanosecond)
+	tm.Track(tx)
+
+	reaper := NewTransactionReaper(tm, time.Millisecond, nil)
+	require.NoError(t, reaper.Start(context.Background()))
+	assert.Error(t, reaper.Start(context.Background()))
+
+	assert.Eventually(t, func() bool {
+		return tm.GetTransaction(WithTransaction(context.Background(), tx)) == nil
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, reaper.Stop())
+	require.NoError(t, reaper.Stop())
+}
+
+// blockingStatusTx 读取状态时阻塞到 unblock 关闭的事务，模拟正在执行语句的分片事务
+type blockingStatusTx struct {
+	*BASETransactionImpl
+	reading chan struct{}
+	unblock chan struct{}
+}
+
+func (tx *blockingStatusTx) GetStatus() TransactionStatus {
+	select {
+	case tx.reading <- struct{}{}:
+	default:
+	}
+	<-tx.unblock
+	return tx.BASETransactionImpl.GetStatus()
+}
+
+func TestTransactionReaper_SweepDoesNotHoldLock(t *testing.T) {
+	tm := NewTransactionManager()
+	busy := &blockingStatusTx{
+		BASETransactionImpl: NewBASETransaction("base-tx-1"),
+		reading:             make(chan struct{}, 1),
+		unblock:             make(chan struct{}),
+	}
+	tm.Track(busy)
+
+	reaper := NewTransactionReaper(tm, time.Minute, nil)
+	done := make(chan int)
+	go func() { done <- reaper.ReapOnce(context.Background()) }()
+	select {
+	case <-busy.reading:
+	case <-time.After(time.Second):
+		t.Fatal("reaper did not read the transaction status")
+	}
+
+	// 读取状态阻塞时仍可以跟踪和获取其他事务
+	other := NewBASETransaction("base-tx-2")
+	tracked := make(chan struct{})
+	go func() {
+		tm.Track(other)
+		close(tracked)
+	}()
+	select {
+	case <-tracked:
+	case <-time.After(time.Second):
+		t.Fatal("Track blocked while the reaper was reading a transaction status")
+	}
+	assert.Same(t, other, tm.GetTransaction(WithTransaction(context.Background(), other)))
+
+	close(busy.unblock)
+	assert.Equal(t, 0, <-done)
+	assert.Same(t, busy, tm.GetTransaction(WithTransaction(context.Background(), busy)))
+}
@@ -3,8 +3,12 @@ package transaction
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"go-sharding/pkg/database"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,6 +22,8 @@ const (
 	XATransaction
 	// BaseTransaction BASE 柔性事务
 	BaseTransaction
+	// ATTransaction AT 模式事务（基于 undo log 自动补偿的 BASE 事务）
+	ATTransaction
 )
 
 // TransactionStatus 事务状态
@@ -184,11 +190,14 @@ type XATransactionImpl struct {
 }
 
 // XABranch XA 事务分支
+// 通过 StartBranch 创建的分支持有独占连接 Conn，并在该连接上执行数据库原生的两阶段提交语句
 type XABranch struct {
-	ID         string
-	DataSource string
-	Tx         *sql.Tx
-	Status     TransactionStatus
+	ID           string
+	DataSource   string
+	Tx           *sql.Tx
+	Conn         *sql.Conn
+	DatabaseType database.DatabaseType
+	Status       TransactionStatus
 }
 
 // XACoordinator XA 事务协调器
@@ -244,14 +253,20 @@ func (t *XATransactionImpl) Commit(ctx context.Context) error {
 	t.status = StatusPrepared
 
 	// 第二阶段：提交
+	var errs []error
 	for _, branch := range t.branches {
 		if err := t.commitBranch(ctx, branch); err != nil {
-			// 提交失败，记录错误但继续尝试其他分支
-			// 在实际实现中，这里需要更复杂的错误处理和恢复机制
+			// 提交失败，记录错误但继续尝试其他分支，已准备的分支可由数据库 XA RECOVER 恢复
+			errs = append(errs, fmt.Errorf("failed to commit branch %s: %w", branch.ID, err))
 			continue
 		}
 	}
 
+	if len(errs) > 0 {
+		t.status = StatusFailed
+		return errors.Join(errs...)
+	}
+
 	t.status = StatusCommitted
 	return nil
 }
@@ -270,18 +285,70 @@ func (t *XATransactionImpl) Rollback(ctx context.Context) error {
 	return nil
 }
 
+// StartBranch 在独占连接上开启 XA 事务分支
+// MySQL 使用 XA START，PostgreSQL 使用 BEGIN 并在准备阶段执行 PREPARE TRANSACTION
+func (t *XATransactionImpl) StartBranch(ctx context.Context, dataSource string, conn *sql.Conn, dbType database.DatabaseType) (*XABranch, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != StatusActive {
+		return nil, fmt.Errorf("XA transaction %s is not in active status", t.id)
+	}
+
+	branch := &XABranch{
+		ID:           fmt.Sprintf("%s_%s", t.id, dataSource),
+		DataSource:   dataSource,
+		Conn:         conn,
+		DatabaseType: dbType,
+		Status:       StatusActive,
+	}
+
+	var query string
+	switch dbType {
+	case database.MySQL:
+		query = "XA START " + quoteXID(branch.ID)
+	case database.PostgreSQL:
+		query = "BEGIN"
+	default:
+		return nil, fmt.Errorf("XA transaction is not supported for database type %s", dbType)
+	}
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to start XA branch %s: %w", branch.ID, err)
+	}
+
+	t.branches[branch.ID] = branch
+	return branch, nil
+}
+
 // prepareBranch 准备分支事务
 func (t *XATransactionImpl) prepareBranch(ctx context.Context, branch *XABranch) error {
-	// 这里应该执行 XA PREPARE 命令
-	// 简化实现，实际需要调用数据库的 XA 接口
+	if branch.Conn != nil {
+		var statements []string
+		switch branch.DatabaseType {
+		case database.PostgreSQL:
+			statements = []string{"PREPARE TRANSACTION " + quoteXID(branch.ID)}
+		default:
+			statements = []string{"XA END " + quoteXID(branch.ID), "XA PREPARE " + quoteXID(branch.ID)}
+		}
+		if err := execStatements(ctx, branch.Conn, statements); err != nil {
+			return err
+		}
+	}
 	branch.Status = StatusPrepared
 	return nil
 }
 
 // commitBranch 提交分支事务
 func (t *XATransactionImpl) commitBranch(ctx context.Context, branch *XABranch) error {
-	// 这里应该执行 XA COMMIT 命令
-	if branch.Tx != nil {
+	if branch.Conn != nil {
+		query := "XA COMMIT " + quoteXID(branch.ID)
+		if branch.DatabaseType == database.PostgreSQL {
+			query = "COMMIT PREPARED " + quoteXID(branch.ID)
+		}
+		if _, err := branch.Conn.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	} else if branch.Tx != nil {
 		err := branch.Tx.Commit()
 		if err != nil {
 			return err
@@ -294,13 +361,48 @@ func (t *XATransactionImpl) commitBranch(ctx context.Context, branch *XABranch)
 // rollbackAllBranches 回滚所有分支事务
 func (t *XATransactionImpl) rollbackAllBranches(ctx context.Context) {
 	for _, branch := range t.branches {
-		if branch.Tx != nil {
+		if branch.Conn != nil {
+			// 分支可能已执行过 XA END，逐条执行并忽略单条语句的失败
+			for _, statement := range xaRollbackStatements(branch) {
+				branch.Conn.ExecContext(ctx, statement)
+			}
+		} else if branch.Tx != nil {
 			branch.Tx.Rollback()
 		}
 		branch.Status = StatusRolledBack
 	}
 }
 
+// xaRollbackStatements 根据分支状态生成回滚语句
+func xaRollbackStatements(branch *XABranch) []string {
+	xid := quoteXID(branch.ID)
+	if branch.DatabaseType == database.PostgreSQL {
+		if branch.Status == StatusPrepared {
+			return []string{"ROLLBACK PREPARED " + xid}
+		}
+		return []string{"ROLLBACK"}
+	}
+	if branch.Status == StatusPrepared {
+		return []string{"XA ROLLBACK " + xid}
+	}
+	return []string{"XA END " + xid, "XA ROLLBACK " + xid}
+}
+
+// execStatements 在连接上依次执行语句
+func execStatements(ctx context.Context, conn *sql.Conn, statements []string) error {
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// quoteXID 将 XA 事务标识转为 SQL 字符串字面量
+func quoteXID(xid string) string {
+	return "'" + strings.ReplaceAll(xid, "'", "''") + "'"
+}
+
 // GetStatus 获取事务状态
 func (t *XATransactionImpl) GetStatus() TransactionStatus {
 	t.mu.RLock()
@@ -334,8 +436,12 @@ func (t *XATransactionImpl) AddBranch(branchID, dataSource string, tx *sql.Tx) {
 // TransactionManagerImpl 事务管理器实现
 type TransactionManagerImpl struct {
 	dataSources   map[string]*sql.DB
+	dataSourceTypes map[string]database.DatabaseType
 	transactions  map[string]Transaction
 	xaCoordinator *XACoordinator
+	undoLogManager *UndoLogManager
+	startTimes    map[string]time.Time
+	timeout       time.Duration
 	mu            sync.RWMutex
 }
 
@@ -343,7 +449,9 @@ type TransactionManagerImpl struct {
 func NewTransactionManager() *TransactionManagerImpl {
 	return &TransactionManagerImpl{
 		dataSources:  make(map[string]*sql.DB),
+		dataSourceTypes: make(map[string]database.DatabaseType),
 		transactions: make(map[string]Transaction),
+		startTimes:   make(map[string]time.Time),
 		xaCoordinator: &XACoordinator{
 			transactions: make(map[string]*XATransactionImpl),
 		},
@@ -377,6 +485,15 @@ func (tm *TransactionManagerImpl) Begin(ctx context.Context, txType TransactionT
 	case BaseTransaction:
 		// BASE 事务的实现
 		tx = NewBASETransaction(txID)
+	case ATTransaction:
+		if tm.undoLogManager == nil {
+			// 未指定数据库类型的数据源按 MySQL 处理
+			tm.undoLogManager = NewUndoLogManager(tm.dataSources, database.MySQL)
+			for name, dbType := range tm.dataSourceTypes {
+				tm.undoLogManager.SetDatabaseType(name, dbType)
+			}
+		}
+		tx = NewATTransaction(txID, tm.undoLogManager)
 	default:
 		return nil, fmt.Errorf("unsupported transaction type: %v", txType)
 	}
@@ -387,19 +504,109 @@ func (tm *TransactionManagerImpl) Begin(ctx context.Context, txType TransactionT
 	}
 
 	tm.transactions[txID] = tx
+	tm.startTimes[txID] = time.Now()
 	return tx, nil
 }
 
+// Track 跟踪由外部创建的事务（如分片事务），使其可以通过上下文获取并受超时回收约束
+func (tm *TransactionManagerImpl) Track(tx Transaction) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.transactions[tx.GetID()] = tx
+	tm.startTimes[tx.GetID()] = time.Now()
+}
+
+// SetTransactionTimeout 设置事务超时时间，0 表示只依据事务自身的过期判断
+func (tm *TransactionManagerImpl) SetTransactionTimeout(timeout time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.timeout = timeout
+}
+
+// sweep 清理已结束的事务，并返回仍处于活跃状态但已过期的事务
+// 读取事务状态可能等待正在执行的语句（如分片事务），因此只在持有锁时复制事务列表，读取状态时不持有锁
+func (tm *TransactionManagerImpl) sweep(now time.Time) (expired []Transaction, active int) {
+	type tracked struct {
+		tx        Transaction
+		startTime time.Time
+	}
+
+	tm.mu.RLock()
+	timeout := tm.timeout
+	snapshot := make([]tracked, 0, len(tm.transactions))
+	for id, tx := range tm.transactions {
+		snapshot = append(snapshot, tracked{tx: tx, startTime: tm.startTimes[id]})
+	}
+	tm.mu.RUnlock()
+
+	var finished []Transaction
+	for _, t := range snapshot {
+		status := t.tx.GetStatus()
+		if status != StatusActive && status != StatusPrepared {
+			finished = append(finished, t.tx)
+			continue
+		}
+
+		// 已准备的事务正在提交或等待协调，不在回收范围内
+		if status == StatusActive && isExpired(t.tx, t.startTime, timeout, now) {
+			expired = append(expired, t.tx)
+			continue
+		}
+		active++
+	}
+
+	if len(finished) > 0 {
+		tm.mu.Lock()
+		for _, tx := range finished {
+			// 复制列表之后同一 ID 可能已被重新跟踪，只删除原来的事务
+			if tm.transactions[tx.GetID()] == tx {
+				delete(tm.transactions, tx.GetID())
+				delete(tm.startTimes, tx.GetID())
+			}
+		}
+		tm.mu.Unlock()
+	}
+	return expired, active
+}
+
+// isExpired 判断事务是否超过管理器的超时时间或自身的过期时间，timeout 为 0 时只依据事务自身的过期判断
+func isExpired(tx Transaction, startTime time.Time, timeout time.Duration, now time.Time) bool {
+	if expirable, ok := tx.(Expirable); ok && expirable.IsExpired() {
+		return true
+	}
+	return timeout > 0 && !startTime.IsZero() && now.Sub(startTime) > timeout
+}
+
+// forget 停止跟踪事务
+func (tm *TransactionManagerImpl) forget(txID string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	delete(tm.transactions, txID)
+	delete(tm.startTimes, txID)
+}
+
+// SetUndoLogManager 设置 AT 事务使用的 undo 日志管理器
+func (tm *TransactionManagerImpl) SetUndoLogManager(manager *UndoLogManager) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.undoLogManager = manager
+}
+
 // GetTransaction 获取当前事务
 func (tm *TransactionManagerImpl) GetTransaction(ctx context.Context) Transaction {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
-	// 从上下文中获取事务 ID
-	if txID, ok := ctx.Value("transaction_id").(string); ok {
-		return tm.transactions[txID]
+	tx := GetTransactionFromContext(ctx)
+	if tx == nil {
+		return nil
 	}
-	return nil
+	// 只返回由当前管理器跟踪的事务
+	return tm.transactions[tx.GetID()]
 }
 
 // RegisterDataSource 注册数据源
@@ -411,6 +618,19 @@ func (tm *TransactionManagerImpl) RegisterDataSource(name string, db *sql.DB) er
 	return nil
 }
 
+// RegisterDataSourceWithType 注册数据源并指定其数据库类型，AT 事务按该类型读写 undo log
+func (tm *TransactionManagerImpl) RegisterDataSourceWithType(name string, db *sql.DB, dbType database.DatabaseType) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.dataSources[name] = db
+	tm.dataSourceTypes[name] = dbType
+	if tm.undoLogManager != nil {
+		tm.undoLogManager.SetDatabaseType(name, dbType)
+	}
+	return nil
+}
+
 // Close 关闭事务管理器
 func (tm *TransactionManagerImpl) Close() error {
 	tm.mu.Lock()
@@ -431,9 +651,17 @@ func (tm *TransactionManagerImpl) Close() error {
 	return nil
 }
 
+// transactionSeq 事务 ID 序号，避免同一纳秒内生成重复 ID
+var transactionSeq uint64
+
 // generateTransactionID 生成事务 ID
 func generateTransactionID() string {
-	return fmt.Sprintf("tx_%d", time.Now().UnixNano())
+	return fmt.Sprintf("tx_%d_%d", time.Now().UnixNano(), atomic.AddUint64(&transactionSeq, 1))
+}
+
+// GenerateTransactionID 生成全局唯一的事务 ID
+func GenerateTransactionID() string {
+	return generateTransactionID()
 }
 
 // TransactionContext 事务上下文
@@ -444,15 +672,26 @@ type TransactionContext struct {
 	Timeout       time.Duration
 }
 
+// transactionContextKey 事务在上下文中的键类型，避免与其他包的字符串键冲突
+type transactionContextKey struct{}
+
 // WithTransaction 在上下文中设置事务
 func WithTransaction(ctx context.Context, tx Transaction) context.Context {
-	return context.WithValue(ctx, "transaction_id", tx.GetID())
+	return context.WithValue(ctx, transactionContextKey{}, tx)
 }
 
-// GetTransactionFromContext 从上下文中获取事务 ID
-func GetTransactionFromContext(ctx context.Context) string {
-	if txID, ok := ctx.Value("transaction_id").(string); ok {
-		return txID
+// GetTransactionFromContext 从上下文中获取事务
+func GetTransactionFromContext(ctx context.Context) Transaction {
+	if tx, ok := ctx.Value(transactionContextKey{}).(Transaction); ok {
+		return tx
+	}
+	return nil
+}
+
+// GetTransactionIDFromContext 从上下文中获取事务 ID
+func GetTransactionIDFromContext(ctx context.Context) string {
+	if tx := GetTransactionFromContext(ctx); tx != nil {
+		return tx.GetID()
 	}
 	return ""
-}
\ No newline at end of file
+}
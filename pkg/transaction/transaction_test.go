@@ -389,18 +389,19 @@ func TestWithTransaction(t *testing.T) {
 	ctxWithTx := WithTransaction(ctx, tx)
 	assert.NotNil(t, ctxWithTx)
 
-	txID := GetTransactionFromContext(ctxWithTx)
-	assert.Equal(t, "test-tx-1", txID)
+	assert.Same(t, tx, GetTransactionFromContext(ctxWithTx))
+	assert.Equal(t, "test-tx-1", GetTransactionIDFromContext(ctxWithTx))
 }
 
 func TestGetTransactionFromContext(t *testing.T) {
 	ctx := context.Background()
-	txID := GetTransactionFromContext(ctx)
-	assert.Empty(t, txID)
+	assert.Nil(t, GetTransactionFromContext(ctx))
+	assert.Empty(t, GetTransactionIDFromContext(ctx))
 
-	ctxWithTx := context.WithValue(ctx, "transaction_id", "test-tx-1")
-	txID = GetTransactionFromContext(ctxWithTx)
-	assert.Equal(t, "test-tx-1", txID)
+	// 字符串键不会被当作事务
+	ctxWithID := context.WithValue(ctx, "transaction_id", "test-tx-1")
+	assert.Nil(t, GetTransactionFromContext(ctxWithID))
+	assert.Empty(t, GetTransactionIDFromContext(ctxWithID))
 }
 
 func TestLocalTransaction_Concurrent(t *testing.T) {
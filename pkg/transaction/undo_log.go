@@ -0,0 +1,583 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-sharding/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultUndoLogTable 默认 undo log 表名
+const DefaultUndoLogTable = "undo_log"
+
+// ErrDirtyWrite 补偿时发现数据已被其他事务修改
+var ErrDirtyWrite = errors.New("dirty write detected")
+
+// ErrPrimaryKeyUpdated AT 模式不支持修改主键列的 UPDATE 语句
+var ErrPrimaryKeyUpdated = errors.New("AT mode does not support updating primary key columns")
+
+// 字段值类型
+const (
+	FieldTypeNull   = "null"
+	FieldTypeInt    = "int"
+	FieldTypeUint   = "uint"
+	FieldTypeFloat  = "float"
+	FieldTypeBool   = "bool"
+	FieldTypeString = "string"
+	FieldTypeBytes  = "bytes"
+	FieldTypeTime   = "time"
+)
+
+// Field 行镜像中的字段，值统一编码为字符串以避免 JSON 数字精度丢失
+type Field struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// Row 行镜像
+type Row struct {
+	Fields []Field `json:"fields"`
+}
+
+// TableImage 表镜像（前镜像或后镜像）
+type TableImage struct {
+	TableName string `json:"tableName"`
+	Rows      []Row  `json:"rows"`
+}
+
+// UndoLog undo 日志，记录 AT 模式下单条 DML 的前后镜像
+type UndoLog struct {
+	XID         string      `json:"xid"`
+	BranchID    string      `json:"branchId"`
+	TableName   string      `json:"tableName"`
+	SQLType     string      `json:"sqlType"`
+	PrimaryKeys []string    `json:"primaryKeys"`
+	BeforeImage *TableImage `json:"beforeImage"`
+	AfterImage  *TableImage `json:"afterImage"`
+}
+
+// ATExecutionUnit AT 模式执行单元
+type ATExecutionUnit struct {
+	DataSource            string
+	TableName             string
+	SQLType               string // UPDATE, DELETE
+	SQL                   string
+	Parameters            []interface{}
+	BeforeImageSQL        string
+	BeforeImageParameters []interface{}
+	PrimaryKeys           []string
+}
+
+// UndoLogManager undo 日志管理器
+type UndoLogManager struct {
+	dataSources map[string]*sql.DB
+	dbType      database.DatabaseType
+	dbTypes     map[string]database.DatabaseType
+	tableName   string
+}
+
+// NewUndoLogManager 创建 undo 日志管理器，dbType 为没有通过 SetDatabaseType 指定类型的数据源的数据库类型
+func NewUndoLogManager(dataSources map[string]*sql.DB, dbType database.DatabaseType) *UndoLogManager {
+	return &UndoLogManager{
+		dataSources: dataSources,
+		dbType:      dbType,
+		dbTypes:     make(map[string]database.DatabaseType),
+		tableName:   DefaultUndoLogTable,
+	}
+}
+
+// SetDatabaseType 设置数据源的数据库类型，undo log 的读写和补偿语句按该类型生成
+func (m *UndoLogManager) SetDatabaseType(dataSource string, dbType database.DatabaseType) {
+	m.dbTypes[dataSource] = dbType
+}
+
+// databaseType 获取数据源的数据库类型
+func (m *UndoLogManager) databaseType(dataSource string) database.DatabaseType {
+	if dbType, exists := m.dbTypes[dataSource]; exists {
+		return dbType
+	}
+	return m.dbType
+}
+
+// SetTableName 设置 undo log 表名
+func (m *UndoLogManager) SetTableName(tableName string) {
+	m.tableName = tableName
+}
+
+// CreateTableSQL 获取数据源上 undo log 表的建表语句，需要在每个分片上执行
+func (m *UndoLogManager) CreateTableSQL(dataSource string) string {
+	if m.databaseType(dataSource) == database.PostgreSQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id BIGSERIAL PRIMARY KEY,
+    xid VARCHAR(128) NOT NULL,
+    branch_id VARCHAR(128) NOT NULL,
+    rollback_info TEXT NOT NULL,
+    log_status INT NOT NULL,
+    log_created TIMESTAMP NOT NULL
+)`, m.tableName)
+	}
+
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    xid VARCHAR(128) NOT NULL,
+    branch_id VARCHAR(128) NOT NULL,
+    rollback_info LONGTEXT NOT NULL,
+    log_status INT NOT NULL,
+    log_created DATETIME(6) NOT NULL,
+    KEY idx_undo_log_xid (xid)
+)`, m.tableName)
+}
+
+// ExecuteUnit 在分支本地事务中执行 DML，并在同一本地事务中写入 undo log
+func (m *UndoLogManager) ExecuteUnit(ctx context.Context, tx *sql.Tx, xid string, unit *ATExecutionUnit) (sql.Result, error) {
+	sqlType := strings.ToUpper(unit.SQLType)
+	if sqlType != "UPDATE" && sqlType != "DELETE" {
+		return nil, fmt.Errorf("AT mode does not support %s statements", unit.SQLType)
+	}
+	if len(unit.PrimaryKeys) == 0 {
+		return nil, fmt.Errorf("primary keys are required for table %s in AT mode", unit.TableName)
+	}
+
+	beforeImage, err := captureImage(ctx, tx, unit.TableName, unit.BeforeImageSQL, unit.BeforeImageParameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture before image on %s: %w", unit.DataSource, err)
+	}
+
+	result, err := tx.ExecContext(ctx, unit.SQL, unit.Parameters...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(beforeImage.Rows) == 0 {
+		return result, nil
+	}
+
+	afterImage := &TableImage{TableName: unit.TableName}
+	if sqlType == "UPDATE" {
+		query, args, err := m.buildSelectByPrimaryKeys(m.databaseType(unit.DataSource), unit.TableName, unit.PrimaryKeys, beforeImage, false)
+		if err != nil {
+			return nil, err
+		}
+		afterImage, err = captureImage(ctx, tx, unit.TableName, query, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture after image on %s: %w", unit.DataSource, err)
+		}
+		// 后镜像按前镜像的主键查询，主键被修改时查不到对应的行，补偿时会误判为脏写
+		if len(afterImage.Rows) != len(beforeImage.Rows) {
+			return nil, fmt.Errorf("%w: table %s", ErrPrimaryKeyUpdated, unit.TableName)
+		}
+	}
+
+	undoLog := &UndoLog{
+		XID:         xid,
+		BranchID:    unit.DataSource,
+		TableName:   unit.TableName,
+		SQLType:     sqlType,
+		PrimaryKeys: unit.PrimaryKeys,
+		BeforeImage: beforeImage,
+		AfterImage:  afterImage,
+	}
+	if err := m.insertUndoLog(ctx, tx, undoLog); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Purge 全局提交后清理指定数据源上的 undo log
+func (m *UndoLogManager) Purge(ctx context.Context, xid, dataSource string) error {
+	db, exists := m.dataSources[dataSource]
+	if !exists {
+		return fmt.Errorf("data source %s not found", dataSource)
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE xid = %s", m.tableName, placeholder(m.databaseType(dataSource), 1))
+	if _, err := db.ExecContext(ctx, query, xid); err != nil {
+		return fmt.Errorf("failed to purge undo log on %s: %w", dataSource, err)
+	}
+	return nil
+}
+
+// Compensate 全局回滚时根据 undo log 逆序补偿指定数据源上的修改
+func (m *UndoLogManager) Compensate(ctx context.Context, xid, dataSource string) error {
+	db, exists := m.dataSources[dataSource]
+	if !exists {
+		return fmt.Errorf("data source %s not found", dataSource)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin compensation on %s: %w", dataSource, err)
+	}
+
+	if err := m.compensate(ctx, tx, xid, m.databaseType(dataSource)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("compensation failed on %s: %w", dataSource, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit compensation on %s: %w", dataSource, err)
+	}
+	return nil
+}
+
+// compensate 在补偿事务中应用 undo log
+func (m *UndoLogManager) compensate(ctx context.Context, tx *sql.Tx, xid string, dbType database.DatabaseType) error {
+	query := fmt.Sprintf("SELECT rollback_info FROM %s WHERE xid = %s ORDER BY id DESC FOR UPDATE", m.tableName, placeholder(dbType, 1))
+	rows, err := tx.QueryContext(ctx, query, xid)
+	if err != nil {
+		return fmt.Errorf("failed to load undo log: %w", err)
+	}
+
+	var undoLogs []*UndoLog
+	for rows.Next() {
+		var info string
+		if err := rows.Scan(&info); err != nil {
+			rows.Close()
+			return err
+		}
+		undoLog := &UndoLog{}
+		if err := json.Unmarshal([]byte(info), undoLog); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to decode undo log: %w", err)
+		}
+		undoLogs = append(undoLogs, undoLog)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, undoLog := range undoLogs {
+		if err := m.applyUndoLog(ctx, tx, dbType, undoLog); err != nil {
+			return err
+		}
+	}
+
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE xid = %s", m.tableName, placeholder(dbType, 1))
+	if _, err := tx.ExecContext(ctx, deleteSQL, xid); err != nil {
+		return fmt.Errorf("failed to delete undo log: %w", err)
+	}
+	return nil
+}
+
+// applyUndoLog 校验脏写并执行单条 undo log 的补偿语句
+func (m *UndoLogManager) applyUndoLog(ctx context.Context, tx *sql.Tx, dbType database.DatabaseType, undoLog *UndoLog) error {
+	query, args, err := m.buildSelectByPrimaryKeys(dbType, undoLog.TableName, undoLog.PrimaryKeys, undoLog.BeforeImage, true)
+	if err != nil {
+		return err
+	}
+	currentImage, err := captureImage(ctx, tx, undoLog.TableName, query, args)
+	if err != nil {
+		return fmt.Errorf("failed to capture current image of %s: %w", undoLog.TableName, err)
+	}
+
+	if imagesEqual(currentImage, undoLog.BeforeImage) {
+		// 数据已经是前镜像状态，无需补偿
+		return nil
+	}
+	if !imagesEqual(currentImage, undoLog.AfterImage) {
+		return fmt.Errorf("%w on table %s for transaction %s", ErrDirtyWrite, undoLog.TableName, undoLog.XID)
+	}
+
+	statements, err := m.buildCompensation(dbType, undoLog)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt.SQL, stmt.Parameters...); err != nil {
+			return fmt.Errorf("failed to execute compensation on %s: %w", undoLog.TableName, err)
+		}
+	}
+	return nil
+}
+
+// CompensationStatement 补偿语句
+type CompensationStatement struct {
+	SQL        string
+	Parameters []interface{}
+}
+
+// BuildCompensation 根据 undo log 生成补偿语句，语句按 undo log 所在分支数据源的数据库类型生成
+// UPDATE 补偿为按主键回写前镜像，DELETE 补偿为重新插入前镜像
+func (m *UndoLogManager) BuildCompensation(undoLog *UndoLog) ([]*CompensationStatement, error) {
+	return m.buildCompensation(m.databaseType(undoLog.BranchID), undoLog)
+}
+
+// buildCompensation 按数据库类型生成补偿语句
+func (m *UndoLogManager) buildCompensation(dbType database.DatabaseType, undoLog *UndoLog) ([]*CompensationStatement, error) {
+	var statements []*CompensationStatement
+
+	for _, row := range undoLog.BeforeImage.Rows {
+		switch undoLog.SQLType {
+		case "UPDATE":
+			var setClauses, whereClauses []string
+			var setArgs, whereArgs []interface{}
+			for _, field := range row.Fields {
+				value, err := field.SQLValue()
+				if err != nil {
+					return nil, err
+				}
+				if containsIgnoreCase(undoLog.PrimaryKeys, field.Name) {
+					whereArgs = append(whereArgs, value)
+					whereClauses = append(whereClauses, field.Name+" = %s")
+				} else {
+					setArgs = append(setArgs, value)
+					setClauses = append(setClauses, field.Name+" = %s")
+				}
+			}
+			if len(whereClauses) != len(undoLog.PrimaryKeys) {
+				return nil, fmt.Errorf("primary key values missing in undo log of table %s", undoLog.TableName)
+			}
+			if len(setClauses) == 0 {
+				continue
+			}
+
+			index := 0
+			bind := func(clauses []string) []string {
+				bound := make([]string, len(clauses))
+				for i, clause := range clauses {
+					index++
+					bound[i] = fmt.Sprintf(clause, placeholder(dbType, index))
+				}
+				return bound
+			}
+			sql := fmt.Sprintf("UPDATE %s SET %s WHERE %s", undoLog.TableName,
+				strings.Join(bind(setClauses), ", "), strings.Join(bind(whereClauses), " AND "))
+			statements = append(statements, &CompensationStatement{
+				SQL:        sql,
+				Parameters: append(setArgs, whereArgs...),
+			})
+		case "DELETE":
+			var columns, placeholders []string
+			var args []interface{}
+			for i, field := range row.Fields {
+				value, err := field.SQLValue()
+				if err != nil {
+					return nil, err
+				}
+				columns = append(columns, field.Name)
+				placeholders = append(placeholders, placeholder(dbType, i+1))
+				args = append(args, value)
+			}
+			sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", undoLog.TableName,
+				strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+			statements = append(statements, &CompensationStatement{SQL: sql, Parameters: args})
+		default:
+			return nil, fmt.Errorf("unsupported undo log type: %s", undoLog.SQLType)
+		}
+	}
+
+	return statements, nil
+}
+
+// insertUndoLog 写入 undo log
+func (m *UndoLogManager) insertUndoLog(ctx context.Context, tx *sql.Tx, undoLog *UndoLog) error {
+	info, err := json.Marshal(undoLog)
+	if err != nil {
+		return fmt.Errorf("failed to encode undo log: %w", err)
+	}
+
+	dbType := m.databaseType(undoLog.BranchID)
+	query := fmt.Sprintf("INSERT INTO %s (xid, branch_id, rollback_info, log_status, log_created) VALUES (%s, %s, %s, %s, %s)",
+		m.tableName, placeholder(dbType, 1), placeholder(dbType, 2), placeholder(dbType, 3), placeholder(dbType, 4), placeholder(dbType, 5))
+	if _, err := tx.ExecContext(ctx, query, undoLog.XID, undoLog.BranchID, string(info), 0, time.Now()); err != nil {
+		return fmt.Errorf("failed to insert undo log: %w", err)
+	}
+	return nil
+}
+
+// buildSelectByPrimaryKeys 根据镜像中的主键值生成查询语句
+func (m *UndoLogManager) buildSelectByPrimaryKeys(dbType database.DatabaseType, tableName string, primaryKeys []string, image *TableImage, forUpdate bool) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	for _, row := range image.Rows {
+		var rowConditions []string
+		for _, pk := range primaryKeys {
+			field, ok := row.field(pk)
+			if !ok {
+				return "", nil, fmt.Errorf("primary key %s not found in image of table %s", pk, tableName)
+			}
+			value, err := field.SQLValue()
+			if err != nil {
+				return "", nil, err
+			}
+			args = append(args, value)
+			rowConditions = append(rowConditions, fmt.Sprintf("%s = %s", field.Name, placeholder(dbType, len(args))))
+		}
+		conditions = append(conditions, "("+strings.Join(rowConditions, " AND ")+")")
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s", tableName, strings.Join(conditions, " OR "))
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	return query, args, nil
+}
+
+// placeholder 获取数据库类型对应的参数占位符
+func placeholder(dbType database.DatabaseType, index int) string {
+	if dbType == database.PostgreSQL {
+		return "$" + strconv.Itoa(index)
+	}
+	return "?"
+}
+
+// captureImage 执行查询并生成表镜像
+func captureImage(ctx context.Context, tx *sql.Tx, tableName, query string, args []interface{}) (*TableImage, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	image := &TableImage{TableName: tableName}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := Row{Fields: make([]Field, len(columns))}
+		for i, column := range columns {
+			row.Fields[i] = NewField(column, values[i])
+		}
+		image.Rows = append(image.Rows, row)
+	}
+
+	return image, rows.Err()
+}
+
+// NewField 根据驱动返回的值创建字段
+func NewField(name string, value interface{}) Field {
+	switch v := value.(type) {
+	case nil:
+		return Field{Name: name, Type: FieldTypeNull}
+	case int64:
+		return Field{Name: name, Type: FieldTypeInt, Value: strconv.FormatInt(v, 10)}
+	case int:
+		return Field{Name: name, Type: FieldTypeInt, Value: strconv.Itoa(v)}
+	case int32:
+		return Field{Name: name, Type: FieldTypeInt, Value: strconv.FormatInt(int64(v), 10)}
+	case uint64:
+		return Field{Name: name, Type: FieldTypeUint, Value: strconv.FormatUint(v, 10)}
+	case float64:
+		return Field{Name: name, Type: FieldTypeFloat, Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case float32:
+		return Field{Name: name, Type: FieldTypeFloat, Value: strconv.FormatFloat(float64(v), 'g', -1, 32)}
+	case bool:
+		return Field{Name: name, Type: FieldTypeBool, Value: strconv.FormatBool(v)}
+	case string:
+		return Field{Name: name, Type: FieldTypeString, Value: v}
+	case []byte:
+		return Field{Name: name, Type: FieldTypeBytes, Value: base64.StdEncoding.EncodeToString(v)}
+	case time.Time:
+		return Field{Name: name, Type: FieldTypeTime, Value: v.Format(time.RFC3339Nano)}
+	default:
+		return Field{Name: name, Type: FieldTypeString, Value: fmt.Sprintf("%v", v)}
+	}
+}
+
+// SQLValue 还原字段值，用于绑定补偿语句参数
+func (f Field) SQLValue() (interface{}, error) {
+	switch f.Type {
+	case FieldTypeNull:
+		return nil, nil
+	case FieldTypeInt:
+		return strconv.ParseInt(f.Value, 10, 64)
+	case FieldTypeUint:
+		return strconv.ParseUint(f.Value, 10, 64)
+	case FieldTypeFloat:
+		return strconv.ParseFloat(f.Value, 64)
+	case FieldTypeBool:
+		return strconv.ParseBool(f.Value)
+	case FieldTypeString:
+		return f.Value, nil
+	case FieldTypeBytes:
+		return base64.StdEncoding.DecodeString(f.Value)
+	case FieldTypeTime:
+		return time.Parse(time.RFC3339Nano, f.Value)
+	default:
+		return nil, fmt.Errorf("unsupported field type %s for column %s", f.Type, f.Name)
+	}
+}
+
+// field 按列名查找字段
+func (r Row) field(name string) (Field, bool) {
+	for _, f := range r.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// imagesEqual 比较两个镜像的数据是否一致（忽略行顺序）
+func imagesEqual(a, b *TableImage) bool {
+	aRows, bRows := imageRowKeys(a), imageRowKeys(b)
+	if len(aRows) != len(bRows) {
+		return false
+	}
+	for key, count := range aRows {
+		if bRows[key] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// imageRowKeys 将镜像中的每一行编码为可比较的键
+func imageRowKeys(image *TableImage) map[string]int {
+	keys := make(map[string]int)
+	if image == nil {
+		return keys
+	}
+	for _, row := range image.Rows {
+		var parts []string
+		for _, field := range row.Fields {
+			parts = append(parts, strings.ToLower(field.Name)+"="+normalizeFieldValue(field))
+		}
+		keys[strings.Join(parts, "\x00")]++
+	}
+	return keys
+}
+
+// normalizeFieldValue 规范化字段值，消除驱动返回 []byte 与 string 的差异
+func normalizeFieldValue(field Field) string {
+	if field.Type == FieldTypeBytes {
+		if decoded, err := base64.StdEncoding.DecodeString(field.Value); err == nil {
+			return string(decoded)
+		}
+	}
+	if field.Type == FieldTypeNull {
+		return "\x01NULL"
+	}
+	return field.Value
+}
+
+// containsIgnoreCase 检查字符串切片是否包含指定元素（忽略大小写）
+func containsIgnoreCase(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}